@@ -0,0 +1,18 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package audit
+
+import (
+	"log"
+)
+
+// LogAuditManager is an AuditManager that writes audit messages to the standard logger.
+type LogAuditManager struct{}
+
+func NewLogAuditManager() *LogAuditManager {
+	return &LogAuditManager{}
+}
+
+func (auditManager *LogAuditManager) Log(msg string) {
+	log.Printf("audit: %v\n", msg)
+}
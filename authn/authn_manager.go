@@ -5,6 +5,7 @@ package authn
 import (
 	gocontext "context"
 	"net/http"
+	"strings"
 
 	"github.com/vmware/virtual-security-module/config"
 	"github.com/vmware/virtual-security-module/context"
@@ -18,6 +19,12 @@ const (
 	HeaderNameAuth = "Authorization"
 	LoginPath      = "/login"
 	UsersPath      = "/users"
+	// The CRL and OCSP responder are accessed by relying parties, which aren't VSM users
+	CRLPath  = "/pki/crl"
+	OCSPPath = "/pki/ocsp"
+	// Wrapped values are unwrapped using their single-use token, by recipients which
+	// needn't be VSM users
+	UnwrapPath = "/sys/wrapping/unwrap"
 )
 
 type AuthnProvider interface {
@@ -33,7 +40,9 @@ type AuthnProvider interface {
 var authnProviderRegistry map[string]AuthnProvider = make(map[string]AuthnProvider)
 
 type AuthnManager struct {
-	whitelist     map[string]bool
+	whitelist map[string]bool
+	// paths under which all paths are whitelisted
+	whitelistDirs []string
 	authnProvider AuthnProvider
 	authzManager  context.AuthorizationManager
 }
@@ -52,7 +61,8 @@ func (authnManager *AuthnManager) Init(moduleInitContext *context.ModuleInitCont
 		return err
 	}
 
-	authnManager.whitelist = map[string]bool{LoginPath: true}
+	authnManager.whitelist = map[string]bool{LoginPath: true, CRLPath: true, OCSPPath: true, UnwrapPath: true}
+	authnManager.whitelistDirs = []string{CRLPath, OCSPPath}
 	authnManager.authnProvider = authnProvider
 	authnManager.authzManager = moduleInitContext.AuthzManager
 
@@ -70,6 +80,12 @@ func (authNManager *AuthnManager) HandlePre(w http.ResponseWriter, r *http.Reque
 		return r
 	}
 
+	for _, dir := range authNManager.whitelistDirs {
+		if strings.HasPrefix(path, dir+"/") {
+			return r
+		}
+	}
+
 	username, err := authNManager.authnProvider.Authenticated(r)
 	if err != nil {
 		util.WriteErrorStatus(w, util.ErrUnauthorized)
@@ -60,6 +60,15 @@ func TestWhitelist(t *testing.T) {
 		t.Fatalf("not admitted to /login, which is in whitelist")
 	}
 
+	for _, path := range []string{"/pki/crl", "/pki/crl/web/ca", "/pki/ocsp", "/pki/ocsp/MEMwQTA%2FMD0wOzAJBgUrDgMCGgUA", "/sys/wrapping/unwrap"} {
+		r = httptest.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		admitted = am.HandlePre(w, r) != nil
+		if !admitted {
+			t.Fatalf("not admitted to %v, which is in whitelist", path)
+		}
+	}
+
 	r = httptest.NewRequest("POST", "/users", nil)
 	w = httptest.NewRecorder()
 	admitted = am.HandlePre(w, r) != nil
@@ -67,6 +76,20 @@ func TestWhitelist(t *testing.T) {
 		t.Fatalf("admitted to /users without a token")
 	}
 
+	r = httptest.NewRequest("POST", "/pki/revocations", nil)
+	w = httptest.NewRecorder()
+	admitted = am.HandlePre(w, r) != nil
+	if admitted {
+		t.Fatalf("admitted to /pki/revocations without a token")
+	}
+
+	r = httptest.NewRequest("POST", "/sys/wrapping/wrap", nil)
+	w = httptest.NewRecorder()
+	admitted = am.HandlePre(w, r) != nil
+	if admitted {
+		t.Fatalf("admitted to /sys/wrapping/wrap without a token")
+	}
+
 	username := "testuser-0"
 	_, privateKey, err := amCreateUser(username)
 	if err != nil {
@@ -0,0 +1,430 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+const (
+	cryptoCmdUsage        = "crypto [sub-command]"
+	cryptoSignCmdUsage    = "sign key-id input-file"
+	cryptoVerifyCmdUsage  = "verify key-id input-file signature-file"
+	cryptoEncryptCmdUsage = "encrypt key-id input-file"
+	cryptoDecryptCmdUsage = "decrypt key-id ciphertext"
+	cryptoRewrapCmdUsage  = "rewrap key-id ciphertext"
+	cryptoHMACCmdUsage    = "hmac key-id input-file"
+	cryptoDeriveCmdUsage  = "derive key-id info"
+)
+
+var signHashAlgorithm string
+var signSignatureAlgorithm string
+var signRaw bool
+var signatureFile string
+var verifyKeyVersion int
+var encryptionContext string
+var plaintextFile string
+var macKeyVersion int
+var deriveSalt string
+var deriveLength int
+var derivedKeyFile string
+
+func init() {
+	for _, cmd := range []*cobra.Command{cryptoSignCmd, cryptoVerifyCmd} {
+		cmd.Flags().StringVar(&signHashAlgorithm, "hash", model.HashAlgorithmSHA256, "hash algorithm of the input's digest: sha256, sha384 or sha512")
+		cmd.Flags().StringVar(&signSignatureAlgorithm, "signature-algorithm", "", "signature algorithm of RSA keys: pkcs1v15 or pss (default: pkcs1v15)")
+		cmd.Flags().BoolVar(&signRaw, "raw", false, "send the input as-is rather than its digest (required for ed25519 keys)")
+	}
+	cryptoSignCmd.Flags().StringVarP(&signatureFile, "output", "o", "", "file to write the signature to (default: base64-encoded to standard output)")
+	cryptoVerifyCmd.Flags().IntVarP(&verifyKeyVersion, "version", "v", 0, "version of the key which produced the signature (default: current version)")
+
+	for _, cmd := range []*cobra.Command{cryptoEncryptCmd, cryptoDecryptCmd, cryptoRewrapCmd} {
+		cmd.Flags().StringVar(&encryptionContext, "context", "", "context the ciphertext is bound to, which must be provided again in order to decrypt it")
+	}
+	cryptoDecryptCmd.Flags().StringVarP(&plaintextFile, "output", "o", "", "file to write the plaintext to (default: standard output)")
+
+	for _, cmd := range []*cobra.Command{cryptoHMACCmd, cryptoDeriveCmd} {
+		cmd.Flags().IntVarP(&macKeyVersion, "version", "v", 0, "version of the key to use (default: current version)")
+	}
+	cryptoDeriveCmd.Flags().StringVar(&deriveSalt, "salt", "", "salt to derive the key with")
+	cryptoDeriveCmd.Flags().IntVarP(&deriveLength, "length", "l", 0, "length of the derived key in bytes (default: 32)")
+	cryptoDeriveCmd.Flags().StringVarP(&derivedKeyFile, "output", "o", "", "file to write the derived key to (default: base64-encoded to standard output)")
+
+	cryptoCmd.AddCommand(cryptoSignCmd)
+	cryptoCmd.AddCommand(cryptoVerifyCmd)
+	cryptoCmd.AddCommand(cryptoEncryptCmd)
+	cryptoCmd.AddCommand(cryptoDecryptCmd)
+	cryptoCmd.AddCommand(cryptoRewrapCmd)
+	cryptoCmd.AddCommand(cryptoHMACCmd)
+	cryptoCmd.AddCommand(cryptoDeriveCmd)
+
+	RootCmd.AddCommand(cryptoCmd)
+}
+
+var cryptoCmd = &cobra.Command{
+	Use:   cryptoCmdUsage,
+	Short: "Cryptographic operations",
+	Long:  "Use keys stored in VSM without retrieving them",
+}
+
+var cryptoSignCmd = &cobra.Command{
+	Use:   cryptoSignCmdUsage,
+	Short: "Sign a file",
+	Long:  "Sign the digest of a file (or, using --raw, the file itself) using a private key secret",
+	Run:   cryptoSign,
+}
+
+var cryptoVerifyCmd = &cobra.Command{
+	Use:   cryptoVerifyCmdUsage,
+	Short: "Verify a signature",
+	Long:  "Verify the signature of a file using a private key secret; the signature file may be raw or base64-encoded",
+	Run:   cryptoVerify,
+}
+
+var cryptoEncryptCmd = &cobra.Command{
+	Use:   cryptoEncryptCmdUsage,
+	Short: "Encrypt a file",
+	Long:  "Encrypt a file using a symmetric key secret, printing the ciphertext",
+	Run:   cryptoEncrypt,
+}
+
+var cryptoDecryptCmd = &cobra.Command{
+	Use:   cryptoDecryptCmdUsage,
+	Short: "Decrypt a ciphertext",
+	Long:  "Decrypt a ciphertext using the symmetric key secret version which produced it",
+	Run:   cryptoDecrypt,
+}
+
+var cryptoRewrapCmd = &cobra.Command{
+	Use:   cryptoRewrapCmdUsage,
+	Short: "Rewrap a ciphertext",
+	Long:  "Re-encrypt a ciphertext using the current version of its symmetric key secret, printing the new ciphertext",
+	Run:   cryptoRewrap,
+}
+
+var cryptoHMACCmd = &cobra.Command{
+	Use:   cryptoHMACCmdUsage,
+	Short: "Compute the HMAC of a file",
+	Long:  "Compute the HMAC-SHA256 of a file using an HMAC key secret, printing it base64-encoded",
+	Run:   cryptoHMAC,
+}
+
+var cryptoDeriveCmd = &cobra.Command{
+	Use:   cryptoDeriveCmdUsage,
+	Short: "Derive a key",
+	Long:  "Derive a subkey from an HMAC key secret using HKDF-SHA256 and the given info (e.g. a tenant name)",
+	Run:   cryptoDerive,
+}
+
+func cryptoSign(cmd *cobra.Command, args []string) {
+	signRequest, err := cryptoSignCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	var signResponse model.SignResponse
+	if err := apiCrypto("sign", signRequest, &signResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if signatureFile == "" {
+		fmt.Println(base64.StdEncoding.EncodeToString(signResponse.Signature))
+		return
+	}
+
+	if err := ioutil.WriteFile(signatureFile, signResponse.Signature, 0644); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Signed successfully")
+	fmt.Printf("Key version: %v\n", signResponse.KeyVersion)
+}
+
+func cryptoSignCheckUsage(args []string) (*model.SignRequest, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("Usage: %v", cryptoSignCmdUsage)
+	}
+
+	input, hashAlgorithm, err := readSignInput(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SignRequest{
+		KeyId:              args[0],
+		Input:              input,
+		HashAlgorithm:      hashAlgorithm,
+		SignatureAlgorithm: signSignatureAlgorithm,
+	}, nil
+}
+
+func cryptoVerify(cmd *cobra.Command, args []string) {
+	verifyRequest, err := cryptoVerifyCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	var verifyResponse model.VerifyResponse
+	if err := apiCrypto("verify", verifyRequest, &verifyResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if !verifyResponse.Valid {
+		fmt.Println("Signature is invalid")
+		return
+	}
+
+	fmt.Println("Signature is valid")
+}
+
+func cryptoVerifyCheckUsage(args []string) (*model.VerifyRequest, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("Usage: %v", cryptoVerifyCmdUsage)
+	}
+
+	input, hashAlgorithm, err := readSignInput(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ioutil.ReadFile(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// signatures written to the standard output by sign are base64-encoded
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+
+	return &model.VerifyRequest{
+		KeyId:              args[0],
+		KeyVersion:         verifyKeyVersion,
+		Input:              input,
+		HashAlgorithm:      hashAlgorithm,
+		SignatureAlgorithm: signSignatureAlgorithm,
+		Signature:          signature,
+	}, nil
+}
+
+func cryptoEncrypt(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %v\n", cryptoEncryptCmdUsage)
+		return
+	}
+
+	plaintext, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	encryptRequest := &model.EncryptRequest{
+		KeyId:     args[0],
+		Plaintext: plaintext,
+		Context:   []byte(encryptionContext),
+	}
+
+	var encryptResponse model.EncryptResponse
+	if err := apiCrypto("encrypt", encryptRequest, &encryptResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println(encryptResponse.Ciphertext)
+}
+
+func cryptoDecrypt(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %v\n", cryptoDecryptCmdUsage)
+		return
+	}
+
+	decryptRequest := &model.DecryptRequest{
+		KeyId:      args[0],
+		Ciphertext: args[1],
+		Context:    []byte(encryptionContext),
+	}
+
+	var decryptResponse model.DecryptResponse
+	if err := apiCrypto("decrypt", decryptRequest, &decryptResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if plaintextFile == "" {
+		fmt.Print(string(decryptResponse.Plaintext))
+		return
+	}
+
+	if err := ioutil.WriteFile(plaintextFile, decryptResponse.Plaintext, 0600); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Decrypted successfully")
+}
+
+func cryptoRewrap(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %v\n", cryptoRewrapCmdUsage)
+		return
+	}
+
+	rewrapRequest := &model.RewrapRequest{
+		KeyId:      args[0],
+		Ciphertext: args[1],
+		Context:    []byte(encryptionContext),
+	}
+
+	var rewrapResponse model.EncryptResponse
+	if err := apiCrypto("rewrap", rewrapRequest, &rewrapResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println(rewrapResponse.Ciphertext)
+}
+
+func cryptoHMAC(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %v\n", cryptoHMACCmdUsage)
+		return
+	}
+
+	input, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	hmacRequest := &model.HMACRequest{
+		KeyId:      args[0],
+		KeyVersion: macKeyVersion,
+		Input:      input,
+	}
+
+	var hmacResponse model.HMACResponse
+	if err := apiCrypto("hmac", hmacRequest, &hmacResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(hmacResponse.HMAC))
+}
+
+func cryptoDerive(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %v\n", cryptoDeriveCmdUsage)
+		return
+	}
+
+	deriveKeyRequest := &model.DeriveKeyRequest{
+		KeyId:      args[0],
+		KeyVersion: macKeyVersion,
+		Salt:       []byte(deriveSalt),
+		Info:       []byte(args[1]),
+		Length:     deriveLength,
+	}
+
+	var deriveKeyResponse model.DeriveKeyResponse
+	if err := apiCrypto("derive", deriveKeyRequest, &deriveKeyResponse); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if derivedKeyFile == "" {
+		fmt.Println(base64.StdEncoding.EncodeToString(deriveKeyResponse.DerivedKey))
+		return
+	}
+
+	if err := ioutil.WriteFile(derivedKeyFile, deriveKeyResponse.DerivedKey, 0600); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Key derived successfully")
+	fmt.Printf("Key version: %v\n", deriveKeyResponse.KeyVersion)
+}
+
+// readSignInput returns the input to sign or verify: the digest of the given file using
+// the hash algorithm, or, if raw input was requested, the file itself (with no hash
+// algorithm).
+func readSignInput(inputFile string) ([]byte, string, error) {
+	input, err := ioutil.ReadFile(inputFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if signRaw {
+		return input, "", nil
+	}
+
+	switch signHashAlgorithm {
+	case model.HashAlgorithmSHA256:
+		digest := sha256.Sum256(input)
+		return digest[:], signHashAlgorithm, nil
+	case model.HashAlgorithmSHA384:
+		digest := sha512.Sum384(input)
+		return digest[:], signHashAlgorithm, nil
+	case model.HashAlgorithmSHA512:
+		digest := sha512.Sum512(input)
+		return digest[:], signHashAlgorithm, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported hash algorithm: %v", signHashAlgorithm)
+	}
+}
+
+// apiCrypto posts a request to the given /crypto endpoint, and decodes its response.
+func apiCrypto(operation string, request interface{}, response interface{}) error {
+	if Token == "" {
+		return fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(request)
+	if err != nil {
+		return err
+	}
+
+	cryptoUrl := fmt.Sprintf("%v/crypto/%v", Url, operation)
+	req, err := http.NewRequest("POST", cryptoUrl, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -0,0 +1,249 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+const execCmdUsage = "exec --env NAME=secret-id [--env ...] -- command [args...]"
+
+// Period after which the auth token is renewed, before it expires, by logging in again.
+const execTokenRefreshPeriod = 30 * time.Minute
+
+// Period given to the child process to exit when it's restarted, before it's killed.
+const execStopTimeout = 10 * time.Second
+
+var execEnv []string
+var execUsername string
+var execPrivateKeyFile string
+var execRestart bool
+var execInterval string
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func init() {
+	execCmd.Flags().StringArrayVarP(&execEnv, "env", "e", nil, "environment variable set to the data of a secret, as NAME=secret-id; may be repeated")
+	execCmd.Flags().StringVar(&execUsername, "username", "", "user to login as, instead of using --token")
+	execCmd.Flags().StringVar(&execPrivateKeyFile, "private-key", "", "private key file of the user to login as")
+	execCmd.Flags().BoolVarP(&execRestart, "restart", "r", false, "restart the command whenever one of the secrets has a new version, e.g. when it's rotated")
+	execCmd.Flags().StringVar(&execInterval, "interval", "1m", "period at which the secrets are checked for new versions, with --restart")
+
+	RootCmd.AddCommand(execCmd)
+}
+
+var execCmd = &cobra.Command{
+	Use:   execCmdUsage,
+	Short: "Run a command with secrets in its environment",
+	Long: `Run a command with environment variables set to the data of secrets, which are never
+written to disk. Signals are forwarded to the command, and its exit status is returned.
+With --username and --private-key, the command logs in by itself, and keeps its token
+fresh for as long as the command runs.`,
+	Run: execWithSecrets,
+}
+
+// secretEnv holds the data of the secrets passed to a child process as environment
+// variables, and the versions of the secrets.
+type secretEnv struct {
+	vars     []string
+	versions map[string]int
+}
+
+func execWithSecrets(cmd *cobra.Command, args []string) {
+	envSecrets, command, interval, err := execCheckUsage(cmd, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	loginTime, err := execLogin()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	env, err := getSecretEnv(envSecrets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	// signals are forwarded to the child rather than terminating this process
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+	child, exited, err := startChild(command, env)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	var ticks <-chan time.Time
+	if execRestart {
+		ticks = time.Tick(interval)
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			child.Process.Signal(sig)
+
+		case err := <-exited:
+			os.Exit(childExitCode(err))
+
+		case <-ticks:
+			if execUsername != "" && time.Since(loginTime) > execTokenRefreshPeriod {
+				if t, err := execLogin(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to renew auth token: %v\n", err)
+				} else {
+					loginTime = t
+				}
+			}
+
+			changed, err := secretVersionsChanged(env.versions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to check secrets for new versions: %v\n", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			newEnv, err := getSecretEnv(envSecrets)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "secrets changed, restarting %v\n", command[0])
+			stopChild(child, exited)
+
+			env = newEnv
+			if child, exited, err = startChild(command, env); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// execCheckUsage returns the secret ids by environment variable name, and the command
+// to run, given after --.
+func execCheckUsage(cmd *cobra.Command, args []string) (map[string]string, []string, time.Duration, error) {
+	if cmd.ArgsLenAtDash() != 0 || len(args) == 0 || len(execEnv) == 0 {
+		return nil, nil, 0, fmt.Errorf("Usage: %v", execCmdUsage)
+	}
+
+	envSecrets := make(map[string]string, len(execEnv))
+	for _, envArg := range execEnv {
+		parts := strings.SplitN(envArg, "=", 2)
+		if len(parts) != 2 || !envNameRegexp.MatchString(parts[0]) || parts[1] == "" {
+			return nil, nil, 0, fmt.Errorf("environment variable %v is not of the form NAME=secret-id", envArg)
+		}
+		envSecrets[parts[0]] = parts[1]
+	}
+
+	if (execUsername == "") != (execPrivateKeyFile == "") {
+		return nil, nil, 0, fmt.Errorf("both --username and --private-key are needed in order to login")
+	}
+
+	interval, err := time.ParseDuration(execInterval)
+	if err != nil || interval <= 0 {
+		return nil, nil, 0, fmt.Errorf("failed to parse %s as a duration", execInterval)
+	}
+
+	return envSecrets, args, interval, nil
+}
+
+// execLogin logs in as the given user, if any, setting the auth token. It returns the
+// login time.
+func execLogin() (time.Time, error) {
+	if execUsername == "" {
+		return time.Now(), nil
+	}
+
+	privateKey, err := util.ReadRSAPrivateKey(execPrivateKeyFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read private key: %v", err)
+	}
+
+	loginTime := time.Now()
+	token, err := apiLogin(execUsername, privateKey)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to login: %v", err)
+	}
+	Token = token
+
+	return loginTime, nil
+}
+
+func getSecretEnv(envSecrets map[string]string) (*secretEnv, error) {
+	env := &secretEnv{versions: make(map[string]int, len(envSecrets))}
+
+	for name, secretId := range envSecrets {
+		se, err := apiGetSecret(secretId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret %v: %v", secretId, err)
+		}
+
+		env.vars = append(env.vars, fmt.Sprintf("%v=%s", name, se.SecretData))
+		env.versions[secretId] = se.Version
+	}
+
+	return env, nil
+}
+
+// startChild starts the command with the secrets added to this process' environment.
+// The returned channel receives the command's exit error once it exits.
+func startChild(command []string, env *secretEnv) (*exec.Cmd, <-chan error, error) {
+	child := exec.Command(command[0], command[1:]...)
+	child.Env = append(os.Environ(), env.vars...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	if err := child.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to run %v: %v", command[0], err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- child.Wait()
+	}()
+
+	return child, exited, nil
+}
+
+// stopChild terminates the command, killing it if it doesn't exit in time.
+func stopChild(child *exec.Cmd, exited <-chan error) {
+	child.Process.Signal(syscall.SIGTERM)
+
+	select {
+	case <-exited:
+	case <-time.After(execStopTimeout):
+		child.Process.Kill()
+		<-exited
+	}
+}
+
+func childExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+
+	return 1
+}
@@ -0,0 +1,455 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/secret"
+	"gopkg.in/yaml.v2"
+)
+
+const importSecretsCmdUsage = "import file namespace"
+
+// Formats of files from which secrets are imported.
+const (
+	importFormatDotenv = "dotenv"
+	importFormatJSON   = "json"
+	importFormatYAML   = "yaml"
+	importFormatK8s    = "k8s"
+)
+
+var importFormat string
+var importConflictPolicy string
+var importDryRun bool
+var importSeparator string
+var importTags []string
+
+var dotenvKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func init() {
+	importSecretsCmd.Flags().StringVarP(&importFormat, "format", "f", "", "file format: dotenv, json, yaml or k8s (default: by the file's name and content)")
+	importSecretsCmd.Flags().StringVar(&importConflictPolicy, "conflict", model.ConflictPolicyFail, "what to do with secrets which already exist: skip, overwrite or fail (the whole import)")
+	importSecretsCmd.Flags().BoolVarP(&importDryRun, "dry-run", "n", false, "report what would be imported, without importing anything")
+	importSecretsCmd.Flags().StringVarP(&importSeparator, "separator", "s", ".", "separator of the keys of nested objects in secret ids, e.g. / to map them to sub-namespaces")
+	importSecretsCmd.Flags().StringArrayVar(&importTags, "tag", nil, "tag of the imported secrets, as key=value; may be repeated")
+
+	secretsCmd.AddCommand(importSecretsCmd)
+}
+
+var importSecretsCmd = &cobra.Command{
+	Use:   importSecretsCmdUsage,
+	Short: "Import secrets from a file",
+	Long: `Create data secrets in a namespace from the entries of a .env file, a flat or nested JSON or YAML object,
+or Kubernetes Secret manifests. Nested keys, and the keys of a manifest, are joined to the object's key
+(or the manifest's name) by the separator.`,
+	Run: importSecrets,
+}
+
+func importSecrets(cmd *cobra.Command, args []string) {
+	fileName, namespace, err := importSecretsCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	entries, err := parseImportFile(fileName, content)
+	if err != nil {
+		fmt.Printf("failed to parse %v: %v\n", fileName, err)
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Printf("no secrets found in %v\n", fileName)
+		return
+	}
+	if len(entries) > model.MaxSecretBatchSize {
+		fmt.Printf("%v holds %v secrets, more than the %v which can be imported at once\n", fileName, len(entries), model.MaxSecretBatchSize)
+		return
+	}
+
+	tags, err := parseTags(importTags)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	batchRequest := &model.SecretBatchRequest{
+		ConflictPolicy: importConflictPolicy,
+		DryRun:         importDryRun,
+	}
+	for _, key := range sortedKeys(entries) {
+		batchRequest.Secrets = append(batchRequest.Secrets, model.SecretEntry{
+			Id:         namespace + "/" + key,
+			Type:       secret.DataSecretTypeName,
+			MetaData:   "{}",
+			SecretData: []byte(entries[key]),
+			Tags:       tags,
+		})
+	}
+
+	batchResponse, err := apiCreateSecrets(batchRequest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	printImportSummary(batchResponse)
+}
+
+func printImportSummary(batchResponse *model.SecretBatchResponse) {
+	if batchResponse.DryRun {
+		fmt.Println("Dry run: no secrets were created or updated")
+	}
+
+	for _, result := range batchResponse.Results {
+		if result.Error != "" {
+			fmt.Printf("%-7v %v: %v\n", result.Action, result.Id, result.Error)
+		} else {
+			fmt.Printf("%-7v %v\n", result.Action, result.Id)
+		}
+	}
+
+	fmt.Printf("Created: %v, Updated: %v, Skipped: %v, Failed: %v\n",
+		batchResponse.Created, batchResponse.Updated, batchResponse.Skipped, batchResponse.Failed)
+}
+
+func importSecretsCheckUsage(args []string) (string, string, error) {
+	if len(args) != 2 {
+		return "", "", fmt.Errorf("Usage: %v", importSecretsCmdUsage)
+	}
+
+	namespace := strings.Trim(args[1], "/")
+	if namespace == "" {
+		return "", "", fmt.Errorf("Usage: %v", importSecretsCmdUsage)
+	}
+
+	if !model.IsValidConflictPolicy(importConflictPolicy) {
+		return "", "", fmt.Errorf("unknown conflict policy %v; expected skip, overwrite or fail", importConflictPolicy)
+	}
+
+	if importSeparator == "" {
+		return "", "", fmt.Errorf("separator is empty")
+	}
+
+	return args[0], namespace, nil
+}
+
+// parseImportFile returns the secrets held by a file to import, by their ids relative
+// to the namespace they're imported to.
+func parseImportFile(fileName string, content []byte) (map[string]string, error) {
+	format := importFormat
+	if format == "" {
+		format = detectImportFormat(fileName, content)
+	}
+
+	switch format {
+	case importFormatDotenv:
+		return parseDotenv(content)
+	case importFormatJSON:
+		return parseJSONImport(content)
+	case importFormatYAML:
+		return parseYAMLImport(content)
+	case importFormatK8s:
+		return parseK8sSecrets(content)
+	case "":
+		return nil, fmt.Errorf("unknown file format; use --format")
+	default:
+		return nil, fmt.Errorf("unknown file format %v", format)
+	}
+}
+
+func detectImportFormat(fileName string, content []byte) string {
+	baseName := filepath.Base(fileName)
+	switch {
+	case baseName == ".env" || strings.HasPrefix(baseName, ".env.") || filepath.Ext(baseName) == ".env":
+		return importFormatDotenv
+	case filepath.Ext(baseName) == ".json":
+		return importFormatJSON
+	case filepath.Ext(baseName) == ".yaml" || filepath.Ext(baseName) == ".yml":
+		var manifest struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := yaml.NewDecoder(bytes.NewReader(content)).Decode(&manifest); err == nil && manifest.Kind == "Secret" {
+			return importFormatK8s
+		}
+		return importFormatYAML
+	}
+
+	return ""
+}
+
+// parseDotenv parses KEY=value lines, optionally prefixed by export. Values may be
+// single-quoted (taken literally) or double-quoted (with \n, \t, \" and \\ escapes);
+// unquoted values end at a # preceded by a space.
+func parseDotenv(content []byte) (map[string]string, error) {
+	entries := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !dotenvKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("line %v is not of the form KEY=value", lineNumber)
+		}
+
+		value, err := parseDotenvValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", lineNumber, err)
+		}
+		entries[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseDotenvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	switch s[0] {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return s[1 : end+1], nil
+	case '"':
+		var value strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; c {
+			case '"':
+				return value.String(), nil
+			case '\\':
+				if i+1 == len(s) {
+					return "", fmt.Errorf("unterminated quoted value")
+				}
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				case 'r':
+					value.WriteByte('\r')
+				default:
+					value.WriteByte(s[i])
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s), nil
+}
+
+func parseJSONImport(content []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]string)
+	if err := flattenImportObject(entries, "", object); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseYAMLImport(content []byte) (map[string]string, error) {
+	var object map[string]interface{}
+	if err := yaml.Unmarshal(content, &object); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]string)
+	if err := flattenImportObject(entries, "", object); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// flattenImportObject adds the values of an object to entries, joining the keys of
+// nested objects by the separator.
+func flattenImportObject(entries map[string]string, prefix string, object map[string]interface{}) error {
+	for key, value := range object {
+		if key == "" {
+			return fmt.Errorf("empty key under %q", prefix)
+		}
+		if prefix != "" {
+			key = prefix + importSeparator + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flattenImportObject(entries, key, v); err != nil {
+				return err
+			}
+		case map[interface{}]interface{}:
+			nested := make(map[string]interface{}, len(v))
+			for k, nestedValue := range v {
+				nested[fmt.Sprint(k)] = nestedValue
+			}
+			if err := flattenImportObject(entries, key, nested); err != nil {
+				return err
+			}
+		case string, bool, int, int64, uint64, float64, json.Number:
+			if _, ok := entries[key]; ok {
+				return fmt.Errorf("duplicate key %v", key)
+			}
+			entries[key] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("value of %v is neither a scalar nor an object", key)
+		}
+	}
+
+	return nil
+}
+
+// k8sSecret holds the fields of a Kubernetes Secret manifest which are imported.
+type k8sSecret struct {
+	Kind     string `yaml:"kind"`
+	MetaData struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// parseK8sSecrets parses one or more Secret manifests, separated by ---. The keys of a
+// manifest are joined to its name by the separator; stringData takes precedence over
+// data, as it does in Kubernetes.
+func parseK8sSecrets(content []byte) (map[string]string, error) {
+	entries := make(map[string]string)
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var manifest k8sSecret
+		err := decoder.Decode(&manifest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if manifest.Kind == "" && manifest.MetaData.Name == "" {
+			// an empty document
+			continue
+		}
+		if manifest.Kind != "Secret" || manifest.MetaData.Name == "" {
+			return nil, fmt.Errorf("manifest %q is not a named Secret", manifest.MetaData.Name)
+		}
+
+		values := make(map[string]string, len(manifest.Data)+len(manifest.StringData))
+		for key, encoded := range manifest.Data {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %v of %v: %v", key, manifest.MetaData.Name, err)
+			}
+			values[key] = string(decoded)
+		}
+		for key, value := range manifest.StringData {
+			values[key] = value
+		}
+
+		for key, value := range values {
+			id := manifest.MetaData.Name + importSeparator + key
+			if _, ok := entries[id]; ok {
+				return nil, fmt.Errorf("duplicate key %v", id)
+			}
+			entries[id] = value
+		}
+	}
+
+	return entries, nil
+}
+
+func sortedKeys(entries map[string]string) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func apiCreateSecrets(batchRequest *model.SecretBatchRequest) (*model.SecretBatchResponse, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(batchRequest); err != nil {
+		return nil, err
+	}
+
+	batchesUrl := fmt.Sprintf("%v/secret-batches", Url)
+	req, err := http.NewRequest("POST", batchesUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var batchResponse model.SecretBatchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&batchResponse); err != nil {
+		return nil, err
+	}
+
+	return &batchResponse, nil
+}
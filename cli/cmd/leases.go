@@ -0,0 +1,232 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+const (
+	leasesCmdUsage      = "leases [sub-command]"
+	listLeasesCmdUsage  = "list"
+	renewLeaseCmdUsage  = "renew lease-id"
+	revokeLeaseCmdUsage = "revoke [lease-id]"
+)
+
+var leasePrefix string
+var leaseOwner string
+var leaseIncrement string
+
+func init() {
+	for _, cmd := range []*cobra.Command{listLeasesCmd, revokeLeaseCmd} {
+		cmd.Flags().StringVarP(&leasePrefix, "prefix", "p", "", "secret id or namespace under which leases were issued, e.g. team-a")
+		cmd.Flags().StringVar(&leaseOwner, "owner", "", "user holding the leases")
+	}
+	renewLeaseCmd.Flags().StringVarP(&leaseIncrement, "increment", "i", "", "period from now by which to extend the lease (default: the secret's lease period)")
+
+	leasesCmd.AddCommand(listLeasesCmd)
+	leasesCmd.AddCommand(renewLeaseCmd)
+	leasesCmd.AddCommand(revokeLeaseCmd)
+
+	RootCmd.AddCommand(leasesCmd)
+}
+
+var leasesCmd = &cobra.Command{
+	Use:   leasesCmdUsage,
+	Short: "Lease management",
+	Long:  "List, renew or revoke the leases of secrets read, e.g. dynamic credentials",
+}
+
+var listLeasesCmd = &cobra.Command{
+	Use:   listLeasesCmdUsage,
+	Short: "List leases",
+	Long:  "List the leases held by you or issued by secrets you can read, optionally by prefix and owner",
+	Run:   listLeases,
+}
+
+var renewLeaseCmd = &cobra.Command{
+	Use:   renewLeaseCmdUsage,
+	Short: "Renew a lease",
+	Long:  "Extend a lease, within the maximal lease period of the secret which issued it",
+	Run:   renewLease,
+}
+
+var revokeLeaseCmd = &cobra.Command{
+	Use:   revokeLeaseCmdUsage,
+	Short: "Revoke leases",
+	Long:  "Revoke a lease, or all the leases under a prefix (--prefix) and/or held by a user (--owner)",
+	Run:   revokeLease,
+}
+
+func listLeases(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		fmt.Printf("Usage: %v\n", listLeasesCmdUsage)
+		return
+	}
+
+	leaseEntries, err := apiListLeases(leasePrefix, leaseOwner)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	s, err := util.JSONPrettyPrint(leaseEntries)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println(s)
+}
+
+func renewLease(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Usage: %v\n", renewLeaseCmdUsage)
+		return
+	}
+
+	renewLeaseResponse, err := apiRenewLease(&model.RenewLeaseRequest{LeaseId: args[0], Increment: leaseIncrement})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Lease renewed successfully")
+	fmt.Printf("Expiration time: %v\n", renewLeaseResponse.ExpirationTime)
+}
+
+func revokeLease(cmd *cobra.Command, args []string) {
+	revokeLeasesRequest, err := revokeLeaseCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	revokeLeasesResponse, err := apiRevokeLeases(revokeLeasesRequest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Printf("%v lease(s) revoked successfully\n", len(revokeLeasesResponse.LeaseIds))
+	for _, leaseId := range revokeLeasesResponse.LeaseIds {
+		fmt.Println(leaseId)
+	}
+}
+
+func revokeLeaseCheckUsage(args []string) (*model.RevokeLeasesRequest, error) {
+	revokeLeasesRequest := &model.RevokeLeasesRequest{Prefix: leasePrefix, Owner: leaseOwner}
+	if len(args) == 1 {
+		revokeLeasesRequest.LeaseId = args[0]
+	}
+
+	if len(args) > 1 || (len(args) == 1) == (leasePrefix != "" || leaseOwner != "") {
+		return nil, fmt.Errorf("Usage: %v (either a lease id, or --prefix and/or --owner)", revokeLeaseCmdUsage)
+	}
+
+	return revokeLeasesRequest, nil
+}
+
+func apiListLeases(prefix string, owner string) ([]model.LeaseEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if owner != "" {
+		query.Set("owner", owner)
+	}
+
+	leasesUrl := fmt.Sprintf("%v/leases?%v", Url, query.Encode())
+	req, err := http.NewRequest("GET", leasesUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var leaseEntries []model.LeaseEntry
+	if err = json.NewDecoder(resp.Body).Decode(&leaseEntries); err != nil {
+		return nil, err
+	}
+
+	return leaseEntries, nil
+}
+
+func apiRenewLease(renewLeaseRequest *model.RenewLeaseRequest) (*model.RenewLeaseResponse, error) {
+	var renewLeaseResponse model.RenewLeaseResponse
+	if err := apiPostLeaseRequest("/leases/renew", renewLeaseRequest, &renewLeaseResponse); err != nil {
+		return nil, err
+	}
+
+	return &renewLeaseResponse, nil
+}
+
+func apiRevokeLeases(revokeLeasesRequest *model.RevokeLeasesRequest) (*model.RevokeLeasesResponse, error) {
+	var revokeLeasesResponse model.RevokeLeasesResponse
+	if err := apiPostLeaseRequest("/leases/revoke", revokeLeasesRequest, &revokeLeasesResponse); err != nil {
+		return nil, err
+	}
+
+	return &revokeLeasesResponse, nil
+}
+
+func apiPostLeaseRequest(endpoint string, request interface{}, response interface{}) error {
+	if Token == "" {
+		return fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%v%v", Url, endpoint), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
 
@@ -21,7 +22,13 @@ const (
 	getNamespaceCmdUsage    = "get namespace-path"
 )
 
+var certIssuancePolicyFile string
+var namespaceTags []string
+
 func init() {
+	createNamespaceCmd.Flags().StringVar(&certIssuancePolicyFile, "cert-policy", "", "JSON file holding the namespace's certificate issuance policy")
+	createNamespaceCmd.Flags().StringArrayVar(&namespaceTags, "tag", nil, "tag of the namespace and the secrets under it, as key=value; may be repeated")
+
 	namespacesCmd.AddCommand(createNamespaceCmd)
 	namespacesCmd.AddCommand(deleteNamespaceCmd)
 	namespacesCmd.AddCommand(getNamespaceCmd)
@@ -63,7 +70,19 @@ func createNamespace(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	_, err = apiCreateNamespace(namespacePath, owner, roleLabels)
+	certIssuancePolicy, err := readCertIssuancePolicy(certIssuancePolicyFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	tags, err := parseTags(namespaceTags)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	_, err = apiCreateNamespace(namespacePath, owner, roleLabels, certIssuancePolicy, tags)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
@@ -130,6 +149,24 @@ func createNamespaceCheckUsage(args []string) (string, string, []string, error)
 	return namespacePath, owner, roleLabels, nil
 }
 
+func readCertIssuancePolicy(filename string) (*model.CertIssuancePolicy, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	policyBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var certIssuancePolicy model.CertIssuancePolicy
+	if err := json.Unmarshal(policyBytes, &certIssuancePolicy); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate issuance policy: %v", err)
+	}
+
+	return &certIssuancePolicy, nil
+}
+
 func deleteNamespaceCheckUsage(args []string) (string, error) {
 	if len(args) != 1 {
 		return "", fmt.Errorf("Usage: %v", deleteNamespaceCmdUsage)
@@ -150,15 +187,17 @@ func getNamespaceCheckUsage(args []string) (string, error) {
 	return namespacePath, nil
 }
 
-func apiCreateNamespace(path, owner string, roleLabels []string) (string, error) {
+func apiCreateNamespace(path, owner string, roleLabels []string, certIssuancePolicy *model.CertIssuancePolicy, tags map[string]string) (string, error) {
 	if Token == "" {
 		return "", fmt.Errorf("authn token is empty")
 	}
 
 	ne := &model.NamespaceEntry{
-		Path:       path,
-		Owner:      owner,
-		RoleLabels: roleLabels,
+		Path:               path,
+		Owner:              owner,
+		RoleLabels:         roleLabels,
+		CertIssuancePolicy: certIssuancePolicy,
+		Tags:               tags,
 	}
 
 	body := new(bytes.Buffer)
@@ -0,0 +1,287 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+const (
+	pkiCmdUsage        = "pki [sub-command]"
+	pkiSignCSRCmdUsage = "sign-csr secret-id csr-file"
+	pkiRevokeCmdUsage  = "revoke [secret-id]"
+	pkiCRLCmdUsage     = "crl"
+)
+
+var csrSignRequest model.CSRSignRequest
+var csrMaxPathLen int
+var csrCertFile string
+var revocationRequest model.RevocationRequest
+var crlFile string
+
+func init() {
+	pkiSignCSRCmd.Flags().StringVar(&csrSignRequest.TTL, "ttl", "", "certificate validity (default: 8760h)")
+	pkiSignCSRCmd.Flags().StringSliceVar(&csrSignRequest.KeyUsages, "key-usages", nil, "comma-separated key usages, e.g. digitalSignature,keyEncipherment")
+	pkiSignCSRCmd.Flags().StringSliceVar(&csrSignRequest.ExtKeyUsages, "ext-key-usages", nil, "comma-separated extended key usages, e.g. serverAuth,clientAuth")
+	pkiSignCSRCmd.Flags().BoolVar(&csrSignRequest.IsCA, "ca", false, "issue a CA certificate")
+	pkiSignCSRCmd.Flags().IntVar(&csrMaxPathLen, "max-path-len", -1, "path length constraint of a CA certificate (default: unlimited)")
+	pkiSignCSRCmd.Flags().StringVarP(&csrCertFile, "output", "o", "", "file to write the certificate to (default: standard output)")
+
+	pkiRevokeCmd.Flags().StringVarP(&revocationRequest.SerialNumber, "serial", "s", "", "hex-encoded serial number of the certificate to revoke, instead of a secret id")
+	pkiRevokeCmd.Flags().StringVarP(&revocationRequest.Reason, "reason", "r", "unspecified", "revocation reason, e.g. keyCompromise, superseded, cessationOfOperation")
+
+	pkiCRLCmd.Flags().StringVarP(&crlFile, "output", "o", "", "file to write the PEM-encoded CRL to (default: standard output)")
+
+	pkiCmd.AddCommand(pkiSignCSRCmd)
+	pkiCmd.AddCommand(pkiRevokeCmd)
+	pkiCmd.AddCommand(pkiCRLCmd)
+
+	RootCmd.AddCommand(pkiCmd)
+}
+
+var pkiCmd = &cobra.Command{
+	Use:   pkiCmdUsage,
+	Short: "Public key infrastructure",
+	Long:  "Issue and revoke X.509 certificates signed by the VSM CA",
+}
+
+var pkiSignCSRCmd = &cobra.Command{
+	Use:   pkiSignCSRCmdUsage,
+	Short: "Sign a certificate signing request",
+	Long:  "Issue a certificate for a PEM-encoded PKCS#10 certificate signing request, and store it as a x509-certificate secret",
+	Run:   pkiSignCSR,
+}
+
+var pkiRevokeCmd = &cobra.Command{
+	Use:   pkiRevokeCmdUsage,
+	Short: "Revoke a certificate",
+	Long:  "Revoke the current certificate of a x509-certificate secret, or the certificate with the given serial number",
+	Run:   pkiRevoke,
+}
+
+var pkiCRLCmd = &cobra.Command{
+	Use:   pkiCRLCmdUsage,
+	Short: "Retrieve the certificate revocation list",
+	Long:  "Retrieve the certificate revocation list of the VSM CA",
+	Run:   pkiCRL,
+}
+
+func pkiSignCSR(cmd *cobra.Command, args []string) {
+	signRequest, err := pkiSignCSRCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	signResponse, err := apiSignCSR(signRequest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if csrCertFile == "" {
+		fmt.Print(signResponse.Certificate)
+		return
+	}
+
+	if err := ioutil.WriteFile(csrCertFile, []byte(signResponse.Certificate), 0644); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Certificate issued successfully")
+	fmt.Printf("Id: %v\n", signResponse.Id)
+}
+
+func pkiSignCSRCheckUsage(args []string) (*model.CSRSignRequest, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("Usage: %v", pkiSignCSRCmdUsage)
+	}
+
+	csr, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	signRequest := csrSignRequest
+	signRequest.Id = args[0]
+	signRequest.CSR = string(csr)
+	if csrMaxPathLen >= 0 {
+		signRequest.MaxPathLen = &csrMaxPathLen
+	}
+
+	return &signRequest, nil
+}
+
+func apiSignCSR(signRequest *model.CSRSignRequest) (*model.CSRSignResponse, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(signRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	signUrl := fmt.Sprintf("%v/pki/sign-csr", Url)
+	req, err := http.NewRequest("POST", signUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var signResponse model.CSRSignResponse
+	if err = json.NewDecoder(resp.Body).Decode(&signResponse); err != nil {
+		return nil, err
+	}
+
+	return &signResponse, nil
+}
+
+func pkiRevoke(cmd *cobra.Command, args []string) {
+	revokeRequest, err := pkiRevokeCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	certEntry, err := apiRevokeCertificate(revokeRequest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Certificate revoked successfully")
+	fmt.Printf("Serial number: %v\n", certEntry.SerialNumber)
+	fmt.Printf("Secret id: %v\n", certEntry.SecretId)
+	fmt.Printf("Revocation time: %v\n", certEntry.RevocationTime)
+}
+
+func pkiRevokeCheckUsage(args []string) (*model.RevocationRequest, error) {
+	revokeRequest := revocationRequest
+	if len(args) == 1 {
+		revokeRequest.SecretId = args[0]
+	}
+
+	if len(args) > 1 || (revokeRequest.SecretId == "") == (revokeRequest.SerialNumber == "") {
+		return nil, fmt.Errorf("Usage: %v (or: revoke --serial serial-number)", pkiRevokeCmdUsage)
+	}
+
+	return &revokeRequest, nil
+}
+
+func apiRevokeCertificate(revokeRequest *model.RevocationRequest) (*model.CertificateEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(revokeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	revokeUrl := fmt.Sprintf("%v/pki/revocations", Url)
+	req, err := http.NewRequest("POST", revokeUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var certEntry model.CertificateEntry
+	if err = json.NewDecoder(resp.Body).Decode(&certEntry); err != nil {
+		return nil, err
+	}
+
+	return &certEntry, nil
+}
+
+func pkiCRL(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		fmt.Printf("Usage: %v\n", pkiCRLCmdUsage)
+		return
+	}
+
+	crlDER, err := apiGetCRL()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	crlPEM := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlDER})
+
+	if crlFile == "" {
+		fmt.Print(string(crlPEM))
+		return
+	}
+
+	if err := ioutil.WriteFile(crlFile, crlPEM, 0644); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("CRL retrieved successfully")
+}
+
+// apiGetCRL retrieves the DER-encoded CRL, which doesn't require authentication.
+func apiGetCRL() ([]byte, error) {
+	crlUrl := fmt.Sprintf("%v/pki/crl", Url)
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(crlUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
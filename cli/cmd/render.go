@@ -0,0 +1,267 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/secret"
+)
+
+const renderCmdUsage = "render template-file output-file"
+
+var renderMode string
+var renderWatch bool
+var renderInterval string
+var renderReloadCommand string
+
+func init() {
+	renderCmd.Flags().StringVar(&renderMode, "mode", "0600", "permissions of the rendered file, in octal")
+	renderCmd.Flags().BoolVarP(&renderWatch, "watch", "w", false, "keep running, rendering the file again whenever a secret it references changes")
+	renderCmd.Flags().StringVar(&renderInterval, "interval", "1m", "period at which referenced secrets are checked for changes, in watch mode")
+	renderCmd.Flags().StringVar(&renderReloadCommand, "reload", "", "shell command run whenever the rendered file changes, e.g. \"systemctl reload nginx\"")
+
+	RootCmd.AddCommand(renderCmd)
+}
+
+var renderCmd = &cobra.Command{
+	Use:   renderCmdUsage,
+	Short: "Render secrets into a file",
+	Long: `Render a Go template into a file, readable by its owner only (unless --mode says otherwise).
+The template references secrets by id: {{ secret "app/db-password" }} is replaced by the data of
+the secret, and {{ cert "app/tls" }} by the PEM-encoded certificate of an x509-certificate secret,
+whose private key is {{ (cert "app/tls").Key }}. The file is only written when its content changes.`,
+	Run: render,
+}
+
+// renderedCert is the value of the cert template function.
+type renderedCert struct {
+	Cert string
+	Key  string
+}
+
+func (c *renderedCert) String() string {
+	return c.Cert
+}
+
+// secretRenderer renders a template, recording the versions of the secrets it references.
+type secretRenderer struct {
+	templateFile string
+	versions     map[string]int
+}
+
+func render(cmd *cobra.Command, args []string) {
+	templateFile, outputFile, mode, interval, err := renderCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	renderer := &secretRenderer{templateFile: templateFile}
+	if err := renderer.renderFile(outputFile, mode); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if !renderWatch {
+		return
+	}
+
+	for range time.Tick(interval) {
+		changed, err := renderer.secretsChanged()
+		if err != nil {
+			fmt.Printf("failed to check secrets for changes: %v\n", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		if err := renderer.renderFile(outputFile, mode); err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+}
+
+func renderCheckUsage(args []string) (string, string, os.FileMode, time.Duration, error) {
+	if len(args) != 2 {
+		return "", "", 0, 0, fmt.Errorf("Usage: %v", renderCmdUsage)
+	}
+
+	mode, err := strconv.ParseUint(renderMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return "", "", 0, 0, fmt.Errorf("failed to parse %v as file permissions", renderMode)
+	}
+
+	interval, err := time.ParseDuration(renderInterval)
+	if err != nil || interval <= 0 {
+		return "", "", 0, 0, fmt.Errorf("failed to parse %s as a duration", renderInterval)
+	}
+
+	return args[0], args[1], os.FileMode(mode), interval, nil
+}
+
+// renderFile renders the template into a file, if its content changes, running the
+// reload command if it does.
+func (r *secretRenderer) renderFile(outputFile string, mode os.FileMode) error {
+	rendered, err := r.render()
+	if err != nil {
+		return fmt.Errorf("failed to render %v: %v", r.templateFile, err)
+	}
+
+	if fileHolds(outputFile, rendered, mode) {
+		return nil
+	}
+
+	if err := writeFileAtomically(outputFile, rendered, mode); err != nil {
+		return fmt.Errorf("failed to write %v: %v", outputFile, err)
+	}
+	fmt.Printf("Rendered %v\n", outputFile)
+
+	if renderReloadCommand == "" {
+		return nil
+	}
+
+	reloadCmd := exec.Command("sh", "-c", renderReloadCommand)
+	reloadCmd.Stdout = os.Stdout
+	reloadCmd.Stderr = os.Stderr
+	if err := reloadCmd.Run(); err != nil {
+		return fmt.Errorf("reload command failed: %v", err)
+	}
+
+	return nil
+}
+
+func (r *secretRenderer) render() ([]byte, error) {
+	text, err := ioutil.ReadFile(r.templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.versions = make(map[string]int)
+	funcs := template.FuncMap{
+		"secret": r.secret,
+		"cert":   r.cert,
+	}
+
+	tmpl, err := template.New(filepath.Base(r.templateFile)).Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, nil); err != nil {
+		return nil, err
+	}
+
+	return rendered.Bytes(), nil
+}
+
+func (r *secretRenderer) secret(secretId string) (string, error) {
+	se, err := apiGetSecret(secretId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %v: %v", secretId, err)
+	}
+	r.versions[secretId] = se.Version
+
+	return string(se.SecretData), nil
+}
+
+func (r *secretRenderer) cert(secretId string) (*renderedCert, error) {
+	se, err := apiGetSecret(secretId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %v: %v", secretId, err)
+	}
+	if se.Type != secret.X509CertificateSecretTypeName {
+		return nil, fmt.Errorf("secret %v is not an x509-certificate secret", secretId)
+	}
+	r.versions[secretId] = se.Version
+
+	var metaData secret.X509CertificateSecretMetaData
+	if err := json.Unmarshal([]byte(se.MetaData), &metaData); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata of secret %v: %v", secretId, err)
+	}
+
+	c := &renderedCert{Cert: string(se.SecretData)}
+	// a certificate issued for a CSR has no private key in VSM
+	if metaData.PrivateKeyId != "" {
+		key, err := r.secret(metaData.PrivateKeyId)
+		if err != nil {
+			return nil, err
+		}
+		c.Key = key
+	}
+
+	return c, nil
+}
+
+// secretsChanged reports whether a secret referenced by the last rendering has a new
+// version.
+func (r *secretRenderer) secretsChanged() (bool, error) {
+	return secretVersionsChanged(r.versions)
+}
+
+// secretVersionsChanged reports whether any of the given secrets has a current version
+// other than the given one, without reading the secrets' data.
+func secretVersionsChanged(versions map[string]int) (bool, error) {
+	for secretId, version := range versions {
+		versionEntries, err := apiListSecretVersions(secretId)
+		if err != nil {
+			return false, fmt.Errorf("failed to list versions of secret %v: %v", secretId, err)
+		}
+
+		for _, versionEntry := range versionEntries {
+			if versionEntry.Current && versionEntry.Version != version {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// fileHolds reports whether a file holds the given data, with the given permissions.
+func fileHolds(fileName string, data []byte, mode os.FileMode) bool {
+	info, err := os.Stat(fileName)
+	if err != nil || info.Mode().Perm() != mode {
+		return false
+	}
+
+	current, err := ioutil.ReadFile(fileName)
+
+	return err == nil && bytes.Equal(current, data)
+}
+
+// writeFileAtomically replaces a file with one holding the given data, so that readers
+// never see a partially written file.
+func writeFileAtomically(fileName string, data []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), fileName)
+}
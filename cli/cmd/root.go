@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -33,6 +34,13 @@ func init() {
 	RootCmd.PersistentFlags().StringVarP(&Cert, "cert", "c", "certs/test-root-cert.pem", "root CA certificate filename")
 }
 
+// Execute runs the command given by the command-line arguments.
+func Execute() error {
+	addCreateSecretTypeCmds(os.Args[1:])
+
+	return RootCmd.Execute()
+}
+
 func httpClient() (*http.Client, error) {
 	u, err := url.Parse(Url)
 	if err != nil {
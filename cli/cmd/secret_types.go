@@ -0,0 +1,366 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+const secretTypesCmdUsage = "types [secret-type]"
+
+// Annotation of the create sub-commands, naming the secret type they create.
+const secretTypeAnnotation = "secret-type"
+
+var secretTypesCmd = &cobra.Command{
+	Use:   secretTypesCmdUsage,
+	Short: "List secret types",
+	Long:  "List the secret types supported by the server, along with their create sub-commands and the fields of their metadata",
+	Run:   listSecretTypes,
+}
+
+func listSecretTypes(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		fmt.Printf("Usage: %v\n", secretTypesCmdUsage)
+		return
+	}
+
+	typeEntries, err := apiListSecretTypes()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	found := false
+	for _, typeEntry := range typeEntries {
+		cmdName := createSecretTypeCmdName(typeEntry.Type)
+		if len(args) == 1 && args[0] != typeEntry.Type && args[0] != cmdName {
+			continue
+		}
+		found = true
+
+		fmt.Printf("%v: %v\n", typeEntry.Type, typeEntry.Description)
+		fmt.Printf("  Create: secrets create %v\n", cmdName)
+		if typeEntry.SecretDataDescription != "" {
+			fmt.Printf("  Secret data: %v\n", typeEntry.SecretDataDescription)
+		}
+		if fields := schemaFieldNames(typeEntry.MetaDataSchema); len(fields) > 0 {
+			fmt.Println("  Metadata:")
+			for _, field := range fields {
+				fmt.Printf("    %v: %v\n", field, describeSchemaField(typeEntry.MetaDataSchema, field))
+			}
+		}
+	}
+
+	if len(args) == 1 && !found {
+		fmt.Printf("Secret type %v is not supported by the server\n", args[0])
+	}
+}
+
+// addCreateSecretTypeCmds adds a create sub-command for each secret type supported by
+// the server which has no hand-written one, with a flag for each field of the type's
+// metadata. The server is only asked for its secret types when the arguments are those
+// of a secrets create command; otherwise, or if the server can't be reached, only the
+// hand-written sub-commands are available.
+func addCreateSecretTypeCmds(args []string) {
+	// the root flags are parsed ahead of cobra, for the server's URL and the auth token
+	rootFlags := pflag.NewFlagSet(RootCmd.Name(), pflag.ContinueOnError)
+	rootFlags.ParseErrorsWhitelist.UnknownFlags = true
+	rootFlags.SetOutput(ioutil.Discard)
+	rootFlags.AddFlagSet(RootCmd.PersistentFlags())
+	rootFlags.Parse(args)
+
+	cmdArgs := rootFlags.Args()
+	if len(cmdArgs) < 2 || cmdArgs[0] != secretsCmd.Name() || cmdArgs[1] != createSecretCmd.Name() {
+		return
+	}
+
+	typeEntries, err := apiListSecretTypes()
+	if err != nil {
+		return
+	}
+
+	for _, typeEntry := range typeEntries {
+		if handWrittenCreateSecretCmd(typeEntry.Type) != nil || createSecretCmdNamed(kebabCase(typeEntry.Type)) {
+			continue
+		}
+		createSecretCmd.AddCommand(newCreateSecretTypeCmd(typeEntry))
+	}
+}
+
+func createSecretCmdNamed(cmdName string) bool {
+	for _, cmd := range createSecretCmd.Commands() {
+		if cmd.Name() == cmdName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handWrittenCreateSecretCmd returns the hand-written create sub-command of a secret type,
+// if it has one.
+func handWrittenCreateSecretCmd(typeName string) *cobra.Command {
+	for _, cmd := range createSecretCmd.Commands() {
+		if cmd.Annotations[secretTypeAnnotation] == typeName {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+func createSecretTypeCmdName(typeName string) string {
+	if cmd := handWrittenCreateSecretCmd(typeName); cmd != nil {
+		return cmd.Name()
+	}
+
+	return kebabCase(typeName)
+}
+
+// newCreateSecretTypeCmd returns a create sub-command of a secret type, built from its
+// description by the server.
+func newCreateSecretTypeCmd(typeEntry *model.SecretTypeEntry) *cobra.Command {
+	cmdName := kebabCase(typeEntry.Type)
+	usage := fmt.Sprintf("%v secret-id", cmdName)
+	long := fmt.Sprintf("Create a %v secret: %v", cmdName, typeEntry.Description)
+	if typeEntry.SecretDataDescription != "" {
+		usage += " secret-data"
+		long += fmt.Sprintf("\nThe secret data is the %v", typeEntry.SecretDataDescription)
+	}
+
+	cmd := &cobra.Command{
+		Use:         usage,
+		Short:       fmt.Sprintf("Create a %v secret", cmdName),
+		Long:        long,
+		Annotations: map[string]string{secretTypeAnnotation: typeEntry.Type},
+	}
+	addSchemaFlags(cmd, typeEntry.MetaDataSchema)
+
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		wantedArgs := 1
+		if typeEntry.SecretDataDescription != "" {
+			wantedArgs = 2
+		}
+		if len(args) != wantedArgs {
+			fmt.Printf("Usage: %v\n", usage)
+			return
+		}
+
+		secretMetaData, err := schemaFlagsMetaData(cmd.Flags(), typeEntry.MetaDataSchema)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		var secretData []byte
+		if wantedArgs == 2 {
+			secretData = []byte(args[1])
+		}
+
+		id, err := apiCreateSecret(args[0], typeEntry.Type, secretMetaData, secretData)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		fmt.Println("Secret created successfully")
+		fmt.Printf("Id: %v\n", id)
+	}
+
+	return cmd
+}
+
+// addSchemaFlags adds a flag for each field of an object schema which clients may set.
+// Object fields are given as JSON, and arrays as comma-separated strings.
+func addSchemaFlags(cmd *cobra.Command, schema *model.Schema) {
+	for _, field := range schemaFieldNames(schema) {
+		fieldSchema := schema.Properties[field]
+		flagName := kebabCase(field)
+		usage := describeSchemaField(schema, field)
+
+		switch fieldSchema.Type {
+		case model.SchemaTypeString:
+			cmd.Flags().String(flagName, "", usage)
+		case model.SchemaTypeInteger:
+			cmd.Flags().Int64(flagName, 0, usage)
+		case model.SchemaTypeNumber:
+			cmd.Flags().Float64(flagName, 0, usage)
+		case model.SchemaTypeBoolean:
+			cmd.Flags().Bool(flagName, false, usage)
+		case model.SchemaTypeArray:
+			cmd.Flags().StringSlice(flagName, nil, usage)
+		case model.SchemaTypeObject:
+			cmd.Flags().String(flagName, "", usage)
+		default:
+			continue
+		}
+
+		if containsField(schema.Required, field) {
+			cmd.MarkFlagRequired(flagName)
+		}
+	}
+}
+
+// schemaFlagsMetaData returns the metadata of a secret, holding the fields whose flags
+// are set.
+func schemaFlagsMetaData(flags *pflag.FlagSet, schema *model.Schema) (string, error) {
+	metaData := make(map[string]interface{})
+
+	for _, field := range schemaFieldNames(schema) {
+		flagName := kebabCase(field)
+		if !flags.Changed(flagName) {
+			continue
+		}
+
+		var value interface{}
+		var err error
+		switch schema.Properties[field].Type {
+		case model.SchemaTypeString:
+			value, err = flags.GetString(flagName)
+		case model.SchemaTypeInteger:
+			value, err = flags.GetInt64(flagName)
+		case model.SchemaTypeNumber:
+			value, err = flags.GetFloat64(flagName)
+		case model.SchemaTypeBoolean:
+			value, err = flags.GetBool(flagName)
+		case model.SchemaTypeArray:
+			value, err = flags.GetStringSlice(flagName)
+		case model.SchemaTypeObject:
+			var object string
+			if object, err = flags.GetString(flagName); err == nil {
+				if !json.Valid([]byte(object)) {
+					return "", fmt.Errorf("--%v is not a JSON document", flagName)
+				}
+				value = json.RawMessage(object)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+
+		metaData[field] = value
+	}
+
+	metaDataBytes, err := json.Marshal(metaData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(metaDataBytes), nil
+}
+
+// schemaFieldNames returns the fields of an object schema which clients may set, in order.
+func schemaFieldNames(schema *model.Schema) []string {
+	if schema == nil {
+		return nil
+	}
+
+	fields := make([]string, 0, len(schema.Properties))
+	for field, fieldSchema := range schema.Properties {
+		if !fieldSchema.ReadOnly {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+func describeSchemaField(schema *model.Schema, field string) string {
+	fieldSchema := schema.Properties[field]
+
+	kind := fieldSchema.Type
+	switch {
+	case fieldSchema.Type == model.SchemaTypeArray && fieldSchema.Items != nil:
+		kind = fmt.Sprintf("comma-separated %v list", fieldSchema.Items.Type)
+	case fieldSchema.Type == model.SchemaTypeObject:
+		kind = "JSON object"
+	case fieldSchema.Format != "":
+		kind = fieldSchema.Format
+	}
+	if containsField(schema.Required, field) {
+		kind += ", required"
+	}
+
+	description := fmt.Sprintf("%v (%v)", fieldSchema.Description, kind)
+	if len(fieldSchema.Enum) > 0 {
+		description += fmt.Sprintf(", one of: %v", strings.Join(fieldSchema.Enum, ", "))
+	}
+
+	return strings.TrimSpace(description)
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// kebabCase turns a camel-cased name, e.g. a secret type or a metadata field, into a
+// command or flag name, e.g. RSAPrivateKey into rsa-private-key.
+func kebabCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+func apiListSecretTypes() ([]*model.SecretTypeEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	typesUrl := fmt.Sprintf("%v/secret-types", Url)
+	req, err := http.NewRequest("GET", typesUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var typeEntries []*model.SecretTypeEntry
+	if err = json.NewDecoder(resp.Body).Decode(&typeEntries); err != nil {
+		return nil, err
+	}
+
+	return typeEntries, nil
+}
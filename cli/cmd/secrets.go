@@ -6,8 +6,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vmware/virtual-security-module/model"
@@ -16,24 +21,94 @@ import (
 )
 
 const (
-	secretsCmdUsage      = "secrets [sub-command]"
-	createSecretCmdUsage = "create secret"
-	deleteSecretCmdUsage = "delete secret-id"
-	getSecretCmdUsage    = "get secret-id"
-
-	createDataSecretCmdUsage            = "data secret-id secret-data"
-	createRSAPrivateKeySecretCmdUsage   = "rsa-private-key secret-id key-length"
-	createX509CertificateSecretCmdUsage = "x509-certificate secret-id private-key-id common-name organization country"
+	secretsCmdUsage         = "secrets [sub-command]"
+	createSecretCmdUsage    = "create secret"
+	deleteSecretCmdUsage    = "delete secret-id"
+	getSecretCmdUsage       = "get secret-id"
+	updateSecretCmdUsage    = "update secret"
+	secretHistoryCmdUsage   = "history secret-id"
+	rollbackSecretCmdUsage  = "rollback secret-id version"
+	expiringSecretsCmdUsage = "expiring"
+	secretEventsCmdUsage    = "events"
+	listSecretsCmdUsage     = "list [namespace]"
+	rotateSecretCmdUsage    = "rotate secret-id"
+	secretRotationCmdUsage  = "rotation secret-id"
+	searchSecretsCmdUsage   = "search [namespace]"
+
+	createDataSecretCmdUsage              = "data secret-id secret-data"
+	createRSAPrivateKeySecretCmdUsage     = "rsa-private-key secret-id key-length"
+	createX509CertificateSecretCmdUsage   = "x509-certificate secret-id private-key-id common-name organization country"
+	createDynamicCredentialSecretCmdUsage = "dynamic-credential secret-id connection-string"
+	createCloudAccessKeySecretCmdUsage    = "cloud-access-key secret-id credentials-file"
 )
 
+var secretVersion int
+var expiringWithin string
+var eventsSince string
+var listRecursive bool
+var certMetaData secret.X509CertificateSecretMetaData
+var certMaxPathLen int
+var dynCredMetaData secret.DynamicCredentialSecretMetaData
+var sqlBackendConfig secret.SQLCredentialBackendConfig
+var cloudKeyMetaData secret.CloudAccessKeySecretMetaData
+var awsIAMConfig secret.AWSIAMProviderConfig
+var rotationPolicy model.RotationPolicy
+var secretTags []string
+var searchSelectors []string
+
 func init() {
 	createSecretCmd.AddCommand(createDataSecretCmd)
-	createSecretCmd.AddCommand(createRSAPrivateKeySecretCmd)
-	createSecretCmd.AddCommand(createX509CertificateSecretCmd)
+	createSecretCmd.AddCommand(createDynamicCredentialSecretCmd)
+	createSecretCmd.AddCommand(createCloudAccessKeySecretCmd)
+
+	updateSecretCmd.AddCommand(updateDataSecretCmd)
+	updateSecretCmd.AddCommand(updateRSAPrivateKeySecretCmd)
+	updateSecretCmd.AddCommand(updateX509CertificateSecretCmd)
+
+	createDynamicCredentialSecretCmd.Flags().StringVar(&dynCredMetaData.TTL, "ttl", "", "lease period of minted credentials (default: 1h)")
+	createDynamicCredentialSecretCmd.Flags().StringVar(&dynCredMetaData.MaxTTL, "max-ttl", "", "period, since being minted, beyond which leases can't be renewed (default: 24h)")
+	createDynamicCredentialSecretCmd.Flags().StringVar(&sqlBackendConfig.Driver, "driver", "", "name of the database driver: postgres or mysql")
+	createDynamicCredentialSecretCmd.Flags().StringArrayVar(&sqlBackendConfig.CreationStatements, "creation-statement", nil, "statement creating a user, using {{.Name}}, {{.Password}} and {{.Expiration}}; may be repeated")
+	createDynamicCredentialSecretCmd.Flags().StringArrayVar(&sqlBackendConfig.RevocationStatements, "revocation-statement", nil, "statement dropping a user, using {{.Name}}; may be repeated")
+	createDynamicCredentialSecretCmd.Flags().StringArrayVar(&sqlBackendConfig.RenewalStatements, "renewal-statement", nil, "statement extending a user's validity when its lease is renewed, using {{.Name}} and {{.Expiration}}; may be repeated")
+	createCloudAccessKeySecretCmd.Flags().StringVar(&awsIAMConfig.UserName, "user", "", "name of the IAM user whose access key is managed")
+	createCloudAccessKeySecretCmd.Flags().StringVar(&awsIAMConfig.Endpoint, "endpoint", "", "IAM endpoint (default: https://iam.amazonaws.com/)")
+	createCloudAccessKeySecretCmd.Flags().StringVar(&awsIAMConfig.Region, "region", "", "region in which IAM requests are signed (default: us-east-1)")
+	updateX509CertificateSecretCmd.Flags().StringSliceVar(&certMetaData.DNSNames, "dns", nil, "comma-separated DNS subject alternative names")
+	updateX509CertificateSecretCmd.Flags().StringSliceVar(&certMetaData.IPAddresses, "ip", nil, "comma-separated IP address subject alternative names")
+	updateX509CertificateSecretCmd.Flags().StringSliceVar(&certMetaData.URIs, "uri", nil, "comma-separated URI subject alternative names")
+	updateX509CertificateSecretCmd.Flags().StringVar(&certMetaData.TTL, "ttl", "", "certificate validity (default: 8760h)")
+	updateX509CertificateSecretCmd.Flags().StringSliceVar(&certMetaData.KeyUsages, "key-usages", nil, "comma-separated key usages, e.g. digitalSignature,keyEncipherment")
+	updateX509CertificateSecretCmd.Flags().StringSliceVar(&certMetaData.ExtKeyUsages, "ext-key-usages", nil, "comma-separated extended key usages, e.g. serverAuth,clientAuth")
+	updateX509CertificateSecretCmd.Flags().BoolVar(&certMetaData.IsCA, "ca", false, "issue a CA certificate")
+	updateX509CertificateSecretCmd.Flags().IntVar(&certMaxPathLen, "max-path-len", -1, "path length constraint of a CA certificate (default: unlimited)")
+	updateX509CertificateSecretCmd.Flags().BoolVar(&certMetaData.AutoRenew, "auto-renew", false, "renew the certificate automatically before it expires")
+	updateX509CertificateSecretCmd.Flags().Float64Var(&certMetaData.RenewAfter, "renew-after", 0, "fraction of the certificate's lifetime after which it's renewed (default: 0.67)")
+	updateX509CertificateSecretCmd.Flags().BoolVar(&certMetaData.Rekey, "rekey", false, "rotate the private key when renewing the certificate")
+	createSecretCmd.PersistentFlags().StringVar(&rotationPolicy.Interval, "rotate-every", "", "rotate the secret at this interval, e.g. 720h")
+	createSecretCmd.PersistentFlags().StringVar(&rotationPolicy.Cron, "rotate-cron", "", "rotate the secret at the times matched by this cron expression (UTC), e.g. \"0 3 * * 0\"")
+	createSecretCmd.PersistentFlags().StringVar(&rotationPolicy.MaxAge, "max-age", "", "rotate the secret once it is older than this, e.g. 2160h")
+	createSecretCmd.PersistentFlags().StringArrayVar(&secretTags, "tag", nil, "tag of the secret, as key=value; may be repeated")
+	updateSecretCmd.PersistentFlags().StringArrayVar(&secretTags, "tag", nil, "tag of the secret, as key=value, replacing its current tags; may be repeated")
+	searchSecretsCmd.Flags().StringArrayVarP(&searchSelectors, "selector", "l", nil, "tag requirement: key=value, key!=value or \"key in (value1,value2)\"; may be repeated")
+	getSecretCmd.Flags().IntVarP(&secretVersion, "version", "v", 0, "secret version (default: current version)")
+	listSecretsCmd.Flags().BoolVarP(&listRecursive, "recursive", "r", false, "list secrets in sub-namespaces as well")
+	expiringSecretsCmd.Flags().StringVarP(&expiringWithin, "within", "w", "24h", "period from now within which secrets expire")
+	secretEventsCmd.Flags().StringVarP(&eventsSince, "since", "s", "24h", "period before now after which events occurred")
 
 	secretsCmd.AddCommand(createSecretCmd)
 	secretsCmd.AddCommand(deleteSecretCmd)
 	secretsCmd.AddCommand(getSecretCmd)
+	secretsCmd.AddCommand(updateSecretCmd)
+	secretsCmd.AddCommand(secretHistoryCmd)
+	secretsCmd.AddCommand(rollbackSecretCmd)
+	secretsCmd.AddCommand(expiringSecretsCmd)
+	secretsCmd.AddCommand(secretEventsCmd)
+	secretsCmd.AddCommand(listSecretsCmd)
+	secretsCmd.AddCommand(rotateSecretCmd)
+	secretsCmd.AddCommand(secretRotationCmd)
+	secretsCmd.AddCommand(searchSecretsCmd)
+	secretsCmd.AddCommand(secretTypesCmd)
 
 	RootCmd.AddCommand(secretsCmd)
 }
@@ -41,7 +116,7 @@ func init() {
 var secretsCmd = &cobra.Command{
 	Use:   secretsCmdUsage,
 	Short: "Secret management",
-	Long:  "Create, get or delete a secret",
+	Long:  "Create, get, list, update or delete a secret",
 }
 
 var createSecretCmd = &cobra.Command{
@@ -64,25 +139,111 @@ var getSecretCmd = &cobra.Command{
 	Run:   getSecret,
 }
 
+var updateSecretCmd = &cobra.Command{
+	Use:   updateSecretCmdUsage,
+	Short: "Update a secret",
+	Long:  "Update a secret, keeping its current version as a prior version",
+}
+
+var secretHistoryCmd = &cobra.Command{
+	Use:   secretHistoryCmdUsage,
+	Short: "List the versions of a secret",
+	Long:  "List the current and prior versions of a secret",
+	Run:   secretHistory,
+}
+
+var rollbackSecretCmd = &cobra.Command{
+	Use:   rollbackSecretCmdUsage,
+	Short: "Rollback a secret",
+	Long:  "Rollback a secret to one of its prior versions",
+	Run:   rollbackSecret,
+}
+
+var rotateSecretCmd = &cobra.Command{
+	Use:   rotateSecretCmdUsage,
+	Short: "Rotate a secret",
+	Long:  "Regenerate a system-generated secret (e.g. a generated password), keeping its current version as a prior version",
+	Run:   rotateSecret,
+}
+
+var secretRotationCmd = &cobra.Command{
+	Use:   secretRotationCmdUsage,
+	Short: "Show the rotation status of a secret",
+	Long:  "Show the next scheduled rotation and the recent rotations of a secret which has a rotation policy",
+	Run:   secretRotation,
+}
+
+var listSecretsCmd = &cobra.Command{
+	Use:   listSecretsCmdUsage,
+	Short: "List secrets",
+	Long:  "List the secrets in a namespace (default: all secrets), without their data",
+	Run:   listSecrets,
+}
+
+var searchSecretsCmd = &cobra.Command{
+	Use:   searchSecretsCmdUsage,
+	Short: "Search secrets by tags",
+	Long:  "List the secrets in a namespace (default: all secrets) and its sub-namespaces whose tags meet all the given requirements",
+	Run:   searchSecrets,
+}
+
+var expiringSecretsCmd = &cobra.Command{
+	Use:   expiringSecretsCmdUsage,
+	Short: "List expiring secrets",
+	Long:  "List the secrets that are due to expire, including expired secrets that were not deleted yet",
+	Run:   expiringSecrets,
+}
+
+var secretEventsCmd = &cobra.Command{
+	Use:   secretEventsCmdUsage,
+	Short: "List secret events",
+	Long:  "List the changes made to secrets by the server itself, e.g. automatic certificate renewals",
+	Run:   secretEvents,
+}
+
 var createDataSecretCmd = &cobra.Command{
+	Use:         createDataSecretCmdUsage,
+	Short:       "Create a data secret",
+	Long:        "Create a data secret",
+	Run:         createDataSecret,
+	Annotations: map[string]string{secretTypeAnnotation: secret.DataSecretTypeName},
+}
+
+var createDynamicCredentialSecretCmd = &cobra.Command{
+	Use:         createDynamicCredentialSecretCmdUsage,
+	Short:       "Create a dynamic-credential secret",
+	Long:        "Create a secret which mints a fresh database user, leased for a limited period, each time it's read",
+	Run:         createDynamicCredentialSecret,
+	Annotations: map[string]string{secretTypeAnnotation: secret.DynamicCredentialSecretTypeName},
+}
+
+var createCloudAccessKeySecretCmd = &cobra.Command{
+	Use:         createCloudAccessKeySecretCmdUsage,
+	Short:       "Create a cloud-access-key secret",
+	Long:        "Create a secret which manages an AWS IAM user's access key, using the administrative access key (JSON with accessKeyId and secretAccessKey) in credentials-file",
+	Run:         createCloudAccessKeySecret,
+	Annotations: map[string]string{secretTypeAnnotation: secret.CloudAccessKeySecretTypeName},
+}
+
+var updateDataSecretCmd = &cobra.Command{
 	Use:   createDataSecretCmdUsage,
-	Short: "Create a data secret",
-	Long:  "Create a data secret",
-	Run:   createDataSecret,
+	Short: "Update a data secret",
+	Long:  "Update a data secret",
+	Run:   updateDataSecret,
 }
 
-var createRSAPrivateKeySecretCmd = &cobra.Command{
+var updateRSAPrivateKeySecretCmd = &cobra.Command{
 	Use:   createRSAPrivateKeySecretCmdUsage,
-	Short: "Create a rsa-private-key secret",
-	Long:  "Create a rsa-private-key secret",
-	Run:   createRSAPrivateKeySecret,
+	Short: "Update a rsa-private-key secret",
+	Long:  "Update a rsa-private-key secret by generating a new key",
+	Run:   updateRSAPrivateKeySecret,
 }
 
-var createX509CertificateSecretCmd = &cobra.Command{
+var updateX509CertificateSecretCmd = &cobra.Command{
 	Use:   createX509CertificateSecretCmdUsage,
-	Short: "Create a x509-certificate secret",
-	Long:  "Create a x509-certificate secret",
-	Run:   createX509CertificateSecret,
+	Short: "Update a x509-certificate secret",
+	Long:  "Update a x509-certificate secret by issuing a new certificate",
+	Run:   updateX509CertificateSecret,
 }
 
 func createDataSecret(cmd *cobra.Command, args []string) {
@@ -102,31 +263,28 @@ func createDataSecret(cmd *cobra.Command, args []string) {
 	fmt.Printf("Id: %v\n", id)
 }
 
-func createRSAPrivateKeySecret(cmd *cobra.Command, args []string) {
-	secretId, keyLength, err := createRSAPrivateKeySecretCheckUsage(args)
+func createDynamicCredentialSecret(cmd *cobra.Command, args []string) {
+	secretId, connectionString, err := createDynamicCredentialSecretCheckUsage(args)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	id, err := apiCreateRSAPrivateKeySecret(secretId, keyLength)
+	backendConfig, err := json.Marshal(sqlBackendConfig)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	fmt.Println("Secret created successfully")
-	fmt.Printf("Id: %v\n", id)
-}
-
-func createX509CertificateSecret(cmd *cobra.Command, args []string) {
-	secretId, privateKeyId, commonName, organization, country, err := createX509CertificateSecretCheckUsage(args)
+	dynCredMetaData.Backend = secret.SQLCredentialBackendType
+	dynCredMetaData.Config = backendConfig
+	secretMetaData, err := json.Marshal(dynCredMetaData)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	id, err := apiCreateX509CertificateSecret(secretId, privateKeyId, commonName, organization, country)
+	id, err := apiCreateSecret(secretId, secret.DynamicCredentialSecretTypeName, string(secretMetaData), []byte(connectionString))
 	if err != nil {
 		fmt.Println(err.Error())
 		return
@@ -136,236 +294,741 @@ func createX509CertificateSecret(cmd *cobra.Command, args []string) {
 	fmt.Printf("Id: %v\n", id)
 }
 
-func deleteSecret(cmd *cobra.Command, args []string) {
-	secretId, err := deleteSecretCheckUsage(args)
+func createCloudAccessKeySecret(cmd *cobra.Command, args []string) {
+	secretId, credentialsFile, err := createCloudAccessKeySecretCheckUsage(args)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	err = apiDeleteSecret(secretId)
+	credentials, err := ioutil.ReadFile(credentialsFile)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	fmt.Println("Secret deleted successfully")
-}
-
-func getSecret(cmd *cobra.Command, args []string) {
-	secretId, err := getSecretCheckUsage(args)
+	providerConfig, err := json.Marshal(awsIAMConfig)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	se, err := apiGetSecret(secretId)
+	cloudKeyMetaData.Provider = secret.AWSIAMProviderType
+	cloudKeyMetaData.Config = providerConfig
+	secretMetaData, err := json.Marshal(cloudKeyMetaData)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	s, err := util.JSONPrettyPrint(se)
+	id, err := apiCreateSecret(secretId, secret.CloudAccessKeySecretTypeName, string(secretMetaData), credentials)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
-	fmt.Println(s)
 
-	fmt.Printf("\nDecoded Secret data:\n")
-	fmt.Printf("%s\n", se.SecretData)
+	fmt.Println("Secret created successfully")
+	fmt.Printf("Id: %v\n", id)
 }
 
-func createDataSecretCheckUsage(args []string) (string, string, error) {
-	if len(args) != 2 {
-		return "", "", fmt.Errorf("Usage: %v", createDataSecretCmdUsage)
+func rotateSecret(cmd *cobra.Command, args []string) {
+	secretId, err := rotateSecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretId := args[0]
-	secretData := args[1]
+	err = apiRotateSecret(secretId)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-	return secretId, secretData, nil
+	fmt.Println("Secret rotated successfully")
 }
 
-func createRSAPrivateKeySecretCheckUsage(args []string) (string, int, error) {
-	if len(args) != 2 {
-		return "", 0, fmt.Errorf("Usage: %v", createRSAPrivateKeySecretCmdUsage)
+func secretRotation(cmd *cobra.Command, args []string) {
+	secretId, err := rotateSecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretId := args[0]
-	keyLengthStr := args[1]
-
-	keyLength, err := strconv.Atoi(keyLengthStr)
+	rotationEntry, err := apiGetSecretRotation(secretId)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to convert %s to an int: %v", keyLengthStr, err)
+		fmt.Println(err.Error())
+		return
 	}
 
-	return secretId, keyLength, nil
+	s, err := util.JSONPrettyPrint(rotationEntry)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println(s)
 }
 
-func createX509CertificateSecretCheckUsage(args []string) (string, string, string, string, string, error) {
-	if len(args) != 5 {
-		return "", "", "", "", "", fmt.Errorf("Usage: %v", createX509CertificateSecretCmdUsage)
+func updateDataSecret(cmd *cobra.Command, args []string) {
+	secretId, secretData, err := createDataSecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretId := args[0]
-	privKeyId := args[1]
-	commonName := args[2]
-	organization := args[3]
-	country := args[4]
+	err = apiUpdateSecret(secretId, secret.DataSecretTypeName, "{}", []byte(secretData))
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-	return secretId, privKeyId, commonName, organization, country, nil
+	fmt.Println("Secret updated successfully")
 }
 
-func deleteSecretCheckUsage(args []string) (string, error) {
-	if len(args) != 1 {
-		return "", fmt.Errorf("Usage: %v", deleteSecretCmdUsage)
+func updateRSAPrivateKeySecret(cmd *cobra.Command, args []string) {
+	secretId, keyLength, err := createRSAPrivateKeySecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretId := args[0]
+	secretMetaData := fmt.Sprintf("{\"keyLength\": %v}", keyLength)
+	err = apiUpdateSecret(secretId, secret.RSAPrivateKeySecretTypeName, secretMetaData, []byte{})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-	return secretId, nil
+	fmt.Println("Secret updated successfully")
 }
 
-func getSecretCheckUsage(args []string) (string, error) {
-	if len(args) != 1 {
-		return "", fmt.Errorf("Usage: %v", getSecretCmdUsage)
+func updateX509CertificateSecret(cmd *cobra.Command, args []string) {
+	secretId, privateKeyId, commonName, organization, country, err := createX509CertificateSecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretId := args[0]
-
-	return secretId, nil
-}
+	secretMetaData, err := x509CertificateSecretMetaData(privateKeyId, commonName, organization, country)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-func apiCreateDataSecret(secretId, secretData string) (string, error) {
-	return apiCreateSecret(secretId, secret.DataSecretTypeName, "{}", []byte(secretData))
-}
+	err = apiUpdateSecret(secretId, secret.X509CertificateSecretTypeName, secretMetaData, []byte{})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-func apiCreateRSAPrivateKeySecret(secretId string, keyLength int) (string, error) {
-	secretMetaData := fmt.Sprintf("{\"keyLength\": %v}", keyLength)
-	return apiCreateSecret(secretId, secret.RSAPrivateKeySecretTypeName, secretMetaData, []byte{})
+	fmt.Println("Secret updated successfully")
 }
 
-func apiCreateX509CertificateSecret(secretId, privKeyId, commonName, organization, country string) (string, error) {
-	secretMetaData := secret.X509CertificateSecretMetaData{
-		CommonName:         commonName,
-		Organization:       organization,
-		OrganizationalUnit: "",
-		Country:            country,
-		Locality:           "",
-		PrivateKeyId:       privKeyId,
+func secretHistory(cmd *cobra.Command, args []string) {
+	secretId, err := secretHistoryCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretMetaDataBytes, err := json.Marshal(secretMetaData)
+	versionEntries, err := apiListSecretVersions(secretId)
 	if err != nil {
-		return "", err
+		fmt.Println(err.Error())
+		return
 	}
 
-	return apiCreateSecret(secretId, secret.X509CertificateSecretTypeName, string(secretMetaDataBytes), []byte{})
+	s, err := util.JSONPrettyPrint(versionEntries)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println(s)
 }
 
-func apiCreateSecret(secretId, secretType, secretMetaData string, secretData []byte) (string, error) {
-	if Token == "" {
-		return "", fmt.Errorf("authn token is empty")
+func rollbackSecret(cmd *cobra.Command, args []string) {
+	secretId, version, err := rollbackSecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	se := &model.SecretEntry{
-		Id:         secretId,
-		Type:       secretType,
-		MetaData:   secretMetaData,
-		SecretData: secretData,
+	err = apiRollbackSecret(secretId, version)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	body := new(bytes.Buffer)
-	err := json.NewEncoder(body).Encode(se)
+	fmt.Println("Secret rolled back successfully")
+}
+
+func listSecrets(cmd *cobra.Command, args []string) {
+	namespace, err := listSecretsCheckUsage(args)
 	if err != nil {
-		return "", err
+		fmt.Println(err.Error())
+		return
 	}
 
-	secretsUrl := fmt.Sprintf("%v/secrets", Url)
-	req, err := http.NewRequest("POST", secretsUrl, body)
+	listEntries, err := apiListSecrets(namespace, listRecursive)
 	if err != nil {
-		return "", err
+		fmt.Println(err.Error())
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
 
-	client, err := httpClient()
+	printSecretTree(namespace, listEntries)
+}
+
+func searchSecrets(cmd *cobra.Command, args []string) {
+	namespace, selector, err := searchSecretsCheckUsage(args)
 	if err != nil {
-		return "", err
+		fmt.Println(err.Error())
+		return
 	}
 
-	resp, err := client.Do(req)
+	listEntries, err := apiSearchSecrets(namespace, selector)
 	if err != nil {
-		return "", err
+		fmt.Println(err.Error())
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	s, err := util.JSONPrettyPrint(listEntries)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
+	fmt.Println(s)
+}
 
-	var creationResponse model.CreationResponse
-	if err = json.NewDecoder(resp.Body).Decode(&creationResponse); err != nil {
-		return "", err
-	}
-	if len(creationResponse.Id) == 0 {
-		return "", fmt.Errorf("returned id is empty")
+// printSecretTree prints the listed secrets as a tree of namespaces, relying
+// on the listing being ordered by secret id.
+func printSecretTree(namespace string, listEntries []model.SecretListEntry) {
+	root := strings.Trim(namespace, "/")
+	fmt.Println(path.Join("/secrets", root))
+
+	var prevDirs []string
+	for _, listEntry := range listEntries {
+		relId := strings.TrimPrefix(strings.TrimPrefix(listEntry.Id, root), "/")
+		dirs := strings.Split(relId, "/")
+		name := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+
+		common := 0
+		for common < len(dirs) && common < len(prevDirs) && dirs[common] == prevDirs[common] {
+			common++
+		}
+		for depth := common; depth < len(dirs); depth++ {
+			fmt.Printf("%v%v/\n", strings.Repeat("  ", depth+1), dirs[depth])
+		}
+		prevDirs = dirs
+
+		details := listEntry.Type
+		if !listEntry.ExpirationTime.IsZero() {
+			details = fmt.Sprintf("%v, expires %v", details, listEntry.ExpirationTime.Format(time.RFC3339))
+		}
+		fmt.Printf("%v%v (%v)\n", strings.Repeat("  ", len(dirs)+1), name, details)
 	}
-
-	return creationResponse.Id, nil
 }
 
-func apiDeleteSecret(secretId string) error {
-	if Token == "" {
-		return fmt.Errorf("authn token is empty")
+func expiringSecrets(cmd *cobra.Command, args []string) {
+	within, err := expiringSecretsCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	deleteUrl := fmt.Sprintf("%v/secrets/%v", Url, secretId)
-	req, err := http.NewRequest("DELETE", deleteUrl, nil)
+	expirationEntries, err := apiListExpiringSecrets(within)
 	if err != nil {
-		return err
+		fmt.Println(err.Error())
+		return
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
 
-	client, err := httpClient()
+	s, err := util.JSONPrettyPrint(expirationEntries)
 	if err != nil {
-		return err
+		fmt.Println(err.Error())
+		return
 	}
+	fmt.Println(s)
+}
 
-	resp, err := client.Do(req)
+func secretEvents(cmd *cobra.Command, args []string) {
+	since, err := secretEventsCheckUsage(args)
 	if err != nil {
-		return err
+		fmt.Println(err.Error())
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Response status is different than 204 StatusNoContent: %v", resp.Status)
+	eventEntries, err := apiListSecretEvents(time.Now().Add(-since))
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	return nil
+	s, err := util.JSONPrettyPrint(eventEntries)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println(s)
 }
 
-func apiGetSecret(secretId string) (*model.SecretEntry, error) {
-	if Token == "" {
-		return nil, fmt.Errorf("authn token is empty")
+func deleteSecret(cmd *cobra.Command, args []string) {
+	secretId, err := deleteSecretCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
-	getUrl := fmt.Sprintf("%v/secrets/%v", Url, secretId)
-	req, err := http.NewRequest("GET", getUrl, nil)
+	err = apiDeleteSecret(secretId)
 	if err != nil {
-		return nil, err
+		fmt.Println(err.Error())
+		return
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
 
-	client, err := httpClient()
+	fmt.Println("Secret deleted successfully")
+}
+
+func getSecret(cmd *cobra.Command, args []string) {
+	secretId, err := getSecretCheckUsage(args)
 	if err != nil {
-		return nil, err
+		fmt.Println(err.Error())
+		return
 	}
 
-	resp, err := client.Do(req)
+	se, err := apiGetSecretVersion(secretId, secretVersion)
 	if err != nil {
-		return nil, err
+		fmt.Println(err.Error())
+		return
+	}
+
+	s, err := util.JSONPrettyPrint(se)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println(s)
+
+	fmt.Printf("\nDecoded Secret data:\n")
+	fmt.Printf("%s\n", se.SecretData)
+}
+
+func createDataSecretCheckUsage(args []string) (string, string, error) {
+	if len(args) != 2 {
+		return "", "", fmt.Errorf("Usage: %v", createDataSecretCmdUsage)
+	}
+
+	secretId := args[0]
+	secretData := args[1]
+
+	return secretId, secretData, nil
+}
+
+func createRSAPrivateKeySecretCheckUsage(args []string) (string, int, error) {
+	if len(args) != 2 {
+		return "", 0, fmt.Errorf("Usage: %v", createRSAPrivateKeySecretCmdUsage)
+	}
+
+	secretId := args[0]
+	keyLengthStr := args[1]
+
+	keyLength, err := strconv.Atoi(keyLengthStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to convert %s to an int: %v", keyLengthStr, err)
+	}
+
+	return secretId, keyLength, nil
+}
+
+func createDynamicCredentialSecretCheckUsage(args []string) (string, string, error) {
+	if len(args) != 2 {
+		return "", "", fmt.Errorf("Usage: %v", createDynamicCredentialSecretCmdUsage)
+	}
+
+	secretId := args[0]
+	connectionString := args[1]
+
+	return secretId, connectionString, nil
+}
+
+func createCloudAccessKeySecretCheckUsage(args []string) (string, string, error) {
+	if len(args) != 2 {
+		return "", "", fmt.Errorf("Usage: %v", createCloudAccessKeySecretCmdUsage)
+	}
+
+	secretId := args[0]
+	credentialsFile := args[1]
+
+	return secretId, credentialsFile, nil
+}
+
+func createX509CertificateSecretCheckUsage(args []string) (string, string, string, string, string, error) {
+	if len(args) != 5 {
+		return "", "", "", "", "", fmt.Errorf("Usage: %v", createX509CertificateSecretCmdUsage)
+	}
+
+	secretId := args[0]
+	privKeyId := args[1]
+	commonName := args[2]
+	organization := args[3]
+	country := args[4]
+
+	return secretId, privKeyId, commonName, organization, country, nil
+}
+
+func secretHistoryCheckUsage(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Usage: %v", secretHistoryCmdUsage)
+	}
+
+	secretId := args[0]
+
+	return secretId, nil
+}
+
+func rollbackSecretCheckUsage(args []string) (string, int, error) {
+	if len(args) != 2 {
+		return "", 0, fmt.Errorf("Usage: %v", rollbackSecretCmdUsage)
+	}
+
+	secretId := args[0]
+	versionStr := args[1]
+
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to convert %s to an int: %v", versionStr, err)
+	}
+
+	return secretId, version, nil
+}
+
+func rotateSecretCheckUsage(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Usage: %v", rotateSecretCmdUsage)
+	}
+
+	secretId := args[0]
+
+	return secretId, nil
+}
+
+func listSecretsCheckUsage(args []string) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("Usage: %v", listSecretsCmdUsage)
+	}
+
+	namespace := ""
+	if len(args) == 1 {
+		namespace = args[0]
+	}
+
+	return namespace, nil
+}
+
+func searchSecretsCheckUsage(args []string) (string, string, error) {
+	if len(args) > 1 || len(searchSelectors) == 0 {
+		return "", "", fmt.Errorf("Usage: %v --selector key=value", searchSecretsCmdUsage)
+	}
+
+	namespace := ""
+	if len(args) == 1 {
+		namespace = args[0]
+	}
+
+	return namespace, strings.Join(searchSelectors, ","), nil
+}
+
+// parseTags parses tags given as key=value.
+func parseTags(tagArgs []string) (map[string]string, error) {
+	if len(tagArgs) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(tagArgs))
+	for _, tagArg := range tagArgs {
+		parts := strings.SplitN(tagArg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("tag %v is not of the form key=value", tagArg)
+		}
+		tags[parts[0]] = parts[1]
+	}
+
+	return tags, nil
+}
+
+func expiringSecretsCheckUsage(args []string) (time.Duration, error) {
+	if len(args) != 0 {
+		return 0, fmt.Errorf("Usage: %v", expiringSecretsCmdUsage)
+	}
+
+	within, err := time.ParseDuration(expiringWithin)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s as a duration: %v", expiringWithin, err)
+	}
+
+	return within, nil
+}
+
+func secretEventsCheckUsage(args []string) (time.Duration, error) {
+	if len(args) != 0 {
+		return 0, fmt.Errorf("Usage: %v", secretEventsCmdUsage)
+	}
+
+	since, err := time.ParseDuration(eventsSince)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s as a duration: %v", eventsSince, err)
+	}
+
+	return since, nil
+}
+
+func deleteSecretCheckUsage(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Usage: %v", deleteSecretCmdUsage)
+	}
+
+	secretId := args[0]
+
+	return secretId, nil
+}
+
+func getSecretCheckUsage(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Usage: %v", getSecretCmdUsage)
+	}
+
+	secretId := args[0]
+
+	return secretId, nil
+}
+
+func apiCreateDataSecret(secretId, secretData string) (string, error) {
+	return apiCreateSecret(secretId, secret.DataSecretTypeName, "{}", []byte(secretData))
+}
+
+func x509CertificateSecretMetaData(privKeyId, commonName, organization, country string) (string, error) {
+	secretMetaData := certMetaData
+	secretMetaData.CommonName = commonName
+	secretMetaData.Organization = organization
+	secretMetaData.Country = country
+	secretMetaData.PrivateKeyId = privKeyId
+	if certMaxPathLen >= 0 {
+		secretMetaData.MaxPathLen = &certMaxPathLen
+	}
+
+	secretMetaDataBytes, err := json.Marshal(secretMetaData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secretMetaDataBytes), nil
+}
+
+func apiCreateSecret(secretId, secretType, secretMetaData string, secretData []byte) (string, error) {
+	if Token == "" {
+		return "", fmt.Errorf("authn token is empty")
+	}
+
+	tags, err := parseTags(secretTags)
+	if err != nil {
+		return "", err
+	}
+
+	se := &model.SecretEntry{
+		Id:         secretId,
+		Type:       secretType,
+		MetaData:   secretMetaData,
+		SecretData: secretData,
+		Tags:       tags,
+	}
+	if rotationPolicy != (model.RotationPolicy{}) {
+		se.RotationPolicy = &rotationPolicy
+	}
+
+	body := new(bytes.Buffer)
+	err = json.NewEncoder(body).Encode(se)
+	if err != nil {
+		return "", err
+	}
+
+	secretsUrl := fmt.Sprintf("%v/secrets", Url)
+	req, err := http.NewRequest("POST", secretsUrl, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		// invalid fields are detailed by the server
+		var validationErrorResponse util.ValidationErrorResponse
+		if resp.StatusCode == http.StatusBadRequest && json.NewDecoder(resp.Body).Decode(&validationErrorResponse) == nil && len(validationErrorResponse.Fields) > 0 {
+			return "", util.NewValidationError(validationErrorResponse.Fields)
+		}
+		return "", fmt.Errorf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var creationResponse model.CreationResponse
+	if err = json.NewDecoder(resp.Body).Decode(&creationResponse); err != nil {
+		return "", err
+	}
+	if len(creationResponse.Id) == 0 {
+		return "", fmt.Errorf("returned id is empty")
+	}
+
+	return creationResponse.Id, nil
+}
+
+func apiDeleteSecret(secretId string) error {
+	if Token == "" {
+		return fmt.Errorf("authn token is empty")
+	}
+
+	deleteUrl := fmt.Sprintf("%v/secrets/%v", Url, secretId)
+	req, err := http.NewRequest("DELETE", deleteUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("Response status is different than 204 StatusNoContent: %v", resp.Status)
+	}
+
+	return nil
+}
+
+func apiGetSecret(secretId string) (*model.SecretEntry, error) {
+	return apiGetSecretVersion(secretId, 0)
+}
+
+// apiGetSecretVersion gets the given version of a secret; version 0 stands for the current version.
+func apiGetSecretVersion(secretId string, version int) (*model.SecretEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	getUrl := fmt.Sprintf("%v/secrets/%v", Url, secretId)
+	if version != 0 {
+		getUrl = fmt.Sprintf("%v?version=%v", getUrl, version)
+	}
+	req, err := http.NewRequest("GET", getUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var secretEntry model.SecretEntry
+	if err = json.NewDecoder(resp.Body).Decode(&secretEntry); err != nil {
+		return nil, err
+	}
+
+	return &secretEntry, nil
+}
+
+func apiUpdateSecret(secretId, secretType, secretMetaData string, secretData []byte) error {
+	if Token == "" {
+		return fmt.Errorf("authn token is empty")
+	}
+
+	tags, err := parseTags(secretTags)
+	if err != nil {
+		return err
+	}
+
+	se := &model.SecretEntry{
+		Id:         secretId,
+		Type:       secretType,
+		MetaData:   secretMetaData,
+		SecretData: secretData,
+		Tags:       tags,
+	}
+
+	body := new(bytes.Buffer)
+	err = json.NewEncoder(body).Encode(se)
+	if err != nil {
+		return err
+	}
+
+	updateUrl := fmt.Sprintf("%v/secrets/%v", Url, secretId)
+	req, err := http.NewRequest("PUT", updateUrl, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	return nil
+}
+
+func apiListSecretVersions(secretId string) ([]model.SecretVersionEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	versionsUrl := fmt.Sprintf("%v/secret-versions/%v", Url, secretId)
+	req, err := http.NewRequest("GET", versionsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -373,10 +1036,263 @@ func apiGetSecret(secretId string) (*model.SecretEntry, error) {
 		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
 	}
 
-	var secretEntry model.SecretEntry
-	if err = json.NewDecoder(resp.Body).Decode(&secretEntry); err != nil {
+	var versionEntries []model.SecretVersionEntry
+	if err = json.NewDecoder(resp.Body).Decode(&versionEntries); err != nil {
 		return nil, err
 	}
 
-	return &secretEntry, nil
+	return versionEntries, nil
+}
+
+func apiGetSecretRotation(secretId string) (*model.RotationEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	rotationUrl := fmt.Sprintf("%v/secret-rotations/%v", Url, secretId)
+	req, err := http.NewRequest("GET", rotationUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var rotationEntry model.RotationEntry
+	if err = json.NewDecoder(resp.Body).Decode(&rotationEntry); err != nil {
+		return nil, err
+	}
+
+	return &rotationEntry, nil
+}
+
+func apiRollbackSecret(secretId string, version int) error {
+	if Token == "" {
+		return fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(&model.RollbackRequest{Version: version})
+	if err != nil {
+		return err
+	}
+
+	versionsUrl := fmt.Sprintf("%v/secret-versions/%v", Url, secretId)
+	req, err := http.NewRequest("POST", versionsUrl, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	return nil
+}
+
+func apiListExpiringSecrets(within time.Duration) ([]model.SecretExpirationEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	expiringUrl := fmt.Sprintf("%v/expiring-secrets?within=%v", Url, within)
+	req, err := http.NewRequest("GET", expiringUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var expirationEntries []model.SecretExpirationEntry
+	if err = json.NewDecoder(resp.Body).Decode(&expirationEntries); err != nil {
+		return nil, err
+	}
+
+	return expirationEntries, nil
+}
+
+func apiListSecretEvents(since time.Time) ([]model.SecretEventEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	query := url.Values{}
+	query.Set("since", since.Format(time.RFC3339))
+
+	eventsUrl := fmt.Sprintf("%v/secret-events?%v", Url, query.Encode())
+	req, err := http.NewRequest("GET", eventsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var eventEntries []model.SecretEventEntry
+	if err = json.NewDecoder(resp.Body).Decode(&eventEntries); err != nil {
+		return nil, err
+	}
+
+	return eventEntries, nil
+}
+
+func apiSearchSecrets(namespace, selector string) ([]model.SecretListEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	query := url.Values{}
+	query.Set("prefix", namespace)
+	query.Set("selector", selector)
+
+	searchUrl := fmt.Sprintf("%v/secret-search?%v", Url, query.Encode())
+	req, err := http.NewRequest("GET", searchUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var listEntries []model.SecretListEntry
+	if err = json.NewDecoder(resp.Body).Decode(&listEntries); err != nil {
+		return nil, err
+	}
+
+	return listEntries, nil
+}
+
+func apiListSecrets(namespace string, recursive bool) ([]model.SecretListEntry, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	query := url.Values{}
+	query.Set("prefix", namespace)
+	query.Set("recursive", strconv.FormatBool(recursive))
+
+	listUrl := fmt.Sprintf("%v/secrets?%v", Url, query.Encode())
+	req, err := http.NewRequest("GET", listUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var listEntries []model.SecretListEntry
+	if err = json.NewDecoder(resp.Body).Decode(&listEntries); err != nil {
+		return nil, err
+	}
+
+	return listEntries, nil
+}
+
+func apiRotateSecret(secretId string) error {
+	if Token == "" {
+		return fmt.Errorf("authn token is empty")
+	}
+
+	rotateUrl := fmt.Sprintf("%v/secret-rotations/%v", Url, secretId)
+	req, err := http.NewRequest("POST", rotateUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	return nil
 }
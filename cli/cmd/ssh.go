@@ -0,0 +1,138 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+const (
+	sshCmdUsage     = "ssh [sub-command]"
+	sshSignCmdUsage = "sign ca-id public-key-file"
+)
+
+var sshSignRequest model.SSHSignRequest
+var sshCertFile string
+
+func init() {
+	sshSignCmd.Flags().StringSliceVarP(&sshSignRequest.Principals, "principals", "n", nil, "comma-separated principals (user or host names) the certificate is valid for")
+	sshSignCmd.Flags().StringVar(&sshSignRequest.CertType, "cert-type", "", "certificate type: user or host (default: user)")
+	sshSignCmd.Flags().StringVarP(&sshSignRequest.KeyId, "key-id", "I", "", "certificate key id (default: the first principal)")
+	sshSignCmd.Flags().StringVarP(&sshSignRequest.TTL, "ttl", "V", "", "certificate validity, e.g. 1h (default: the CA's maximal validity)")
+	sshSignCmd.Flags().StringVarP(&sshCertFile, "output", "o", "", "file to write the certificate to (default: standard output)")
+
+	sshCmd.AddCommand(sshSignCmd)
+
+	RootCmd.AddCommand(sshCmd)
+}
+
+var sshCmd = &cobra.Command{
+	Use:   sshCmdUsage,
+	Short: "SSH certificate authority",
+	Long:  "Sign SSH public keys using a ssh-certificate-authority secret",
+}
+
+var sshSignCmd = &cobra.Command{
+	Use:   sshSignCmdUsage,
+	Short: "Sign a SSH public key",
+	Long:  "Sign a SSH public key (in authorized_keys format) using a ssh-certificate-authority secret",
+	Run:   sshSign,
+}
+
+func sshSign(cmd *cobra.Command, args []string) {
+	signRequest, err := sshSignCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	signResponse, err := apiSSHSign(signRequest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if sshCertFile == "" {
+		fmt.Print(signResponse.Certificate)
+		return
+	}
+
+	if err := ioutil.WriteFile(sshCertFile, []byte(signResponse.Certificate), 0644); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Public key signed successfully")
+	fmt.Printf("Serial: %v\n", signResponse.Serial)
+	fmt.Printf("Valid before: %v\n", signResponse.ValidBefore)
+}
+
+func sshSignCheckUsage(args []string) (*model.SSHSignRequest, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("Usage: %v", sshSignCmdUsage)
+	}
+
+	if len(sshSignRequest.Principals) == 0 {
+		return nil, fmt.Errorf("at least one principal is required")
+	}
+
+	publicKey, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	signRequest := sshSignRequest
+	signRequest.CaId = args[0]
+	signRequest.PublicKey = string(publicKey)
+
+	return &signRequest, nil
+}
+
+func apiSSHSign(signRequest *model.SSHSignRequest) (*model.SSHSignResponse, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(signRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	signUrl := fmt.Sprintf("%v/ssh/sign", Url)
+	req, err := http.NewRequest("POST", signUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var signResponse model.SSHSignResponse
+	if err = json.NewDecoder(resp.Body).Decode(&signResponse); err != nil {
+		return nil, err
+	}
+
+	return &signResponse, nil
+}
@@ -0,0 +1,204 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+const (
+	wrapCmdUsage   = "wrap [secret-id]"
+	unwrapCmdUsage = "unwrap token"
+)
+
+var wrapData string
+var wrapFile string
+var wrapTTL string
+var unwrappedFile string
+
+func init() {
+	wrapCmd.Flags().StringVarP(&wrapData, "data", "d", "", "literal to wrap, rather than a secret")
+	wrapCmd.Flags().StringVarP(&wrapFile, "file", "f", "", "file whose content to wrap, rather than a secret")
+	wrapCmd.Flags().StringVar(&wrapTTL, "ttl", "", "period within which the token must be unwrapped, e.g. 1h (default: 15m)")
+	unwrapCmd.Flags().StringVarP(&unwrappedFile, "output", "o", "", "file to write the unwrapped value to (default: standard output)")
+
+	RootCmd.AddCommand(wrapCmd)
+	RootCmd.AddCommand(unwrapCmd)
+}
+
+var wrapCmd = &cobra.Command{
+	Use:   wrapCmdUsage,
+	Short: "Wrap a secret for one-time retrieval",
+	Long:  "Wrap a secret (or a literal, using --data or --file) and print a single-use token for retrieving it",
+	Run:   wrap,
+}
+
+var unwrapCmd = &cobra.Command{
+	Use:   unwrapCmdUsage,
+	Short: "Unwrap a wrapped secret",
+	Long:  "Retrieve a wrapped secret using its single-use token; no VSM account is needed",
+	Run:   unwrap,
+}
+
+func wrap(cmd *cobra.Command, args []string) {
+	wrapRequest, err := wrapCheckUsage(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	wrapResponse, err := apiWrap(wrapRequest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Wrapped successfully")
+	fmt.Printf("Token: %v\n", wrapResponse.Token)
+	fmt.Printf("Expiration time: %v\n", wrapResponse.ExpirationTime)
+}
+
+func wrapCheckUsage(args []string) (*model.WrapRequest, error) {
+	wrapRequest := &model.WrapRequest{TTL: wrapTTL}
+
+	sources := 0
+	if len(args) == 1 {
+		wrapRequest.SecretId = args[0]
+		sources++
+	}
+	if wrapData != "" {
+		wrapRequest.Data = []byte(wrapData)
+		sources++
+	}
+	if wrapFile != "" {
+		data, err := ioutil.ReadFile(wrapFile)
+		if err != nil {
+			return nil, err
+		}
+		wrapRequest.Data = data
+		sources++
+	}
+
+	if len(args) > 1 || sources != 1 {
+		return nil, fmt.Errorf("Usage: %v (either a secret id, --data or --file)", wrapCmdUsage)
+	}
+
+	return wrapRequest, nil
+}
+
+func unwrap(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Usage: %v\n", unwrapCmdUsage)
+		return
+	}
+
+	unwrapResponse, err := apiUnwrap(&model.UnwrapRequest{Token: args[0]})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	if unwrappedFile == "" {
+		fmt.Print(string(unwrapResponse.Data))
+		return
+	}
+
+	if err := ioutil.WriteFile(unwrappedFile, unwrapResponse.Data, 0600); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Unwrapped successfully")
+	if unwrapResponse.SecretId != "" {
+		fmt.Printf("Secret id: %v (version %v)\n", unwrapResponse.SecretId, unwrapResponse.SecretVersion)
+	}
+}
+
+func apiWrap(wrapRequest *model.WrapRequest) (*model.WrapResponse, error) {
+	if Token == "" {
+		return nil, fmt.Errorf("authn token is empty")
+	}
+
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(wrapRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%v/sys/wrapping/wrap", Url), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", Token))
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var wrapResponse model.WrapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&wrapResponse); err != nil {
+		return nil, err
+	}
+
+	return &wrapResponse, nil
+}
+
+// apiUnwrap unwraps a token; unlike other requests, it needs no authn token.
+func apiUnwrap(unwrapRequest *model.UnwrapRequest) (*model.UnwrapResponse, error) {
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(unwrapRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%v/sys/wrapping/unwrap", Url), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("The token was already unwrapped: the wrapped value may have been intercepted, so report this to whoever wrapped it")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var unwrapResponse model.UnwrapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&unwrapResponse); err != nil {
+		return nil, err
+	}
+
+	return &unwrapResponse, nil
+}
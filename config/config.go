@@ -2,12 +2,18 @@
 // SPDX-License-Identifier: BSD-2-Clause
 package config
 
+import (
+	"time"
+)
+
 var DefaultConfigFile = "config.yaml"
 
 type Config struct {
 	ServerConfig          `yaml:"server"`
 	DataStoreConfig       `yaml:"dataStore"`
 	VirtualKeyStoreConfig `yaml:"virtualKeyStore"`
+	SecretsConfig         `yaml:"secrets"`
+	PKIConfig             `yaml:"pki"`
 }
 
 type ServerConfig struct {
@@ -46,3 +52,20 @@ type KeyStoreConfig struct {
 	StoreType        string `yaml:"type"`
 	ConnectionString string `yaml:"connectionString"`
 }
+
+type SecretsConfig struct {
+	MaxVersions           int           `yaml:"maxVersions"`
+	ExpirationGracePeriod time.Duration `yaml:"expirationGracePeriod"`
+	ReaperInterval        time.Duration `yaml:"reaperInterval"`
+	EventRetention        time.Duration `yaml:"eventRetention"`
+	RotationInterval      time.Duration `yaml:"rotationInterval"`
+	// Endpoints (e.g. http://iam.example.com:9000), other than the cloud providers'
+	// default ones, which cloud access key secrets may use
+	CloudProviderEndpoints []string `yaml:"cloudProviderEndpoints,omitempty"`
+}
+
+type PKIConfig struct {
+	BaseUrl         string        `yaml:"baseUrl"`
+	CRLInterval     time.Duration `yaml:"crlInterval"`
+	RenewalInterval time.Duration `yaml:"renewalInterval"`
+}
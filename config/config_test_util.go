@@ -2,6 +2,10 @@
 // SPDX-License-Identifier: BSD-2-Clause
 package config
 
+import (
+	"time"
+)
+
 func GenerateTestConfig() *Config {
 	config := &Config{
 		ServerConfig: ServerConfig{
@@ -32,6 +36,17 @@ func GenerateTestConfig() *Config {
 				KeyStoreConfig{StoreType: "InMemoryKeyStore", ConnectionString: "mem3"},
 			},
 		},
+		SecretsConfig: SecretsConfig{
+			MaxVersions:           3,
+			ExpirationGracePeriod: time.Hour,
+			ReaperInterval:        time.Hour,
+			RotationInterval:      time.Hour,
+		},
+		PKIConfig: PKIConfig{
+			BaseUrl:         "https://localhost:8443",
+			CRLInterval:     time.Hour,
+			RenewalInterval: time.Hour,
+		},
 	}
 
 	return config
@@ -0,0 +1,49 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+func encryptAESGCM(data []byte, key []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepend random nonce to ciphertext
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, additionalData), nil
+}
+
+func decryptAESGCM(ciphertext []byte, key []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], additionalData)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
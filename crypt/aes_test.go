@@ -39,3 +39,37 @@ func TestAES(t *testing.T) {
 	}
 
 }
+
+func TestAESGCM(t *testing.T) {
+	data := []byte("this is some message we would like to encrypt and authenticate")
+	additionalData := []byte("tenant-1")
+
+	key, _ := GenerateKey()
+
+	encrypted, err := EncryptAuthenticated(data, key, additionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(encrypted, data) {
+		t.Fatal("Encrypted contains input")
+	}
+
+	decrypted, err := DecryptAuthenticated(encrypted, key, additionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("Decrypted data differs from input (expected: %v, actual: %v)", data, decrypted)
+	}
+
+	if _, err := DecryptAuthenticated(encrypted, key, []byte("tenant-2")); err == nil {
+		t.Fatal("Decrypted using different additional data")
+	}
+
+	encrypted[len(encrypted)-1] ^= 0xff
+	if _, err := DecryptAuthenticated(encrypted, key, additionalData); err == nil {
+		t.Fatal("Decrypted tampered ciphertext")
+	}
+}
@@ -13,3 +13,26 @@ func Encrypt(data []byte, key []byte) ([]byte, error) {
 func Decrypt(data []byte, key []byte) ([]byte, error) {
 	return decryptAES(data, key)
 }
+
+// EncryptAuthenticated encrypts data using an authenticated cipher, binding the
+// ciphertext to additionalData: decrypting it requires the same additional data.
+func EncryptAuthenticated(data []byte, key []byte, additionalData []byte) ([]byte, error) {
+	return encryptAESGCM(data, key, additionalData)
+}
+
+// DecryptAuthenticated decrypts data encrypted by EncryptAuthenticated, failing if
+// either the ciphertext or the additional data were tampered with.
+func DecryptAuthenticated(data []byte, key []byte, additionalData []byte) ([]byte, error) {
+	return decryptAESGCM(data, key, additionalData)
+}
+
+// MAC returns the HMAC-SHA256 of data using key.
+func MAC(data []byte, key []byte) []byte {
+	return hmacSHA256(data, key)
+}
+
+// DeriveKey derives a key of the given length from key using HKDF-SHA256. Keys derived
+// using different info (e.g. tenant names) are independent of each other.
+func DeriveKey(key []byte, salt []byte, info []byte, length int) ([]byte, error) {
+	return hkdfSHA256(key, salt, info, length)
+}
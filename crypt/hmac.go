@@ -0,0 +1,27 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func hmacSHA256(data []byte, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+func hkdfSHA256(key []byte, salt []byte, info []byte, length int) ([]byte, error) {
+	derivedKey := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, info), derivedKey); err != nil {
+		return nil, err
+	}
+
+	return derivedKey, nil
+}
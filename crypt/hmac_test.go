@@ -0,0 +1,36 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package crypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 4231, test case 2
+func TestMAC(t *testing.T) {
+	expected, _ := hex.DecodeString("5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843")
+
+	mac := MAC([]byte("what do ya want for nothing?"), []byte("Jefe"))
+	if !bytes.Equal(mac, expected) {
+		t.Fatalf("Unexpected MAC (expected: %x, actual: %x)", expected, mac)
+	}
+}
+
+// RFC 5869, test case 1
+func TestDeriveKey(t *testing.T) {
+	key, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	expected, _ := hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+
+	derivedKey, err := DeriveKey(key, salt, info, len(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(derivedKey, expected) {
+		t.Fatalf("Unexpected derived key (expected: %x, actual: %x)", expected, derivedKey)
+	}
+}
@@ -4,6 +4,7 @@ package crypt
 
 import (
 	"crypto/rand"
+	"fmt"
 	"math/big"
 )
 
@@ -16,3 +17,17 @@ func randPrime(numBits int) *big.Int {
 	res, _ := rand.Prime(rand.Reader, numBits)
 	return res
 }
+
+// RandIndex returns a uniformly distributed random integer in [0, n).
+func RandIndex(n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid random index limit: %v", n)
+	}
+
+	res, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(res.Int64()), nil
+}
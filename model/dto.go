@@ -2,6 +2,10 @@
 // SPDX-License-Identifier: BSD-2-Clause
 package model
 
+import (
+	"time"
+)
+
 type CreationResponse struct {
 	Id string `json:"id"`
 }
@@ -14,3 +18,249 @@ type LoginRequest struct {
 type LoginResponse struct {
 	ChallengeOrToken string `json:"challengeOrToken"`
 }
+
+type RollbackRequest struct {
+	Version int `json:"version"`
+}
+
+// SSHSignRequest asks a SSH certificate authority to sign a public key.
+type SSHSignRequest struct {
+	CaId            string            `json:"caId"`
+	PublicKey       string            `json:"publicKey"`
+	CertType        string            `json:"certType"`
+	KeyId           string            `json:"keyId"`
+	Principals      []string          `json:"principals"`
+	TTL             string            `json:"ttl"`
+	CriticalOptions map[string]string `json:"criticalOptions"`
+	Extensions      map[string]string `json:"extensions"`
+}
+
+type SSHSignResponse struct {
+	Certificate string    `json:"certificate"`
+	Serial      uint64    `json:"serial"`
+	ValidBefore time.Time `json:"validBefore"`
+}
+
+// CSRSignRequest asks the CA to issue a certificate for a PKCS#10 certificate signing
+// request; the certificate is stored as a X509Certificate secret with the given id.
+type CSRSignRequest struct {
+	Id             string    `json:"id"`
+	CSR            string    `json:"csr"`
+	Owner          string    `json:"owner"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	TTL            string    `json:"ttl"`
+	KeyUsages      []string  `json:"keyUsages"`
+	ExtKeyUsages   []string  `json:"extKeyUsages"`
+	IsCA           bool      `json:"isCA"`
+	MaxPathLen     *int      `json:"maxPathLen,omitempty"`
+}
+
+type CSRSignResponse struct {
+	Id          string `json:"id"`
+	Certificate string `json:"certificate"`
+}
+
+// RevocationRequest asks to revoke a certificate, identified either by its
+// (hex-encoded) serial number or by the id of the certificate secret.
+type RevocationRequest struct {
+	SerialNumber string `json:"serialNumber"`
+	SecretId     string `json:"secretId"`
+	Reason       string `json:"reason"`
+}
+
+// Hash algorithms of the digests which may be signed, and signature algorithms of RSA keys.
+const (
+	HashAlgorithmSHA256 = "sha256"
+	HashAlgorithmSHA384 = "sha384"
+	HashAlgorithmSHA512 = "sha512"
+
+	SignatureAlgorithmPKCS1v15 = "pkcs1v15"
+	SignatureAlgorithmPSS      = "pss"
+)
+
+// SignRequest asks to sign a digest using a private key secret. Ed25519 keys sign
+// messages rather than digests, so for them Input is the message itself.
+type SignRequest struct {
+	KeyId              string `json:"keyId"`
+	Input              []byte `json:"input"`
+	HashAlgorithm      string `json:"hashAlgorithm"`
+	SignatureAlgorithm string `json:"signatureAlgorithm"`
+}
+
+type SignResponse struct {
+	KeyId      string `json:"keyId"`
+	KeyVersion int    `json:"keyVersion"`
+	Signature  []byte `json:"signature"`
+}
+
+// VerifyRequest asks to verify a signature using the given version of a private key
+// secret (default: its current version).
+type VerifyRequest struct {
+	KeyId              string `json:"keyId"`
+	KeyVersion         int    `json:"keyVersion"`
+	Input              []byte `json:"input"`
+	HashAlgorithm      string `json:"hashAlgorithm"`
+	SignatureAlgorithm string `json:"signatureAlgorithm"`
+	Signature          []byte `json:"signature"`
+}
+
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// EncryptRequest asks to encrypt data using a symmetric key secret. The ciphertext is
+// bound to the optional context, which must be provided again in order to decrypt it.
+type EncryptRequest struct {
+	KeyId     string `json:"keyId"`
+	Plaintext []byte `json:"plaintext"`
+	Context   []byte `json:"context"`
+}
+
+// EncryptResponse holds a ciphertext, which identifies the version of the key that
+// produced it, e.g. "vsm:v2:<base64-encoded data>".
+type EncryptResponse struct {
+	KeyId      string `json:"keyId"`
+	KeyVersion int    `json:"keyVersion"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type DecryptRequest struct {
+	KeyId      string `json:"keyId"`
+	Ciphertext string `json:"ciphertext"`
+	Context    []byte `json:"context"`
+}
+
+type DecryptResponse struct {
+	Plaintext []byte `json:"plaintext"`
+}
+
+// RewrapRequest asks to re-encrypt a ciphertext using the current version of its key,
+// without revealing the plaintext.
+type RewrapRequest struct {
+	KeyId      string `json:"keyId"`
+	Ciphertext string `json:"ciphertext"`
+	Context    []byte `json:"context"`
+}
+
+// HMACRequest asks to compute the HMAC-SHA256 of an input using the given version of an
+// HMAC key secret (its current version, if unspecified).
+type HMACRequest struct {
+	KeyId      string `json:"keyId"`
+	KeyVersion int    `json:"keyVersion"`
+	Input      []byte `json:"input"`
+}
+
+type HMACResponse struct {
+	KeyId      string `json:"keyId"`
+	KeyVersion int    `json:"keyVersion"`
+	HMAC       []byte `json:"hmac"`
+}
+
+// DeriveKeyRequest asks to derive a subkey of the given length (default: 32 bytes) from
+// the given version of an HMAC key secret (its current version, if unspecified) using
+// HKDF-SHA256. The same salt and info (e.g. a tenant name) always derive the same subkey.
+type DeriveKeyRequest struct {
+	KeyId      string `json:"keyId"`
+	KeyVersion int    `json:"keyVersion"`
+	Salt       []byte `json:"salt"`
+	Info       []byte `json:"info"`
+	Length     int    `json:"length"`
+}
+
+type DeriveKeyResponse struct {
+	KeyId      string `json:"keyId"`
+	KeyVersion int    `json:"keyVersion"`
+	DerivedKey []byte `json:"derivedKey"`
+}
+
+// WrapRequest asks to wrap either the current data of a secret or the given data for a
+// single retrieval, within the given TTL (default: 15m).
+type WrapRequest struct {
+	SecretId string `json:"secretId"`
+	Data     []byte `json:"data"`
+	TTL      string `json:"ttl"`
+}
+
+type WrapResponse struct {
+	Token          string    `json:"token"`
+	ExpirationTime time.Time `json:"expirationTime"`
+}
+
+type UnwrapRequest struct {
+	Token string `json:"token"`
+}
+
+// UnwrapResponse holds an unwrapped value, and the secret it was taken from (if any).
+type UnwrapResponse struct {
+	SecretId      string `json:"secretId"`
+	SecretVersion int    `json:"secretVersion"`
+	Data          []byte `json:"data"`
+}
+
+// RenewLeaseRequest asks to extend a lease by the given increment from now (default: the
+// lease period of the secret which issued it), within the secret's maximal lease period.
+type RenewLeaseRequest struct {
+	LeaseId   string `json:"leaseId"`
+	Increment string `json:"increment"`
+}
+
+type RenewLeaseResponse struct {
+	LeaseId        string    `json:"leaseId"`
+	ExpirationTime time.Time `json:"expirationTime"`
+}
+
+// RevokeLeasesRequest asks to revoke either a single lease, or all the leases of secrets
+// under the given prefix (a secret id or a namespace, e.g. team-a), held by the given
+// owner, or both.
+type RevokeLeasesRequest struct {
+	LeaseId string `json:"leaseId"`
+	Prefix  string `json:"prefix"`
+	Owner   string `json:"owner"`
+}
+
+type RevokeLeasesResponse struct {
+	LeaseIds []string `json:"leaseIds"`
+}
+
+// Maximal number of secrets created by a single batch request.
+const MaxSecretBatchSize = 1000
+
+// Policies for secrets of a batch which already exist.
+const (
+	ConflictPolicySkip      = "skip"
+	ConflictPolicyOverwrite = "overwrite"
+	ConflictPolicyFail      = "fail"
+)
+
+// Outcomes of the secrets of a batch. Planned outcomes are reported for dry runs.
+const (
+	BatchActionCreate = "create"
+	BatchActionUpdate = "update"
+	BatchActionSkip   = "skip"
+	BatchActionFail   = "fail"
+)
+
+// SecretBatchRequest asks to create several secrets. Secrets which already exist are
+// skipped, overwritten (i.e. updated with a new version), or fail the whole batch before
+// any secret is created, per the conflict policy (default: fail). A dry run reports what
+// would be done, without doing it.
+type SecretBatchRequest struct {
+	Secrets        []SecretEntry `json:"secrets"`
+	ConflictPolicy string        `json:"conflictPolicy"`
+	DryRun         bool          `json:"dryRun"`
+}
+
+type SecretBatchResponse struct {
+	DryRun  bool                 `json:"dryRun"`
+	Results []*SecretBatchResult `json:"results"`
+	Created int                  `json:"created"`
+	Updated int                  `json:"updated"`
+	Skipped int                  `json:"skipped"`
+	Failed  int                  `json:"failed"`
+}
+
+type SecretBatchResult struct {
+	Id     string `json:"id"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
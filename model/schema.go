@@ -0,0 +1,226 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// Types of the values described by schemas.
+const (
+	SchemaTypeObject  = "object"
+	SchemaTypeArray   = "array"
+	SchemaTypeString  = "string"
+	SchemaTypeInteger = "integer"
+	SchemaTypeNumber  = "number"
+	SchemaTypeBoolean = "boolean"
+)
+
+// Formats of string values described by schemas.
+const (
+	// A positive duration, e.g. 720h
+	SchemaFormatDuration = "duration"
+)
+
+// Schema describes the JSON values accepted as, e.g., the metadata of a secret type,
+// using a subset of JSON Schema. A null value or an empty string stands for a field
+// which is not set, which only required fields reject; fields of objects which aren't
+// described by their schema are rejected unless AdditionalProperties is set.
+type Schema struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// Set by the server, rather than by clients
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// Objects
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties bool               `json:"additionalProperties,omitempty"`
+
+	// Arrays
+	Items *Schema `json:"items,omitempty"`
+
+	// Strings
+	Enum      []string `json:"enum,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+
+	// Numbers and integers
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+}
+
+// SchemaBound returns a bound of a number, e.g. a Schema's Minimum.
+func SchemaBound(bound float64) *float64 {
+	return &bound
+}
+
+// ValidateJSON validates a JSON document, the value of the given field of a request,
+// against a schema. An empty document stands for an empty object. It returns a
+// *util.ValidationError detailing the invalid fields.
+func (schema *Schema) ValidateJSON(field, document string) error {
+	if strings.TrimSpace(document) == "" {
+		document = "{}"
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(document)))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return util.NewValidationError([]util.FieldError{{Field: field, Message: "should be a JSON document"}})
+	}
+
+	if fieldErrors := schema.Validate(field, value); len(fieldErrors) > 0 {
+		return util.NewValidationError(fieldErrors)
+	}
+
+	return nil
+}
+
+// Validate validates a value decoded from JSON (using json.Number for numbers) against
+// a schema, returning the invalid fields. The value's field name is given by field.
+func (schema *Schema) Validate(field string, value interface{}) []util.FieldError {
+	if value == nil {
+		return nil
+	}
+
+	invalid := func(format string, args ...interface{}) []util.FieldError {
+		return []util.FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch schema.Type {
+	case SchemaTypeObject:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("should be an object")
+		}
+		return schema.validateObject(field, object)
+
+	case SchemaTypeArray:
+		array, ok := value.([]interface{})
+		if !ok {
+			return invalid("should be an array")
+		}
+		fieldErrors := make([]util.FieldError, 0)
+		if schema.Items != nil {
+			for i, item := range array {
+				fieldErrors = append(fieldErrors, schema.Items.Validate(fmt.Sprintf("%v[%v]", field, i), item)...)
+			}
+		}
+		return fieldErrors
+
+	case SchemaTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return invalid("should be a string")
+		}
+		return schema.validateString(field, s)
+
+	case SchemaTypeInteger, SchemaTypeNumber:
+		n, ok := value.(json.Number)
+		if !ok {
+			return invalid("should be a number")
+		}
+		if schema.Type == SchemaTypeInteger {
+			if _, err := n.Int64(); err != nil {
+				return invalid("should be an integer")
+			}
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return invalid("should be a number")
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return invalid("should be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return invalid("should be at most %v", *schema.Maximum)
+		}
+		return nil
+
+	case SchemaTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return invalid("should be a boolean")
+		}
+		return nil
+	}
+
+	return nil
+}
+
+func (schema *Schema) validateObject(field string, object map[string]interface{}) []util.FieldError {
+	fieldErrors := make([]util.FieldError, 0)
+
+	for _, name := range schema.Required {
+		if value, ok := object[name]; !ok || value == nil || value == "" {
+			fieldErrors = append(fieldErrors, util.FieldError{Field: joinSchemaField(field, name), Message: "is required"})
+		}
+	}
+
+	// fields are validated in order, for the errors to be reported consistently
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertySchema, ok := schema.Properties[name]
+		if !ok {
+			if !schema.AdditionalProperties {
+				fieldErrors = append(fieldErrors, util.FieldError{Field: joinSchemaField(field, name), Message: "is not a known field"})
+			}
+			continue
+		}
+		fieldErrors = append(fieldErrors, propertySchema.Validate(joinSchemaField(field, name), object[name])...)
+	}
+
+	return fieldErrors
+}
+
+func (schema *Schema) validateString(field string, s string) []util.FieldError {
+	if s == "" {
+		return nil
+	}
+
+	invalid := func(format string, args ...interface{}) []util.FieldError {
+		return []util.FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if len(schema.Enum) > 0 && !containsString(schema.Enum, s) {
+		return invalid("should be one of: %v", strings.Join(schema.Enum, ", "))
+	}
+	if schema.MaxLength > 0 && len(s) > schema.MaxLength {
+		return invalid("should be at most %v characters long", schema.MaxLength)
+	}
+	if schema.Pattern != "" {
+		if re, err := regexp.Compile(schema.Pattern); err == nil && !re.MatchString(s) {
+			return invalid("should match %v", schema.Pattern)
+		}
+	}
+	if schema.Format == SchemaFormatDuration {
+		if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+			return invalid("should be a positive duration, e.g. 720h")
+		}
+	}
+
+	return nil
+}
+
+func joinSchemaField(field, name string) string {
+	if field == "" {
+		return name
+	}
+
+	return field + "." + name
+}
@@ -0,0 +1,161 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// Maximal number of tags of a secret or a namespace.
+const MaxTags = 32
+
+// Tag keys and values are restricted to characters which can't be confused with the
+// syntax of tag selectors.
+var (
+	tagKeyRegexp   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+	tagValueRegexp = regexp.MustCompile(`^[A-Za-z0-9._/-]{0,63}$`)
+	tagInRegexp    = regexp.MustCompile(`^(\S+)\s+in\s+\((.*)\)$`)
+)
+
+// Operators of tag selector requirements.
+const (
+	TagOpEquals    = "="
+	TagOpNotEquals = "!="
+	TagOpIn        = "in"
+)
+
+// TagRequirement is a requirement of a tag selector, e.g. env=prod, env!=prod or
+// env in (prod,staging). A != requirement is met by entries which don't have the tag.
+type TagRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return util.ErrInputValidation
+	}
+
+	for key, value := range tags {
+		if !tagKeyRegexp.MatchString(key) || !tagValueRegexp.MatchString(value) {
+			return util.ErrInputValidation
+		}
+	}
+
+	return nil
+}
+
+// ParseTagSelector parses a comma-separated list of tag requirements, all of which
+// should be met by matching entries, e.g. "env=prod,team in (a,b),app!=legacy".
+func ParseTagSelector(selector string) ([]TagRequirement, error) {
+	requirements := make([]TagRequirement, 0)
+
+	for _, part := range splitTagSelector(selector) {
+		part = strings.TrimSpace(part)
+
+		var requirement TagRequirement
+		if match := tagInRegexp.FindStringSubmatch(part); match != nil {
+			requirement = TagRequirement{Key: match[1], Operator: TagOpIn}
+			for _, value := range strings.Split(match[2], ",") {
+				requirement.Values = append(requirement.Values, strings.TrimSpace(value))
+			}
+		} else if i := strings.Index(part, TagOpNotEquals); i >= 0 {
+			requirement = TagRequirement{
+				Key:      strings.TrimSpace(part[:i]),
+				Operator: TagOpNotEquals,
+				Values:   []string{strings.TrimSpace(part[i+len(TagOpNotEquals):])},
+			}
+		} else if i := strings.Index(part, TagOpEquals); i >= 0 {
+			requirement = TagRequirement{
+				Key:      strings.TrimSpace(part[:i]),
+				Operator: TagOpEquals,
+				Values:   []string{strings.TrimSpace(part[i+len(TagOpEquals):])},
+			}
+		} else {
+			return nil, util.ErrInputValidation
+		}
+
+		if !tagKeyRegexp.MatchString(requirement.Key) {
+			return nil, util.ErrInputValidation
+		}
+		for _, value := range requirement.Values {
+			if !tagValueRegexp.MatchString(value) {
+				return nil, util.ErrInputValidation
+			}
+		}
+
+		requirements = append(requirements, requirement)
+	}
+
+	if len(requirements) == 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	return requirements, nil
+}
+
+// TagsMatch reports whether the given tags meet all the requirements.
+func TagsMatch(requirements []TagRequirement, tags map[string]string) bool {
+	for _, requirement := range requirements {
+		value, ok := tags[requirement.Key]
+
+		switch requirement.Operator {
+		case TagOpEquals:
+			if !ok || value != requirement.Values[0] {
+				return false
+			}
+		case TagOpNotEquals:
+			if ok && value == requirement.Values[0] {
+				return false
+			}
+		case TagOpIn:
+			if !ok || !containsString(requirement.Values, value) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitTagSelector splits a selector at the commas which aren't within the value list
+// of an in requirement.
+func splitTagSelector(selector string) []string {
+	parts := make([]string, 0)
+	if strings.TrimSpace(selector) == "" {
+		return parts
+	}
+
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, selector[start:])
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
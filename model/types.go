@@ -24,13 +24,201 @@ type SecretEntry struct {
 	SecretData     []byte    `json:"secretData"`
 	Owner          string    `json:"owner"`
 	ExpirationTime time.Time `json:"expirationTime"`
+	Version        int       `json:"version"`
+	// Only for secret types whose data is generated by the server
+	RotationPolicy *RotationPolicy `json:"rotationPolicy,omitempty"`
+	// User-defined labels, e.g. {"env": "prod"}
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// RotationPolicy schedules the automatic rotation of a secret. Exactly one of its fields
+// is set; a policy none of whose fields is set removes a secret's policy when updating it.
+type RotationPolicy struct {
+	// Rotate at fixed intervals, e.g. 720h
+	Interval string `json:"interval,omitempty"`
+	// Rotate at the times matched by a cron expression (minute, hour, day of month,
+	// month and day of week, in UTC), e.g. "0 3 * * 0"
+	Cron string `json:"cron,omitempty"`
+	// Rotate once the current version is older than this, e.g. 2160h; rotating a secret
+	// on demand postpones its next scheduled rotation
+	MaxAge string `json:"maxAge,omitempty"`
+}
+
+// RotationEntry tracks the scheduled rotations of a secret which has a rotation policy.
+type RotationEntry struct {
+	SecretId         string    `json:"secretId"`
+	NextRotationTime time.Time `json:"nextRotationTime"`
+	LastRotationTime time.Time `json:"lastRotationTime"`
+	// Failed attempts since the last rotation, which are retried with a growing delay
+	FailedAttempts int `json:"failedAttempts"`
+	// The most recent attempts, from the oldest to the most recent one
+	History []RotationRecord `json:"history"`
+}
+
+// RotationRecord describes an attempt to rotate a secret, either scheduled or on demand.
+type RotationRecord struct {
+	Time      time.Time `json:"time"`
+	Scheduled bool      `json:"scheduled"`
+	// Version created by the rotation, unless it failed
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Secrets are created at their first version; every update yields a new version.
+const FirstSecretVersion = 1
+
+// SecretVersionEntry describes a version of a secret, without its data.
+type SecretVersionEntry struct {
+	Id             string    `json:"id"`
+	Version        int       `json:"version"`
+	Type           string    `json:"type"`
+	Owner          string    `json:"owner"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	Current        bool      `json:"current"`
+}
+
+// SecretTypeEntry describes a secret type supported by the server.
+type SecretTypeEntry struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// Description of the data clients provide when creating a secret, unless the
+	// data is generated by the server
+	SecretDataDescription string `json:"secretDataDescription,omitempty"`
+	// Schema of the secrets' metadata, if the type has one
+	MetaDataSchema *Schema `json:"metaDataSchema,omitempty"`
+}
+
+// SecretListEntry describes a secret in a listing of secrets, without its data.
+type SecretListEntry struct {
+	Id             string            `json:"id"`
+	Type           string            `json:"type"`
+	Owner          string            `json:"owner"`
+	ExpirationTime time.Time         `json:"expirationTime"`
+	// The secret's own tags, along with the tags inherited from its namespaces when
+	// the secret was found by a search
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// SecretExpirationEntry describes a secret that is due to expire, without its data.
+type SecretExpirationEntry struct {
+	Id             string    `json:"id"`
+	Type           string    `json:"type"`
+	Owner          string    `json:"owner"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	Expired        bool      `json:"expired"`
+}
+
+// Types of secret events.
+const (
+	// A certificate was renewed by the server before it expired
+	SecretEventCertificateRenewed = "certificateRenewed"
+	// A secret was rotated by the server according to its rotation policy
+	SecretEventRotated = "rotated"
+	// The server failed to rotate a secret according to its rotation policy; it retries later
+	SecretEventRotationFailed = "rotationFailed"
+)
+
+// SecretEventEntry describes a change made to a secret by the server itself (e.g. the
+// automatic renewal of a certificate), so that consumers of the secret can reload it.
+type SecretEventEntry struct {
+	Id       string    `json:"id"`
+	Type     string    `json:"type"`
+	SecretId string    `json:"secretId"`
+	Version  int       `json:"version"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message"`
+}
+
+// WrappedEntry is a value (either the data of a secret or a literal) wrapped for a
+// single retrieval using a wrapping token. Once unwrapped, the entry's data is dropped,
+// while the entry is kept for a while so that further unwrap attempts are detected.
+type WrappedEntry struct {
+	// Hash of the wrapping token, which isn't kept
+	Id             string    `json:"id"`
+	SecretId       string    `json:"secretId"`
+	SecretVersion  int       `json:"secretVersion"`
+	Creator        string    `json:"creator"`
+	CreationTime   time.Time `json:"creationTime"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	Unwrapped      bool      `json:"unwrapped"`
+	UnwrapTime     time.Time `json:"unwrapTime"`
+	// Encrypted using the wrapping token; kept as the data store entry's data
+	Data []byte `json:"-"`
+}
+
+// LeaseEntry tracks the data handed to a user when reading a leasable secret (e.g. a
+// credential minted by a dynamic credential secret); the data is revoked once the lease
+// expires, unless the lease is renewed.
+type LeaseEntry struct {
+	// <secret id>/<unique id>
+	Id             string    `json:"id"`
+	SecretId       string    `json:"secretId"`
+	SecretVersion  int       `json:"secretVersion"`
+	Owner          string    `json:"owner"`
+	CreationTime   time.Time `json:"creationTime"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	// Data needed for revoking the credential, e.g. the name of a database user; it's
+	// returned when listing leases, so it mustn't be sensitive
+	RevocationData map[string]string `json:"revocationData"`
+}
+
+// LeasedCredential is the data of a dynamic credential secret, as returned when reading
+// it: a newly minted credential (e.g. a database username and password) and its lease.
+type LeasedCredential struct {
+	LeaseId        string            `json:"leaseId"`
+	ExpirationTime time.Time         `json:"expirationTime"`
+	Credential     map[string]string `json:"credential"`
+}
+
+// CloudAccessKey is a cloud provider's access key pair, e.g. an AWS access key id and
+// secret access key.
+type CloudAccessKey struct {
+	AccessKeyId     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
 }
 
 type NamespaceEntry struct {
-	Path       string   `json:"path"`
-	Owner      string   `json:"owner"`
-	RoleLabels []string `json:"roleLabels"`
-	ChildPaths []string `json:"childPaths"`
+	Path               string              `json:"path"`
+	Owner              string              `json:"owner"`
+	RoleLabels         []string            `json:"roleLabels"`
+	ChildPaths         []string            `json:"childPaths"`
+	CertIssuancePolicy *CertIssuancePolicy `json:"certIssuancePolicy,omitempty"`
+	// User-defined labels, which apply to the secrets under the namespace as well
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// CertIssuancePolicy caps the X.509 certificates that may be issued for
+// certificate secrets in a namespace and in all of its sub-namespaces.
+type CertIssuancePolicy struct {
+	// DNS SANs must be one of these domains or, if AllowSubdomains is set,
+	// a subdomain of one; "*" allows any domain
+	AllowedDomains  []string `json:"allowedDomains"`
+	AllowSubdomains bool     `json:"allowSubdomains"`
+	// IP SANs must be within one of these CIDR ranges
+	AllowedIPRanges []string `json:"allowedIPRanges"`
+	// URI SANs must start with one of these prefixes
+	AllowedURIPrefixes []string `json:"allowedURIPrefixes"`
+	// Maximal certificate validity, e.g. 2160h (default: no cap)
+	MaxTTL string `json:"maxTTL"`
+	// Key usages and extended key usages which may be requested (default: any)
+	AllowedKeyUsages    []string `json:"allowedKeyUsages"`
+	AllowedExtKeyUsages []string `json:"allowedExtKeyUsages"`
+	// Whether CA certificates may be issued and, if so, their maximal path length
+	AllowCA    bool `json:"allowCA"`
+	MaxPathLen *int `json:"maxPathLen,omitempty"`
+}
+
+// CertificateEntry records a certificate issued by the server acting as a CA, and its revocation.
+type CertificateEntry struct {
+	// Hex-encoded
+	SerialNumber string `json:"serialNumber"`
+	SecretId     string `json:"secretId"`
+	// Id of the intermediate CA secret which issued the certificate; empty for the root CA
+	IssuerId         string    `json:"issuerId"`
+	NotAfter         time.Time `json:"notAfter"`
+	Revoked          bool      `json:"revoked"`
+	RevocationTime   time.Time `json:"revocationTime"`
+	RevocationReason string    `json:"revocationReason"`
 }
 
 const (
@@ -38,6 +226,12 @@ const (
 	OpRead   = "R"
 	OpUpdate = "U"
 	OpDelete = "D"
+	// Using a key (e.g. signing with it) without reading it
+	OpSign = "S"
+	// Computing MACs with, or deriving keys from, an HMAC key without reading it
+	OpMAC = "M"
+	// Encrypting, decrypting and rewrapping with a symmetric key without reading it
+	OpEncrypt = "E"
 )
 
 type Operation struct {
@@ -4,6 +4,7 @@ package model
 
 import (
 	"bytes"
+	"time"
 )
 
 func SecretsEqual(s, t *SecretEntry) bool {
@@ -19,6 +20,12 @@ func SecretsEqual(s, t *SecretEntry) bool {
 		bytes.Equal(s.SecretData, t.SecretData)
 }
 
+// SecretExpired returns true if the secret's expiration time has passed at time t.
+// A zero expiration time means that the secret never expires.
+func SecretExpired(se *SecretEntry, t time.Time) bool {
+	return !se.ExpirationTime.IsZero() && !t.Before(se.ExpirationTime)
+}
+
 func NewUserEntry(ue *UserEntry) *UserEntry {
 	return &UserEntry{
 		Username:    ue.Username,
@@ -42,14 +49,19 @@ func NewSecretEntry(se *SecretEntry) *SecretEntry {
 		SecretData:     se.SecretData,
 		Owner:          se.Owner,
 		ExpirationTime: se.ExpirationTime,
+		Version:        se.Version,
+		RotationPolicy: se.RotationPolicy,
+		Tags:           se.Tags,
 	}
 }
 
 func NewNamespaceEntry(ne *NamespaceEntry) *NamespaceEntry {
 	return &NamespaceEntry{
-		Path:       ne.Path,
-		Owner:      ne.Owner,
-		ChildPaths: ne.ChildPaths,
+		Path:               ne.Path,
+		Owner:              ne.Owner,
+		ChildPaths:         ne.ChildPaths,
+		CertIssuancePolicy: ne.CertIssuancePolicy,
+		Tags:               ne.Tags,
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/vmware/virtual-security-module/util"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func ExtractAndValidateSecretEntry(req *http.Request) (*SecretEntry, error) {
@@ -24,6 +25,289 @@ func ExtractAndValidateSecretEntry(req *http.Request) (*SecretEntry, error) {
 	return &secretEntry, nil
 }
 
+func ExtractAndValidateSecretUpdate(req *http.Request, secretId string) (*SecretEntry, error) {
+	decoder := json.NewDecoder(req.Body)
+	var secretEntry SecretEntry
+	if err := decoder.Decode(&secretEntry); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	// the secret id is taken from the request path; if it is also provided in
+	// the body, the two must match
+	if secretEntry.Id == "" {
+		secretEntry.Id = secretId
+	}
+	if secretEntry.Id != secretId {
+		return nil, util.ErrInputValidation
+	}
+
+	return &secretEntry, nil
+}
+
+func ExtractAndValidateRollbackRequest(req *http.Request) (*RollbackRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var rollbackRequest RollbackRequest
+	if err := decoder.Decode(&rollbackRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if rollbackRequest.Version < FirstSecretVersion {
+		return nil, util.ErrInputValidation
+	}
+
+	return &rollbackRequest, nil
+}
+
+func ExtractAndValidateSSHSignRequest(req *http.Request) (*SSHSignRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var signRequest SSHSignRequest
+	if err := decoder.Decode(&signRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if signRequest.CaId == "" || signRequest.PublicKey == "" || len(signRequest.Principals) == 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	return &signRequest, nil
+}
+
+func ExtractAndValidateCSRSignRequest(req *http.Request) (*CSRSignRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var signRequest CSRSignRequest
+	if err := decoder.Decode(&signRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if signRequest.Id == "" || signRequest.CSR == "" {
+		return nil, util.ErrInputValidation
+	}
+
+	return &signRequest, nil
+}
+
+func ExtractAndValidateRevocationRequest(req *http.Request) (*RevocationRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var revocationRequest RevocationRequest
+	if err := decoder.Decode(&revocationRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if (revocationRequest.SerialNumber == "") == (revocationRequest.SecretId == "") {
+		return nil, util.ErrInputValidation
+	}
+
+	if revocationRequest.Reason == "" {
+		revocationRequest.Reason = RevocationReasonUnspecified
+	}
+	if _, ok := X509RevocationReasons[revocationRequest.Reason]; !ok {
+		return nil, util.ErrInputValidation
+	}
+
+	return &revocationRequest, nil
+}
+
+func ExtractAndValidateSignRequest(req *http.Request) (*SignRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var signRequest SignRequest
+	if err := decoder.Decode(&signRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if signRequest.KeyId == "" || len(signRequest.Input) == 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	return &signRequest, nil
+}
+
+func ExtractAndValidateVerifyRequest(req *http.Request) (*VerifyRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var verifyRequest VerifyRequest
+	if err := decoder.Decode(&verifyRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if verifyRequest.KeyId == "" || len(verifyRequest.Input) == 0 || len(verifyRequest.Signature) == 0 || verifyRequest.KeyVersion < 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	return &verifyRequest, nil
+}
+
+func ExtractAndValidateEncryptRequest(req *http.Request) (*EncryptRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var encryptRequest EncryptRequest
+	if err := decoder.Decode(&encryptRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if encryptRequest.KeyId == "" {
+		return nil, util.ErrInputValidation
+	}
+
+	return &encryptRequest, nil
+}
+
+func ExtractAndValidateDecryptRequest(req *http.Request) (*DecryptRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var decryptRequest DecryptRequest
+	if err := decoder.Decode(&decryptRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if decryptRequest.KeyId == "" || decryptRequest.Ciphertext == "" {
+		return nil, util.ErrInputValidation
+	}
+
+	return &decryptRequest, nil
+}
+
+func ExtractAndValidateRewrapRequest(req *http.Request) (*RewrapRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var rewrapRequest RewrapRequest
+	if err := decoder.Decode(&rewrapRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if rewrapRequest.KeyId == "" || rewrapRequest.Ciphertext == "" {
+		return nil, util.ErrInputValidation
+	}
+
+	return &rewrapRequest, nil
+}
+
+func ExtractAndValidateHMACRequest(req *http.Request) (*HMACRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var hmacRequest HMACRequest
+	if err := decoder.Decode(&hmacRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if hmacRequest.KeyId == "" || hmacRequest.KeyVersion < 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	return &hmacRequest, nil
+}
+
+func ExtractAndValidateDeriveKeyRequest(req *http.Request) (*DeriveKeyRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var deriveKeyRequest DeriveKeyRequest
+	if err := decoder.Decode(&deriveKeyRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if deriveKeyRequest.KeyId == "" || deriveKeyRequest.KeyVersion < 0 || deriveKeyRequest.Length < 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	return &deriveKeyRequest, nil
+}
+
+func ExtractAndValidateWrapRequest(req *http.Request) (*WrapRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var wrapRequest WrapRequest
+	if err := decoder.Decode(&wrapRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	// either a secret or a literal is wrapped
+	if (wrapRequest.SecretId == "") == (len(wrapRequest.Data) == 0) {
+		return nil, util.ErrInputValidation
+	}
+
+	return &wrapRequest, nil
+}
+
+func ExtractAndValidateUnwrapRequest(req *http.Request) (*UnwrapRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var unwrapRequest UnwrapRequest
+	if err := decoder.Decode(&unwrapRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if unwrapRequest.Token == "" {
+		return nil, util.ErrInputValidation
+	}
+
+	return &unwrapRequest, nil
+}
+
+func ExtractAndValidateRenewLeaseRequest(req *http.Request) (*RenewLeaseRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var renewLeaseRequest RenewLeaseRequest
+	if err := decoder.Decode(&renewLeaseRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if renewLeaseRequest.LeaseId == "" {
+		return nil, util.ErrInputValidation
+	}
+
+	return &renewLeaseRequest, nil
+}
+
+func ExtractAndValidateRevokeLeasesRequest(req *http.Request) (*RevokeLeasesRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var revokeLeasesRequest RevokeLeasesRequest
+	if err := decoder.Decode(&revokeLeasesRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	// either a single lease or the leases matching a prefix and/or an owner are revoked
+	if (revokeLeasesRequest.LeaseId == "") == (revokeLeasesRequest.Prefix == "" && revokeLeasesRequest.Owner == "") {
+		return nil, util.ErrInputValidation
+	}
+
+	return &revokeLeasesRequest, nil
+}
+
+func ExtractAndValidateSecretBatchRequest(req *http.Request) (*SecretBatchRequest, error) {
+	decoder := json.NewDecoder(req.Body)
+	var batchRequest SecretBatchRequest
+	if err := decoder.Decode(&batchRequest); err != nil {
+		return nil, util.ErrInputValidation
+	}
+	defer req.Body.Close()
+
+	if batchRequest.ConflictPolicy == "" {
+		batchRequest.ConflictPolicy = ConflictPolicyFail
+	}
+	if !IsValidConflictPolicy(batchRequest.ConflictPolicy) {
+		return nil, util.ErrInputValidation
+	}
+	if len(batchRequest.Secrets) == 0 || len(batchRequest.Secrets) > MaxSecretBatchSize {
+		return nil, util.ErrInputValidation
+	}
+
+	ids := make(map[string]bool)
+	for _, secretEntry := range batchRequest.Secrets {
+		if secretEntry.Id == "" || secretEntry.Type == "" || ids[secretEntry.Id] {
+			return nil, util.ErrInputValidation
+		}
+		ids[secretEntry.Id] = true
+	}
+
+	return &batchRequest, nil
+}
+
 func ExtractAndValidateUserEntry(req *http.Request) (*UserEntry, error) {
 	decoder := json.NewDecoder(req.Body)
 	var userEntry UserEntry
@@ -71,6 +355,16 @@ func ExtractAndValidateNamespaceEntry(req *http.Request) (*NamespaceEntry, error
 		return nil, util.ErrInputValidation
 	}
 
+	if namespaceEntry.CertIssuancePolicy != nil {
+		if err := ValidateCertIssuancePolicy(namespaceEntry.CertIssuancePolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ValidateTags(namespaceEntry.Tags); err != nil {
+		return nil, err
+	}
+
 	return &namespaceEntry, nil
 }
 
@@ -93,9 +387,54 @@ func IsValidOpLabel(label string) bool {
 	return label == OpCreate ||
 		label == OpRead ||
 		label == OpUpdate ||
-		label == OpDelete
+		label == OpDelete ||
+		label == OpSign ||
+		label == OpMAC ||
+		label == OpEncrypt
 }
 
 func ValidOpLabels() []string {
-	return []string{OpCreate, OpRead, OpUpdate, OpDelete}
+	return []string{OpCreate, OpRead, OpUpdate, OpDelete, OpSign, OpMAC, OpEncrypt}
+}
+
+// RotationPolicyEmpty reports whether none of a rotation policy's fields is set.
+func RotationPolicyEmpty(policy *RotationPolicy) bool {
+	return policy.Interval == "" && policy.Cron == "" && policy.MaxAge == ""
+}
+
+func ValidateRotationPolicy(policy *RotationPolicy) error {
+	set := 0
+	for _, duration := range []string{policy.Interval, policy.MaxAge} {
+		if duration == "" {
+			continue
+		}
+		set++
+		if d, err := time.ParseDuration(duration); err != nil || d <= 0 {
+			return util.ErrInputValidation
+		}
+	}
+
+	if policy.Cron != "" {
+		set++
+		schedule, err := util.ParseCronSchedule(policy.Cron)
+		if err != nil {
+			return err
+		}
+		// e.g. February 31st
+		if schedule.Next(time.Now()).IsZero() {
+			return util.ErrInputValidation
+		}
+	}
+
+	if set != 1 {
+		return util.ErrInputValidation
+	}
+
+	return nil
+}
+
+func IsValidConflictPolicy(policy string) bool {
+	return policy == ConflictPolicySkip ||
+		policy == ConflictPolicyOverwrite ||
+		policy == ConflictPolicyFail
 }
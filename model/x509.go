@@ -0,0 +1,77 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package model
+
+import (
+	"crypto/x509"
+	"net"
+	"time"
+
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// Names of the key usages which may be requested for X.509 certificates.
+var X509KeyUsages = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"certSign":          x509.KeyUsageCertSign,
+	"crlSign":           x509.KeyUsageCRLSign,
+}
+
+// Names of the extended key usages which may be requested for X.509 certificates.
+var X509ExtKeyUsages = map[string]x509.ExtKeyUsage{
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+const RevocationReasonUnspecified = "unspecified"
+
+// Names of the reasons for revoking certificates, and their RFC 5280 codes.
+var X509RevocationReasons = map[string]int{
+	RevocationReasonUnspecified: 0,
+	"keyCompromise":             1,
+	"caCompromise":              2,
+	"affiliationChanged":        3,
+	"superseded":                4,
+	"cessationOfOperation":      5,
+	"privilegeWithdrawn":        9,
+}
+
+func ValidateCertIssuancePolicy(policy *CertIssuancePolicy) error {
+	for _, ipRange := range policy.AllowedIPRanges {
+		if _, _, err := net.ParseCIDR(ipRange); err != nil {
+			return util.ErrInputValidation
+		}
+	}
+
+	if policy.MaxTTL != "" {
+		if maxTTL, err := time.ParseDuration(policy.MaxTTL); err != nil || maxTTL <= 0 {
+			return util.ErrInputValidation
+		}
+	}
+
+	for _, keyUsage := range policy.AllowedKeyUsages {
+		if _, ok := X509KeyUsages[keyUsage]; !ok {
+			return util.ErrInputValidation
+		}
+	}
+
+	for _, extKeyUsage := range policy.AllowedExtKeyUsages {
+		if _, ok := X509ExtKeyUsages[extKeyUsage]; !ok {
+			return util.ErrInputValidation
+		}
+	}
+
+	if policy.MaxPathLen != nil && (!policy.AllowCA || *policy.MaxPathLen < 0) {
+		return util.ErrInputValidation
+	}
+
+	return nil
+}
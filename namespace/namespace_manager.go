@@ -117,7 +117,7 @@ func (namespaceManager *NamespaceManager) DeleteNamespace(ctx gocontext.Context,
 }
 
 func (namespaceManager *NamespaceManager) initNamespaces() error {
-	paths := []string{"/", "/users", "/secrets"}
+	paths := []string{"/", "/users", "/secrets", "/sys", "/sys/wrapped"}
 
 	for _, path := range paths {
 		if err := namespaceManager.createNamespaceIfNotExists(path); err != nil {
@@ -76,6 +76,37 @@ func TestCreateAndGetNamespace(t *testing.T) {
 	}
 }
 
+func TestCreateAndGetNamespaceWithCertIssuancePolicy(t *testing.T) {
+	ne := &model.NamespaceEntry{
+		Path:       "/namespace-with-policy",
+		Owner:      "user0",
+		RoleLabels: []string{},
+		CertIssuancePolicy: &model.CertIssuancePolicy{
+			AllowedDomains:  []string{"example.com"},
+			AllowSubdomains: true,
+			MaxTTL:          "720h",
+		},
+	}
+
+	id, err := nm.CreateNamespace(context.GetTestRequestContext(), ne)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+
+	ne2, err := nm.GetNamespace(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get namespace for id %v: %v", id, err)
+	}
+
+	if !reflect.DeepEqual(ne.CertIssuancePolicy, ne2.CertIssuancePolicy) {
+		t.Fatalf("Created and retrieved issuance policies are different: %v %v", ne.CertIssuancePolicy, ne2.CertIssuancePolicy)
+	}
+
+	if err := nm.DeleteNamespace(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Faied to delete namespace:%v", err)
+	}
+}
+
 func TestCreateAlreadyExists(t *testing.T) {
 	ne := &model.NamespaceEntry{
 		Path:       "/namespace0",
@@ -0,0 +1,313 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+const (
+	AWSIAMProviderType = "aws-iam"
+
+	DefaultAWSIAMEndpoint = "https://iam.amazonaws.com/"
+	DefaultAWSIAMRegion   = "us-east-1"
+
+	awsIAMService     = "iam"
+	awsIAMAPIVersion  = "2010-05-08"
+	awsIAMContentType = "application/x-www-form-urlencoded; charset=utf-8"
+	awsIAMTimeout     = 30 * time.Second
+	// Maximal size of an IAM response
+	awsIAMMaxResponseSize = 1024 * 1024
+
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	awsDateTimeLayout   = "20060102T150405Z"
+	awsDateLayout       = "20060102"
+)
+
+func init() {
+	if err := CloudProviderRegistrar.Register(AWSIAMProviderType, NewAWSIAMProvider()); err != nil {
+		panic(fmt.Sprintf("Failed to register cloud provider %v: %v", AWSIAMProviderType, err))
+	}
+}
+
+// A cloud provider which manages the access keys of an AWS IAM user, through the IAM query
+// API (or a compatible one). Its credentials are the access key (a JSON-encoded
+// model.CloudAccessKey) of an IAM user allowed to create and delete the managed user's
+// access keys. As IAM users have at most two access keys, the managed user should have
+// no access keys other than the one managed by the secret. Requests are only sent to the
+// default endpoint, or to https endpoints allowed by the server's configuration.
+type AWSIAMProvider struct {
+	client           *http.Client
+	allowedEndpoints []*url.URL
+}
+
+type AWSIAMProviderConfig struct {
+	// Name of the IAM user whose access keys are managed
+	UserName string `json:"userName"`
+	// IAM endpoint (default: https://iam.amazonaws.com/)
+	Endpoint string `json:"endpoint"`
+	// Region in which requests are signed (default: us-east-1)
+	Region string `json:"region"`
+}
+
+type awsCreateAccessKeyResponse struct {
+	AccessKey struct {
+		AccessKeyId     string `xml:"AccessKeyId"`
+		SecretAccessKey string `xml:"SecretAccessKey"`
+	} `xml:"CreateAccessKeyResult>AccessKey"`
+}
+
+type awsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func NewAWSIAMProvider() *AWSIAMProvider {
+	return &AWSIAMProvider{
+		client: &http.Client{Timeout: awsIAMTimeout},
+	}
+}
+
+func (awsProvider *AWSIAMProvider) Type() string {
+	return AWSIAMProviderType
+}
+
+func (awsProvider *AWSIAMProvider) Init(cfg *config.Config) error {
+	awsProvider.allowedEndpoints = make([]*url.URL, 0, len(cfg.SecretsConfig.CloudProviderEndpoints))
+	for _, endpoint := range cfg.SecretsConfig.CloudProviderEndpoints {
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil || endpointURL.Host == "" {
+			return util.ErrBadConfig
+		}
+		awsProvider.allowedEndpoints = append(awsProvider.allowedEndpoints, endpointURL)
+	}
+
+	return nil
+}
+
+func (awsProvider *AWSIAMProvider) ValidateConfig(config json.RawMessage, credentials []byte) error {
+	awsConfig, err := parseAWSIAMProviderConfig(config)
+	if err != nil || awsConfig.UserName == "" {
+		return util.ErrInputValidation
+	}
+
+	if !awsProvider.endpointAllowed(awsConfig.Endpoint) {
+		return util.ErrInputValidation
+	}
+
+	var awsCredentials model.CloudAccessKey
+	if err := json.Unmarshal(credentials, &awsCredentials); err != nil {
+		return util.ErrInputValidation
+	}
+	if awsCredentials.AccessKeyId == "" || awsCredentials.SecretAccessKey == "" {
+		return util.ErrInputValidation
+	}
+
+	return nil
+}
+
+func (awsProvider *AWSIAMProvider) IssueAccessKey(config json.RawMessage, credentials []byte) (*model.CloudAccessKey, error) {
+	awsConfig, err := parseAWSIAMProviderConfig(config)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	respBody, err := awsProvider.call(awsConfig, credentials, url.Values{
+		"Action":   {"CreateAccessKey"},
+		"UserName": {awsConfig.UserName},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var createResponse awsCreateAccessKeyResponse
+	if err := xml.Unmarshal(respBody, &createResponse); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if createResponse.AccessKey.AccessKeyId == "" || createResponse.AccessKey.SecretAccessKey == "" {
+		return nil, util.ErrInternal
+	}
+
+	return &model.CloudAccessKey{
+		AccessKeyId:     createResponse.AccessKey.AccessKeyId,
+		SecretAccessKey: createResponse.AccessKey.SecretAccessKey,
+	}, nil
+}
+
+func (awsProvider *AWSIAMProvider) RotateAccessKey(config json.RawMessage, credentials []byte, accessKeyId string) (*model.CloudAccessKey, error) {
+	accessKey, err := awsProvider.IssueAccessKey(config, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := awsProvider.RevokeAccessKey(config, credentials, accessKeyId); err != nil {
+		// the user can't be left with two access keys, or the next rotation would fail
+		if e := awsProvider.RevokeAccessKey(config, credentials, accessKey.AccessKeyId); e != nil {
+			log.Printf("failed to delete access key %v, issued while failing to rotate %v: %v\n", accessKey.AccessKeyId, accessKeyId, e)
+		}
+		return nil, err
+	}
+
+	return accessKey, nil
+}
+
+func (awsProvider *AWSIAMProvider) RevokeAccessKey(config json.RawMessage, credentials []byte, accessKeyId string) error {
+	awsConfig, err := parseAWSIAMProviderConfig(config)
+	if err != nil {
+		return util.ErrInternal
+	}
+
+	_, err = awsProvider.call(awsConfig, credentials, url.Values{
+		"Action":      {"DeleteAccessKey"},
+		"UserName":    {awsConfig.UserName},
+		"AccessKeyId": {accessKeyId},
+	})
+
+	return err
+}
+
+// endpointAllowed returns whether requests may be sent to an endpoint: the default one, or
+// one allowed by the server's configuration, with the same scheme and host.
+func (awsProvider *AWSIAMProvider) endpointAllowed(endpoint string) bool {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return false
+	}
+
+	defaultURL, _ := url.Parse(DefaultAWSIAMEndpoint)
+	for _, allowedURL := range append([]*url.URL{defaultURL}, awsProvider.allowedEndpoints...) {
+		if endpointURL.Scheme == allowedURL.Scheme && strings.EqualFold(endpointURL.Host, allowedURL.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// call makes a signed IAM query API request, returning the response's body.
+func (awsProvider *AWSIAMProvider) call(awsConfig *AWSIAMProviderConfig, credentials []byte, params url.Values) ([]byte, error) {
+	// the configuration might have changed since the secret was created
+	if !awsProvider.endpointAllowed(awsConfig.Endpoint) {
+		log.Printf("failed to call %v: endpoint %v is not allowed\n", params.Get("Action"), awsConfig.Endpoint)
+		return nil, util.ErrInternal
+	}
+
+	var awsCredentials model.CloudAccessKey
+	if err := json.Unmarshal(credentials, &awsCredentials); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	params.Set("Version", awsIAMAPIVersion)
+	body := params.Encode()
+
+	req, err := http.NewRequest("POST", awsConfig.Endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+	req.Header.Set("Content-Type", awsIAMContentType)
+	signAWSRequest(req, []byte(body), &awsCredentials, awsConfig.Region, awsIAMService, time.Now())
+
+	resp, err := awsProvider.client.Do(req)
+	if err != nil {
+		log.Printf("failed to call %v: %v\n", params.Get("Action"), err)
+		return nil, util.ErrInternal
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, awsIAMMaxResponseSize+1))
+	if err != nil || len(respBody) > awsIAMMaxResponseSize {
+		return nil, util.ErrInternal
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse awsErrorResponse
+		xml.Unmarshal(respBody, &errorResponse)
+		log.Printf("%v failed: %v %v: %v\n", params.Get("Action"), resp.Status, errorResponse.Code, errorResponse.Message)
+		if errorResponse.Code == "NoSuchEntity" {
+			return nil, util.ErrNotFound
+		}
+		return nil, util.ErrInternal
+	}
+
+	return respBody, nil
+}
+
+// signAWSRequest adds an AWS signature version 4 to a request, covering its content type,
+// host, date and body.
+func signAWSRequest(req *http.Request, body []byte, awsCredentials *model.CloudAccessKey, region string, service string, now time.Time) {
+	dateTime := now.UTC().Format(awsDateTimeLayout)
+	date := now.UTC().Format(awsDateLayout)
+	req.Header.Set("X-Amz-Date", dateTime)
+
+	signedHeaders := "content-type;host;x-amz-date"
+	scope := fmt.Sprintf("%v/%v/%v/aws4_request", date, region, service)
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.Query().Encode(),
+		fmt.Sprintf("content-type:%v\nhost:%v\nx-amz-date:%v\n", req.Header.Get("Content-Type"), req.URL.Host, dateTime),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		awsSigningAlgorithm,
+		dateTime,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := []byte("AWS4" + awsCredentials.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		signingKey = crypt.MAC([]byte(part), signingKey)
+	}
+	signature := hex.EncodeToString(crypt.MAC([]byte(stringToSign), signingKey))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%v Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		awsSigningAlgorithm, awsCredentials.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func parseAWSIAMProviderConfig(config json.RawMessage) (*AWSIAMProviderConfig, error) {
+	var awsConfig AWSIAMProviderConfig
+	if err := json.Unmarshal(config, &awsConfig); err != nil {
+		return nil, err
+	}
+
+	if awsConfig.Endpoint == "" {
+		awsConfig.Endpoint = DefaultAWSIAMEndpoint
+	}
+	if awsConfig.Region == "" {
+		awsConfig.Region = DefaultAWSIAMRegion
+	}
+
+	return &awsConfig, nil
+}
+
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,322 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// mockIAMServer is a local stand-in for the AWS IAM query API, supporting the creation and
+// deletion of access keys. It verifies request signatures against a single administrative
+// access key, and limits users to two access keys like IAM does.
+type mockIAMServer struct {
+	*httptest.Server
+	adminKey model.CloudAccessKey
+
+	lock       sync.Mutex
+	accessKeys map[string]map[string]string
+	keyCount   int
+}
+
+type mockIAMAccessKey struct {
+	UserName        string `xml:"UserName"`
+	AccessKeyId     string `xml:"AccessKeyId"`
+	Status          string `xml:"Status"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+}
+
+func newMockIAMServer() *mockIAMServer {
+	mock := &mockIAMServer{
+		adminKey:   model.CloudAccessKey{AccessKeyId: "AKIAMOCKADMIN", SecretAccessKey: "mock-admin-secret"},
+		accessKeys: make(map[string]map[string]string),
+	}
+	mock.Server = httptest.NewServer(http.HandlerFunc(mock.handle))
+
+	// allow the secret manager's provider to use the mock server
+	if provider, err := CloudProviderRegistrar.Get(AWSIAMProviderType); err == nil {
+		allowEndpoint(provider, mock.URL)
+	}
+
+	return mock
+}
+
+// provider returns a provider allowed to use the mock server.
+func (mock *mockIAMServer) provider() *AWSIAMProvider {
+	provider := NewAWSIAMProvider()
+	allowEndpoint(provider, mock.URL)
+
+	return provider
+}
+
+// allowEndpoint configures a provider to allow an endpoint, as the server's configuration does.
+func allowEndpoint(provider CloudProvider, endpoint string) error {
+	cfg := config.GenerateTestConfig()
+	cfg.SecretsConfig.CloudProviderEndpoints = []string{endpoint}
+
+	return provider.Init(cfg)
+}
+
+// userKeys returns the ids of the access keys of a user.
+func (mock *mockIAMServer) userKeys(userName string) []string {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+
+	keyIds := make([]string, 0)
+	for keyId := range mock.accessKeys[userName] {
+		keyIds = append(keyIds, keyId)
+	}
+
+	return keyIds
+}
+
+func (mock *mockIAMServer) secretAccessKey(userName string, accessKeyId string) string {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+
+	return mock.accessKeys[userName][accessKeyId]
+}
+
+func (mock *mockIAMServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || !mock.verifySignature(r, body) {
+		mock.writeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("Version") != awsIAMAPIVersion {
+		mock.writeError(w, http.StatusBadRequest, "InvalidInput")
+		return
+	}
+
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+
+	userName := form.Get("UserName")
+	switch form.Get("Action") {
+	case "CreateAccessKey":
+		if len(mock.accessKeys[userName]) >= 2 {
+			mock.writeError(w, http.StatusConflict, "LimitExceeded")
+			return
+		}
+
+		mock.keyCount++
+		accessKey := mockIAMAccessKey{
+			UserName:        userName,
+			AccessKeyId:     fmt.Sprintf("AKIAMOCK%08d", mock.keyCount),
+			Status:          "Active",
+			SecretAccessKey: fmt.Sprintf("mock-secret-%v", mock.keyCount),
+		}
+		if mock.accessKeys[userName] == nil {
+			mock.accessKeys[userName] = make(map[string]string)
+		}
+		mock.accessKeys[userName][accessKey.AccessKeyId] = accessKey.SecretAccessKey
+
+		resp := struct {
+			XMLName   xml.Name         `xml:"CreateAccessKeyResponse"`
+			AccessKey mockIAMAccessKey `xml:"CreateAccessKeyResult>AccessKey"`
+		}{AccessKey: accessKey}
+		mock.writeResponse(w, resp)
+	case "DeleteAccessKey":
+		accessKeyId := form.Get("AccessKeyId")
+		if _, ok := mock.accessKeys[userName][accessKeyId]; !ok {
+			mock.writeError(w, http.StatusNotFound, "NoSuchEntity")
+			return
+		}
+		delete(mock.accessKeys[userName], accessKeyId)
+
+		resp := struct {
+			XMLName xml.Name `xml:"DeleteAccessKeyResponse"`
+		}{}
+		mock.writeResponse(w, resp)
+	default:
+		mock.writeError(w, http.StatusBadRequest, "InvalidAction")
+	}
+}
+
+// verifySignature re-signs a request using the administrative access key, and compares
+// the signatures.
+func (mock *mockIAMServer) verifySignature(r *http.Request, body []byte) bool {
+	signTime, err := time.Parse(awsDateTimeLayout, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	req, err := http.NewRequest(r.Method, fmt.Sprintf("http://%v%v", r.Host, r.URL.RequestURI()), nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	signAWSRequest(req, body, &mock.adminKey, DefaultAWSIAMRegion, awsIAMService, signTime)
+
+	return req.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (mock *mockIAMServer) writeResponse(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	xml.NewEncoder(w).Encode(resp)
+}
+
+func (mock *mockIAMServer) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%v</Code><Message>%v</Message></Error></ErrorResponse>", code, code)
+}
+
+func (mock *mockIAMServer) config(userName string) json.RawMessage {
+	config, _ := json.Marshal(&AWSIAMProviderConfig{UserName: userName, Endpoint: mock.URL + "/"})
+
+	return config
+}
+
+func (mock *mockIAMServer) credentials() []byte {
+	credentials, _ := json.Marshal(&mock.adminKey)
+
+	return credentials
+}
+
+func TestAWSIAMProviderIssueRotateAndRevoke(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	provider := mock.provider()
+	config := mock.config("app0")
+
+	if err := provider.ValidateConfig(config, mock.credentials()); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+
+	accessKey, err := provider.IssueAccessKey(config, mock.credentials())
+	if err != nil {
+		t.Fatalf("Failed to issue access key: %v", err)
+	}
+
+	if mock.secretAccessKey("app0", accessKey.AccessKeyId) != accessKey.SecretAccessKey {
+		t.Fatalf("Unexpected access key: %v", accessKey)
+	}
+
+	rotatedKey, err := provider.RotateAccessKey(config, mock.credentials(), accessKey.AccessKeyId)
+	if err != nil {
+		t.Fatalf("Failed to rotate access key: %v", err)
+	}
+
+	if keyIds := mock.userKeys("app0"); len(keyIds) != 1 || keyIds[0] != rotatedKey.AccessKeyId {
+		t.Fatalf("Unexpected access keys after rotation: %v", keyIds)
+	}
+
+	if err := provider.RevokeAccessKey(config, mock.credentials(), rotatedKey.AccessKeyId); err != nil {
+		t.Fatalf("Failed to revoke access key: %v", err)
+	}
+
+	if keyIds := mock.userKeys("app0"); len(keyIds) != 0 {
+		t.Fatalf("Unexpected access keys after revocation: %v", keyIds)
+	}
+
+	if err := provider.RevokeAccessKey(config, mock.credentials(), rotatedKey.AccessKeyId); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when revoking a revoked access key: %v", err)
+	}
+}
+
+func TestAWSIAMProviderBadCredentials(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	provider := mock.provider()
+	credentials, _ := json.Marshal(&model.CloudAccessKey{AccessKeyId: mock.adminKey.AccessKeyId, SecretAccessKey: "wrong"})
+
+	if _, err := provider.IssueAccessKey(mock.config("app1"), credentials); err == nil {
+		t.Fatalf("Issued an access key using bad credentials")
+	}
+
+	if keyIds := mock.userKeys("app1"); len(keyIds) != 0 {
+		t.Fatalf("Unexpected access keys: %v", keyIds)
+	}
+}
+
+func TestAWSIAMProviderValidateConfig(t *testing.T) {
+	provider := NewAWSIAMProvider()
+	if err := allowEndpoint(provider, "http://iam.example.com:9000"); err != nil {
+		t.Fatalf("Failed to initialize provider: %v", err)
+	}
+	credentials := []byte(`{"accessKeyId": "AKIA", "secretAccessKey": "secret"}`)
+
+	invalidConfigs := []string{
+		`not json`,
+		`{}`,
+		`{"userName": "app2", "endpoint": "ftp://iam.example.com"}`,
+		`{"userName": "app2", "endpoint": "iam.example.com"}`,
+		// endpoints must be allowed by the server's configuration, including their scheme
+		`{"userName": "app2", "endpoint": "http://iam.amazonaws.com/"}`,
+		`{"userName": "app2", "endpoint": "https://169.254.169.254/"}`,
+		`{"userName": "app2", "endpoint": "https://iam.example.com:9000/"}`,
+	}
+
+	for _, config := range invalidConfigs {
+		if err := provider.ValidateConfig(json.RawMessage(config), credentials); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when validating config %v: %v", config, err)
+		}
+	}
+
+	for _, config := range []string{`{"userName": "app2", "endpoint": "http://iam.example.com:9000/"}`, `{"userName": "app2", "endpoint": "https://iam.amazonaws.com"}`} {
+		if err := provider.ValidateConfig(json.RawMessage(config), credentials); err != nil {
+			t.Fatalf("Failed to validate config %v: %v", config, err)
+		}
+	}
+
+	config := json.RawMessage(`{"userName": "app2"}`)
+	if err := provider.ValidateConfig(config, credentials); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+
+	for _, credentials := range []string{`not json`, `{"accessKeyId": "AKIA"}`} {
+		if err := provider.ValidateConfig(config, []byte(credentials)); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when validating credentials %v: %v", credentials, err)
+		}
+	}
+}
+
+func TestAWSIAMProviderLargeResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, awsIAMMaxResponseSize+1))
+	}))
+	defer ts.Close()
+
+	provider := NewAWSIAMProvider()
+	allowEndpoint(provider, ts.URL)
+
+	config, _ := json.Marshal(&AWSIAMProviderConfig{UserName: "app3", Endpoint: ts.URL + "/"})
+	credentials := []byte(`{"accessKeyId": "AKIA", "secretAccessKey": "secret"}`)
+	if _, err := provider.IssueAccessKey(config, credentials); err != util.ErrInternal {
+		t.Fatalf("Unexpected error when reading a large response: %v", err)
+	}
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	// example from the AWS signature version 4 documentation
+	req, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", awsIAMContentType)
+
+	awsCredentials := &model.CloudAccessKey{AccessKeyId: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWSRequest(req, []byte{}, awsCredentials, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if authz := req.Header.Get("Authorization"); authz != expected {
+		t.Fatalf("Unexpected authorization header: %v", authz)
+	}
+}
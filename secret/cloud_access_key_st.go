@@ -0,0 +1,306 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vmware/virtual-security-module/audit"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const CloudAccessKeySecretTypeName = "CloudAccessKey"
+
+func init() {
+	if err := SecretTypeRegistrar.Register(CloudAccessKeySecretTypeName, NewCloudAccessKeySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", CloudAccessKeySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(CloudAccessKeySecretTypeName, cloudAccessKeyMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", CloudAccessKeySecretTypeName, err))
+	}
+}
+
+// A secret type which manages an access key of a cloud provider's account (e.g. an AWS IAM
+// user) using a cloud provider. The secret's data, as provided, is the credentials used for
+// managing the access key, which are kept encrypted and never returned; reading the secret
+// returns the current access key (a JSON-encoded model.CloudAccessKey) instead. The access
+// key is rotated whenever the secret is rotated (or updated), either on demand or according
+// to its rotation policy; the previous access key is revoked, so prior versions of the
+// secret hold revoked keys.
+type CloudAccessKeySecretType struct {
+	dataStore    vds.DataStoreAdapter
+	keyStore     *vks.VirtualKeyStore
+	auditManager audit.AuditManager
+}
+
+type CloudAccessKeySecretMetaData struct {
+	// Type of the cloud provider, e.g. aws-iam
+	Provider string `json:"provider"`
+	// Provider-specific configuration, e.g. AWSIAMProviderConfig
+	Config json.RawMessage `json:"config"`
+	// Set by the server
+	AccessKeyId string    `json:"accessKeyId"`
+	IssueTime   time.Time `json:"issueTime"`
+}
+
+var cloudAccessKeyMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"provider":    {Type: model.SchemaTypeString, Description: "type of the cloud provider, e.g. aws-iam"},
+		"config":      {Type: model.SchemaTypeObject, Description: "provider-specific configuration", AdditionalProperties: true},
+		"accessKeyId": {Type: model.SchemaTypeString, Description: "id of the current access key", ReadOnly: true},
+		"issueTime":   {Type: model.SchemaTypeString, Description: "time the current access key was issued", ReadOnly: true},
+	},
+	Required: []string{"provider"},
+}
+
+// cloudAccessKeyData is the (encrypted) data of a cloud access key secret.
+type cloudAccessKeyData struct {
+	Credentials []byte               `json:"credentials"`
+	AccessKey   model.CloudAccessKey `json:"accessKey"`
+}
+
+func NewCloudAccessKeySecretType() *CloudAccessKeySecretType {
+	return &CloudAccessKeySecretType{}
+}
+
+func (cloudKeyST *CloudAccessKeySecretType) Type() string {
+	return CloudAccessKeySecretTypeName
+}
+
+func (cloudKeyST *CloudAccessKeySecretType) Description() string {
+	return "A cloud access key, issued and rotated by the server through a cloud provider"
+}
+
+func (cloudKeyST *CloudAccessKeySecretType) SecretDataDescription() string {
+	return "credentials of the provider account managing the access key"
+}
+
+func (cloudKeyST *CloudAccessKeySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	cloudKeyST.dataStore = moduleInitContext.DataStore
+	cloudKeyST.keyStore = moduleInitContext.VirtualKeyStore
+	cloudKeyST.auditManager = audit.NewLogAuditManager()
+
+	return CloudProviderRegistrar.InitProviders(moduleInitContext.Config)
+}
+
+func (cloudKeyST *CloudAccessKeySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	cloudKeyMetaData, provider, err := validateCloudAccessKeySecretEntry(secretEntry, secretEntry.SecretData)
+	if err != nil {
+		return "", err
+	}
+
+	accessKey, err := provider.IssueAccessKey(cloudKeyMetaData.Config, secretEntry.SecretData)
+	if err != nil {
+		return "", err
+	}
+
+	if err := cloudKeyST.storeAccessKey(secretEntry, cloudKeyMetaData, secretEntry.SecretData, accessKey, createEncryptedSecret); err != nil {
+		cloudKeyST.revokeAccessKey(provider, cloudKeyMetaData.Config, secretEntry.SecretData, accessKey.AccessKeyId)
+		return "", err
+	}
+
+	cloudKeyST.auditManager.Log(fmt.Sprintf("issued access key %v for %v", accessKey.AccessKeyId, secretEntry.Id))
+
+	return secretEntry.Id, nil
+}
+
+// GetSecret returns the current access key.
+func (cloudKeyST *CloudAccessKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	data, err := decryptCloudAccessKeyData(cloudKeyST.keyStore, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce exposure of the provider's credentials due to memory compromize / leak
+	defer util.Memzero(data.Credentials)
+
+	accessKey, err := json.Marshal(&data.AccessKey)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	secretEntry.SecretData = accessKey
+
+	return secretEntry, nil
+}
+
+// UpdateSecret rotates the access key, using the provided credentials (if any) or the
+// current ones. If the provider or its configuration changed, the current access key is
+// revoked using the current ones, and a new access key is issued using the new ones.
+func (cloudKeyST *CloudAccessKeySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	currentEntry, err := getCurrentSecretEntry(cloudKeyST.dataStore, secretEntry.Id)
+	if err != nil {
+		return "", err
+	}
+
+	currentMetaData, err := getCloudAccessKeyMetaData(currentEntry)
+	if err != nil {
+		return "", err
+	}
+
+	currentData, err := decryptCloudAccessKeyData(cloudKeyST.keyStore, currentEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce exposure of the provider's credentials due to memory compromize / leak
+	defer util.Memzero(currentData.Credentials)
+
+	credentials := secretEntry.SecretData
+	if len(credentials) == 0 {
+		credentials = currentData.Credentials
+	}
+
+	cloudKeyMetaData, provider, err := validateCloudAccessKeySecretEntry(secretEntry, credentials)
+	if err != nil {
+		return "", err
+	}
+
+	var accessKey *model.CloudAccessKey
+	if cloudKeyMetaData.Provider == currentMetaData.Provider && bytes.Equal(cloudKeyMetaData.Config, currentMetaData.Config) {
+		accessKey, err = provider.RotateAccessKey(cloudKeyMetaData.Config, credentials, currentData.AccessKey.AccessKeyId)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		accessKey, err = provider.IssueAccessKey(cloudKeyMetaData.Config, credentials)
+		if err != nil {
+			return "", err
+		}
+
+		if currentProvider, err := CloudProviderRegistrar.Get(currentMetaData.Provider); err == nil {
+			cloudKeyST.revokeAccessKey(currentProvider, currentMetaData.Config, currentData.Credentials, currentData.AccessKey.AccessKeyId)
+		}
+	}
+
+	if err := cloudKeyST.storeAccessKey(secretEntry, cloudKeyMetaData, credentials, accessKey, updateEncryptedSecret); err != nil {
+		// the current access key is already revoked, so the secret is left with no valid key
+		cloudKeyST.revokeAccessKey(provider, cloudKeyMetaData.Config, credentials, accessKey.AccessKeyId)
+		return "", err
+	}
+
+	cloudKeyST.auditManager.Log(fmt.Sprintf("rotated access key %v of %v, replacing %v", accessKey.AccessKeyId, secretEntry.Id, currentData.AccessKey.AccessKeyId))
+
+	return secretEntry.Id, nil
+}
+
+// RotateSecret rotates the access key using the current credentials.
+func (cloudKeyST *CloudAccessKeySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return cloudKeyST.UpdateSecret(ctx, secretEntry)
+}
+
+// DeleteSecret revokes the current access key before deleting the secret.
+func (cloudKeyST *CloudAccessKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	cloudKeyMetaData, err := getCloudAccessKeyMetaData(secretEntry)
+	if err != nil {
+		return err
+	}
+
+	provider, err := CloudProviderRegistrar.Get(cloudKeyMetaData.Provider)
+	if err != nil {
+		return util.ErrInternal
+	}
+
+	data, err := decryptCloudAccessKeyData(cloudKeyST.keyStore, secretEntry)
+	if err != nil {
+		return err
+	}
+
+	// reduce exposure of the provider's credentials due to memory compromize / leak
+	defer util.Memzero(data.Credentials)
+
+	// the access key may have been deleted by other means
+	err = provider.RevokeAccessKey(cloudKeyMetaData.Config, data.Credentials, data.AccessKey.AccessKeyId)
+	if err != nil && err != util.ErrNotFound {
+		return err
+	}
+
+	cloudKeyST.auditManager.Log(fmt.Sprintf("revoked access key %v of %v", data.AccessKey.AccessKeyId, secretEntry.Id))
+
+	return deleteEncryptedSecret(cloudKeyST.dataStore, cloudKeyST.keyStore, secretEntry)
+}
+
+// storeAccessKey records an access key in the secret's meta-data and data, and stores the
+// secret using storeFn.
+func (cloudKeyST *CloudAccessKeySecretType) storeAccessKey(secretEntry *model.SecretEntry, cloudKeyMetaData *CloudAccessKeySecretMetaData, credentials []byte, accessKey *model.CloudAccessKey, storeFn func(vds.DataStoreAdapter, *vks.VirtualKeyStore, *model.SecretEntry, []byte) error) error {
+	cloudKeyMetaData.AccessKeyId = accessKey.AccessKeyId
+	cloudKeyMetaData.IssueTime = time.Now()
+
+	metaData, err := json.Marshal(cloudKeyMetaData)
+	if err != nil {
+		return util.ErrInternal
+	}
+
+	data, err := json.Marshal(&cloudAccessKeyData{Credentials: credentials, AccessKey: *accessKey})
+	if err != nil {
+		return util.ErrInternal
+	}
+
+	// reduce exposure of the provider's credentials due to memory compromize / leak
+	defer util.Memzero(data)
+
+	se := model.NewSecretEntry(secretEntry)
+	se.MetaData = string(metaData)
+
+	return storeFn(cloudKeyST.dataStore, cloudKeyST.keyStore, se, data)
+}
+
+// revokeAccessKey revokes an access key which was issued in vain.
+func (cloudKeyST *CloudAccessKeySecretType) revokeAccessKey(provider CloudProvider, config json.RawMessage, credentials []byte, accessKeyId string) {
+	if err := provider.RevokeAccessKey(config, credentials, accessKeyId); err != nil {
+		cloudKeyST.auditManager.Log(fmt.Sprintf("failed to revoke access key %v: %v", accessKeyId, err))
+	}
+}
+
+func validateCloudAccessKeySecretEntry(secretEntry *model.SecretEntry, credentials []byte) (*CloudAccessKeySecretMetaData, CloudProvider, error) {
+	var cloudKeyMetaData CloudAccessKeySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &cloudKeyMetaData); err != nil {
+		return nil, nil, util.ErrInputValidation
+	}
+
+	provider, err := CloudProviderRegistrar.Get(cloudKeyMetaData.Provider)
+	if err != nil {
+		return nil, nil, util.ErrInputValidation
+	}
+
+	if err := provider.ValidateConfig(cloudKeyMetaData.Config, credentials); err != nil {
+		return nil, nil, err
+	}
+
+	return &cloudKeyMetaData, provider, nil
+}
+
+func getCloudAccessKeyMetaData(secretEntry *model.SecretEntry) (*CloudAccessKeySecretMetaData, error) {
+	var cloudKeyMetaData CloudAccessKeySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &cloudKeyMetaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &cloudKeyMetaData, nil
+}
+
+func decryptCloudAccessKeyData(keyStore *vks.VirtualKeyStore, secretEntry *model.SecretEntry) (*cloudAccessKeyData, error) {
+	secretData, err := decryptSecret(keyStore, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce exposure of the provider's credentials due to memory compromize / leak
+	defer util.Memzero(secretData)
+
+	var data cloudAccessKeyData
+	if err := json.Unmarshal(secretData, &data); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &data, nil
+}
@@ -0,0 +1,179 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestCloudAccessKeyRotation(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	id, err := createCloudAccessKey(mock, "cloud-access-key-id0", "app0", &model.RotationPolicy{Interval: "720h"})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	accessKey, err := getCloudAccessKey(id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if mock.secretAccessKey("app0", accessKey.AccessKeyId) != accessKey.SecretAccessKey {
+		t.Fatalf("Unexpected access key: %v", accessKey)
+	}
+
+	// not due for rotation yet
+	if rotated, err := sm.rotateDueSecret(id, time.Now()); err != nil || rotated {
+		t.Fatalf("Access key rotated before its rotation interval: %v", err)
+	}
+
+	start := time.Now()
+	if rotated, err := sm.rotateDueSecret(id, time.Now().Add(720*time.Hour)); err != nil || !rotated {
+		t.Fatalf("Failed to rotate access key: %v", err)
+	}
+
+	rotatedKey, err := getCloudAccessKey(id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	// readers get the current access key, and the previous one is revoked
+	if keyIds := mock.userKeys("app0"); len(keyIds) != 1 || keyIds[0] != rotatedKey.AccessKeyId || rotatedKey.AccessKeyId == accessKey.AccessKeyId {
+		t.Fatalf("Unexpected access keys after rotation: %v", keyIds)
+	}
+
+	eventEntries, err := sm.ListSecretEvents(context.GetTestRequestContext(), start)
+	if err != nil {
+		t.Fatalf("Failed to list secret events: %v", err)
+	}
+	if len(eventEntries) != 1 || eventEntries[0].Type != model.SecretEventRotated || eventEntries[0].SecretId != id {
+		t.Fatalf("Unexpected secret events: %v", eventEntries)
+	}
+
+	// rotate on demand
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to rotate secret %v: %v", id, err)
+	}
+
+	if keyIds := mock.userKeys("app0"); len(keyIds) != 1 || keyIds[0] == rotatedKey.AccessKeyId {
+		t.Fatalf("Unexpected access keys after rotation: %v", keyIds)
+	}
+}
+
+func TestCloudAccessKeyDeleteRevokesKey(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	id, err := createCloudAccessKey(mock, "cloud-access-key-id1", "app1", nil)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if keyIds := mock.userKeys("app1"); len(keyIds) != 1 {
+		t.Fatalf("Unexpected access keys: %v", keyIds)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret %v: %v", id, err)
+	}
+
+	if keyIds := mock.userKeys("app1"); len(keyIds) != 0 {
+		t.Fatalf("Unexpected access keys after deletion: %v", keyIds)
+	}
+}
+
+func TestCloudAccessKeyRollback(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	id, err := createCloudAccessKey(mock, "cloud-access-key-id3", "app3", nil)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to rotate secret %v: %v", id, err)
+	}
+
+	// the previous access key is revoked
+	if _, err := sm.RollbackSecret(context.GetTestRequestContext(), id, model.FirstSecretVersion); !errors.Is(err, util.ErrInputValidation) {
+		t.Fatalf("Unexpected error when rolling back a cloud access key: %v", err)
+	}
+}
+
+func TestCloudAccessKeyInvalidMetaData(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	invalidMetaData := []string{
+		"not json",
+		`{"provider": "no-such-provider", "config": {}}`,
+		`{"provider": "aws-iam", "config": {}}`,
+	}
+
+	for _, metaData := range invalidMetaData {
+		se := &model.SecretEntry{
+			Id:             "cloud-access-key-id2",
+			Type:           CloudAccessKeySecretTypeName,
+			MetaData:       metaData,
+			SecretData:     mock.credentials(),
+			Owner:          "user0",
+			ExpirationTime: time.Now().Add(time.Hour),
+		}
+
+		if _, err := sm.CreateSecret(context.GetTestRequestContext(), se); !errors.Is(err, util.ErrInputValidation) {
+			t.Fatalf("Unexpected error when creating a secret with meta-data %v: %v", metaData, err)
+		}
+	}
+
+	if keyIds := mock.userKeys("app2"); len(keyIds) != 0 {
+		t.Fatalf("Unexpected access keys: %v", keyIds)
+	}
+}
+
+func createCloudAccessKey(mock *mockIAMServer, id string, userName string, rotationPolicy *model.RotationPolicy) (string, error) {
+	metaData, err := json.Marshal(&CloudAccessKeySecretMetaData{
+		Provider: AWSIAMProviderType,
+		Config:   mock.config(userName),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           CloudAccessKeySecretTypeName,
+		MetaData:       string(metaData),
+		SecretData:     mock.credentials(),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(24 * 365 * time.Hour),
+		RotationPolicy: rotationPolicy,
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
+
+func getCloudAccessKey(id string) (*model.CloudAccessKey, error) {
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	var accessKey model.CloudAccessKey
+	if err := json.Unmarshal(se.SecretData, &accessKey); err != nil {
+		return nil, err
+	}
+
+	return &accessKey, nil
+}
@@ -0,0 +1,80 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// CloudProvider issues, rotates and revokes access keys of a cloud provider's account on
+// behalf of cloud access key secrets. A secret holds the provider's configuration (as part
+// of its meta-data, e.g. the account whose keys are managed) and the credentials used for
+// managing the keys, which are kept encrypted.
+type CloudProvider interface {
+	Type() string
+	Init(cfg *config.Config) error
+	// ValidateConfig validates the configuration and credentials of a secret.
+	ValidateConfig(config json.RawMessage, credentials []byte) error
+	IssueAccessKey(config json.RawMessage, credentials []byte) (*model.CloudAccessKey, error)
+	// RotateAccessKey issues a new access key, and then revokes the given one.
+	RotateAccessKey(config json.RawMessage, credentials []byte, accessKeyId string) (*model.CloudAccessKey, error)
+	RevokeAccessKey(config json.RawMessage, credentials []byte, accessKeyId string) error
+}
+
+// singleton registrar for cloud providers
+var CloudProviderRegistrar *cloudProviderRegistrar = newCloudProviderRegistrar()
+
+type cloudProviderRegistrar struct {
+	providers map[string]CloudProvider
+}
+
+func newCloudProviderRegistrar() *cloudProviderRegistrar {
+	return &cloudProviderRegistrar{
+		providers: make(map[string]CloudProvider),
+	}
+}
+
+func (cpRegistrar *cloudProviderRegistrar) Register(providerType string, provider CloudProvider) error {
+	_, ok := cpRegistrar.providers[providerType]
+	if ok {
+		return util.ErrAlreadyExists
+	}
+
+	cpRegistrar.providers[providerType] = provider
+
+	return nil
+}
+
+func (cpRegistrar *cloudProviderRegistrar) Unregister(providerType string) error {
+	_, ok := cpRegistrar.providers[providerType]
+	if !ok {
+		return util.ErrNotFound
+	}
+
+	delete(cpRegistrar.providers, providerType)
+
+	return nil
+}
+
+func (cpRegistrar *cloudProviderRegistrar) InitProviders(cfg *config.Config) error {
+	for _, provider := range cpRegistrar.providers {
+		if err := provider.Init(cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cpRegistrar *cloudProviderRegistrar) Get(providerType string) (CloudProvider, error) {
+	provider, ok := cpRegistrar.providers[providerType]
+	if !ok {
+		return nil, util.ErrNotFound
+	}
+
+	return provider, nil
+}
@@ -0,0 +1,73 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// CredentialBackend mints and revokes credentials (e.g. database users) on behalf of
+// dynamic credential secrets. A secret holds the backend's configuration (as part of
+// its meta-data) and the data needed to connect to the system the credentials are
+// for (e.g. a database's connection string, including administrative credentials),
+// which is kept encrypted.
+type CredentialBackend interface {
+	Type() string
+	// ValidateConfig validates the configuration and connection data of a secret.
+	ValidateConfig(config json.RawMessage, connectionData []byte) error
+	// CreateCredential mints a credential which should remain valid until the given
+	// expiration time. It returns the credential, as handed to the caller, and the
+	// data needed to revoke it later on.
+	CreateCredential(config json.RawMessage, connectionData []byte, expirationTime time.Time) (credential map[string]string, revocationData map[string]string, err error)
+	// RenewCredential extends the validity of a credential to the given expiration time;
+	// backends whose credentials remain valid until revoked need not do anything.
+	RenewCredential(config json.RawMessage, connectionData []byte, revocationData map[string]string, expirationTime time.Time) error
+	RevokeCredential(config json.RawMessage, connectionData []byte, revocationData map[string]string) error
+}
+
+// singleton registrar for credential backends
+var CredentialBackendRegistrar *credentialBackendRegistrar = newCredentialBackendRegistrar()
+
+type credentialBackendRegistrar struct {
+	backends map[string]CredentialBackend
+}
+
+func newCredentialBackendRegistrar() *credentialBackendRegistrar {
+	return &credentialBackendRegistrar{
+		backends: make(map[string]CredentialBackend),
+	}
+}
+
+func (cbRegistrar *credentialBackendRegistrar) Register(backendType string, backend CredentialBackend) error {
+	_, ok := cbRegistrar.backends[backendType]
+	if ok {
+		return util.ErrAlreadyExists
+	}
+
+	cbRegistrar.backends[backendType] = backend
+
+	return nil
+}
+
+func (cbRegistrar *credentialBackendRegistrar) Unregister(backendType string) error {
+	_, ok := cbRegistrar.backends[backendType]
+	if !ok {
+		return util.ErrNotFound
+	}
+
+	delete(cbRegistrar.backends, backendType)
+
+	return nil
+}
+
+func (cbRegistrar *credentialBackendRegistrar) Get(backendType string) (CredentialBackend, error) {
+	backend, ok := cbRegistrar.backends[backendType]
+	if !ok {
+		return nil, util.ErrNotFound
+	}
+
+	return backend, nil
+}
@@ -0,0 +1,238 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Interval at which the CRL is regenerated, unless configured otherwise.
+const DefaultCRLInterval = time.Hour
+
+// Paths, relative to the configured base URL, at which the CRL and OCSP responder are served.
+const (
+	crlPath  = "/pki/crl"
+	ocspPath = "/pki/ocsp"
+)
+
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// crlCache holds the most recently generated CRLs, by issuer id (empty for the root CA).
+type crlCache struct {
+	lock    sync.RWMutex
+	crlDERs map[string][]byte
+}
+
+// startCRLUpdater generates the CRLs, and starts a goroutine that periodically regenerates them.
+// The CRLs are also regenerated whenever a certificate is revoked.
+func (secretManager *SecretManager) startCRLUpdater(interval time.Duration) {
+	if err := secretManager.updateCRL(time.Now()); err != nil {
+		log.Printf("failed to generate CRL: %v\n", err)
+	}
+
+	secretManager.crlUpdaterStop = make(chan struct{})
+	secretManager.crlUpdaterDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := secretManager.updateCRL(time.Now()); err != nil {
+					log.Printf("failed to generate CRL: %v\n", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(secretManager.crlUpdaterStop, secretManager.crlUpdaterDone)
+}
+
+func (secretManager *SecretManager) stopCRLUpdater() {
+	if secretManager.crlUpdaterStop == nil {
+		return
+	}
+
+	close(secretManager.crlUpdaterStop)
+	<-secretManager.crlUpdaterDone
+
+	secretManager.crlUpdaterStop = nil
+	secretManager.crlUpdaterDone = nil
+}
+
+// GetCRL returns the DER-encoded list of the certificates issued by the given CA (the root
+// CA if issuerId is empty, or otherwise an intermediate CA secret) which were revoked before
+// they expired, signed by the CA.
+func (secretManager *SecretManager) GetCRL(issuerId string) ([]byte, error) {
+	secretManager.crl.lock.RLock()
+	crlDER := secretManager.crl.crlDERs[issuerId]
+	secretManager.crl.lock.RUnlock()
+
+	if crlDER != nil {
+		return crlDER, nil
+	}
+
+	// the CA may have been created since the CRLs were generated; as CRLs are served to
+	// anyone, other ids are refused before looking for revoked certificates
+	if issuerId == "" {
+		return nil, util.ErrNotFound
+	}
+	caEntry, err := secretManager.getUnexpiredSecretEntry(vds.SecretIdToPath(issuerId))
+	if err != nil || caEntry.Type != X509CertificateAuthoritySecretTypeName {
+		return nil, util.ErrNotFound
+	}
+
+	now := time.Now()
+	revokedCerts, err := secretManager.getRevokedCerts(now)
+	if err != nil {
+		return nil, err
+	}
+
+	crlDER, err = secretManager.generateCRL(issuerId, revokedCerts[issuerId], now)
+	if err != nil {
+		return nil, err
+	}
+
+	secretManager.crl.lock.Lock()
+	secretManager.crl.crlDERs[issuerId] = crlDER
+	secretManager.crl.lock.Unlock()
+
+	return crlDER, nil
+}
+
+// updateCRL regenerates the CRLs of the root CA and of all intermediate CAs at time now.
+// A CA whose key is unavailable (e.g. the root CA's, when it's kept offline) is skipped.
+func (secretManager *SecretManager) updateCRL(now time.Time) error {
+	revokedCerts, err := secretManager.getRevokedCerts(now)
+	if err != nil {
+		return err
+	}
+
+	issuerIds := []string{""}
+	err = secretManager.walkSecretEntries(vds.SecretsRootPath, true, func(secretEntry *model.SecretEntry) error {
+		if secretEntry.Type == X509CertificateAuthoritySecretTypeName {
+			issuerIds = append(issuerIds, secretEntry.Id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	crlDERs := make(map[string][]byte)
+	for _, issuerId := range issuerIds {
+		crlDER, err := secretManager.generateCRL(issuerId, revokedCerts[issuerId], now)
+		if err != nil {
+			log.Printf("failed to generate CRL of CA %q: %v\n", issuerId, err)
+			continue
+		}
+
+		crlDERs[issuerId] = crlDER
+	}
+
+	secretManager.crl.lock.Lock()
+	secretManager.crl.crlDERs = crlDERs
+	secretManager.crl.lock.Unlock()
+
+	return nil
+}
+
+// generateCRL returns the CRL of the given CA at time now. The CRL is valid for two
+// update intervals, so that relying parties aren't affected by a missed update.
+func (secretManager *SecretManager) generateCRL(issuerId string, revokedCerts []pkix.RevokedCertificate, now time.Time) ([]byte, error) {
+	issuer, err := getCertIssuer(secretManager.dataStore, secretManager.keyStore, secretManager.cfg, issuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuer.cert.CreateCRL(rand.Reader, issuer.privKey, revokedCerts, now, now.Add(2*secretManager.crlInterval))
+}
+
+// getRevokedCerts returns the certificates revoked before they expired, by issuer id.
+func (secretManager *SecretManager) getRevokedCerts(now time.Time) (map[string][]pkix.RevokedCertificate, error) {
+	revokedCerts := make(map[string][]pkix.RevokedCertificate)
+	err := secretManager.walkCertificateEntries(func(certEntry *model.CertificateEntry) error {
+		// expired certificates are no longer listed
+		if !certEntry.Revoked || now.After(certEntry.NotAfter) {
+			return nil
+		}
+
+		revokedCert, err := certificateEntryToRevokedCertificate(certEntry)
+		if err != nil {
+			return err
+		}
+
+		revokedCerts[certEntry.IssuerId] = append(revokedCerts[certEntry.IssuerId], *revokedCert)
+
+		return nil
+	})
+
+	return revokedCerts, err
+}
+
+// walkCertificateEntries calls walkFn for each certificate issued by the server.
+func (secretManager *SecretManager) walkCertificateEntries(walkFn func(*model.CertificateEntry) error) error {
+	childEntries, err := secretManager.dataStore.SearchChildEntries(vds.CertificatesRootPath)
+	if err != nil {
+		return err
+	}
+
+	for _, childEntry := range childEntries {
+		if !vds.IsCertificateEntry(childEntry) {
+			continue
+		}
+
+		certEntry, err := vds.DataStoreEntryToCertificateEntry(childEntry)
+		if err != nil {
+			return err
+		}
+
+		if err := walkFn(certEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func certificateEntryToRevokedCertificate(certEntry *model.CertificateEntry) (*pkix.RevokedCertificate, error) {
+	serialNumber, ok := new(big.Int).SetString(certEntry.SerialNumber, 16)
+	if !ok {
+		return nil, util.ErrInternal
+	}
+
+	revokedCert := &pkix.RevokedCertificate{
+		SerialNumber:   serialNumber,
+		RevocationTime: certEntry.RevocationTime,
+	}
+
+	// the reason code extension is omitted, rather than set to unspecified (RFC 5280, 5.3.1)
+	reasonCode := model.X509RevocationReasons[certEntry.RevocationReason]
+	if reasonCode != 0 {
+		reasonCodeBytes, err := asn1.Marshal(asn1.Enumerated(reasonCode))
+		if err != nil {
+			return nil, util.ErrInternal
+		}
+
+		revokedCert.Extensions = []pkix.Extension{
+			pkix.Extension{Id: oidExtensionReasonCode, Value: reasonCodeBytes},
+		}
+	}
+
+	return revokedCert, nil
+}
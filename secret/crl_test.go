@@ -0,0 +1,140 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestCRL(t *testing.T) {
+	revokedCert, err := signTestCSR("crl-cert-id0", "crl0.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "crl-cert-id0")
+
+	validCert, err := signTestCSR("crl-cert-id1", "crl1.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "crl-cert-id1")
+
+	revocationRequest := &model.RevocationRequest{
+		SecretId: "crl-cert-id0",
+		Reason:   "cessationOfOperation",
+	}
+	if _, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest); err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+
+	crlDER, err := sm.GetCRL("")
+	if err != nil {
+		t.Fatalf("Failed to get CRL: %v", err)
+	}
+
+	crl, err := x509.ParseDERCRL(crlDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: %v", err)
+	}
+
+	caCert, _, err := getCACertAndKey(config.GenerateTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+	if err := caCert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("CRL is not signed by the CA: %v", err)
+	}
+
+	if crl.HasExpired(time.Now()) {
+		t.Fatalf("CRL has expired")
+	}
+
+	revoked, notRevoked := false, true
+	for _, revokedCertEntry := range crl.TBSCertList.RevokedCertificates {
+		if revokedCertEntry.SerialNumber.Cmp(revokedCert.SerialNumber) == 0 {
+			revoked = true
+			var reasonCode asn1.Enumerated
+			if len(revokedCertEntry.Extensions) != 1 || !revokedCertEntry.Extensions[0].Id.Equal(oidExtensionReasonCode) {
+				t.Fatalf("Unexpected revoked certificate extensions: %v", revokedCertEntry.Extensions)
+			}
+			if _, err := asn1.Unmarshal(revokedCertEntry.Extensions[0].Value, &reasonCode); err != nil || reasonCode != 5 {
+				t.Fatalf("Unexpected revocation reason code: %v", reasonCode)
+			}
+		}
+		if revokedCertEntry.SerialNumber.Cmp(validCert.SerialNumber) == 0 {
+			notRevoked = false
+		}
+	}
+
+	if !revoked || !notRevoked {
+		t.Fatalf("Unexpected CRL entries: %v", crl.TBSCertList.RevokedCertificates)
+	}
+
+	// expired certificates are no longer listed
+	if err := sm.updateCRL(revokedCert.NotAfter.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to update CRL: %v", err)
+	}
+
+	crlDER, err = sm.GetCRL("")
+	if err != nil {
+		t.Fatalf("Failed to get CRL: %v", err)
+	}
+
+	crl, err = x509.ParseDERCRL(crlDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: %v", err)
+	}
+
+	for _, revokedCertEntry := range crl.TBSCertList.RevokedCertificates {
+		if revokedCertEntry.SerialNumber.Cmp(revokedCert.SerialNumber) == 0 {
+			t.Fatalf("Expired certificate is listed in CRL")
+		}
+	}
+
+	if err := sm.updateCRL(time.Now()); err != nil {
+		t.Fatalf("Failed to update CRL: %v", err)
+	}
+}
+
+func TestGetCRLOfUnknownCA(t *testing.T) {
+	id, err := createDataSecret("crl-data-id0", "not a CA")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// CRLs are served to anyone, so unknown CAs don't cost a search of the certificates
+	searchingDS := &searchCountingDS{DataStoreAdapter: sm.dataStore}
+	countingManager := *sm
+	countingManager.dataStore = searchingDS
+
+	for _, issuerId := range []string{"no-such-ca", id} {
+		if _, err := countingManager.GetCRL(issuerId); err != util.ErrNotFound {
+			t.Fatalf("Unexpected error when getting the CRL of %v: %v", issuerId, err)
+		}
+	}
+	if searchingDS.searches != 0 {
+		t.Fatalf("Unexpected searches when getting the CRLs of unknown CAs: %v", searchingDS.searches)
+	}
+}
+
+// searchCountingDS counts the searches of a data store.
+type searchCountingDS struct {
+	vds.DataStoreAdapter
+	searches int
+}
+
+func (ds *searchCountingDS) SearchChildEntries(parentEntryId string) ([]*vds.DataStoreEntry, error) {
+	ds.searches++
+
+	return ds.DataStoreAdapter.SearchChildEntries(parentEntryId)
+}
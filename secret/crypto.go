@@ -0,0 +1,196 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"path"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Private key secret types which may be used for signing.
+var signingKeySecretTypes = map[string]bool{
+	RSAPrivateKeySecretTypeName:     true,
+	ECDSAPrivateKeySecretTypeName:   true,
+	Ed25519PrivateKeySecretTypeName: true,
+}
+
+var hashAlgorithms = map[string]crypto.Hash{
+	model.HashAlgorithmSHA256: crypto.SHA256,
+	model.HashAlgorithmSHA384: crypto.SHA384,
+	model.HashAlgorithmSHA512: crypto.SHA512,
+}
+
+// Sign signs a digest using the current version of a private key secret, without
+// revealing the key. Signing requires sign permission on the key's namespace, so that
+// a key can be used by roles which aren't allowed to read it.
+func (secretManager *SecretManager) Sign(ctx gocontext.Context, signRequest *model.SignRequest) (*model.SignResponse, error) {
+	keyEntry, err := secretManager.getKeyEntry(ctx, signRequest.KeyId, 0, model.OpSign, signingKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := secretManager.getSigner(keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := getSignerOpts(signer.Public(), signRequest.HashAlgorithm, signRequest.SignatureAlgorithm, len(signRequest.Input))
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signer.Sign(rand.Reader, signRequest.Input, opts)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	secretManager.auditManager.Log(fmt.Sprintf("key %v (version %v) signed a %v-byte input", keyEntry.Id, keyEntry.Version, len(signRequest.Input)))
+
+	return &model.SignResponse{
+		KeyId:      keyEntry.Id,
+		KeyVersion: keyEntry.Version,
+		Signature:  signature,
+	}, nil
+}
+
+// Verify verifies a signature using the given version of a private key secret (its
+// current version, if unspecified), so that signatures remain verifiable after the key
+// is rotated. Like signing, verifying requires sign permission on the key's namespace.
+func (secretManager *SecretManager) Verify(ctx gocontext.Context, verifyRequest *model.VerifyRequest) (*model.VerifyResponse, error) {
+	keyEntry, err := secretManager.getKeyEntry(ctx, verifyRequest.KeyId, verifyRequest.KeyVersion, model.OpSign, signingKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := secretManager.getSigner(keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := getSignerOpts(signer.Public(), verifyRequest.HashAlgorithm, verifyRequest.SignatureAlgorithm, len(verifyRequest.Input))
+	if err != nil {
+		return nil, err
+	}
+
+	valid := verifySignature(signer.Public(), verifyRequest.Input, verifyRequest.Signature, opts)
+
+	return &model.VerifyResponse{Valid: valid}, nil
+}
+
+// getKeyEntry returns the given version (or, if zero, the current version) of a key
+// secret of one of the given types, provided that the caller is allowed the given
+// operation on its namespace.
+func (secretManager *SecretManager) getKeyEntry(ctx gocontext.Context, keyId string, version int, opLabel string, keyTypes map[string]bool) (*model.SecretEntry, error) {
+	keyPath := vds.SecretIdToPath(keyId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: opLabel}, path.Dir(keyPath)); err != nil {
+		return nil, err
+	}
+
+	keyEntry, err := secretManager.getUnexpiredSecretEntry(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !keyTypes[keyEntry.Type] {
+		return nil, util.ErrInputValidation
+	}
+
+	if version != 0 && version != keyEntry.Version {
+		return secretManager.getSecretVersionEntry(keyId, version)
+	}
+
+	return keyEntry, nil
+}
+
+func (secretManager *SecretManager) getSigner(keyEntry *model.SecretEntry) (crypto.Signer, error) {
+	pkPEM, err := decryptSecret(secretManager.keyStore, keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(pkPEM)
+
+	signer, err := parsePrivateKeyPEM(pkPEM)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return signer, nil
+}
+
+// getSignerOpts returns the options for signing an input of the given length using a key
+// with the given public key. RSA and ECDSA keys sign digests of the given hash algorithm
+// (default: SHA-256); RSA keys use either PKCS#1 v1.5 (the default) or PSS signatures.
+// Ed25519 keys sign messages, and take neither algorithm.
+func getSignerOpts(pubKey crypto.PublicKey, hashAlgorithm string, signatureAlgorithm string, inputLen int) (crypto.SignerOpts, error) {
+	if _, ok := pubKey.(ed25519.PublicKey); ok {
+		if hashAlgorithm != "" || signatureAlgorithm != "" {
+			return nil, util.ErrInputValidation
+		}
+
+		return crypto.Hash(0), nil
+	}
+
+	if hashAlgorithm == "" {
+		hashAlgorithm = model.HashAlgorithmSHA256
+	}
+
+	hash, ok := hashAlgorithms[hashAlgorithm]
+	if !ok || inputLen != hash.Size() {
+		return nil, util.ErrInputValidation
+	}
+
+	switch pubKey.(type) {
+	case *rsa.PublicKey:
+		switch signatureAlgorithm {
+		case "", model.SignatureAlgorithmPKCS1v15:
+			return hash, nil
+		case model.SignatureAlgorithmPSS:
+			return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}, nil
+		}
+	case *ecdsa.PublicKey:
+		if signatureAlgorithm == "" {
+			return hash, nil
+		}
+	}
+
+	return nil, util.ErrInputValidation
+}
+
+func verifySignature(pubKey crypto.PublicKey, input []byte, signature []byte, opts crypto.SignerOpts) bool {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			return rsa.VerifyPSS(pubKey, pssOpts.Hash, input, signature, pssOpts) == nil
+		}
+
+		return rsa.VerifyPKCS1v15(pubKey, opts.HashFunc(), input, signature) == nil
+	case *ecdsa.PublicKey:
+		var ecdsaSignature struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(signature, &ecdsaSignature); err != nil || len(rest) != 0 {
+			return false
+		}
+
+		return ecdsa.Verify(pubKey, input, ecdsaSignature.R, ecdsaSignature.S)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pubKey, input, signature)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/naoina/denco"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func (secretManager *SecretManager) registerCryptoEndpoints(mux *denco.Mux) []denco.Handler {
+	// swagger:route POST /crypto/sign crypto Sign
+	//
+	// Signs a digest using a private key secret, without revealing the key
+	//
+	//	Responses:
+	//		200: SignResponse
+	sign := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		signRequest, err := model.ExtractAndValidateSignRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		signResponse, err := secretManager.Sign(r.Context(), signRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, signResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /crypto/verify crypto Verify
+	//
+	// Verifies a signature using a private key secret
+	//
+	//	Responses:
+	//		200: VerifyResponse
+	verify := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		verifyRequest, err := model.ExtractAndValidateVerifyRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		verifyResponse, err := secretManager.Verify(r.Context(), verifyRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, verifyResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /crypto/encrypt crypto Encrypt
+	//
+	// Encrypts data using a symmetric key secret, without revealing the key
+	//
+	//	Responses:
+	//		200: EncryptResponse
+	encrypt := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		encryptRequest, err := model.ExtractAndValidateEncryptRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		encryptResponse, err := secretManager.Encrypt(r.Context(), encryptRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, encryptResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /crypto/decrypt crypto Decrypt
+	//
+	// Decrypts a ciphertext using the symmetric key secret version which produced it
+	//
+	//	Responses:
+	//		200: DecryptResponse
+	decrypt := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		decryptRequest, err := model.ExtractAndValidateDecryptRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		decryptResponse, err := secretManager.Decrypt(r.Context(), decryptRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, decryptResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /crypto/rewrap crypto Rewrap
+	//
+	// Re-encrypts a ciphertext using the current version of its symmetric key secret
+	//
+	//	Responses:
+	//		200: EncryptResponse
+	rewrap := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		rewrapRequest, err := model.ExtractAndValidateRewrapRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		rewrapResponse, err := secretManager.Rewrap(r.Context(), rewrapRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, rewrapResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /crypto/hmac crypto HMAC
+	//
+	// Computes the HMAC-SHA256 of an input using an HMAC key secret, without revealing the key
+	//
+	//	Responses:
+	//		200: HMACResponse
+	hmac := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		hmacRequest, err := model.ExtractAndValidateHMACRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		hmacResponse, err := secretManager.HMAC(r.Context(), hmacRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, hmacResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /crypto/derive crypto DeriveKey
+	//
+	// Derives a subkey from an HMAC key secret using HKDF-SHA256
+	//
+	//	Responses:
+	//		200: DeriveKeyResponse
+	deriveKey := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		deriveKeyRequest, err := model.ExtractAndValidateDeriveKeyRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		deriveKeyResponse, err := secretManager.DeriveKey(r.Context(), deriveKeyRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, deriveKeyResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	handlers := []denco.Handler{
+		mux.POST("/crypto/sign", sign),
+		mux.POST("/crypto/verify", verify),
+		mux.POST("/crypto/encrypt", encrypt),
+		mux.POST("/crypto/decrypt", decrypt),
+		mux.POST("/crypto/rewrap", rewrap),
+		mux.POST("/crypto/hmac", hmac),
+		mux.POST("/crypto/derive", deriveKey),
+	}
+
+	return handlers
+}
+
+// swagger:parameters Sign
+type SignRequestParam struct {
+	// in:body
+	SignRequest model.SignRequest
+}
+
+// swagger:response SignResponse
+type SignResponse struct {
+	// in:body
+	SignResponse model.SignResponse
+}
+
+// swagger:parameters Verify
+type VerifyRequestParam struct {
+	// in:body
+	VerifyRequest model.VerifyRequest
+}
+
+// swagger:response VerifyResponse
+type VerifyResponse struct {
+	// in:body
+	VerifyResponse model.VerifyResponse
+}
+
+// swagger:parameters Encrypt
+type EncryptRequestParam struct {
+	// in:body
+	EncryptRequest model.EncryptRequest
+}
+
+// swagger:response EncryptResponse
+type EncryptResponse struct {
+	// in:body
+	EncryptResponse model.EncryptResponse
+}
+
+// swagger:parameters Decrypt
+type DecryptRequestParam struct {
+	// in:body
+	DecryptRequest model.DecryptRequest
+}
+
+// swagger:response DecryptResponse
+type DecryptResponse struct {
+	// in:body
+	DecryptResponse model.DecryptResponse
+}
+
+// swagger:parameters Rewrap
+type RewrapRequestParam struct {
+	// in:body
+	RewrapRequest model.RewrapRequest
+}
+
+// swagger:parameters HMAC
+type HMACRequestParam struct {
+	// in:body
+	HMACRequest model.HMACRequest
+}
+
+// swagger:response HMACResponse
+type HMACResponse struct {
+	// in:body
+	HMACResponse model.HMACResponse
+}
+
+// swagger:parameters DeriveKey
+type DeriveKeyRequestParam struct {
+	// in:body
+	DeriveKeyRequest model.DeriveKeyRequest
+}
+
+// swagger:response DeriveKeyResponse
+type DeriveKeyResponse struct {
+	// in:body
+	DeriveKeyResponse model.DeriveKeyResponse
+}
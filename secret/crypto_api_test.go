@@ -0,0 +1,192 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestAPISignAndVerify(t *testing.T) {
+	keyId, err := createECDSAPrivKey("api-sign-ecdsa-key-id0", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	digest := sha256.Sum256([]byte("api sign"))
+	signRequest := &model.SignRequest{
+		KeyId: keyId,
+		Input: digest[:],
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(signRequest); err != nil {
+		t.Fatalf("failed to marshal sign request %v: %v", signRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/crypto/sign", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var signResponse model.SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signResponse); err != nil {
+		t.Fatalf("Failed to parse sign response: %v", err)
+	}
+
+	verifyRequest := &model.VerifyRequest{
+		KeyId:     keyId,
+		Input:     digest[:],
+		Signature: signResponse.Signature,
+	}
+	body = new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(verifyRequest); err != nil {
+		t.Fatalf("failed to marshal verify request %v: %v", verifyRequest, err)
+	}
+
+	resp2, err := http.Post(fmt.Sprintf("%v/crypto/verify", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp2.Status)
+	}
+
+	var verifyResponse model.VerifyResponse
+	if err := json.NewDecoder(resp2.Body).Decode(&verifyResponse); err != nil {
+		t.Fatalf("Failed to parse verify response: %v", err)
+	}
+	if !verifyResponse.Valid {
+		t.Fatalf("Signature is invalid")
+	}
+
+	// requests without input are rejected
+	resp3, err := http.Post(fmt.Sprintf("%v/crypto/sign", ts.URL), "application/json", bytes.NewBufferString(`{"keyId": "api-sign-ecdsa-key-id0"}`))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp3.Status)
+	}
+}
+
+func TestAPIEncryptAndDecrypt(t *testing.T) {
+	keyId, err := createSymmetricKey("api-transit-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	encryptRequest := &model.EncryptRequest{
+		KeyId:     keyId,
+		Plaintext: []byte("api encrypt"),
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(encryptRequest); err != nil {
+		t.Fatalf("failed to marshal encrypt request %v: %v", encryptRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/crypto/encrypt", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var encryptResponse model.EncryptResponse
+	if err := json.NewDecoder(resp.Body).Decode(&encryptResponse); err != nil {
+		t.Fatalf("Failed to parse encrypt response: %v", err)
+	}
+
+	decryptRequest := &model.DecryptRequest{
+		KeyId:      keyId,
+		Ciphertext: encryptResponse.Ciphertext,
+	}
+	body = new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(decryptRequest); err != nil {
+		t.Fatalf("failed to marshal decrypt request %v: %v", decryptRequest, err)
+	}
+
+	resp2, err := http.Post(fmt.Sprintf("%v/crypto/decrypt", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp2.Status)
+	}
+
+	var decryptResponse model.DecryptResponse
+	if err := json.NewDecoder(resp2.Body).Decode(&decryptResponse); err != nil {
+		t.Fatalf("Failed to parse decrypt response: %v", err)
+	}
+	if !bytes.Equal(decryptResponse.Plaintext, encryptRequest.Plaintext) {
+		t.Fatalf("Decrypted plaintext is different than the original one: %v", string(decryptResponse.Plaintext))
+	}
+}
+
+func TestAPIHMAC(t *testing.T) {
+	keyId, err := createHMACKey("api-hmac-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create HMAC key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	hmacRequest := &model.HMACRequest{
+		KeyId: keyId,
+		Input: []byte("api hmac"),
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(hmacRequest); err != nil {
+		t.Fatalf("failed to marshal hmac request %v: %v", hmacRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/crypto/hmac", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to compute HMAC: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var hmacResponse model.HMACResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hmacResponse); err != nil {
+		t.Fatalf("Failed to parse hmac response: %v", err)
+	}
+	if len(hmacResponse.HMAC) != 32 {
+		t.Fatalf("Unexpected HMAC length: %v", len(hmacResponse.HMAC))
+	}
+
+	// derived keys are limited in length
+	resp2, err := http.Post(fmt.Sprintf("%v/crypto/derive", ts.URL), "application/json", bytes.NewBufferString(`{"keyId": "api-hmac-key-id0", "length": 1024}`))
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp2.Status)
+	}
+}
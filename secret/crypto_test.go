@@ -0,0 +1,144 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKeyId, err := createPrivKey()
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer deletePrivKey(rsaKeyId)
+
+	ecdsaKeyId, err := createECDSAPrivKey("sign-ecdsa-key-id0", "P-384")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), ecdsaKeyId)
+
+	ed25519KeyId, err := createEd25519PrivKey("sign-ed25519-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), ed25519KeyId)
+
+	message := []byte("the quick brown fox jumps over the lazy dog")
+	sha256Digest := sha256.Sum256(message)
+	sha384Digest := sha512.Sum384(message)
+
+	for _, signRequest := range []*model.SignRequest{
+		&model.SignRequest{KeyId: rsaKeyId, Input: sha256Digest[:]},
+		&model.SignRequest{KeyId: rsaKeyId, Input: sha384Digest[:], HashAlgorithm: model.HashAlgorithmSHA384, SignatureAlgorithm: model.SignatureAlgorithmPSS},
+		&model.SignRequest{KeyId: ecdsaKeyId, Input: sha384Digest[:], HashAlgorithm: model.HashAlgorithmSHA384},
+		&model.SignRequest{KeyId: ed25519KeyId, Input: message},
+	} {
+		signResponse, err := sm.Sign(context.GetTestRequestContext(), signRequest)
+		if err != nil {
+			t.Fatalf("Failed to sign using %v: %v", signRequest.KeyId, err)
+		}
+		if signResponse.KeyVersion != model.FirstSecretVersion || len(signResponse.Signature) == 0 {
+			t.Fatalf("Unexpected sign response: %v", signResponse)
+		}
+
+		verifyRequest := &model.VerifyRequest{
+			KeyId:              signRequest.KeyId,
+			Input:              signRequest.Input,
+			HashAlgorithm:      signRequest.HashAlgorithm,
+			SignatureAlgorithm: signRequest.SignatureAlgorithm,
+			Signature:          signResponse.Signature,
+		}
+		verifyResponse, err := sm.Verify(context.GetTestRequestContext(), verifyRequest)
+		if err != nil {
+			t.Fatalf("Failed to verify using %v: %v", signRequest.KeyId, err)
+		}
+		if !verifyResponse.Valid {
+			t.Fatalf("Signature of %v is invalid", signRequest.KeyId)
+		}
+
+		// a signature of another input is invalid
+		verifyRequest.Input = append([]byte{}, signRequest.Input...)
+		verifyRequest.Input[0] ^= 0xff
+		verifyResponse, err = sm.Verify(context.GetTestRequestContext(), verifyRequest)
+		if err != nil {
+			t.Fatalf("Failed to verify using %v: %v", signRequest.KeyId, err)
+		}
+		if verifyResponse.Valid {
+			t.Fatalf("Signature of %v is valid for another input", signRequest.KeyId)
+		}
+	}
+}
+
+func TestVerifyWithRotatedKey(t *testing.T) {
+	keyId, err := createECDSAPrivKey("sign-ecdsa-key-id1", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	digest := sha256.Sum256([]byte("rotated"))
+	signResponse, err := sm.Sign(context.GetTestRequestContext(), &model.SignRequest{KeyId: keyId, Input: digest[:]})
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), keyId); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+
+	// the signature is verified using the key version that produced it
+	verifyRequest := &model.VerifyRequest{
+		KeyId:     keyId,
+		Input:     digest[:],
+		Signature: signResponse.Signature,
+	}
+	for version, expectedValid := range map[int]bool{0: false, signResponse.KeyVersion: true} {
+		verifyRequest.KeyVersion = version
+		verifyResponse, err := sm.Verify(context.GetTestRequestContext(), verifyRequest)
+		if err != nil {
+			t.Fatalf("Failed to verify using version %v: %v", version, err)
+		}
+		if verifyResponse.Valid != expectedValid {
+			t.Fatalf("Unexpected validity of signature using version %v: %v", version, verifyResponse.Valid)
+		}
+	}
+}
+
+func TestSignBadRequest(t *testing.T) {
+	keyId, err := createECDSAPrivKey("sign-ecdsa-key-id2", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	dataId, err := createExpiringDataSecret("sign-data-id0", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), dataId)
+
+	digest := sha256.Sum256([]byte("bad request"))
+
+	for _, signRequest := range []*model.SignRequest{
+		// the input isn't a digest of the hash algorithm
+		&model.SignRequest{KeyId: keyId, Input: digest[:16]},
+		&model.SignRequest{KeyId: keyId, Input: digest[:], HashAlgorithm: "md5"},
+		// ECDSA signatures have no variants
+		&model.SignRequest{KeyId: keyId, Input: digest[:], SignatureAlgorithm: model.SignatureAlgorithmPSS},
+		// only private keys sign
+		&model.SignRequest{KeyId: dataId, Input: digest[:]},
+	} {
+		if _, err := sm.Sign(context.GetTestRequestContext(), signRequest); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when signing %v: %v", signRequest, err)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 
 	"github.com/vmware/virtual-security-module/context"
-	"github.com/vmware/virtual-security-module/crypt"
 	"github.com/vmware/virtual-security-module/model"
 	"github.com/vmware/virtual-security-module/util"
 	"github.com/vmware/virtual-security-module/vds"
@@ -16,6 +15,9 @@ import (
 
 const DataSecretTypeName = "Data"
 
+// Maximal size of the data of a data secret.
+const MaxDataSecretSize = 1024 * 1024
+
 func init() {
 	if err := SecretTypeRegistrar.Register(DataSecretTypeName, NewDataSecretType()); err != nil {
 		panic(fmt.Sprintf("Failed to register secret type %v: %v", DataSecretTypeName, err))
@@ -37,6 +39,14 @@ func (dataST *DataSecretType) Type() string {
 	return DataSecretTypeName
 }
 
+func (dataST *DataSecretType) Description() string {
+	return "Data provided by the client, opaque to the server"
+}
+
+func (dataST *DataSecretType) SecretDataDescription() string {
+	return "the secret data"
+}
+
 func (dataST *DataSecretType) Init(moduleInitContext *context.ModuleInitContext) error {
 	dataST.dataStore = moduleInitContext.DataStore
 	dataST.keyStore = moduleInitContext.VirtualKeyStore
@@ -44,42 +54,24 @@ func (dataST *DataSecretType) Init(moduleInitContext *context.ModuleInitContext)
 	return nil
 }
 
-func (dataST *DataSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+// Validate checks that a data secret has data, of a reasonable size.
+func (dataST *DataSecretType) Validate(secretEntry *model.SecretEntry) error {
 	if len(secretEntry.SecretData) == 0 {
-		return "", util.ErrInputValidation
+		return util.NewValidationError([]util.FieldError{{Field: "secretData", Message: "is required"}})
 	}
-
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-
-	// generate encryption key for secret
-	key, err := crypt.GenerateKey()
-	if err != nil {
-		return "", util.ErrInternal
+	if len(secretEntry.SecretData) > MaxDataSecretSize {
+		return util.NewValidationError([]util.FieldError{{Field: "secretData", Message: fmt.Sprintf("should be at most %v bytes long", MaxDataSecretSize)}})
 	}
 
-	// reduce key exposure due to memory compromize / leak
-	defer util.Memzero(key)
-
-	// encrypt secret data using key
-	encryptedSecretData, err := crypt.Encrypt(secretEntry.SecretData, key)
-	if err != nil {
-		return "", util.ErrInternal
-	}
-
-	se := model.NewSecretEntry(secretEntry)
-	se.SecretData = encryptedSecretData
+	return nil
+}
 
-	// create a data store entry and save it
-	dataStoreEntry, err := vds.SecretEntryToDataStoreEntry(se)
-	if err != nil {
-		return "", err
-	}
-	if err := dataST.dataStore.CreateEntry(dataStoreEntry); err != nil {
-		return "", err
+func (dataST *DataSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if len(secretEntry.SecretData) == 0 {
+		return "", util.ErrInputValidation
 	}
 
-	// persist key using virtual key store
-	if err := dataST.keyStore.Create(secretPath, key); err != nil {
+	if err := createEncryptedSecret(dataST.dataStore, dataST.keyStore, secretEntry, secretEntry.SecretData); err != nil {
 		return "", err
 	}
 
@@ -87,39 +79,29 @@ func (dataST *DataSecretType) CreateSecret(ctx gocontext.Context, secretEntry *m
 }
 
 func (dataST *DataSecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-
-	// fetch encryption key
-	key, err := dataST.keyStore.Read(secretPath)
+	decryptedData, err := decryptSecret(dataST.keyStore, secretEntry)
 	if err != nil {
 		return nil, err
 	}
 
-	// reduce key exposure due to memory compromize / leak
-	defer util.Memzero(key)
-
-	// decrypt secret data using key
-	decryptedData, err := crypt.Decrypt(secretEntry.SecretData, key)
-	if err != nil {
-		return nil, util.ErrInternal
-	}
-
 	// set decrypted data
 	secretEntry.SecretData = decryptedData
 
 	return secretEntry, nil
 }
 
-func (dataST *DataSecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-
-	if err := dataST.dataStore.DeleteEntry(secretPath); err != nil {
-		return err
+func (dataST *DataSecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if len(secretEntry.SecretData) == 0 {
+		return "", util.ErrInputValidation
 	}
 
-	if err := dataST.keyStore.Delete(secretPath); err != nil {
-		return err
+	if err := updateEncryptedSecret(dataST.dataStore, dataST.keyStore, secretEntry, secretEntry.SecretData); err != nil {
+		return "", err
 	}
 
-	return nil
+	return secretEntry.Id, nil
+}
+
+func (dataST *DataSecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(dataST.dataStore, dataST.keyStore, secretEntry)
 }
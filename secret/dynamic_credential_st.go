@@ -0,0 +1,359 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vmware/virtual-security-module/audit"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const (
+	DynamicCredentialSecretTypeName = "DynamicCredential"
+
+	// Lease period of minted credentials, unless the secret's meta-data says otherwise.
+	DefaultCredentialTTL = time.Hour
+	// Period, since being minted, beyond which a credential's lease can't be renewed,
+	// unless the secret's meta-data says otherwise.
+	DefaultMaxCredentialTTL = 24 * time.Hour
+)
+
+func init() {
+	if err := SecretTypeRegistrar.Register(DynamicCredentialSecretTypeName, NewDynamicCredentialSecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", DynamicCredentialSecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(DynamicCredentialSecretTypeName, dynamicCredentialMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", DynamicCredentialSecretTypeName, err))
+	}
+}
+
+// A secret type which mints a fresh credential (e.g. a database user) using a credential
+// backend each time it's read. The secret's data is the backend's connection data, which
+// is kept encrypted and never returned; reading the secret returns a LeasedCredential
+// instead. Each credential is leased for the secret's TTL, which can be renewed up to the
+// secret's maximal TTL, and is revoked by the reaper once its lease expires, or when the
+// secret is deleted.
+type DynamicCredentialSecretType struct {
+	dataStore    vds.DataStoreAdapter
+	keyStore     *vks.VirtualKeyStore
+	auditManager audit.AuditManager
+}
+
+type DynamicCredentialSecretMetaData struct {
+	// Type of the credential backend, e.g. sql
+	Backend string `json:"backend"`
+	// Lease period of minted credentials, e.g. 30m (default: 1h)
+	TTL string `json:"ttl"`
+	// Period, since being minted, beyond which leases can't be renewed, e.g. 8h (default: 24h)
+	MaxTTL string `json:"maxTTL"`
+	// Backend-specific configuration, e.g. SQLCredentialBackendConfig
+	Config json.RawMessage `json:"config"`
+}
+
+var dynamicCredentialMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"backend": {Type: model.SchemaTypeString, Description: "type of the credential backend, e.g. sql"},
+		"ttl":     {Type: model.SchemaTypeString, Description: "lease period of minted credentials (default: 1h)", Format: model.SchemaFormatDuration},
+		"maxTTL":  {Type: model.SchemaTypeString, Description: "period, since being minted, beyond which leases can't be renewed (default: 24h)", Format: model.SchemaFormatDuration},
+		"config":  {Type: model.SchemaTypeObject, Description: "backend-specific configuration", AdditionalProperties: true},
+	},
+	Required: []string{"backend"},
+}
+
+func NewDynamicCredentialSecretType() *DynamicCredentialSecretType {
+	return &DynamicCredentialSecretType{}
+}
+
+func (dynCredST *DynamicCredentialSecretType) Type() string {
+	return DynamicCredentialSecretTypeName
+}
+
+func (dynCredST *DynamicCredentialSecretType) Description() string {
+	return "Short-lived credentials, minted by a backend (e.g. a database) whenever the secret is read"
+}
+
+func (dynCredST *DynamicCredentialSecretType) SecretDataDescription() string {
+	return "connection string of the backend, e.g. a database URL"
+}
+
+func (dynCredST *DynamicCredentialSecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	dynCredST.dataStore = moduleInitContext.DataStore
+	dynCredST.keyStore = moduleInitContext.VirtualKeyStore
+	dynCredST.auditManager = audit.NewLogAuditManager()
+
+	return nil
+}
+
+func (dynCredST *DynamicCredentialSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if err := validateDynamicCredentialSecretEntry(secretEntry); err != nil {
+		return "", err
+	}
+
+	if err := createEncryptedSecret(dynCredST.dataStore, dynCredST.keyStore, secretEntry, secretEntry.SecretData); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+// GetSecret mints a new credential, leased to the caller.
+func (dynCredST *DynamicCredentialSecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	dynCredMetaData, err := getDynamicCredentialMetaData(secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := CredentialBackendRegistrar.Get(dynCredMetaData.Backend)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	ttl, _, err := getCredentialTTLs(dynCredMetaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	// credentials don't outlive the secret that minted them
+	now := time.Now()
+	expirationTime := now.Add(ttl)
+	if !secretEntry.ExpirationTime.IsZero() && secretEntry.ExpirationTime.Before(expirationTime) {
+		expirationTime = secretEntry.ExpirationTime
+	}
+
+	connectionData, err := decryptSecret(dynCredST.keyStore, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce exposure of connection credentials due to memory compromize / leak
+	defer util.Memzero(connectionData)
+
+	credential, revocationData, err := backend.CreateCredential(dynCredMetaData.Config, connectionData, expirationTime)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseEntry := &model.LeaseEntry{
+		Id:             newLeaseId(secretEntry.Id),
+		SecretId:       secretEntry.Id,
+		SecretVersion:  secretEntry.Version,
+		Owner:          requestUsername(ctx),
+		CreationTime:   now,
+		ExpirationTime: expirationTime,
+		RevocationData: revocationData,
+	}
+
+	if err := createLeaseEntry(dynCredST.dataStore, leaseEntry); err != nil {
+		// a credential without a lease would never be revoked
+		if e := backend.RevokeCredential(dynCredMetaData.Config, connectionData, revocationData); e != nil {
+			dynCredST.auditManager.Log(fmt.Sprintf("failed to revoke credential minted by %v, which couldn't be leased: %v", secretEntry.Id, e))
+		}
+		return nil, err
+	}
+
+	dynCredST.auditManager.Log(fmt.Sprintf("%v was leased a credential by %v as %v, expiring at %v", leaseEntry.Owner, secretEntry.Id, leaseEntry.Id, leaseEntry.ExpirationTime))
+
+	leasedCredential := &model.LeasedCredential{
+		LeaseId:        leaseEntry.Id,
+		ExpirationTime: leaseEntry.ExpirationTime,
+		Credential:     credential,
+	}
+
+	secretData, err := json.Marshal(leasedCredential)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	secretEntry.SecretData = secretData
+
+	return secretEntry, nil
+}
+
+// UpdateSecret replaces the backend's configuration and connection data; credentials
+// leased so far are revoked using the new ones.
+func (dynCredST *DynamicCredentialSecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if err := validateDynamicCredentialSecretEntry(secretEntry); err != nil {
+		return "", err
+	}
+
+	if err := updateEncryptedSecret(dynCredST.dataStore, dynCredST.keyStore, secretEntry, secretEntry.SecretData); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+// DeleteSecret revokes the credentials leased by the secret before deleting it.
+func (dynCredST *DynamicCredentialSecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	leaseEntries := make([]*model.LeaseEntry, 0)
+	err := walkLeaseEntries(dynCredST.dataStore, func(leaseEntry *model.LeaseEntry) error {
+		if leaseEntry.SecretId == secretEntry.Id {
+			leaseEntries = append(leaseEntries, leaseEntry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, leaseEntry := range leaseEntries {
+		if err := dynCredST.RevokeLease(secretEntry, leaseEntry); err != nil {
+			return err
+		}
+	}
+
+	return deleteEncryptedSecret(dynCredST.dataStore, dynCredST.keyStore, secretEntry)
+}
+
+// RenewLease extends the lease of a credential, updating the credential's validity if the
+// backend enforces it (e.g. using a database's user expiration).
+func (dynCredST *DynamicCredentialSecretType) RenewLease(secretEntry *model.SecretEntry, leaseEntry *model.LeaseEntry, increment time.Duration) (time.Time, error) {
+	dynCredMetaData, err := getDynamicCredentialMetaData(secretEntry)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	backend, err := CredentialBackendRegistrar.Get(dynCredMetaData.Backend)
+	if err != nil {
+		return time.Time{}, util.ErrInternal
+	}
+
+	ttl, maxTTL, err := getCredentialTTLs(dynCredMetaData)
+	if err != nil {
+		return time.Time{}, util.ErrInternal
+	}
+	if increment == 0 {
+		increment = ttl
+	}
+
+	expirationTime := time.Now().Add(increment)
+	if maxExpirationTime := leaseEntry.CreationTime.Add(maxTTL); maxExpirationTime.Before(expirationTime) {
+		expirationTime = maxExpirationTime
+	}
+	if !secretEntry.ExpirationTime.IsZero() && secretEntry.ExpirationTime.Before(expirationTime) {
+		expirationTime = secretEntry.ExpirationTime
+	}
+
+	// leases aren't shortened by renewing them
+	if !expirationTime.After(leaseEntry.ExpirationTime) {
+		return leaseEntry.ExpirationTime, nil
+	}
+
+	connectionData, err := decryptSecret(dynCredST.keyStore, secretEntry)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// reduce exposure of connection credentials due to memory compromize / leak
+	defer util.Memzero(connectionData)
+
+	if err := backend.RenewCredential(dynCredMetaData.Config, connectionData, leaseEntry.RevocationData, expirationTime); err != nil {
+		return time.Time{}, err
+	}
+
+	renewedEntry := *leaseEntry
+	renewedEntry.ExpirationTime = expirationTime
+	if err := updateLeaseEntry(dynCredST.dataStore, &renewedEntry); err != nil {
+		return time.Time{}, err
+	}
+
+	dynCredST.auditManager.Log(fmt.Sprintf("renewed the credential leased to %v as %v until %v", leaseEntry.Owner, leaseEntry.Id, expirationTime))
+
+	return expirationTime, nil
+}
+
+// RevokeLease revokes the credential of a lease using the given version of the secret
+// which minted it, and deletes the lease.
+func (dynCredST *DynamicCredentialSecretType) RevokeLease(secretEntry *model.SecretEntry, leaseEntry *model.LeaseEntry) error {
+	dynCredMetaData, err := getDynamicCredentialMetaData(secretEntry)
+	if err != nil {
+		return err
+	}
+
+	backend, err := CredentialBackendRegistrar.Get(dynCredMetaData.Backend)
+	if err != nil {
+		return util.ErrInternal
+	}
+
+	connectionData, err := decryptSecret(dynCredST.keyStore, secretEntry)
+	if err != nil {
+		return err
+	}
+
+	// reduce exposure of connection credentials due to memory compromize / leak
+	defer util.Memzero(connectionData)
+
+	if err := backend.RevokeCredential(dynCredMetaData.Config, connectionData, leaseEntry.RevocationData); err != nil {
+		return err
+	}
+
+	if err := deleteLeaseEntry(dynCredST.dataStore, leaseEntry.Id); err != nil {
+		return err
+	}
+
+	dynCredST.auditManager.Log(fmt.Sprintf("revoked the credential leased to %v as %v, expiring at %v", leaseEntry.Owner, leaseEntry.Id, leaseEntry.ExpirationTime))
+
+	return nil
+}
+
+func validateDynamicCredentialSecretEntry(secretEntry *model.SecretEntry) error {
+	var dynCredMetaData DynamicCredentialSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &dynCredMetaData); err != nil {
+		return util.ErrInputValidation
+	}
+
+	backend, err := CredentialBackendRegistrar.Get(dynCredMetaData.Backend)
+	if err != nil {
+		return util.ErrInputValidation
+	}
+
+	ttl, maxTTL, err := getCredentialTTLs(&dynCredMetaData)
+	if err != nil || ttl <= 0 || maxTTL < ttl {
+		return util.ErrInputValidation
+	}
+
+	return backend.ValidateConfig(dynCredMetaData.Config, secretEntry.SecretData)
+}
+
+func getDynamicCredentialMetaData(secretEntry *model.SecretEntry) (*DynamicCredentialSecretMetaData, error) {
+	var dynCredMetaData DynamicCredentialSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &dynCredMetaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &dynCredMetaData, nil
+}
+
+// getCredentialTTLs returns the lease period and maximal lease period of the credentials
+// minted by a secret.
+func getCredentialTTLs(dynCredMetaData *DynamicCredentialSecretMetaData) (time.Duration, time.Duration, error) {
+	ttl := DefaultCredentialTTL
+	if dynCredMetaData.TTL != "" {
+		d, err := time.ParseDuration(dynCredMetaData.TTL)
+		if err != nil {
+			return 0, 0, err
+		}
+		ttl = d
+	}
+
+	maxTTL := DefaultMaxCredentialTTL
+	if dynCredMetaData.MaxTTL != "" {
+		d, err := time.ParseDuration(dynCredMetaData.MaxTTL)
+		if err != nil {
+			return 0, 0, err
+		}
+		maxTTL = d
+	}
+
+	return ttl, maxTTL, nil
+}
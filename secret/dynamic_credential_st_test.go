@@ -0,0 +1,199 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestDynamicCredentialLeaseExpiration(t *testing.T) {
+	dsn := "dynamic-credential-dsn0"
+	id, err := createDynamicCredential("dynamic-credential-id0", dsn, "10m")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	leasedCredential, err := getLeasedCredential(id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if !strings.HasPrefix(leasedCredential.LeaseId, id+"/") || leasedCredential.Credential["password"] == "" {
+		t.Fatalf("Unexpected leased credential: %v", leasedCredential)
+	}
+
+	if leasedCredential.ExpirationTime.After(time.Now().Add(10 * time.Minute)) {
+		t.Fatalf("Credential leased past its TTL: %v", leasedCredential.ExpirationTime)
+	}
+
+	leaseEntry, err := getLeaseEntry(sm.dataStore, leasedCredential.LeaseId)
+	if err != nil {
+		t.Fatalf("Failed to get lease %v: %v", leasedCredential.LeaseId, err)
+	}
+
+	if leaseEntry.SecretId != id || leaseEntry.Owner != "root" {
+		t.Fatalf("Unexpected lease: %v", leaseEntry)
+	}
+
+	// leases which haven't expired aren't revoked
+	if revoked := sm.revokeExpiredLeases(time.Now()); revoked != 0 {
+		t.Fatalf("Revoked %v leases before they expired", revoked)
+	}
+
+	if revoked := sm.revokeExpiredLeases(leasedCredential.ExpirationTime); revoked != 1 {
+		t.Fatalf("Unexpected number of leases revoked: %v", revoked)
+	}
+
+	executed := testSQL.executed(dsn)
+	if len(executed) != 2 || executed[1] != "DROP USER "+leasedCredential.Credential["username"] {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+
+	if _, err := getLeaseEntry(sm.dataStore, leasedCredential.LeaseId); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when getting a revoked lease: %v", err)
+	}
+}
+
+func TestDynamicCredentialLeaseRevokedOnce(t *testing.T) {
+	dsn := "dynamic-credential-dsn3"
+	id, err := createDynamicCredential("dynamic-credential-id3", dsn, "10m")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	leasedCredential, err := getLeasedCredential(id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	// a lease being revoked by another server is left alone
+	lockName := vds.LeaseIdToPath(leasedCredential.LeaseId)
+	if locked, err := vds.TryLock(sm.dataStore, lockName, "another-node", time.Minute); err != nil || !locked {
+		t.Fatalf("Failed to lock lease %v: %v", leasedCredential.LeaseId, err)
+	}
+
+	if revoked := sm.revokeExpiredLeases(leasedCredential.ExpirationTime); revoked != 0 {
+		t.Fatalf("Revoked %v leases locked by another server", revoked)
+	}
+
+	if err := vds.Unlock(sm.dataStore, lockName, "another-node"); err != nil {
+		t.Fatalf("Failed to unlock lease %v: %v", leasedCredential.LeaseId, err)
+	}
+
+	if revoked := sm.revokeExpiredLeases(leasedCredential.ExpirationTime); revoked != 1 {
+		t.Fatalf("Unexpected number of leases revoked: %v", revoked)
+	}
+
+	// a lease which was already revoked isn't revoked again
+	if _, err := sm.revokeExpiredLease(leasedCredential.LeaseId, leasedCredential.ExpirationTime); err != nil {
+		t.Fatalf("Unexpected error when revoking a revoked lease: %v", err)
+	}
+
+	if executed := testSQL.executed(dsn); len(executed) != 2 {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+}
+
+func TestDynamicCredentialDeleteRevokesLeases(t *testing.T) {
+	dsn := "dynamic-credential-dsn1"
+	id, err := createDynamicCredential("dynamic-credential-id1", dsn, "")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	leasedCredentials := make([]*model.LeasedCredential, 2)
+	for i := range leasedCredentials {
+		if leasedCredentials[i], err = getLeasedCredential(id); err != nil {
+			t.Fatalf("Failed to get secret for id %v: %v", id, err)
+		}
+	}
+
+	if leasedCredentials[0].Credential["username"] == leasedCredentials[1].Credential["username"] {
+		t.Fatalf("The same credential was leased twice")
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret %v: %v", id, err)
+	}
+
+	if executed := testSQL.executed(dsn); len(executed) != 4 {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+
+	for _, leasedCredential := range leasedCredentials {
+		if _, err := getLeaseEntry(sm.dataStore, leasedCredential.LeaseId); err != util.ErrNotFound {
+			t.Fatalf("Unexpected error when getting lease %v of a deleted secret: %v", leasedCredential.LeaseId, err)
+		}
+	}
+}
+
+func TestDynamicCredentialInvalidMetaData(t *testing.T) {
+	invalidMetaData := []string{
+		"not json",
+		`{"backend": "no-such-backend", "config": {}}`,
+		`{"backend": "sql", "ttl": "forever", "config": ` + string(testSQLConfig("CREATE USER {{.Name}}")) + `}`,
+		`{"backend": "sql", "config": ` + string(testSQLConfig()) + `}`,
+	}
+
+	for _, metaData := range invalidMetaData {
+		se := &model.SecretEntry{
+			Id:             "dynamic-credential-id2",
+			Type:           DynamicCredentialSecretTypeName,
+			MetaData:       metaData,
+			SecretData:     []byte("dsn"),
+			Owner:          "user0",
+			ExpirationTime: time.Now().Add(time.Hour),
+		}
+
+		if _, err := sm.CreateSecret(context.GetTestRequestContext(), se); !errors.Is(err, util.ErrInputValidation) {
+			t.Fatalf("Unexpected error when creating a secret with meta-data %v: %v", metaData, err)
+		}
+	}
+}
+
+func createDynamicCredential(id string, dsn string, ttl string) (string, error) {
+	metaData, err := json.Marshal(&DynamicCredentialSecretMetaData{
+		Backend: SQLCredentialBackendType,
+		TTL:     ttl,
+		Config:  testSQLConfig("CREATE USER {{.Name}} PASSWORD '{{.Password}}'"),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           DynamicCredentialSecretTypeName,
+		MetaData:       string(metaData),
+		SecretData:     []byte(dsn),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
+
+func getLeasedCredential(id string) (*model.LeasedCredential, error) {
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	var leasedCredential model.LeasedCredential
+	if err := json.Unmarshal(se.SecretData, &leasedCredential); err != nil {
+		return nil, err
+	}
+
+	return &leasedCredential, nil
+}
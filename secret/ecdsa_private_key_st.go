@@ -0,0 +1,149 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const ECDSAPrivateKeySecretTypeName = "ECDSAPrivateKey"
+
+func init() {
+	if err := SecretTypeRegistrar.Register(ECDSAPrivateKeySecretTypeName, NewECDSAPrivateKeySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", ECDSAPrivateKeySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(ECDSAPrivateKeySecretTypeName, ecdsaPrivateKeyMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", ECDSAPrivateKeySecretTypeName, err))
+	}
+}
+
+// A secret type whose data is a generated ECDSA private key, PEM-encoded as PKCS#8.
+type ECDSAPrivateKeySecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+}
+
+type ECDSAPrivateKeySecretMetaData struct {
+	// One of: P-256, P-384
+	Curve string `json:"curve"`
+}
+
+var ecdsaPrivateKeyMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"curve": {Type: model.SchemaTypeString, Description: "elliptic curve", Enum: []string{"P-256", "P-384"}},
+	},
+	Required: []string{"curve"},
+}
+
+func NewECDSAPrivateKeySecretType() *ECDSAPrivateKeySecretType {
+	return &ECDSAPrivateKeySecretType{}
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) Type() string {
+	return ECDSAPrivateKeySecretTypeName
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) Description() string {
+	return "An ECDSA private key, generated by the server"
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	ecdsaPrivKeyST.dataStore = moduleInitContext.DataStore
+	ecdsaPrivKeyST.keyStore = moduleInitContext.VirtualKeyStore
+
+	return nil
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	pkPEM, err := generateECDSAPrivateKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := createEncryptedSecret(ecdsaPrivKeyST.dataStore, ecdsaPrivKeyST.keyStore, secretEntry, pkPEM); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	pkPEM, err := decryptSecret(ecdsaPrivKeyST.keyStore, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// set decrypted data
+	secretEntry.SecretData = pkPEM
+
+	return secretEntry, nil
+}
+
+// UpdateSecret generates a new private key, replacing the current one.
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	pkPEM, err := generateECDSAPrivateKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := updateEncryptedSecret(ecdsaPrivKeyST.dataStore, ecdsaPrivKeyST.keyStore, secretEntry, pkPEM); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+// RotateSecret generates a new private key on the same curve.
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return ecdsaPrivKeyST.UpdateSecret(ctx, secretEntry)
+}
+
+func (ecdsaPrivKeyST *ECDSAPrivateKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(ecdsaPrivKeyST.dataStore, ecdsaPrivKeyST.keyStore, secretEntry)
+}
+
+func generateECDSAPrivateKey(secretEntry *model.SecretEntry) ([]byte, error) {
+	var ecdsaPrivKeySTMetaData ECDSAPrivateKeySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &ecdsaPrivKeySTMetaData); err != nil {
+		return nil, util.ErrInputValidation
+	}
+
+	var curve elliptic.Curve
+	switch ecdsaPrivKeySTMetaData.Curve {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	default:
+		return nil, util.ErrInputValidation
+	}
+
+	// we expect the input to contain no data, as we're generating the data
+	// (the private key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	pk, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePKCS8PrivateKey(pk)
+}
@@ -0,0 +1,67 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestCreateAndGetECDSAPrivateKeySecret(t *testing.T) {
+	for _, curve := range []string{"P-256", "P-384"} {
+		id, err := createECDSAPrivKey("ecdsa-id-"+curve, curve)
+		if err != nil {
+			t.Fatalf("Failed to create secret: %v", err)
+		}
+
+		se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+		if err != nil {
+			t.Fatalf("Failed to get secret for id %v: %v", id, err)
+		}
+
+		block, _ := pem.Decode(se.SecretData)
+		if block == nil {
+			t.Fatalf("Failed to decode returned private key")
+		}
+
+		pk, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse returned key as PKCS#8 private key: %v", err)
+		}
+
+		ecdsaPk, ok := pk.(*ecdsa.PrivateKey)
+		if !ok || ecdsaPk.Curve.Params().Name != curve {
+			t.Fatalf("Returned key is not an ECDSA %v private key", curve)
+		}
+
+		if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+			t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+		}
+	}
+}
+
+func TestCreateECDSAPrivateKeySecretWithInvalidCurve(t *testing.T) {
+	if _, err := createECDSAPrivKey("ecdsa-invalid-id", "P-224"); err == nil {
+		t.Fatalf("Succeeded to create an ECDSA private key with an unsupported curve")
+	}
+}
+
+func createECDSAPrivKey(id, curve string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           ECDSAPrivateKeySecretTypeName,
+		MetaData:       fmt.Sprintf("{\"curve\": \"%v\"}", curve),
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
@@ -0,0 +1,122 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const Ed25519PrivateKeySecretTypeName = "Ed25519PrivateKey"
+
+func init() {
+	if err := SecretTypeRegistrar.Register(Ed25519PrivateKeySecretTypeName, NewEd25519PrivateKeySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", Ed25519PrivateKeySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(Ed25519PrivateKeySecretTypeName, ed25519PrivateKeyMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", Ed25519PrivateKeySecretTypeName, err))
+	}
+}
+
+var ed25519PrivateKeyMetaDataSchema = &model.Schema{Type: model.SchemaTypeObject}
+
+// A secret type whose data is a generated Ed25519 private key, PEM-encoded as PKCS#8.
+// Ed25519 keys have a fixed size, so the secret takes no meta-data.
+type Ed25519PrivateKeySecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+}
+
+func NewEd25519PrivateKeySecretType() *Ed25519PrivateKeySecretType {
+	return &Ed25519PrivateKeySecretType{}
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) Type() string {
+	return Ed25519PrivateKeySecretTypeName
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) Description() string {
+	return "An Ed25519 private key, generated by the server"
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	ed25519PrivKeyST.dataStore = moduleInitContext.DataStore
+	ed25519PrivKeyST.keyStore = moduleInitContext.VirtualKeyStore
+
+	return nil
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	pkPEM, err := generateEd25519PrivateKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := createEncryptedSecret(ed25519PrivKeyST.dataStore, ed25519PrivKeyST.keyStore, secretEntry, pkPEM); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	pkPEM, err := decryptSecret(ed25519PrivKeyST.keyStore, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// set decrypted data
+	secretEntry.SecretData = pkPEM
+
+	return secretEntry, nil
+}
+
+// UpdateSecret generates a new private key, replacing the current one.
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	pkPEM, err := generateEd25519PrivateKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := updateEncryptedSecret(ed25519PrivKeyST.dataStore, ed25519PrivKeyST.keyStore, secretEntry, pkPEM); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+// RotateSecret generates a new private key.
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return ed25519PrivKeyST.UpdateSecret(ctx, secretEntry)
+}
+
+func (ed25519PrivKeyST *Ed25519PrivateKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(ed25519PrivKeyST.dataStore, ed25519PrivKeyST.keyStore, secretEntry)
+}
+
+func generateEd25519PrivateKey(secretEntry *model.SecretEntry) ([]byte, error) {
+	// we expect the input to contain no data, as we're generating the data
+	// (the private key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	_, pk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePKCS8PrivateKey(pk)
+}
@@ -0,0 +1,57 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestCreateAndGetEd25519PrivateKeySecret(t *testing.T) {
+	id, err := createEd25519PrivKey("ed25519-id1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	block, _ := pem.Decode(se.SecretData)
+	if block == nil {
+		t.Fatalf("Failed to decode returned private key")
+	}
+
+	pk, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse returned key as PKCS#8 private key: %v", err)
+	}
+
+	if _, ok := pk.(ed25519.PrivateKey); !ok {
+		t.Fatalf("Returned key is not an Ed25519 private key")
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func createEd25519PrivKey(id string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           Ed25519PrivateKeySecretTypeName,
+		MetaData:       "",
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
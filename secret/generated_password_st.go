@@ -0,0 +1,309 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+const GeneratedPasswordSecretTypeName = "GeneratedPassword"
+
+const (
+	maxGeneratedPasswordLength = 1024
+	maxPassphraseWordCount     = 64
+	defaultPassphraseSeparator = "-"
+
+	lowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars     = "0123456789"
+	symbolChars    = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+func init() {
+	if err := SecretTypeRegistrar.Register(GeneratedPasswordSecretTypeName, NewGeneratedPasswordSecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", GeneratedPasswordSecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(GeneratedPasswordSecretTypeName, generatedPasswordMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", GeneratedPasswordSecretTypeName, err))
+	}
+}
+
+// A secret type whose data is a password (or a passphrase) generated by the system.
+// The generated password is stored the same way a data secret is.
+type GeneratedPasswordSecretType struct {
+	dataST *DataSecretType
+}
+
+// GeneratedPasswordSecretMetaData specifies how a password is generated: either
+// Length characters, drawn from the enabled character classes (lowercase, uppercase
+// and digits if none is enabled) with at least one character of each class, or
+// WordCount words separated by WordSeparator.
+type GeneratedPasswordSecretMetaData struct {
+	Length        int    `json:"length"`
+	Lowercase     bool   `json:"lowercase"`
+	Uppercase     bool   `json:"uppercase"`
+	Digits        bool   `json:"digits"`
+	Symbols       bool   `json:"symbols"`
+	ExcludedChars string `json:"excludedChars"`
+	WordCount     int    `json:"wordCount"`
+	WordSeparator string `json:"wordSeparator"`
+}
+
+var generatedPasswordMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"length":        {Type: model.SchemaTypeInteger, Description: "password length, unless generating a passphrase", Minimum: model.SchemaBound(0), Maximum: model.SchemaBound(maxGeneratedPasswordLength)},
+		"lowercase":     {Type: model.SchemaTypeBoolean, Description: "include lowercase letters"},
+		"uppercase":     {Type: model.SchemaTypeBoolean, Description: "include uppercase letters"},
+		"digits":        {Type: model.SchemaTypeBoolean, Description: "include digits"},
+		"symbols":       {Type: model.SchemaTypeBoolean, Description: "include symbols"},
+		"excludedChars": {Type: model.SchemaTypeString, Description: "characters to exclude from the password"},
+		"wordCount":     {Type: model.SchemaTypeInteger, Description: "generate a passphrase of this number of words instead", Minimum: model.SchemaBound(0), Maximum: model.SchemaBound(maxPassphraseWordCount)},
+		"wordSeparator": {Type: model.SchemaTypeString, Description: "passphrase word separator (default: -)"},
+	},
+}
+
+func NewGeneratedPasswordSecretType() *GeneratedPasswordSecretType {
+	return &GeneratedPasswordSecretType{
+		dataST: NewDataSecretType(),
+	}
+}
+
+func (passwordST *GeneratedPasswordSecretType) Type() string {
+	return GeneratedPasswordSecretTypeName
+}
+
+func (passwordST *GeneratedPasswordSecretType) Description() string {
+	return "A password or passphrase, generated by the server"
+}
+
+func (passwordST *GeneratedPasswordSecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (passwordST *GeneratedPasswordSecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	return passwordST.dataST.Init(moduleInitContext)
+}
+
+// Validate checks that a generated password secret has no data, which is generated by
+// the server, and that it describes either a password or a passphrase.
+func (passwordST *GeneratedPasswordSecretType) Validate(secretEntry *model.SecretEntry) error {
+	fieldErrors := make([]util.FieldError, 0)
+
+	if len(secretEntry.SecretData) > 0 {
+		fieldErrors = append(fieldErrors, util.FieldError{Field: "secretData", Message: "is generated by the server"})
+	}
+
+	var passwordMetaData GeneratedPasswordSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &passwordMetaData); err == nil {
+		if passwordMetaData.WordCount > 0 && passwordMetaData.Length != 0 {
+			fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.length", Message: "can't be set along with metaData.wordCount"})
+		}
+		if passwordMetaData.WordCount == 0 && passwordMetaData.Length == 0 {
+			fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.length", Message: "is required, unless metaData.wordCount is set"})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return util.NewValidationError(fieldErrors)
+	}
+
+	return nil
+}
+
+func (passwordST *GeneratedPasswordSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	se, err := generatePasswordSecretEntry(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce password exposure due to memory compromize / leak
+	defer util.Memzero(se.SecretData)
+
+	return passwordST.dataST.CreateSecret(ctx, se)
+}
+
+func (passwordST *GeneratedPasswordSecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	return passwordST.dataST.GetSecret(ctx, secretEntry)
+}
+
+// UpdateSecret generates a new password, replacing the current one.
+func (passwordST *GeneratedPasswordSecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	se, err := generatePasswordSecretEntry(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce password exposure due to memory compromize / leak
+	defer util.Memzero(se.SecretData)
+
+	return passwordST.dataST.UpdateSecret(ctx, se)
+}
+
+// RotateSecret generates a new password, per the secret's current metadata.
+func (passwordST *GeneratedPasswordSecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return passwordST.UpdateSecret(ctx, secretEntry)
+}
+
+func (passwordST *GeneratedPasswordSecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return passwordST.dataST.DeleteSecret(ctx, secretEntry)
+}
+
+// generatePasswordSecretEntry returns a copy of secretEntry whose data is a newly generated password.
+func generatePasswordSecretEntry(secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	var passwordMetaData GeneratedPasswordSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &passwordMetaData); err != nil {
+		return nil, util.ErrInputValidation
+	}
+
+	// we expect the input to contain no data, as we're generating the data
+	// (the password) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	password, err := generatePassword(&passwordMetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	se := model.NewSecretEntry(secretEntry)
+	se.SecretData = password
+
+	return se, nil
+}
+
+func generatePassword(passwordMetaData *GeneratedPasswordSecretMetaData) ([]byte, error) {
+	if passwordMetaData.WordCount > 0 {
+		if passwordMetaData.Length != 0 {
+			return nil, util.ErrInputValidation
+		}
+
+		return generatePassphrase(passwordMetaData.WordCount, passwordMetaData.WordSeparator)
+	}
+
+	if passwordMetaData.Length <= 0 || passwordMetaData.Length > maxGeneratedPasswordLength {
+		return nil, util.ErrInputValidation
+	}
+
+	charClasses, err := passwordCharClasses(passwordMetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	if passwordMetaData.Length < len(charClasses) {
+		return nil, util.ErrInputValidation
+	}
+
+	password := make([]byte, 0, passwordMetaData.Length)
+
+	// at least one character of each class...
+	for _, charClass := range charClasses {
+		c, err := randomChar(charClass)
+		if err != nil {
+			return nil, util.ErrInternal
+		}
+		password = append(password, c)
+	}
+
+	// ...and the rest from all of them
+	allChars := strings.Join(charClasses, "")
+	for len(password) < passwordMetaData.Length {
+		c, err := randomChar(allChars)
+		if err != nil {
+			return nil, util.ErrInternal
+		}
+		password = append(password, c)
+	}
+
+	// shuffle, so that the position of the per-class characters is not predictable
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := crypt.RandIndex(i + 1)
+		if err != nil {
+			return nil, util.ErrInternal
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return password, nil
+}
+
+func generatePassphrase(wordCount int, separator string) ([]byte, error) {
+	if wordCount > maxPassphraseWordCount {
+		return nil, util.ErrInputValidation
+	}
+
+	if separator == "" {
+		separator = defaultPassphraseSeparator
+	}
+
+	words := make([]string, 0, wordCount)
+	for i := 0; i < wordCount; i++ {
+		index, err := crypt.RandIndex(len(passphraseWords))
+		if err != nil {
+			return nil, util.ErrInternal
+		}
+		words = append(words, passphraseWords[index])
+	}
+
+	return []byte(strings.Join(words, separator)), nil
+}
+
+// passwordCharClasses returns the enabled character classes, without the excluded characters.
+func passwordCharClasses(passwordMetaData *GeneratedPasswordSecretMetaData) ([]string, error) {
+	classes := []struct {
+		enabled bool
+		chars   string
+	}{
+		{passwordMetaData.Lowercase, lowercaseChars},
+		{passwordMetaData.Uppercase, uppercaseChars},
+		{passwordMetaData.Digits, digitChars},
+		{passwordMetaData.Symbols, symbolChars},
+	}
+
+	if !passwordMetaData.Lowercase && !passwordMetaData.Uppercase && !passwordMetaData.Digits && !passwordMetaData.Symbols {
+		classes[0].enabled = true
+		classes[1].enabled = true
+		classes[2].enabled = true
+	}
+
+	charClasses := make([]string, 0, len(classes))
+	for _, class := range classes {
+		if !class.enabled {
+			continue
+		}
+
+		chars := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(passwordMetaData.ExcludedChars, r) {
+				return -1
+			}
+			return r
+		}, class.chars)
+
+		// all characters of an enabled class are excluded
+		if chars == "" {
+			return nil, util.ErrInputValidation
+		}
+
+		charClasses = append(charClasses, chars)
+	}
+
+	return charClasses, nil
+}
+
+func randomChar(chars string) (byte, error) {
+	index, err := crypt.RandIndex(len(chars))
+	if err != nil {
+		return 0, err
+	}
+
+	return chars[index], nil
+}
@@ -0,0 +1,47 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+)
+
+func TestAPIRotateGeneratedPasswordSecret(t *testing.T) {
+	id, err := createGeneratedPasswordSecret("api-password-id0", "{\"length\": 16, \"symbols\": true}")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	secretUrl := fmt.Sprintf("%v/secrets/%v", ts.URL, id)
+	se, err := apiGetSecret(secretUrl)
+	if err != nil {
+		t.Fatalf("Failed to get secret with id %v: %v", id, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/secret-rotations/%v", ts.URL, id), "application/json", nil)
+	if err != nil {
+		t.Fatalf("Failed to rotate secret with id %v: %v", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	se2, err := apiGetSecret(secretUrl)
+	if err != nil {
+		t.Fatalf("Failed to get secret with id %v: %v", id, err)
+	}
+
+	if se2.Version != se.Version+1 || string(se2.SecretData) == string(se.SecretData) {
+		t.Fatalf("Unexpected rotated secret: version %v, data %s", se2.Version, se2.SecretData)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
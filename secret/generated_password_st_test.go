@@ -0,0 +1,132 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestCreateAndGetGeneratedPasswordSecret(t *testing.T) {
+	secretMetaData := "{\"length\": 24, \"lowercase\": true, \"digits\": true, \"symbols\": true, \"excludedChars\": \"0o1l\"}"
+
+	id, err := createGeneratedPasswordSecret("password-id0", secretMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	password := string(se.SecretData)
+	if len(password) != 24 {
+		t.Fatalf("Unexpected password length: %v", len(password))
+	}
+	if strings.ContainsAny(password, "0o1lABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		t.Fatalf("Password contains excluded characters: %v", password)
+	}
+	if !strings.ContainsAny(password, digitChars) || !strings.ContainsAny(password, symbolChars) {
+		t.Fatalf("Password is missing an enabled character class: %v", password)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestCreateGeneratedPassphraseSecret(t *testing.T) {
+	id, err := createGeneratedPasswordSecret("password-id1", "{\"wordCount\": 6, \"wordSeparator\": \" \"}")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	words := strings.Split(string(se.SecretData), " ")
+	if len(words) != 6 {
+		t.Fatalf("Unexpected passphrase: %s", se.SecretData)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestCreateGeneratedPasswordSecretWithInvalidMetaData(t *testing.T) {
+	invalidMetaData := []string{
+		"",
+		"{\"length\": 0}",
+		"{\"length\": 2, \"lowercase\": true, \"uppercase\": true, \"digits\": true}",
+		"{\"length\": 8, \"digits\": true, \"excludedChars\": \"0123456789\"}",
+		"{\"length\": 8, \"wordCount\": 4}",
+		"{\"wordCount\": 1000}",
+	}
+
+	for i, secretMetaData := range invalidMetaData {
+		if _, err := createGeneratedPasswordSecret("password-invalid-id", secretMetaData); err == nil {
+			t.Fatalf("Succeeded to create a generated password with invalid metadata %v: %v", i, secretMetaData)
+		}
+	}
+}
+
+func TestRotateGeneratedPasswordSecret(t *testing.T) {
+	id, err := createGeneratedPasswordSecret("password-id2", "{\"length\": 32}")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to rotate secret %v: %v", id, err)
+	}
+
+	se2, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if se2.Version != se.Version+1 || len(se2.SecretData) != 32 || string(se2.SecretData) == string(se.SecretData) {
+		t.Fatalf("Unexpected rotated secret: version %v, data %s", se2.Version, se2.SecretData)
+	}
+
+	// a secret whose data is not generated can't be rotated
+	dataId, err := createDataSecret("password-id3", "not-generated")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), dataId); err == nil {
+		t.Fatalf("Succeeded to rotate data secret %v", dataId)
+	}
+
+	for _, id := range []string{id, dataId} {
+		if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+			t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+		}
+	}
+}
+
+func createGeneratedPasswordSecret(id, secretMetaData string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           GeneratedPasswordSecretTypeName,
+		MetaData:       secretMetaData,
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
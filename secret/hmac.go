@@ -0,0 +1,83 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+const (
+	DefaultDerivedKeyLength = 32
+	MaxDerivedKeyLength     = 64
+)
+
+var hmacKeySecretTypes = map[string]bool{
+	HMACKeySecretTypeName: true,
+}
+
+// HMAC computes the HMAC-SHA256 of an input using an HMAC key secret, without revealing
+// the key. Computing HMACs requires MAC permission on the key's namespace.
+func (secretManager *SecretManager) HMAC(ctx gocontext.Context, hmacRequest *model.HMACRequest) (*model.HMACResponse, error) {
+	keyEntry, err := secretManager.getKeyEntry(ctx, hmacRequest.KeyId, hmacRequest.KeyVersion, model.OpMAC, hmacKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := decryptSecret(secretManager.keyStore, keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	return &model.HMACResponse{
+		KeyId:      keyEntry.Id,
+		KeyVersion: keyEntry.Version,
+		HMAC:       crypt.MAC(hmacRequest.Input, key),
+	}, nil
+}
+
+// DeriveKey derives a subkey from an HMAC key secret using HKDF-SHA256, so that e.g.
+// each tenant can be given its own key without revealing the key it's derived from.
+// Like computing HMACs, deriving requires MAC permission on the key's namespace.
+func (secretManager *SecretManager) DeriveKey(ctx gocontext.Context, deriveKeyRequest *model.DeriveKeyRequest) (*model.DeriveKeyResponse, error) {
+	length := deriveKeyRequest.Length
+	if length == 0 {
+		length = DefaultDerivedKeyLength
+	}
+	if length > MaxDerivedKeyLength {
+		return nil, util.ErrInputValidation
+	}
+
+	keyEntry, err := secretManager.getKeyEntry(ctx, deriveKeyRequest.KeyId, deriveKeyRequest.KeyVersion, model.OpMAC, hmacKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := decryptSecret(secretManager.keyStore, keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	derivedKey, err := crypt.DeriveKey(key, deriveKeyRequest.Salt, deriveKeyRequest.Info, length)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	secretManager.auditManager.Log(fmt.Sprintf("key %v (version %v) derived a %v-byte key", keyEntry.Id, keyEntry.Version, length))
+
+	return &model.DeriveKeyResponse{
+		KeyId:      keyEntry.Id,
+		KeyVersion: keyEntry.Version,
+		DerivedKey: derivedKey,
+	}, nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const HMACKeySecretTypeName = "HMACKey"
+
+func init() {
+	if err := SecretTypeRegistrar.Register(HMACKeySecretTypeName, NewHMACKeySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", HMACKeySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(HMACKeySecretTypeName, hmacKeyMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", HMACKeySecretTypeName, err))
+	}
+}
+
+var hmacKeyMetaDataSchema = &model.Schema{Type: model.SchemaTypeObject}
+
+// A secret type whose data is a generated 256-bit HMAC key, used for computing HMACs
+// and deriving subkeys on behalf of clients (see SecretManager.HMAC and
+// SecretManager.DeriveKey). Like a symmetric key, the key itself never leaves the
+// server, and updating (or rotating) the secret generates a new key version.
+type HMACKeySecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+}
+
+func NewHMACKeySecretType() *HMACKeySecretType {
+	return &HMACKeySecretType{}
+}
+
+func (hmacKeyST *HMACKeySecretType) Type() string {
+	return HMACKeySecretTypeName
+}
+
+func (hmacKeyST *HMACKeySecretType) Description() string {
+	return "An HMAC key, generated by the server and used through the crypto API"
+}
+
+func (hmacKeyST *HMACKeySecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (hmacKeyST *HMACKeySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	hmacKeyST.dataStore = moduleInitContext.DataStore
+	hmacKeyST.keyStore = moduleInitContext.VirtualKeyStore
+
+	return nil
+}
+
+func (hmacKeyST *HMACKeySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	key, err := generateSymmetricKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	if err := createEncryptedSecret(hmacKeyST.dataStore, hmacKeyST.keyStore, secretEntry, key); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+func (hmacKeyST *HMACKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	// the key itself is never returned
+	secretEntry.SecretData = []byte{}
+
+	return secretEntry, nil
+}
+
+// UpdateSecret generates a new key, replacing the current one.
+func (hmacKeyST *HMACKeySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	key, err := generateSymmetricKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	if err := updateEncryptedSecret(hmacKeyST.dataStore, hmacKeyST.keyStore, secretEntry, key); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+// RotateSecret generates a new HMAC key.
+func (hmacKeyST *HMACKeySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return hmacKeyST.UpdateSecret(ctx, secretEntry)
+}
+
+func (hmacKeyST *HMACKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(hmacKeyST.dataStore, hmacKeyST.keyStore, secretEntry)
+}
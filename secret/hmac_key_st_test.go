@@ -0,0 +1,46 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestCreateAndGetHMACKeySecret(t *testing.T) {
+	id, err := createHMACKey("hmac-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if se.Type != HMACKeySecretTypeName {
+		t.Fatalf("Unexpected secret type: %v", se.Type)
+	}
+
+	// the key never leaves the server
+	if len(se.SecretData) != 0 {
+		t.Fatalf("HMAC key secret data was returned")
+	}
+}
+
+func createHMACKey(id string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           HMACKeySecretTypeName,
+		MetaData:       "",
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
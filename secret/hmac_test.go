@@ -0,0 +1,112 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestHMACWithRotatedKey(t *testing.T) {
+	keyId, err := createHMACKey("hmac-key-id1")
+	if err != nil {
+		t.Fatalf("Failed to create HMAC key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	hmacRequest := &model.HMACRequest{KeyId: keyId, Input: []byte("GET /orders?id=42")}
+	hmacResponse, err := sm.HMAC(context.GetTestRequestContext(), hmacRequest)
+	if err != nil {
+		t.Fatalf("Failed to compute HMAC: %v", err)
+	}
+	if hmacResponse.KeyVersion != model.FirstSecretVersion || len(hmacResponse.HMAC) != 32 {
+		t.Fatalf("Unexpected HMAC response: %v", hmacResponse)
+	}
+
+	// HMACs are deterministic
+	hmacResponse2, err := sm.HMAC(context.GetTestRequestContext(), hmacRequest)
+	if err != nil {
+		t.Fatalf("Failed to compute HMAC: %v", err)
+	}
+	if !bytes.Equal(hmacResponse.HMAC, hmacResponse2.HMAC) {
+		t.Fatalf("HMACs of the same input differ")
+	}
+
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), keyId); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+
+	// the current key version computes another HMAC, while the prior one is still available
+	for version, expectedEqual := range map[int]bool{0: false, hmacResponse.KeyVersion: true} {
+		hmacRequest.KeyVersion = version
+		hmacResponse2, err := sm.HMAC(context.GetTestRequestContext(), hmacRequest)
+		if err != nil {
+			t.Fatalf("Failed to compute HMAC using version %v: %v", version, err)
+		}
+		if bytes.Equal(hmacResponse.HMAC, hmacResponse2.HMAC) != expectedEqual {
+			t.Fatalf("Unexpected HMAC using version %v", version)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	keyId, err := createHMACKey("hmac-key-id2")
+	if err != nil {
+		t.Fatalf("Failed to create HMAC key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	deriveKey := func(info string, length int) []byte {
+		deriveKeyResponse, err := sm.DeriveKey(context.GetTestRequestContext(), &model.DeriveKeyRequest{KeyId: keyId, Info: []byte(info), Length: length})
+		if err != nil {
+			t.Fatalf("Failed to derive key for %v: %v", info, err)
+		}
+
+		return deriveKeyResponse.DerivedKey
+	}
+
+	tenant0Key := deriveKey("tenant0", 0)
+	if len(tenant0Key) != DefaultDerivedKeyLength {
+		t.Fatalf("Unexpected derived key length: %v", len(tenant0Key))
+	}
+
+	if !bytes.Equal(tenant0Key, deriveKey("tenant0", 0)) {
+		t.Fatalf("Keys derived for the same tenant differ")
+	}
+
+	if bytes.Equal(tenant0Key, deriveKey("tenant1", 0)) {
+		t.Fatalf("Keys derived for different tenants are equal")
+	}
+
+	if len(deriveKey("tenant0", MaxDerivedKeyLength)) != MaxDerivedKeyLength {
+		t.Fatalf("Unexpected derived key length")
+	}
+}
+
+func TestDeriveKeyBadRequest(t *testing.T) {
+	keyId, err := createHMACKey("hmac-key-id3")
+	if err != nil {
+		t.Fatalf("Failed to create HMAC key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	symKeyId, err := createSymmetricKey("hmac-symmetric-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), symKeyId)
+
+	for _, deriveKeyRequest := range []*model.DeriveKeyRequest{
+		&model.DeriveKeyRequest{KeyId: keyId, Length: MaxDerivedKeyLength + 1},
+		// only HMAC keys derive keys
+		&model.DeriveKeyRequest{KeyId: symKeyId},
+	} {
+		if _, err := sm.DeriveKey(context.GetTestRequestContext(), deriveKeyRequest); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when deriving key %v: %v", deriveKeyRequest, err)
+		}
+	}
+}
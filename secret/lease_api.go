@@ -0,0 +1,143 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/naoina/denco"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func (secretManager *SecretManager) registerLeaseEndpoints(mux *denco.Mux) []denco.Handler {
+	// swagger:route GET /leases leases ListLeases
+	//
+	// Lists the leases held by the caller or issued by secrets the caller can read
+	//
+	//	Responses:
+	//		200: LeasesResponse
+	listLeases := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		query := r.URL.Query()
+
+		leaseEntries, err := secretManager.ListLeases(r.Context(), query.Get("prefix"), query.Get("owner"))
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, leaseEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /leases/renew leases RenewLease
+	//
+	// Extends a lease
+	//
+	//	Responses:
+	//		200: RenewLeaseResponse
+	renewLease := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		renewLeaseRequest, err := model.ExtractAndValidateRenewLeaseRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		renewLeaseResponse, err := secretManager.RenewLease(r.Context(), renewLeaseRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, renewLeaseResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /leases/revoke leases RevokeLeases
+	//
+	// Revokes a lease, or the leases of secrets under a prefix and/or held by an owner
+	//
+	//	Responses:
+	//		200: RevokeLeasesResponse
+	revokeLeases := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		revokeLeasesRequest, err := model.ExtractAndValidateRevokeLeasesRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		revokeLeasesResponse, err := secretManager.RevokeLeases(r.Context(), revokeLeasesRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, revokeLeasesResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	handlers := []denco.Handler{
+		mux.GET("/leases", listLeases),
+		mux.POST("/leases/renew", renewLease),
+		mux.POST("/leases/revoke", revokeLeases),
+	}
+
+	return handlers
+}
+
+// swagger:parameters ListLeases
+type LeasesParam struct {
+	// Secret id or namespace under which leases were issued (default: any)
+	// in:query
+	Prefix string `json:"prefix"`
+	// Owner of the leases (default: any)
+	// in:query
+	Owner string `json:"owner"`
+}
+
+// swagger:response LeasesResponse
+type LeasesResponse struct {
+	// in:body
+	LeaseEntries []model.LeaseEntry
+}
+
+// swagger:parameters RenewLease
+type RenewLeaseRequestParam struct {
+	// in:body
+	RenewLeaseRequest model.RenewLeaseRequest
+}
+
+// swagger:response RenewLeaseResponse
+type RenewLeaseResponse struct {
+	// in:body
+	RenewLeaseResponse model.RenewLeaseResponse
+}
+
+// swagger:parameters RevokeLeases
+type RevokeLeasesRequestParam struct {
+	// in:body
+	RevokeLeasesRequest model.RevokeLeasesRequest
+}
+
+// swagger:response RevokeLeasesResponse
+type RevokeLeasesResponse struct {
+	// in:body
+	RevokeLeasesResponse model.RevokeLeasesResponse
+}
@@ -0,0 +1,78 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestAPIRenewAndRevokeLease(t *testing.T) {
+	id, err := createDynamicCredential("leases-api-id0", "leases-api-dsn0", "10m")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	leasedCredential, err := getLeasedCredential(id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	post := func(endpoint string, request interface{}) *http.Response {
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(request); err != nil {
+			t.Fatalf("failed to marshal request %v: %v", request, err)
+		}
+
+		resp, err := http.Post(fmt.Sprintf("%v%v", ts.URL, endpoint), "application/json", body)
+		if err != nil {
+			t.Fatalf("Failed to post to %v: %v", endpoint, err)
+		}
+
+		return resp
+	}
+
+	resp := post("/leases/renew", &model.RenewLeaseRequest{LeaseId: leasedCredential.LeaseId, Increment: "2h"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var renewLeaseResponse model.RenewLeaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&renewLeaseResponse); err != nil {
+		t.Fatalf("Failed to parse renew lease response: %v", err)
+	}
+	if !renewLeaseResponse.ExpirationTime.After(leasedCredential.ExpirationTime) {
+		t.Fatalf("Lease wasn't extended: %v", renewLeaseResponse.ExpirationTime)
+	}
+
+	resp2 := post("/leases/revoke", &model.RevokeLeasesRequest{Prefix: id})
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp2.Status)
+	}
+
+	var revokeLeasesResponse model.RevokeLeasesResponse
+	if err := json.NewDecoder(resp2.Body).Decode(&revokeLeasesResponse); err != nil {
+		t.Fatalf("Failed to parse revoke leases response: %v", err)
+	}
+	if len(revokeLeasesResponse.LeaseIds) != 1 || revokeLeasesResponse.LeaseIds[0] != leasedCredential.LeaseId {
+		t.Fatalf("Unexpected leases revoked: %v", revokeLeasesResponse.LeaseIds)
+	}
+
+	resp3 := post("/leases/revoke", &model.RevokeLeasesRequest{})
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp3.Status)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// LeasableSecretType is implemented by secret types which lease the data they return
+// when read (e.g. a credential minted by a dynamic credential secret), so that leases
+// can be renewed and the data revoked.
+type LeasableSecretType interface {
+	// RenewLease extends a lease by the given increment from now (or by the secret's
+	// lease period, if zero), capped by the secret's maximal lease period and expiration
+	// time. It returns the lease's new expiration time.
+	RenewLease(secretEntry *model.SecretEntry, leaseEntry *model.LeaseEntry, increment time.Duration) (time.Time, error)
+	// RevokeLease revokes the data of a lease and deletes the lease.
+	RevokeLease(secretEntry *model.SecretEntry, leaseEntry *model.LeaseEntry) error
+}
+
+// A server revoking an expired lease holds its lock for at most this long, so that the
+// revocation is retried by another server if it dies in the middle
+const leaseRevocationLockTTL = 10 * time.Minute
+
+type leaseEntriesByCreationTime []*model.LeaseEntry
+
+func (l leaseEntriesByCreationTime) Len() int      { return len(l) }
+func (l leaseEntriesByCreationTime) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l leaseEntriesByCreationTime) Less(i, j int) bool {
+	return l[i].CreationTime.Before(l[j].CreationTime)
+}
+
+// RenewLease extends an unexpired lease. Leases can be renewed by their owner, or by
+// users allowed to update the secret which issued them.
+func (secretManager *SecretManager) RenewLease(ctx gocontext.Context, renewLeaseRequest *model.RenewLeaseRequest) (*model.RenewLeaseResponse, error) {
+	var increment time.Duration
+	if renewLeaseRequest.Increment != "" {
+		d, err := time.ParseDuration(renewLeaseRequest.Increment)
+		if err != nil || d <= 0 {
+			return nil, util.ErrInputValidation
+		}
+		increment = d
+	}
+
+	leaseEntry, err := getLeaseEntry(secretManager.dataStore, renewLeaseRequest.LeaseId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := secretManager.authorizeLeaseOp(ctx, leaseEntry, model.OpUpdate); err != nil {
+		return nil, err
+	}
+
+	// an expired lease is about to be revoked
+	if !time.Now().Before(leaseEntry.ExpirationTime) {
+		return nil, util.ErrNotFound
+	}
+
+	secretEntry, leasableSecretType, err := secretManager.getLeasingSecret(leaseEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	expirationTime, err := leasableSecretType.RenewLease(secretEntry, leaseEntry, increment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RenewLeaseResponse{
+		LeaseId:        leaseEntry.Id,
+		ExpirationTime: expirationTime,
+	}, nil
+}
+
+// RevokeLeases revokes either a single lease, or all the leases of secrets under a prefix
+// and/or held by an owner (e.g. when responding to an incident). Leases can be revoked by
+// their owner, or by users allowed to delete the secret which issued them; unless all
+// the matching leases can be revoked by the caller, none is. It returns the ids of the
+// leases revoked.
+func (secretManager *SecretManager) RevokeLeases(ctx gocontext.Context, revokeLeasesRequest *model.RevokeLeasesRequest) (*model.RevokeLeasesResponse, error) {
+	var leaseEntries []*model.LeaseEntry
+
+	if revokeLeasesRequest.LeaseId != "" {
+		leaseEntry, err := getLeaseEntry(secretManager.dataStore, revokeLeasesRequest.LeaseId)
+		if err != nil {
+			return nil, err
+		}
+		leaseEntries = []*model.LeaseEntry{leaseEntry}
+	} else {
+		var err error
+		leaseEntries, err = secretManager.findLeaseEntries(revokeLeasesRequest.Prefix, revokeLeasesRequest.Owner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, leaseEntry := range leaseEntries {
+		if err := secretManager.authorizeLeaseOp(ctx, leaseEntry, model.OpDelete); err != nil {
+			return nil, err
+		}
+	}
+
+	revokedIds := make([]string, 0, len(leaseEntries))
+	for _, leaseEntry := range leaseEntries {
+		if err := secretManager.revokeLease(leaseEntry); err != nil {
+			log.Printf("failed to revoke lease %v: %v\n", leaseEntry.Id, err)
+			return nil, err
+		}
+
+		revokedIds = append(revokedIds, leaseEntry.Id)
+	}
+
+	return &model.RevokeLeasesResponse{LeaseIds: revokedIds}, nil
+}
+
+// ListLeases lists the leases of secrets under a prefix and/or held by an owner (all of
+// them, if neither is given), which are either held by the caller or issued by secrets
+// the caller can read, ordered from the oldest to the most recent one.
+func (secretManager *SecretManager) ListLeases(ctx gocontext.Context, prefix string, owner string) ([]*model.LeaseEntry, error) {
+	leaseEntries, err := secretManager.findLeaseEntries(prefix, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedEntries := make([]*model.LeaseEntry, 0, len(leaseEntries))
+	for _, leaseEntry := range leaseEntries {
+		if secretManager.authorizeLeaseOp(ctx, leaseEntry, model.OpRead) == nil {
+			allowedEntries = append(allowedEntries, leaseEntry)
+		}
+	}
+
+	return allowedEntries, nil
+}
+
+// findLeaseEntries returns the leases of secrets under a prefix (a secret id or a
+// namespace, either relative to the secrets' root or not) which are held by an owner;
+// an empty prefix or owner matches any.
+func (secretManager *SecretManager) findLeaseEntries(prefix string, owner string) ([]*model.LeaseEntry, error) {
+	if strings.HasPrefix(prefix, vds.SecretsRootPath+"/") {
+		prefix = vds.SecretPathToId(prefix)
+	}
+	prefix = strings.Trim(prefix, "/")
+
+	leaseEntries := make([]*model.LeaseEntry, 0)
+	err := walkLeaseEntries(secretManager.dataStore, func(leaseEntry *model.LeaseEntry) error {
+		if prefix != "" && leaseEntry.SecretId != prefix && !strings.HasPrefix(leaseEntry.SecretId, prefix+"/") {
+			return nil
+		}
+
+		if owner != "" && leaseEntry.Owner != owner {
+			return nil
+		}
+
+		leaseEntries = append(leaseEntries, leaseEntry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(leaseEntriesByCreationTime(leaseEntries))
+
+	return leaseEntries, nil
+}
+
+// authorizeLeaseOp checks whether the caller may perform an operation on a lease: the
+// owner of a lease may, and so may users allowed to perform the operation on the
+// namespace of the secret which issued it.
+func (secretManager *SecretManager) authorizeLeaseOp(ctx gocontext.Context, leaseEntry *model.LeaseEntry, opLabel string) error {
+	if username := requestUsername(ctx); username != "" && username == leaseEntry.Owner {
+		return nil
+	}
+
+	return secretManager.authzManager.Allowed(ctx, model.Operation{Label: opLabel}, path.Dir(vds.SecretIdToPath(leaseEntry.SecretId)))
+}
+
+// revokeExpiredLeases revokes the credentials whose lease expired at time now. It returns
+// the number of leases revoked.
+func (secretManager *SecretManager) revokeExpiredLeases(now time.Time) int {
+	expiredEntries := make([]*model.LeaseEntry, 0)
+	err := walkLeaseEntries(secretManager.dataStore, func(leaseEntry *model.LeaseEntry) error {
+		if !now.Before(leaseEntry.ExpirationTime) {
+			expiredEntries = append(expiredEntries, leaseEntry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to look for expired leases: %v\n", err)
+	}
+
+	revoked := 0
+	for _, leaseEntry := range expiredEntries {
+		ok, err := secretManager.revokeExpiredLease(leaseEntry.Id, now)
+		if err != nil {
+			log.Printf("failed to revoke expired lease %v: %v\n", leaseEntry.Id, err)
+			continue
+		}
+
+		if ok {
+			revoked++
+		}
+	}
+
+	return revoked
+}
+
+// revokeExpiredLease revokes a lease if it still exists and is expired at time now. It
+// returns whether the lease was revoked.
+func (secretManager *SecretManager) revokeExpiredLease(leaseId string, now time.Time) (bool, error) {
+	lockName := vds.LeaseIdToPath(leaseId)
+	locked, err := vds.TryLock(secretManager.dataStore, lockName, secretManager.nodeId, leaseRevocationLockTTL)
+	if err != nil || !locked {
+		// another server is revoking the lease
+		return false, err
+	}
+	defer func() {
+		if err := vds.Unlock(secretManager.dataStore, lockName, secretManager.nodeId); err != nil {
+			log.Printf("failed to release revocation lock of lease %v: %v\n", leaseId, err)
+		}
+	}()
+
+	// re-read the lease, as it might have been revoked or renewed (by another server)
+	// since it was found to be expired
+	leaseEntry, err := getLeaseEntry(secretManager.dataStore, leaseId)
+	if err == util.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if now.Before(leaseEntry.ExpirationTime) {
+		return false, nil
+	}
+
+	if err := secretManager.revokeLease(leaseEntry); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// revokeLease revokes the data of a lease using the current version of the secret which
+// issued it, and deletes the lease.
+func (secretManager *SecretManager) revokeLease(leaseEntry *model.LeaseEntry) error {
+	secretEntry, leasableSecretType, err := secretManager.getLeasingSecret(leaseEntry)
+	if err != nil {
+		return err
+	}
+
+	return leasableSecretType.RevokeLease(secretEntry, leaseEntry)
+}
+
+// getLeasingSecret returns the current version of the secret which issued a lease, along
+// with its type.
+func (secretManager *SecretManager) getLeasingSecret(leaseEntry *model.LeaseEntry) (*model.SecretEntry, LeasableSecretType, error) {
+	secretEntry, err := secretManager.getSecretEntry(vds.SecretIdToPath(leaseEntry.SecretId))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(secretEntry.Type)
+	if err != nil {
+		return nil, nil, util.ErrInternal
+	}
+
+	leasableSecretType, ok := secretType.(LeasableSecretType)
+	if !ok {
+		return nil, nil, util.ErrInternal
+	}
+
+	return secretEntry, leasableSecretType, nil
+}
+
+func createLeaseEntry(dataStore vds.DataStoreAdapter, leaseEntry *model.LeaseEntry) error {
+	dataStoreEntry, err := vds.LeaseEntryToDataStoreEntry(leaseEntry)
+	if err != nil {
+		return err
+	}
+
+	return dataStore.CreateEntry(dataStoreEntry)
+}
+
+func getLeaseEntry(dataStore vds.DataStoreAdapter, leaseId string) (*model.LeaseEntry, error) {
+	dataStoreEntry, err := dataStore.ReadEntry(vds.LeaseIdToPath(leaseId))
+	if err != nil {
+		return nil, err
+	}
+
+	leaseEntry, err := vds.DataStoreEntryToLeaseEntry(dataStoreEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// leases are kept by the last element of their id only
+	if leaseEntry.Id != leaseId {
+		return nil, util.ErrNotFound
+	}
+
+	return leaseEntry, nil
+}
+
+func updateLeaseEntry(dataStore vds.DataStoreAdapter, leaseEntry *model.LeaseEntry) error {
+	dataStoreEntry, err := vds.LeaseEntryToDataStoreEntry(leaseEntry)
+	if err != nil {
+		return err
+	}
+
+	return dataStore.UpdateEntry(dataStoreEntry)
+}
+
+func deleteLeaseEntry(dataStore vds.DataStoreAdapter, leaseId string) error {
+	return dataStore.DeleteEntry(vds.LeaseIdToPath(leaseId))
+}
+
+func walkLeaseEntries(dataStore vds.DataStoreAdapter, walkFn func(*model.LeaseEntry) error) error {
+	childEntries, err := dataStore.SearchChildEntries(vds.LeasesRootPath)
+	if err != nil {
+		return err
+	}
+
+	for _, childEntry := range childEntries {
+		if !vds.IsLeaseEntry(childEntry) {
+			continue
+		}
+
+		leaseEntry, err := vds.DataStoreEntryToLeaseEntry(childEntry)
+		if err != nil {
+			return err
+		}
+
+		if err := walkFn(leaseEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newLeaseId(secretId string) string {
+	return fmt.Sprintf("%v/%v", secretId, util.NewUUID())
+}
@@ -0,0 +1,169 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestRenewLease(t *testing.T) {
+	dsn := "leases-dsn0"
+	config, _ := json.Marshal(&SQLCredentialBackendConfig{
+		Driver:               testSQLDriverName,
+		CreationStatements:   []string{"CREATE USER {{.Name}}"},
+		RevocationStatements: []string{"DROP USER {{.Name}}"},
+		RenewalStatements:    []string{"ALTER USER {{.Name}} VALID UNTIL '{{.Expiration}}'"},
+	})
+	metaData, _ := json.Marshal(&DynamicCredentialSecretMetaData{
+		Backend: SQLCredentialBackendType,
+		TTL:     "10m",
+		MaxTTL:  "30m",
+		Config:  config,
+	})
+	se := &model.SecretEntry{
+		Id:             "leases-id0",
+		Type:           DynamicCredentialSecretTypeName,
+		MetaData:       string(metaData),
+		SecretData:     []byte(dsn),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	id, err := sm.CreateSecret(context.GetTestRequestContext(), se)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	leasedCredential, err := getLeasedCredential(id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	renewLeaseRequest := &model.RenewLeaseRequest{LeaseId: leasedCredential.LeaseId, Increment: "20m"}
+	renewLeaseResponse, err := sm.RenewLease(context.GetTestRequestContext(), renewLeaseRequest)
+	if err != nil {
+		t.Fatalf("Failed to renew lease %v: %v", leasedCredential.LeaseId, err)
+	}
+
+	if !renewLeaseResponse.ExpirationTime.After(leasedCredential.ExpirationTime) {
+		t.Fatalf("Lease wasn't extended: %v", renewLeaseResponse.ExpirationTime)
+	}
+
+	expected := "ALTER USER " + leasedCredential.Credential["username"] + " VALID UNTIL '" + renewLeaseResponse.ExpirationTime.UTC().Format(sqlExpirationLayout) + "'"
+	if executed := testSQL.executed(dsn); len(executed) != 2 || executed[1] != expected {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+
+	leaseEntry, err := getLeaseEntry(sm.dataStore, leasedCredential.LeaseId)
+	if err != nil {
+		t.Fatalf("Failed to get lease %v: %v", leasedCredential.LeaseId, err)
+	}
+	if !leaseEntry.ExpirationTime.Equal(renewLeaseResponse.ExpirationTime) {
+		t.Fatalf("Unexpected lease expiration time: %v", leaseEntry.ExpirationTime)
+	}
+
+	// leases can't be renewed beyond the maximal TTL
+	renewLeaseRequest.Increment = "2h"
+	renewLeaseResponse, err = sm.RenewLease(context.GetTestRequestContext(), renewLeaseRequest)
+	if err != nil {
+		t.Fatalf("Failed to renew lease %v: %v", leasedCredential.LeaseId, err)
+	}
+
+	if !renewLeaseResponse.ExpirationTime.Equal(leaseEntry.CreationTime.Add(30 * time.Minute)) {
+		t.Fatalf("Lease was renewed beyond its maximal TTL: %v", renewLeaseResponse.ExpirationTime)
+	}
+
+	renewLeaseRequest.Increment = "-1h"
+	if _, err := sm.RenewLease(context.GetTestRequestContext(), renewLeaseRequest); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when renewing a lease by a negative increment: %v", err)
+	}
+
+	renewLeaseRequest = &model.RenewLeaseRequest{LeaseId: id + "/no-such-lease"}
+	if _, err := sm.RenewLease(context.GetTestRequestContext(), renewLeaseRequest); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when renewing a non-existent lease: %v", err)
+	}
+}
+
+func TestRevokeLeases(t *testing.T) {
+	ids := []string{"leases-id1", "leases-id2"}
+	for _, id := range ids {
+		if _, err := createDynamicCredential(id, "leases-dsn-"+id, ""); err != nil {
+			t.Fatalf("Failed to create secret: %v", err)
+		}
+		defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+	}
+
+	user1Ctx := gocontext.WithValue(context.GetTestRequestContext(), context.RequestContextKeyUsername, "user1")
+
+	leaseIds := make([]string, 0)
+	for _, ctx := range []gocontext.Context{context.GetTestRequestContext(), user1Ctx} {
+		for _, id := range ids {
+			se, err := sm.GetSecret(ctx, id)
+			if err != nil {
+				t.Fatalf("Failed to get secret for id %v: %v", id, err)
+			}
+
+			var leasedCredential model.LeasedCredential
+			if err := json.Unmarshal(se.SecretData, &leasedCredential); err != nil {
+				t.Fatalf("Failed to parse leased credential: %v", err)
+			}
+			leaseIds = append(leaseIds, leasedCredential.LeaseId)
+		}
+	}
+
+	leaseEntries, err := sm.ListLeases(context.GetTestRequestContext(), "", "user1")
+	if err != nil {
+		t.Fatalf("Failed to list leases: %v", err)
+	}
+	if len(leaseEntries) != 2 || leaseEntries[0].Id != leaseIds[2] || leaseEntries[1].Id != leaseIds[3] {
+		t.Fatalf("Unexpected leases of user1: %v", leaseEntries)
+	}
+
+	// revoke a single lease
+	revokeLeasesResponse, err := sm.RevokeLeases(context.GetTestRequestContext(), &model.RevokeLeasesRequest{LeaseId: leaseIds[0]})
+	if err != nil {
+		t.Fatalf("Failed to revoke lease %v: %v", leaseIds[0], err)
+	}
+	if len(revokeLeasesResponse.LeaseIds) != 1 || revokeLeasesResponse.LeaseIds[0] != leaseIds[0] {
+		t.Fatalf("Unexpected leases revoked: %v", revokeLeasesResponse.LeaseIds)
+	}
+
+	// revoke the leases of a user under a prefix
+	revokeLeasesRequest := &model.RevokeLeasesRequest{Prefix: "/secrets/leases-id1", Owner: "user1"}
+	revokeLeasesResponse, err = sm.RevokeLeases(context.GetTestRequestContext(), revokeLeasesRequest)
+	if err != nil {
+		t.Fatalf("Failed to revoke leases: %v", err)
+	}
+	if len(revokeLeasesResponse.LeaseIds) != 1 || revokeLeasesResponse.LeaseIds[0] != leaseIds[2] {
+		t.Fatalf("Unexpected leases revoked: %v", revokeLeasesResponse.LeaseIds)
+	}
+
+	// a prefix matches whole path elements only
+	leaseEntries, err = sm.ListLeases(context.GetTestRequestContext(), "leases-id", "")
+	if err != nil {
+		t.Fatalf("Failed to list leases: %v", err)
+	}
+	if len(leaseEntries) != 0 {
+		t.Fatalf("Unexpected leases listed: %v", leaseEntries)
+	}
+
+	leaseEntries, err = sm.ListLeases(context.GetTestRequestContext(), "leases-id2", "")
+	if err != nil {
+		t.Fatalf("Failed to list leases: %v", err)
+	}
+	if len(leaseEntries) != 2 || leaseEntries[0].Id != leaseIds[1] || leaseEntries[1].Id != leaseIds[3] {
+		t.Fatalf("Unexpected leases of leases-id2: %v", leaseEntries)
+	}
+
+	if _, err := sm.RevokeLeases(context.GetTestRequestContext(), &model.RevokeLeasesRequest{LeaseId: leaseIds[0]}); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when revoking a revoked lease: %v", err)
+	}
+}
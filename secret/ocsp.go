@@ -0,0 +1,104 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"log"
+	"time"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RespondOCSP answers a DER-encoded RFC 6960 OCSP request with the DER-encoded status
+// of the requested certificate, signed by its issuer (the root CA or an intermediate CA):
+// good or revoked for certificates issued by the server, and unknown otherwise. Malformed
+// requests, and requests for certificates issued by another CA, are answered with an
+// OCSP error response.
+func (secretManager *SecretManager) RespondOCSP(reqDER []byte) []byte {
+	req, err := ocsp.ParseRequest(reqDER)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse
+	}
+
+	// the certificate's issuer is recorded when it's registered; unregistered certificates
+	// are looked up with the root CA
+	certEntry, err := getCertificateEntry(secretManager.dataStore, certSerialToHex(req.SerialNumber))
+	if err != nil && err != util.ErrNotFound {
+		log.Printf("failed to read certificate %x: %v\n", req.SerialNumber, err)
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	issuerId := ""
+	if certEntry != nil {
+		issuerId = certEntry.IssuerId
+	}
+
+	issuer, err := getCertIssuer(secretManager.dataStore, secretManager.keyStore, secretManager.cfg, issuerId)
+	if err == util.ErrNotFound {
+		return ocsp.UnauthorizedErrorResponse
+	}
+	if err != nil {
+		log.Printf("failed to read CA %q: %v\n", issuerId, err)
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	if !ocspRequestIssuedBy(req, issuer.cert) {
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	now := time.Now()
+	template := ocsp.Response{
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(secretManager.crlInterval),
+	}
+
+	switch {
+	case certEntry == nil:
+		template.Status = ocsp.Unknown
+	case certEntry.Revoked:
+		template.Status = ocsp.Revoked
+		template.RevokedAt = certEntry.RevocationTime
+		template.RevocationReason = model.X509RevocationReasons[certEntry.RevocationReason]
+	default:
+		template.Status = ocsp.Good
+	}
+
+	respDER, err := ocsp.CreateResponse(issuer.cert, issuer.cert, template, issuer.privKey)
+	if err != nil {
+		log.Printf("failed to create OCSP response: %v\n", err)
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	return respDER
+}
+
+// ocspRequestIssuedBy returns whether the certificate identified by the request is issued
+// by the given CA, i.e. whether the request's issuer name and key hashes match the CA's.
+func ocspRequestIssuedBy(req *ocsp.Request, caCert *x509.Certificate) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(caCert.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return false
+	}
+
+	nameHash := req.HashAlgorithm.New()
+	nameHash.Write(caCert.RawSubject)
+
+	keyHash := req.HashAlgorithm.New()
+	keyHash.Write(publicKeyInfo.PublicKey.RightAlign())
+
+	return bytes.Equal(nameHash.Sum(nil), req.IssuerNameHash) && bytes.Equal(keyHash.Sum(nil), req.IssuerKeyHash)
+}
@@ -0,0 +1,93 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRespondOCSP(t *testing.T) {
+	cert, err := signTestCSR("ocsp-cert-id0", "ocsp0.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ocsp-cert-id0")
+
+	caCert, _, err := getCACertAndKey(config.GenerateTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+
+	resp, err := respondTestOCSP(cert, caCert)
+	if err != nil {
+		t.Fatalf("Failed to get OCSP response: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Fatalf("Unexpected status of valid certificate: %v", resp.Status)
+	}
+
+	revocationRequest := &model.RevocationRequest{
+		SecretId: "ocsp-cert-id0",
+		Reason:   "superseded",
+	}
+	if _, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest); err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+
+	resp, err = respondTestOCSP(cert, caCert)
+	if err != nil {
+		t.Fatalf("Failed to get OCSP response: %v", err)
+	}
+	if resp.Status != ocsp.Revoked || resp.RevocationReason != ocsp.Superseded {
+		t.Fatalf("Unexpected status of revoked certificate: %v (reason %v)", resp.Status, resp.RevocationReason)
+	}
+
+	// a certificate which wasn't issued by the server
+	unknownCert := *cert
+	unknownCert.SerialNumber = big.NewInt(42)
+	resp, err = respondTestOCSP(&unknownCert, caCert)
+	if err != nil {
+		t.Fatalf("Failed to get OCSP response: %v", err)
+	}
+	if resp.Status != ocsp.Unknown {
+		t.Fatalf("Unexpected status of unknown certificate: %v", resp.Status)
+	}
+}
+
+func TestRespondBadOCSPRequest(t *testing.T) {
+	if _, err := ocsp.ParseResponse(sm.RespondOCSP([]byte("not an OCSP request")), nil); err != (ocsp.ResponseError{Status: ocsp.Malformed}) {
+		t.Fatalf("Unexpected response to a malformed request: %v", err)
+	}
+
+	cert, err := signTestCSR("ocsp-cert-id1", "ocsp1.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ocsp-cert-id1")
+
+	// the certificate's issuer is claimed to be the certificate itself
+	reqDER, err := ocsp.CreateRequest(cert, cert, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("Failed to create OCSP request: %v", err)
+	}
+	if _, err := ocsp.ParseResponse(sm.RespondOCSP(reqDER), nil); err != (ocsp.ResponseError{Status: ocsp.Unauthorized}) {
+		t.Fatalf("Unexpected response to a request for a certificate of another issuer: %v", err)
+	}
+}
+
+func respondTestOCSP(cert, caCert *x509.Certificate) (*ocsp.Response, error) {
+	reqDER, err := ocsp.CreateRequest(cert, caCert, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ocsp.ParseResponseForCert(sm.RespondOCSP(reqDER), cert, caCert)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+// Word list for generating diceware-style passphrases.
+// It contains 512 distinct words, so each word adds 9 bits of entropy to a passphrase.
+var passphraseWords = []string{
+	"able", "acid", "acre", "aged", "ally", "also", "arch", "area", "army", "aunt", "away", "axis",
+	"baby", "back", "bake", "ball", "band", "bank", "bark", "barn", "base", "bath", "bead", "beam",
+	"bean", "bear", "beat", "been", "beer", "bell", "belt", "best", "bike", "bill", "bird", "bite",
+	"blow", "blue", "boat", "body", "bolt", "bomb", "bond", "bone", "book", "boom", "born", "boss",
+	"both", "bowl", "brow", "buck", "bulb", "bulk", "burn", "bush", "busy", "cake", "calf", "call",
+	"calm", "came", "camp", "cane", "cape", "card", "care", "cart", "case", "cash", "cast", "cave",
+	"cell", "chat", "chef", "chin", "chip", "city", "clay", "clip", "club", "clue", "coal", "coat",
+	"code", "cold", "come", "cook", "cool", "cope", "copy", "cord", "core", "corn", "cost", "crew",
+	"crop", "dark", "data", "date", "dawn", "deal", "dear", "debt", "deck", "deep", "deer", "desk",
+	"dial", "diet", "disc", "dish", "dock", "door", "dose", "down", "draw", "drew", "drop", "drum",
+	"dual", "dust", "duty", "each", "earn", "ease", "east", "easy", "edge", "else", "even", "ever",
+	"exit", "face", "fact", "fair", "fall", "farm", "fast", "fate", "fear", "feed", "feel", "feet",
+	"fell", "felt", "file", "fill", "film", "find", "fine", "fire", "firm", "fish", "five", "flag",
+	"flat", "flow", "food", "foot", "form", "fort", "four", "free", "from", "fuel", "full", "fund",
+	"gain", "game", "gate", "gave", "gear", "gift", "girl", "give", "glad", "goal", "goes", "gold",
+	"golf", "gone", "good", "gray", "grew", "grid", "grow", "gulf", "hair", "half", "hall", "hand",
+	"hang", "hard", "harm", "hate", "have", "head", "hear", "heat", "held", "help", "here", "hero",
+	"high", "hill", "hire", "hold", "hole", "holy", "home", "hope", "host", "hour", "huge", "hung",
+	"hunt", "idea", "inch", "into", "iron", "item", "join", "jump", "jury", "just", "keen", "keep",
+	"kept", "kick", "kind", "king", "knee", "knew", "know", "lack", "lady", "laid", "lake", "land",
+	"lane", "last", "late", "lead", "left", "less", "life", "lift", "like", "line", "link", "list",
+	"live", "load", "loan", "lock", "logo", "long", "look", "lord", "lose", "loss", "lost", "love",
+	"luck", "made", "mail", "main", "make", "male", "many", "mark", "mass", "meal", "mean", "meat",
+	"meet", "menu", "mere", "mild", "mile", "milk", "mill", "mind", "mine", "miss", "mode", "mood",
+	"moon", "more", "most", "move", "much", "must", "name", "navy", "near", "neck", "need", "news",
+	"next", "nice", "nine", "none", "nose", "note", "once", "only", "onto", "open", "oral", "over",
+	"pace", "pack", "page", "paid", "pain", "pair", "palm", "park", "part", "pass", "past", "path",
+	"peak", "pick", "pink", "pipe", "plan", "play", "plot", "plug", "plus", "poem", "poet", "pole",
+	"poll", "pool", "poor", "port", "post", "pull", "pure", "push", "quit", "race", "rail", "rain",
+	"rank", "rare", "rate", "read", "real", "rear", "rely", "rent", "rest", "rice", "rich", "ride",
+	"ring", "rise", "risk", "road", "rock", "role", "roll", "roof", "room", "root", "rose", "rule",
+	"rush", "safe", "said", "sake", "sale", "salt", "same", "sand", "save", "seat", "seed", "seek",
+	"seem", "seen", "self", "sell", "send", "sent", "ship", "shop", "shot", "show", "shut", "sick",
+	"side", "sign", "silk", "site", "size", "skin", "slip", "slow", "snow", "soft", "soil", "sold",
+	"sole", "some", "song", "soon", "sort", "soul", "spot", "star", "stay", "step", "stop", "such",
+	"suit", "sure", "take", "tale", "talk", "tall", "tank", "tape", "task", "team", "tech", "tell",
+	"tend", "term", "test", "text", "than", "that", "them", "then", "they", "thin", "this", "thus",
+	"tide", "tile", "time", "tiny", "told", "toll", "tone", "took", "tool", "tour", "town", "tree",
+	"trip", "true", "tune", "turn", "twin", "type", "unit", "upon", "used", "user", "vary", "vast",
+	"very", "view", "vote", "wage", "wait", "wake", "walk", "wall", "want", "ward", "warm", "wash",
+	"wave", "ways", "weak", "wear", "week", "well", "went", "were", "west", "what", "when", "whom",
+	"wide", "wife", "wild", "will", "wind", "wine", "wing", "wire", "wise", "wish", "with", "wood",
+	"word", "wore", "work", "yard", "year", "your", "zero", "zone",
+}
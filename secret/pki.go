@@ -0,0 +1,276 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// SignCSR issues a certificate for a certificate signing request, whose private key
+// is kept outside of VSM (e.g. in a HSM or on a device). The certificate is stored as
+// a X509Certificate secret, so it's subject to the issuance policies of the secret's
+// namespace, and can be renewed by rotating the secret. CA certificates are only issued
+// if the policies explicitly allow them.
+func (secretManager *SecretManager) SignCSR(ctx gocontext.Context, signRequest *model.CSRSignRequest) (*model.CSRSignResponse, error) {
+	if signRequest.IsCA && !certIssuanceAllowsCA(getCertIssuancePolicies(secretManager.dataStore, signRequest.Id)) {
+		return nil, util.ErrUnauthorized
+	}
+
+	certMetaData := &X509CertificateSecretMetaData{
+		CSR:          signRequest.CSR,
+		TTL:          signRequest.TTL,
+		KeyUsages:    signRequest.KeyUsages,
+		ExtKeyUsages: signRequest.ExtKeyUsages,
+		IsCA:         signRequest.IsCA,
+		MaxPathLen:   signRequest.MaxPathLen,
+	}
+
+	metaDataBytes, err := json.Marshal(certMetaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	se := &model.SecretEntry{
+		Id:             signRequest.Id,
+		Type:           X509CertificateSecretTypeName,
+		MetaData:       string(metaDataBytes),
+		SecretData:     []byte{},
+		Owner:          signRequest.Owner,
+		ExpirationTime: signRequest.ExpirationTime,
+	}
+
+	id, err := secretManager.CreateSecret(ctx, se)
+	if err != nil {
+		return nil, err
+	}
+
+	// the caller may create the certificate, but not necessarily read secrets in its
+	// namespace; the issued certificate is returned nonetheless
+	certEntry, err := secretManager.getSecretEntry(vds.SecretIdToPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(certEntry.Type)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	certEntry, err = secretType.GetSecret(ctx, certEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CSRSignResponse{
+		Id:          id,
+		Certificate: string(certEntry.SecretData),
+	}, nil
+}
+
+// parseCSR parses a PEM-encoded PKCS#10 certificate signing request, verifying its signature.
+func parseCSR(csrPEM string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || (block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST") {
+		return nil, util.ErrInputValidation
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, util.ErrInputValidation
+	}
+
+	// the requester must hold the private key
+	if err := csr.CheckSignature(); err != nil {
+		return nil, util.ErrInputValidation
+	}
+
+	return csr, nil
+}
+
+// RevokeCertificate revokes a certificate issued by the server, identified either by its
+// serial number or by the id of the X509Certificate (or X509CertificateAuthority) secret
+// holding it, and regenerates the CRLs. Revoking a certificate requires permission to
+// update secrets in the namespace of the secret holding it.
+func (secretManager *SecretManager) RevokeCertificate(ctx gocontext.Context, revocationRequest *model.RevocationRequest) (*model.CertificateEntry, error) {
+	var certEntry *model.CertificateEntry
+	var err error
+	if revocationRequest.SecretId != "" {
+		certEntry, err = secretManager.getSecretCertificateEntry(ctx, revocationRequest.SecretId)
+	} else {
+		certEntry, err = secretManager.getSerialCertificateEntry(ctx, revocationRequest.SerialNumber)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if certEntry.Revoked {
+		return nil, util.ErrAlreadyExists
+	}
+
+	certEntry.Revoked = true
+	certEntry.RevocationTime = time.Now().UTC()
+	certEntry.RevocationReason = revocationRequest.Reason
+
+	if err := putCertificateEntry(secretManager.dataStore, certEntry); err != nil {
+		return nil, err
+	}
+
+	secretManager.auditManager.Log(fmt.Sprintf("revoked certificate %v of secret %v (%v)", certEntry.SerialNumber, certEntry.SecretId, certEntry.RevocationReason))
+
+	if err := secretManager.updateCRL(time.Now()); err != nil {
+		log.Printf("failed to generate CRL: %v\n", err)
+	}
+
+	return certEntry, nil
+}
+
+// getSecretCertificateEntry returns the registry entry of the current certificate of
+// a X509Certificate secret, creating it if the certificate isn't registered (e.g. since
+// it was issued before certificates were registered).
+func (secretManager *SecretManager) getSecretCertificateEntry(ctx gocontext.Context, secretId string) (*model.CertificateEntry, error) {
+	secretPath := vds.SecretIdToPath(secretId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpUpdate}, path.Dir(secretPath)); err != nil {
+		return nil, err
+	}
+
+	secretEntry, err := secretManager.getSecretEntry(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if secretEntry.Type != X509CertificateSecretTypeName && secretEntry.Type != X509CertificateAuthoritySecretTypeName {
+		return nil, util.ErrInputValidation
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(secretEntry.Type)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	secretEntry, err = secretType.GetSecret(ctx, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := parseCertificate(secretEntry.SecretData)
+	if err != nil {
+		return nil, err
+	}
+
+	certEntry, err := getCertificateEntry(secretManager.dataStore, certSerialToHex(cert.SerialNumber))
+	if err == util.ErrNotFound {
+		return newCertificateEntry(secretId, secretManager.getCertIssuerId(secretId, cert), cert), nil
+	}
+
+	return certEntry, err
+}
+
+// getSerialCertificateEntry returns the registry entry of the certificate with the given
+// hex-encoded serial number.
+func (secretManager *SecretManager) getSerialCertificateEntry(ctx gocontext.Context, serialNumber string) (*model.CertificateEntry, error) {
+	serial, ok := new(big.Int).SetString(strings.Replace(serialNumber, ":", "", -1), 16)
+	if !ok {
+		return nil, util.ErrInputValidation
+	}
+
+	certEntry, err := getCertificateEntry(secretManager.dataStore, certSerialToHex(serial))
+	if err != nil {
+		return nil, err
+	}
+
+	// the secret holding the certificate may have been deleted, or renewed since
+	secretPath := vds.SecretIdToPath(certEntry.SecretId)
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpUpdate}, path.Dir(secretPath)); err != nil {
+		return nil, err
+	}
+
+	return certEntry, nil
+}
+
+// getCertIssuerId returns the id of the CA which issued an unregistered certificate of the
+// given secret: the CA currently issuing the secret's certificates, if it signed the
+// certificate, or otherwise the root CA.
+func (secretManager *SecretManager) getCertIssuerId(secretId string, cert *x509.Certificate) string {
+	issuer, err := findCertIssuer(secretManager.dataStore, secretManager.keyStore, secretManager.cfg, secretId)
+	if err != nil || cert.CheckSignatureFrom(issuer.cert) != nil {
+		return ""
+	}
+
+	return issuer.id
+}
+
+// recordIssuedCert registers a certificate issued for a secret, so that it can later be
+// revoked, and its status looked up.
+func recordIssuedCert(dataStore vds.DataStoreAdapter, secretId string, issuerId string, certPEM []byte) error {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+
+	return putCertificateEntry(dataStore, newCertificateEntry(secretId, issuerId, cert))
+}
+
+func newCertificateEntry(secretId string, issuerId string, cert *x509.Certificate) *model.CertificateEntry {
+	return &model.CertificateEntry{
+		SerialNumber: certSerialToHex(cert.SerialNumber),
+		SecretId:     secretId,
+		IssuerId:     issuerId,
+		NotAfter:     cert.NotAfter,
+	}
+}
+
+func getCertificateEntry(dataStore vds.DataStoreAdapter, serialNumber string) (*model.CertificateEntry, error) {
+	dataStoreEntry, err := dataStore.ReadEntry(vds.CertificateSerialToPath(serialNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	return vds.DataStoreEntryToCertificateEntry(dataStoreEntry)
+}
+
+// putCertificateEntry creates or updates a certificate's registry entry.
+func putCertificateEntry(dataStore vds.DataStoreAdapter, certEntry *model.CertificateEntry) error {
+	dataStoreEntry, err := vds.CertificateEntryToDataStoreEntry(certEntry)
+	if err != nil {
+		return err
+	}
+
+	err = dataStore.UpdateEntry(dataStoreEntry)
+	if err == util.ErrNotFound {
+		err = dataStore.CreateEntry(dataStoreEntry)
+	}
+
+	return err
+}
+
+func certSerialToHex(serialNumber *big.Int) string {
+	return fmt.Sprintf("%x", serialNumber)
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, util.ErrInternal
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return cert, nil
+}
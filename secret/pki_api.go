@@ -0,0 +1,210 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/naoina/denco"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP requests are small; larger requests are truncated, and thus malformed.
+const maxOCSPRequestSize = 64 * 1024
+
+func (secretManager *SecretManager) registerPKIEndpoints(mux *denco.Mux) []denco.Handler {
+	// swagger:route POST /pki/sign-csr pki SignCSR
+	//
+	// Issues a certificate for a certificate signing request, and stores it as a secret
+	//
+	//	Responses:
+	//		201: CSRSignResponse
+	signCSR := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		signRequest, err := model.ExtractAndValidateCSRSignRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		signResponse, err := secretManager.SignCSR(r.Context(), signRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, signResponse, http.StatusCreated); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /pki/revocations pki RevokeCertificate
+	//
+	// Revokes a certificate issued by the server
+	//
+	//	Responses:
+	//		201: CertificateEntryResponse
+	revokeCertificate := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		revocationRequest, err := model.ExtractAndValidateRevocationRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		certEntry, err := secretManager.RevokeCertificate(r.Context(), revocationRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, certEntry, http.StatusCreated); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /pki/crl pki GetCRL
+	//
+	// Retrieves the DER-encoded certificate revocation list of the root CA
+	//
+	//	Produces:
+	//	- application/pkix-crl
+	//
+	//	Responses:
+	//		200:
+	getCRL := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretManager.writeCRL(w, "")
+	}
+
+	// swagger:route GET /pki/crl/{path} pki GetCACRL
+	//
+	// Retrieves the DER-encoded certificate revocation list of an intermediate CA
+	//
+	//	Produces:
+	//	- application/pkix-crl
+	//
+	//	Responses:
+	//		200:
+	getCACRL := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretManager.writeCRL(w, strings.TrimPrefix(r.URL.Path, "/pki/crl/"))
+	}
+
+	// swagger:route POST /pki/ocsp pki PostOCSP
+	//
+	// Answers a DER-encoded OCSP request
+	//
+	//	Consumes:
+	//	- application/ocsp-request
+	//
+	//	Produces:
+	//	- application/ocsp-response
+	//
+	//	Responses:
+	//		200:
+	postOCSP := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		defer r.Body.Close()
+		reqDER, err := ioutil.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize))
+		if err != nil {
+			if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		writeOCSPResponse(w, secretManager.RespondOCSP(reqDER))
+	}
+
+	// swagger:route GET /pki/ocsp/{request} pki GetOCSP
+	//
+	// Answers a base64-encoded DER OCSP request
+	//
+	//	Produces:
+	//	- application/ocsp-response
+	//
+	//	Responses:
+	//		200:
+	getOCSP := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		reqDER, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/pki/ocsp/"))
+		if err != nil {
+			writeOCSPResponse(w, ocsp.MalformedRequestErrorResponse)
+			return
+		}
+
+		writeOCSPResponse(w, secretManager.RespondOCSP(reqDER))
+	}
+
+	handlers := []denco.Handler{
+		mux.POST("/pki/sign-csr", signCSR),
+		mux.POST("/pki/revocations", revokeCertificate),
+		mux.GET("/pki/crl", getCRL),
+		mux.GET("/pki/crl/*", getCACRL),
+		mux.POST("/pki/ocsp", postOCSP),
+		mux.GET("/pki/ocsp/*", getOCSP),
+	}
+
+	return handlers
+}
+
+func (secretManager *SecretManager) writeCRL(w http.ResponseWriter, issuerId string) {
+	crlDER, err := secretManager.GetCRL(issuerId)
+	if err != nil {
+		log.Printf("Error: %s\n", err.Error())
+		if e := util.WriteErrorResponse(w, err); e != nil {
+			log.Printf("failed to write error response: %v\n", e)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	if _, e := w.Write(crlDER); e != nil {
+		log.Printf("failed to write response: %v\n", e)
+	}
+}
+
+// writeOCSPResponse writes an OCSP response, which signals errors in-band rather than
+// through the HTTP status.
+func writeOCSPResponse(w http.ResponseWriter, respDER []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	if _, e := w.Write(respDER); e != nil {
+		log.Printf("failed to write response: %v\n", e)
+	}
+}
+
+// swagger:parameters SignCSR
+type CSRSignRequestParam struct {
+	// in:body
+	CSRSignRequest model.CSRSignRequest
+}
+
+// swagger:response CSRSignResponse
+type CSRSignResponse struct {
+	// in:body
+	CSRSignResponse model.CSRSignResponse
+}
+
+// swagger:parameters RevokeCertificate
+type RevocationRequestParam struct {
+	// in:body
+	RevocationRequest model.RevocationRequest
+}
+
+// swagger:response CertificateEntryResponse
+type CertificateEntryResponse struct {
+	// in:body
+	CertificateEntry model.CertificateEntry
+}
@@ -0,0 +1,194 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestAPISignCSR(t *testing.T) {
+	privKey, csrPEM, err := generateTestCSR("api-device0.example.com", []string{"api-device0.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate CSR: %v", err)
+	}
+
+	signRequest := &model.CSRSignRequest{
+		Id:  "api-csr-cert-id0",
+		CSR: csrPEM,
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(signRequest); err != nil {
+		t.Fatalf("failed to marshal sign request %v: %v", signRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/pki/sign-csr", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to sign CSR: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var signResponse model.CSRSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signResponse); err != nil {
+		t.Fatalf("Failed to parse sign response: %v", err)
+	}
+
+	cert, err := parseCertificatePEM(signResponse.Certificate)
+	if err != nil {
+		t.Fatalf("Failed to parse returned certificate: %v", err)
+	}
+
+	if signResponse.Id != "api-csr-cert-id0" || !publicKeysEqual(&privKey.PublicKey, cert.PublicKey) {
+		t.Fatalf("Unexpected sign response: %v", signResponse)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), signResponse.Id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", signResponse.Id, err)
+	}
+}
+
+func TestAPIRevokeCertificate(t *testing.T) {
+	cert, err := signTestCSR("api-revoked-cert-id0", "api-revoked0.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "api-revoked-cert-id0")
+
+	caCert, _, err := getCACertAndKey(config.GenerateTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+
+	revocationRequest := &model.RevocationRequest{
+		SerialNumber: fmt.Sprintf("%x", cert.SerialNumber),
+		Reason:       "affiliationChanged",
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(revocationRequest); err != nil {
+		t.Fatalf("failed to marshal revocation request %v: %v", revocationRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/pki/revocations", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var certEntry model.CertificateEntry
+	if err := json.NewDecoder(resp.Body).Decode(&certEntry); err != nil {
+		t.Fatalf("Failed to parse certificate entry: %v", err)
+	}
+	if !certEntry.Revoked || certEntry.SecretId != "api-revoked-cert-id0" {
+		t.Fatalf("Unexpected certificate entry: %v", certEntry)
+	}
+
+	// the CRL lists the certificate
+	crlResp, err := http.Get(fmt.Sprintf("%v/pki/crl", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to get CRL: %v", err)
+	}
+	defer crlResp.Body.Close()
+
+	if crlResp.StatusCode != http.StatusOK || crlResp.Header.Get("Content-Type") != "application/pkix-crl" {
+		t.Fatalf("Unexpected CRL response: %v %v", crlResp.Status, crlResp.Header.Get("Content-Type"))
+	}
+
+	crlDER, err := ioutil.ReadAll(crlResp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read CRL: %v", err)
+	}
+
+	crl, err := x509.ParseDERCRL(crlDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: %v", err)
+	}
+
+	listed := false
+	for _, revokedCertEntry := range crl.TBSCertList.RevokedCertificates {
+		if revokedCertEntry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			listed = true
+		}
+	}
+	if !listed {
+		t.Fatalf("Revoked certificate is not listed in CRL")
+	}
+
+	// the OCSP responder reports the certificate as revoked, for both POST and GET requests
+	reqDER, err := ocsp.CreateRequest(cert, caCert, nil)
+	if err != nil {
+		t.Fatalf("Failed to create OCSP request: %v", err)
+	}
+
+	postResp, err := http.Post(fmt.Sprintf("%v/pki/ocsp", ts.URL), "application/ocsp-request", bytes.NewReader(reqDER))
+	if err != nil {
+		t.Fatalf("Failed to post OCSP request: %v", err)
+	}
+	defer postResp.Body.Close()
+
+	getResp, err := http.Get(fmt.Sprintf("%v/pki/ocsp/%v", ts.URL, base64.StdEncoding.EncodeToString(reqDER)))
+	if err != nil {
+		t.Fatalf("Failed to get OCSP response: %v", err)
+	}
+	defer getResp.Body.Close()
+
+	for _, ocspResp := range []*http.Response{postResp, getResp} {
+		if ocspResp.StatusCode != http.StatusOK || ocspResp.Header.Get("Content-Type") != "application/ocsp-response" {
+			t.Fatalf("Unexpected OCSP response: %v %v", ocspResp.Status, ocspResp.Header.Get("Content-Type"))
+		}
+
+		respDER, err := ioutil.ReadAll(ocspResp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read OCSP response: %v", err)
+		}
+
+		resp, err := ocsp.ParseResponseForCert(respDER, cert, caCert)
+		if err != nil {
+			t.Fatalf("Failed to parse OCSP response: %v", err)
+		}
+		if resp.Status != ocsp.Revoked || resp.RevocationReason != ocsp.AffiliationChanged {
+			t.Fatalf("Unexpected status of revoked certificate: %v (reason %v)", resp.Status, resp.RevocationReason)
+		}
+	}
+}
+
+func TestAPIRevokeCertificateBadRequest(t *testing.T) {
+	for _, revocationRequest := range []*model.RevocationRequest{
+		&model.RevocationRequest{},
+		&model.RevocationRequest{SerialNumber: "01", SecretId: "api-revoked-cert-id1"},
+		&model.RevocationRequest{SecretId: "api-revoked-cert-id1", Reason: "noReason"},
+	} {
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(revocationRequest); err != nil {
+			t.Fatalf("failed to marshal revocation request %v: %v", revocationRequest, err)
+		}
+
+		resp, err := http.Post(fmt.Sprintf("%v/pki/revocations", ts.URL), "application/json", body)
+		if err != nil {
+			t.Fatalf("Failed to revoke certificate: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp.Status)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestSignCSR(t *testing.T) {
+	privKey, csrPEM, err := generateTestCSR("device0.example.com", []string{"device0.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate CSR: %v", err)
+	}
+
+	signRequest := &model.CSRSignRequest{
+		Id:             "csr-cert-id0",
+		CSR:            csrPEM,
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+		TTL:            "24h",
+		ExtKeyUsages:   []string{"clientAuth"},
+	}
+	signResponse, err := sm.SignCSR(context.GetTestRequestContext(), signRequest)
+	if err != nil {
+		t.Fatalf("Failed to sign CSR: %v", err)
+	}
+
+	cert, err := parseCertificatePEM(signResponse.Certificate)
+	if err != nil {
+		t.Fatalf("Failed to parse returned certificate: %v", err)
+	}
+
+	if !publicKeysEqual(&privKey.PublicKey, cert.PublicKey) {
+		t.Fatalf("Certificate public key differs from the CSR's")
+	}
+
+	if cert.Subject.CommonName != "device0.example.com" || len(cert.DNSNames) != 1 || cert.DNSNames[0] != "device0.example.com" {
+		t.Fatalf("Unexpected certificate subject %v or SANs %v", cert.Subject, cert.DNSNames)
+	}
+
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Fatalf("Unexpected extended key usage: %v", cert.ExtKeyUsage)
+	}
+
+	if len(cert.CRLDistributionPoints) != 1 || cert.CRLDistributionPoints[0] != "https://localhost:8443/pki/crl" {
+		t.Fatalf("Unexpected CRL distribution points: %v", cert.CRLDistributionPoints)
+	}
+	if len(cert.OCSPServer) != 1 || cert.OCSPServer[0] != "https://localhost:8443/pki/ocsp" {
+		t.Fatalf("Unexpected OCSP servers: %v", cert.OCSPServer)
+	}
+
+	caCert, _, err := getCACertAndKey(config.GenerateTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+	if err := cert.CheckSignatureFrom(caCert); err != nil {
+		t.Fatalf("Certificate is not signed by the CA: %v", err)
+	}
+
+	// the certificate secret is renewed from the stored CSR
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), signResponse.Id); err != nil {
+		t.Fatalf("Failed to renew certificate: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), signResponse.Id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", signResponse.Id, err)
+	}
+
+	renewedCert, err := parseCertificatePEM(string(se.SecretData))
+	if err != nil {
+		t.Fatalf("Failed to parse renewed certificate: %v", err)
+	}
+
+	if renewedCert.SerialNumber.Cmp(cert.SerialNumber) == 0 || !publicKeysEqual(&privKey.PublicKey, renewedCert.PublicKey) {
+		t.Fatalf("Unexpected renewed certificate")
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), signResponse.Id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", signResponse.Id, err)
+	}
+}
+
+func TestSignBadCSR(t *testing.T) {
+	_, csrPEM, err := generateTestCSR("device1.example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate CSR: %v", err)
+	}
+
+	// tamper with the CSR, invalidating its signature
+	block, _ := pem.Decode([]byte(csrPEM))
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	tamperedCSRPEM := string(pem.EncodeToMemory(block))
+
+	for _, csr := range []string{"not a CSR", tamperedCSRPEM} {
+		signRequest := &model.CSRSignRequest{
+			Id:  "csr-cert-id1",
+			CSR: csr,
+		}
+		if _, err := sm.SignCSR(context.GetTestRequestContext(), signRequest); err == nil {
+			t.Fatalf("Signed a bad CSR")
+		}
+	}
+}
+
+func TestSignCSRViolatingIssuancePolicy(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("csr-ns", &model.CertIssuancePolicy{
+		AllowedDomains: []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	// the CSR's common name and SANs are both checked
+	for _, names := range [][]string{
+		{"device2.example.org", "device2.example.org"},
+		{"device2.example.org", "example.com"},
+		{"example.com", "device2.example.org"},
+	} {
+		_, csrPEM, err := generateTestCSR(names[0], names[1:])
+		if err != nil {
+			t.Fatalf("Failed to generate CSR: %v", err)
+		}
+
+		signRequest := &model.CSRSignRequest{
+			Id:  "csr-ns/csr-cert-id2",
+			CSR: csrPEM,
+		}
+		if _, err := sm.SignCSR(context.GetTestRequestContext(), signRequest); err != util.ErrUnauthorized {
+			t.Fatalf("Unexpected error when signing a CSR for %v violating the namespace's issuance policy: %v", names, err)
+		}
+	}
+}
+
+func TestSignCSRForCACertificate(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("csr-ca-ns", testCAIssuancePolicy)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	_, csrPEM, err := generateTestCSR("ca.example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate CSR: %v", err)
+	}
+
+	// CA certificates are refused unless a policy explicitly allows them
+	for _, id := range []string{"csr-ca-id0", "csr-ca-ns/csr-ca-id0"} {
+		signRequest := &model.CSRSignRequest{
+			Id:   id,
+			CSR:  csrPEM,
+			IsCA: true,
+		}
+		_, err := sm.SignCSR(context.GetTestRequestContext(), signRequest)
+		if id == "csr-ca-ns/csr-ca-id0" {
+			if err != nil {
+				t.Fatalf("Failed to sign a CSR for a CA certificate allowed by the issuance policy: %v", err)
+			}
+			defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+		} else if err != util.ErrUnauthorized {
+			t.Fatalf("Unexpected error when signing a CSR for a CA certificate as %v: %v", id, err)
+		}
+	}
+}
+
+func TestRevokeCertificate(t *testing.T) {
+	cert, err := signTestCSR("revoked-cert-id0", "revoked0.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "revoked-cert-id0")
+
+	revocationRequest := &model.RevocationRequest{
+		SecretId: "revoked-cert-id0",
+		Reason:   "keyCompromise",
+	}
+	certEntry, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest)
+	if err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+
+	if certEntry.SerialNumber != fmt.Sprintf("%x", cert.SerialNumber) || certEntry.SecretId != "revoked-cert-id0" ||
+		!certEntry.Revoked || certEntry.RevocationReason != "keyCompromise" {
+		t.Fatalf("Unexpected certificate entry: %v", certEntry)
+	}
+
+	// a certificate can only be revoked once
+	revocationRequest = &model.RevocationRequest{
+		SerialNumber: certEntry.SerialNumber,
+		Reason:       "superseded",
+	}
+	if _, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest); err != util.ErrAlreadyExists {
+		t.Fatalf("Unexpected error when revoking a revoked certificate: %v", err)
+	}
+}
+
+func TestRevokeCertificateBySerialNumber(t *testing.T) {
+	cert, err := signTestCSR("revoked-cert-id1", "revoked1.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	// the certificate can still be revoked after its secret is deleted
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), "revoked-cert-id1"); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+
+	revocationRequest := &model.RevocationRequest{
+		SerialNumber: fmt.Sprintf("%X", cert.SerialNumber),
+		Reason:       model.RevocationReasonUnspecified,
+	}
+	certEntry, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest)
+	if err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+
+	if certEntry.SecretId != "revoked-cert-id1" || !certEntry.Revoked {
+		t.Fatalf("Unexpected certificate entry: %v", certEntry)
+	}
+
+	revocationRequest = &model.RevocationRequest{
+		SerialNumber: "0123456789abcdef",
+	}
+	if _, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when revoking a non-existent certificate: %v", err)
+	}
+}
+
+func signTestCSR(id string, commonName string) (*x509.Certificate, error) {
+	_, csrPEM, err := generateTestCSR(commonName, []string{commonName})
+	if err != nil {
+		return nil, err
+	}
+
+	signRequest := &model.CSRSignRequest{
+		Id:  id,
+		CSR: csrPEM,
+	}
+	signResponse, err := sm.SignCSR(context.GetTestRequestContext(), signRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCertificatePEM(signResponse.Certificate)
+}
+
+func generateTestCSR(commonName string, dnsNames []string) (*ecdsa.PrivateKey, string, error) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName, Organization: []string{"Test Examples"}},
+		DNSNames: dnsNames,
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, template, privKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return privKey, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})), nil
+}
+
+func parseCertificatePEM(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func publicKeysEqual(pubKey1, pubKey2 interface{}) bool {
+	der1, err1 := x509.MarshalPKIXPublicKey(pubKey1)
+	der2, err2 := x509.MarshalPKIXPublicKey(pubKey2)
+
+	return err1 == nil && err2 == nil && bytes.Equal(der1, der2)
+}
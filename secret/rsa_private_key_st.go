@@ -12,7 +12,6 @@ import (
 	"fmt"
 
 	"github.com/vmware/virtual-security-module/context"
-	"github.com/vmware/virtual-security-module/crypt"
 	"github.com/vmware/virtual-security-module/model"
 	"github.com/vmware/virtual-security-module/util"
 	"github.com/vmware/virtual-security-module/vds"
@@ -25,6 +24,9 @@ func init() {
 	if err := SecretTypeRegistrar.Register(RSAPrivateKeySecretTypeName, NewRSAPrivateKeySecretType()); err != nil {
 		panic(fmt.Sprintf("Failed to register secret type %v: %v", RSAPrivateKeySecretTypeName, err))
 	}
+	if err := SecretTypeRegistrar.RegisterSchema(RSAPrivateKeySecretTypeName, rsaPrivateKeyMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", RSAPrivateKeySecretTypeName, err))
+	}
 }
 
 type RSAPrivateKeySecretType struct {
@@ -36,6 +38,14 @@ type RSAPrivateKeySecretMetaData struct {
 	KeyLength int `json:"keyLength"`
 }
 
+var rsaPrivateKeyMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"keyLength": {Type: model.SchemaTypeInteger, Description: "key length in bits", Minimum: model.SchemaBound(1), Maximum: model.SchemaBound(2048)},
+	},
+	Required: []string{"keyLength"},
+}
+
 func NewRSAPrivateKeySecretType() *RSAPrivateKeySecretType {
 	return &RSAPrivateKeySecretType{}
 }
@@ -44,6 +54,14 @@ func (rsaPrivKeyST *RSAPrivateKeySecretType) Type() string {
 	return RSAPrivateKeySecretTypeName
 }
 
+func (rsaPrivKeyST *RSAPrivateKeySecretType) Description() string {
+	return "An RSA private key, generated by the server"
+}
+
+func (rsaPrivKeyST *RSAPrivateKeySecretType) SecretDataDescription() string {
+	return ""
+}
+
 func (rsaPrivKeyST *RSAPrivateKeySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
 	rsaPrivKeyST.dataStore = moduleInitContext.DataStore
 	rsaPrivKeyST.keyStore = moduleInitContext.VirtualKeyStore
@@ -52,104 +70,81 @@ func (rsaPrivKeyST *RSAPrivateKeySecretType) Init(moduleInitContext *context.Mod
 }
 
 func (rsaPrivKeyST *RSAPrivateKeySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
-	// get desired key length
-	var rsaPrivKeySTMetaData RSAPrivateKeySecretMetaData
-	if err := json.Unmarshal([]byte(secretEntry.MetaData), &rsaPrivKeySTMetaData); err != nil {
-		return "", util.ErrInputValidation
-	}
-
-	keyLength := rsaPrivKeySTMetaData.KeyLength
-	if keyLength <= 0 || keyLength > 2048 {
-		return "", util.ErrInputValidation
-	}
-
-	// we expect the input to contain no data, as we're generating the data
-	// (the private key) in this case
-	if len(secretEntry.SecretData) > 0 {
-		return "", util.ErrInputValidation
-	}
-
-	// generate encryption key for secret
-	key, err := crypt.GenerateKey()
+	// generate secret data (private key in this case)
+	pkPEM, err := generateRSAPrivateKey(secretEntry)
 	if err != nil {
-		return "", util.ErrInternal
+		return "", err
 	}
 
-	// reduce key exposure due to memory compromize / leak
-	defer util.Memzero(key)
-
-	// generate secret data (private key in this case) and encrypt it using temporary key
-	pk, err := rsa.GenerateKey(rand.Reader, keyLength)
-	if err != nil {
+	if err := createEncryptedSecret(rsaPrivKeyST.dataStore, rsaPrivKeyST.keyStore, secretEntry, pkPEM); err != nil {
 		return "", err
 	}
-	b := x509.MarshalPKCS1PrivateKey(pk)
-	block := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: b,
-	}
-	pkPEM := pem.EncodeToMemory(&block)
 
-	encryptedSecretData, err := crypt.Encrypt(pkPEM, key)
+	return secretEntry.Id, nil
+}
+
+func (rsaPrivKeyST *RSAPrivateKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	pkPEM, err := decryptSecret(rsaPrivKeyST.keyStore, secretEntry)
 	if err != nil {
-		return "", util.ErrInternal
+		return nil, err
 	}
 
-	se := model.NewSecretEntry(secretEntry)
-	se.SecretData = encryptedSecretData
+	// set decrypted data
+	secretEntry.SecretData = pkPEM
+
+	return secretEntry, nil
+}
 
-	// create a data store entry and save it
-	dataStoreEntry, err := vds.SecretEntryToDataStoreEntry(se)
+// UpdateSecret generates a new private key, replacing the current one.
+func (rsaPrivKeyST *RSAPrivateKeySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	pkPEM, err := generateRSAPrivateKey(secretEntry)
 	if err != nil {
 		return "", err
 	}
-	if err := rsaPrivKeyST.dataStore.CreateEntry(dataStoreEntry); err != nil {
-		return "", err
-	}
 
-	// persist key using virtual key store
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-	if err := rsaPrivKeyST.keyStore.Create(secretPath, key); err != nil {
+	if err := updateEncryptedSecret(rsaPrivKeyST.dataStore, rsaPrivKeyST.keyStore, secretEntry, pkPEM); err != nil {
 		return "", err
 	}
 
 	return secretEntry.Id, nil
 }
 
-func (rsaPrivKeyST *RSAPrivateKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-
-	// fetch encryption key
-	key, err := rsaPrivKeyST.keyStore.Read(secretPath)
-	if err != nil {
-		return nil, err
-	}
+// RotateSecret generates a new private key of the same length.
+func (rsaPrivKeyST *RSAPrivateKeySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return rsaPrivKeyST.UpdateSecret(ctx, secretEntry)
+}
 
-	// reduce key exposure due to memory compromize / leak
-	defer util.Memzero(key)
+func (rsaPrivKeyST *RSAPrivateKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(rsaPrivKeyST.dataStore, rsaPrivKeyST.keyStore, secretEntry)
+}
 
-	// decrypt secret data using key
-	pkPEM, err := crypt.Decrypt(secretEntry.SecretData, key)
-	if err != nil {
-		return nil, util.ErrInternal
+func generateRSAPrivateKey(secretEntry *model.SecretEntry) ([]byte, error) {
+	// get desired key length
+	var rsaPrivKeySTMetaData RSAPrivateKeySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &rsaPrivKeySTMetaData); err != nil {
+		return nil, util.ErrInputValidation
 	}
 
-	// set decrypted data
-	secretEntry.SecretData = pkPEM
-
-	return secretEntry, nil
-}
-
-func (rsaPrivKeyST *RSAPrivateKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
+	keyLength := rsaPrivKeySTMetaData.KeyLength
+	if keyLength <= 0 || keyLength > 2048 {
+		return nil, util.ErrInputValidation
+	}
 
-	if err := rsaPrivKeyST.dataStore.DeleteEntry(secretPath); err != nil {
-		return err
+	// we expect the input to contain no data, as we're generating the data
+	// (the private key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, util.ErrInputValidation
 	}
 
-	if err := rsaPrivKeyST.keyStore.Delete(secretPath); err != nil {
-		return err
+	pk, err := rsa.GenerateKey(rand.Reader, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	b := x509.MarshalPKCS1PrivateKey(pk)
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: b,
 	}
 
-	return nil
+	return pem.EncodeToMemory(&block), nil
 }
@@ -12,13 +12,18 @@ package secret
 import (
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/naoina/denco"
 	"github.com/vmware/virtual-security-module/model"
 	"github.com/vmware/virtual-security-module/util"
 )
 
+// Period within which secrets are listed as expiring, unless specified otherwise.
+const DefaultExpiringSecretsWithin = 24 * time.Hour
+
 func (secretManager *SecretManager) RegisterEndpoints(mux *denco.Mux) []denco.Handler {
 	// swagger:route POST /secrets secrets CreateSecret
 	//
@@ -49,9 +54,97 @@ func (secretManager *SecretManager) RegisterEndpoints(mux *denco.Mux) []denco.Ha
 		}
 	}
 
+	// swagger:route POST /secret-batches secrets CreateSecrets
+	//
+	// Creates a batch of secrets, reporting the outcome of each
+	//
+	//	Responses:
+	//		200: SecretBatchResponse
+	createSecrets := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		batchRequest, err := model.ExtractAndValidateSecretBatchRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		batchResponse, err := secretManager.CreateSecrets(r.Context(), batchRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, batchResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /secrets secrets ListSecrets
+	//
+	// Lists the secrets in a namespace, without their data
+	//
+	// 	Responses:
+	//		200: SecretListResponse
+	listSecrets := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		query := r.URL.Query()
+
+		recursive := false
+		if recursiveStr := query.Get("recursive"); recursiveStr != "" {
+			b, err := strconv.ParseBool(recursiveStr)
+			if err != nil {
+				if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+					log.Printf("failed to write error response: %v\n", e)
+				}
+				return
+			}
+			recursive = b
+		}
+
+		listEntries, err := secretManager.ListSecrets(r.Context(), query.Get("prefix"), recursive)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, listEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /secret-search secrets SearchSecrets
+	//
+	// Lists the secrets in a namespace and its sub-namespaces whose tags match a selector,
+	// without their data
+	//
+	//	Responses:
+	//		200: SecretListResponse
+	searchSecrets := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		query := r.URL.Query()
+
+		listEntries, err := secretManager.SearchSecrets(r.Context(), query.Get("prefix"), query.Get("selector"))
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, listEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
 	// swagger:route GET /secrets/{path} secrets GetSecret
 	//
-	// Retrieves a secret
+	// Retrieves a secret, or a specific version of it
 	//
 	// 	Responses:
 	//		200: SecretEntryResponse
@@ -65,7 +158,21 @@ func (secretManager *SecretManager) RegisterEndpoints(mux *denco.Mux) []denco.Ha
 			return
 		}
 
-		secretEntry, err := secretManager.GetSecret(r.Context(), secretPath)
+		var secretEntry *model.SecretEntry
+		var err error
+		if versionStr := r.URL.Query().Get("version"); versionStr != "" {
+			version, e := strconv.Atoi(versionStr)
+			if e != nil {
+				if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+					log.Printf("failed to write error response: %v\n", e)
+				}
+				return
+			}
+
+			secretEntry, err = secretManager.GetSecretVersion(r.Context(), secretPath, version)
+		} else {
+			secretEntry, err = secretManager.GetSecret(r.Context(), secretPath)
+		}
 		if err != nil {
 			log.Printf("Error: %s\n", err.Error())
 			if e := util.WriteErrorResponse(w, err); e != nil {
@@ -79,6 +186,112 @@ func (secretManager *SecretManager) RegisterEndpoints(mux *denco.Mux) []denco.Ha
 		}
 	}
 
+	// swagger:route PUT /secrets/{path} secrets UpdateSecret
+	//
+	// Updates a secret, keeping its current version as a prior version
+	//
+	//	Responses:
+	//		200: SecretUpdateResponse
+	updateSecret := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretPath := strings.TrimPrefix(r.URL.Path, "/secrets/")
+
+		if secretPath == "" {
+			if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		secretEntry, err := model.ExtractAndValidateSecretUpdate(r, secretPath)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		id, err := secretManager.UpdateSecret(r.Context(), secretEntry)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, &model.CreationResponse{Id: id}, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /secret-versions/{path} secrets ListSecretVersions
+	//
+	// Lists the current and prior versions of a secret
+	//
+	// 	Responses:
+	//		200: SecretVersionsResponse
+	listSecretVersions := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretPath := strings.TrimPrefix(r.URL.Path, "/secret-versions/")
+
+		if secretPath == "" {
+			if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		versionEntries, err := secretManager.ListSecretVersions(r.Context(), secretPath)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, versionEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /secret-versions/{path} secrets RollbackSecret
+	//
+	// Rolls a secret back to one of its prior versions
+	//
+	//	Responses:
+	//		200: SecretUpdateResponse
+	rollbackSecret := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretPath := strings.TrimPrefix(r.URL.Path, "/secret-versions/")
+
+		if secretPath == "" {
+			if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		rollbackRequest, err := model.ExtractAndValidateRollbackRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		id, err := secretManager.RollbackSecret(r.Context(), secretPath, rollbackRequest.Version)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, &model.CreationResponse{Id: id}, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
 	// swagger:route DELETE /secrets/{path} secrets DeleteSecret
 	//
 	// Deletes a secret
@@ -104,11 +317,196 @@ func (secretManager *SecretManager) RegisterEndpoints(mux *denco.Mux) []denco.Ha
 		util.WriteStatus(w, http.StatusNoContent)
 	}
 
+	// swagger:route POST /secret-rotations/{path} secrets RotateSecret
+	//
+	// Regenerates a system-generated secret, keeping its current version as a prior version
+	//
+	//	Responses:
+	//		200: SecretUpdateResponse
+	rotateSecret := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretPath := strings.TrimPrefix(r.URL.Path, "/secret-rotations/")
+
+		if secretPath == "" {
+			if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		id, err := secretManager.RotateSecret(r.Context(), secretPath)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, &model.CreationResponse{Id: id}, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /secret-rotations/{path} secrets GetSecretRotation
+	//
+	// Retrieves the rotation schedule and history of a secret which has a rotation policy
+	//
+	//	Responses:
+	//		200: RotationEntryResponse
+	getSecretRotation := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		secretPath := strings.TrimPrefix(r.URL.Path, "/secret-rotations/")
+
+		if secretPath == "" {
+			if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		rotationEntry, err := secretManager.GetRotation(r.Context(), secretPath)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, rotationEntry, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /secret-types secrets ListSecretTypes
+	//
+	// Lists the supported secret types, along with their descriptions and the schemas of their metadata
+	//
+	//	Responses:
+	//		200: SecretTypesResponse
+	listSecretTypes := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		typeEntries := secretManager.ListSecretTypes(r.Context())
+
+		if e := util.WriteResponse(w, typeEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /expiring-secrets secrets ListExpiringSecrets
+	//
+	// Lists the secrets that are due to expire
+	//
+	// 	Responses:
+	//		200: SecretExpirationsResponse
+	listExpiringSecrets := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		within := DefaultExpiringSecretsWithin
+		if withinStr := r.URL.Query().Get("within"); withinStr != "" {
+			d, err := time.ParseDuration(withinStr)
+			if err != nil {
+				if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+					log.Printf("failed to write error response: %v\n", e)
+				}
+				return
+			}
+			within = d
+		}
+
+		expirationEntries, err := secretManager.ListExpiringSecrets(r.Context(), within)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, expirationEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route GET /secret-events secrets ListSecretEvents
+	//
+	// Lists the changes made to secrets by the server itself, e.g. certificate renewals
+	//
+	// 	Responses:
+	//		200: SecretEventsResponse
+	listSecretEvents := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		var since time.Time
+		if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+			t, err := time.Parse(time.RFC3339, sinceStr)
+			if err != nil {
+				if e := util.WriteErrorResponse(w, util.ErrInputValidation); e != nil {
+					log.Printf("failed to write error response: %v\n", e)
+				}
+				return
+			}
+			since = t
+		}
+
+		eventEntries, err := secretManager.ListSecretEvents(r.Context(), since)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, eventEntries, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /ssh/sign ssh SignSSHPublicKey
+	//
+	// Signs a SSH public key using a SSH certificate authority secret
+	//
+	//	Responses:
+	//		200: SSHSignResponse
+	signSSHPublicKey := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		signRequest, err := model.ExtractAndValidateSSHSignRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		signResponse, err := secretManager.SignSSHPublicKey(r.Context(), signRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, signResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
 	handlers := []denco.Handler{
 		mux.POST("/secrets", createSecret),
+		mux.GET("/secrets", listSecrets),
+		mux.POST("/secret-batches", createSecrets),
+		mux.GET("/secret-search", searchSecrets),
 		mux.GET("/secrets/*", getSecret),
+		mux.Handler("PUT", "/secrets/*", updateSecret),
 		mux.Handler("DELETE", "/secrets/*", deleteSecret),
+		mux.GET("/secret-versions/*", listSecretVersions),
+		mux.POST("/secret-versions/*", rollbackSecret),
+		mux.POST("/secret-rotations/*", rotateSecret),
+		mux.GET("/secret-rotations/*", getSecretRotation),
+		mux.GET("/secret-types", listSecretTypes),
+		mux.GET("/expiring-secrets", listExpiringSecrets),
+		mux.GET("/secret-events", listSecretEvents),
+		mux.POST("/ssh/sign", signSSHPublicKey),
 	}
+	handlers = append(handlers, secretManager.registerPKIEndpoints(mux)...)
+	handlers = append(handlers, secretManager.registerCryptoEndpoints(mux)...)
+	handlers = append(handlers, secretManager.registerWrappingEndpoints(mux)...)
+	handlers = append(handlers, secretManager.registerLeaseEndpoints(mux)...)
 
 	return handlers
 }
@@ -132,3 +530,126 @@ type SecretEntryResponse struct {
 	// in:body
 	SecretEntry model.SecretEntry
 }
+
+// swagger:parameters CreateSecrets
+type SecretBatchRequestParam struct {
+	// in:body
+	SecretBatchRequest model.SecretBatchRequest
+}
+
+// swagger:response SecretBatchResponse
+type SecretBatchResponse struct {
+	// in:body
+	SecretBatchResponse model.SecretBatchResponse
+}
+
+// swagger:parameters ListSecrets
+type SecretListParam struct {
+	// Namespace to list, relative to /secrets (default: /secrets)
+	// in:query
+	Prefix string `json:"prefix"`
+
+	// Whether to list secrets in sub-namespaces as well
+	// in:query
+	Recursive bool `json:"recursive"`
+}
+
+// swagger:parameters SearchSecrets
+type SecretSearchParam struct {
+	// Namespace to search, relative to /secrets (default: /secrets)
+	// in:query
+	Prefix string `json:"prefix"`
+
+	// Comma-separated tag requirements, all of which are met by matching secrets,
+	// e.g. env=prod,team in (a,b),app!=legacy
+	// in:query
+	Selector string `json:"selector"`
+}
+
+// swagger:response SecretListResponse
+type SecretListResponse struct {
+	// in:body
+	SecretListEntries []model.SecretListEntry
+}
+
+// swagger:parameters GetSecret
+type SecretVersionParam struct {
+	// in:query
+	Version int `json:"version"`
+}
+
+// swagger:parameters UpdateSecret
+type SecretUpdateParam struct {
+	// in:body
+	SecretEntry model.SecretEntry
+}
+
+// swagger:response SecretUpdateResponse
+type SecretUpdateResponse struct {
+	// in:body
+	Body struct {
+		SecretId string
+	}
+}
+
+// swagger:parameters RollbackSecret
+type RollbackRequestParam struct {
+	// in:body
+	RollbackRequest model.RollbackRequest
+}
+
+// swagger:response SecretVersionsResponse
+type SecretVersionsResponse struct {
+	// in:body
+	SecretVersionEntries []model.SecretVersionEntry
+}
+
+// swagger:response RotationEntryResponse
+type RotationEntryResponse struct {
+	// in:body
+	RotationEntry model.RotationEntry
+}
+
+// swagger:response SecretTypesResponse
+type SecretTypesResponse struct {
+	// in:body
+	SecretTypeEntries []model.SecretTypeEntry
+}
+
+// swagger:parameters ListExpiringSecrets
+type ExpiringSecretsParam struct {
+	// Period from now within which secrets expire, e.g. 72h (default: 24h)
+	// in:query
+	Within string `json:"within"`
+}
+
+// swagger:response SecretExpirationsResponse
+type SecretExpirationsResponse struct {
+	// in:body
+	SecretExpirationEntries []model.SecretExpirationEntry
+}
+
+// swagger:parameters ListSecretEvents
+type SecretEventsParam struct {
+	// Time (RFC 3339) after which events occurred (default: all events kept)
+	// in:query
+	Since string `json:"since"`
+}
+
+// swagger:response SecretEventsResponse
+type SecretEventsResponse struct {
+	// in:body
+	SecretEventEntries []model.SecretEventEntry
+}
+
+// swagger:parameters SignSSHPublicKey
+type SSHSignRequestParam struct {
+	// in:body
+	SSHSignRequest model.SSHSignRequest
+}
+
+// swagger:response SSHSignResponse
+type SSHSignResponse struct {
+	// in:body
+	SSHSignResponse model.SSHSignResponse
+}
@@ -0,0 +1,138 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+	"path"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// CreateSecrets creates a batch of secrets, e.g. ones imported from a file. Secrets
+// which already exist are skipped, updated or fail the batch, per the request's conflict
+// policy. When the batch fails, no secret is created or updated; otherwise each secret is
+// created or updated on its own, and the outcome of each is reported. A dry run reports
+// the planned outcomes, without creating or updating any secret.
+func (secretManager *SecretManager) CreateSecrets(ctx gocontext.Context, batchRequest *model.SecretBatchRequest) (*model.SecretBatchResponse, error) {
+	if !model.IsValidConflictPolicy(batchRequest.ConflictPolicy) {
+		return nil, util.ErrInputValidation
+	}
+
+	results := make([]*model.SecretBatchResult, 0, len(batchRequest.Secrets))
+	conflict := false
+	for i := range batchRequest.Secrets {
+		result, exists := secretManager.planBatchSecret(ctx, &batchRequest.Secrets[i], batchRequest.ConflictPolicy)
+		if exists && batchRequest.ConflictPolicy == model.ConflictPolicyFail {
+			conflict = true
+		}
+		results = append(results, result)
+	}
+
+	if conflict {
+		// nothing is done when the batch fails
+		for _, result := range results {
+			if result.Action != model.BatchActionFail {
+				result.Action = model.BatchActionSkip
+			}
+		}
+	} else if !batchRequest.DryRun {
+		for i, result := range results {
+			secretManager.applyBatchSecret(ctx, &batchRequest.Secrets[i], result)
+		}
+		secretManager.auditManager.Log(fmt.Sprintf("created a batch of %v secrets", len(results)))
+	}
+
+	response := &model.SecretBatchResponse{
+		DryRun:  batchRequest.DryRun,
+		Results: results,
+	}
+	for _, result := range results {
+		switch result.Action {
+		case model.BatchActionCreate:
+			response.Created++
+		case model.BatchActionUpdate:
+			response.Updated++
+		case model.BatchActionSkip:
+			response.Skipped++
+		case model.BatchActionFail:
+			response.Failed++
+		}
+	}
+
+	return response, nil
+}
+
+// planBatchSecret returns the planned outcome of a secret of a batch, and whether the
+// secret already exists. Whether it exists is only looked up once the caller is known to
+// be allowed to create secrets in its namespace (and to update them, if existing secrets
+// are overwritten), so that it isn't revealed to other callers.
+func (secretManager *SecretManager) planBatchSecret(ctx gocontext.Context, secretEntry *model.SecretEntry, conflictPolicy string) (*model.SecretBatchResult, bool) {
+	result := &model.SecretBatchResult{Id: secretEntry.Id}
+	secretPath := vds.SecretIdToPath(secretEntry.Id)
+
+	ops := []string{model.OpCreate}
+	if conflictPolicy == model.ConflictPolicyOverwrite {
+		ops = append(ops, model.OpUpdate)
+	}
+	for _, op := range ops {
+		if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: op}, path.Dir(secretPath)); err != nil {
+			return batchFailure(result, err), false
+		}
+	}
+
+	if err := SecretTypeRegistrar.Validate(secretEntry); err != nil {
+		return batchFailure(result, err), false
+	}
+
+	_, err := secretManager.getSecretEntry(secretPath)
+	if err != nil && err != util.ErrNotFound {
+		return batchFailure(result, err), false
+	}
+	exists := err == nil
+
+	if exists {
+		switch conflictPolicy {
+		case model.ConflictPolicySkip:
+			result.Action = model.BatchActionSkip
+			return result, true
+		case model.ConflictPolicyFail:
+			return batchFailure(result, util.ErrAlreadyExists), true
+		}
+	}
+
+	if exists {
+		result.Action = model.BatchActionUpdate
+	} else {
+		result.Action = model.BatchActionCreate
+	}
+
+	return result, exists
+}
+
+// applyBatchSecret creates or updates a secret of a batch, per its planned outcome.
+func (secretManager *SecretManager) applyBatchSecret(ctx gocontext.Context, secretEntry *model.SecretEntry, result *model.SecretBatchResult) {
+	var err error
+	switch result.Action {
+	case model.BatchActionCreate:
+		_, err = secretManager.CreateSecret(ctx, secretEntry)
+	case model.BatchActionUpdate:
+		_, err = secretManager.UpdateSecret(ctx, secretEntry)
+	default:
+		return
+	}
+
+	if err != nil {
+		batchFailure(result, err)
+	}
+}
+
+func batchFailure(result *model.SecretBatchResult, err error) *model.SecretBatchResult {
+	result.Action = model.BatchActionFail
+	result.Error = err.Error()
+
+	return result
+}
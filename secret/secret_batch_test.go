@@ -0,0 +1,193 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestCreateSecretsConflictPolicies(t *testing.T) {
+	if _, err := createDataSecret("batch-id0", "existing"); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "batch-id0")
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "batch-id1")
+
+	// with the fail policy, a conflict fails the batch and nothing is created
+	resp, err := sm.CreateSecrets(context.GetTestRequestContext(), newBatchRequest(model.ConflictPolicyFail, false, "batch-id0", "batch-id1"))
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	assertBatchResults(t, resp, map[string]string{"batch-id0": model.BatchActionFail, "batch-id1": model.BatchActionSkip})
+	if resp.Failed != 1 || resp.Skipped != 1 || resp.Results[0].Error != util.ErrAlreadyExists.Error() {
+		t.Fatalf("Unexpected batch response: %+v", resp)
+	}
+	if _, err := sm.GetSecret(context.GetTestRequestContext(), "batch-id1"); err != util.ErrNotFound {
+		t.Fatalf("Secret of a failed batch should not have been created: %v", err)
+	}
+
+	// a dry run reports the planned outcomes only
+	resp, err = sm.CreateSecrets(context.GetTestRequestContext(), newBatchRequest(model.ConflictPolicyOverwrite, true, "batch-id0", "batch-id1"))
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	assertBatchResults(t, resp, map[string]string{"batch-id0": model.BatchActionUpdate, "batch-id1": model.BatchActionCreate})
+	if se, err := sm.GetSecret(context.GetTestRequestContext(), "batch-id0"); err != nil || string(se.SecretData) != "existing" {
+		t.Fatalf("Secret should not have been updated by a dry run: %v %v", se, err)
+	}
+	if _, err := sm.GetSecret(context.GetTestRequestContext(), "batch-id1"); err != util.ErrNotFound {
+		t.Fatalf("Secret should not have been created by a dry run: %v", err)
+	}
+
+	// with the skip policy, existing secrets are left as is
+	resp, err = sm.CreateSecrets(context.GetTestRequestContext(), newBatchRequest(model.ConflictPolicySkip, false, "batch-id0", "batch-id1"))
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	assertBatchResults(t, resp, map[string]string{"batch-id0": model.BatchActionSkip, "batch-id1": model.BatchActionCreate})
+	if se, err := sm.GetSecret(context.GetTestRequestContext(), "batch-id0"); err != nil || string(se.SecretData) != "existing" {
+		t.Fatalf("Skipped secret should not have been updated: %v %v", se, err)
+	}
+	if se, err := sm.GetSecret(context.GetTestRequestContext(), "batch-id1"); err != nil || string(se.SecretData) != "imported-batch-id1" {
+		t.Fatalf("Failed to get created secret: %v %v", se, err)
+	}
+
+	// with the overwrite policy, existing secrets are updated with a new version
+	resp, err = sm.CreateSecrets(context.GetTestRequestContext(), newBatchRequest(model.ConflictPolicyOverwrite, false, "batch-id0"))
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	assertBatchResults(t, resp, map[string]string{"batch-id0": model.BatchActionUpdate})
+	se, err := sm.GetSecret(context.GetTestRequestContext(), "batch-id0")
+	if err != nil || string(se.SecretData) != "imported-batch-id0" || se.Version != model.FirstSecretVersion+1 {
+		t.Fatalf("Failed to get overwritten secret: %v %v", se, err)
+	}
+}
+
+func TestCreateSecretsPartialFailure(t *testing.T) {
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "batch-ok/id0")
+
+	restrictedManager := *sm
+	restrictedManager.authzManager = &denyNamespaceAuthzManager{namespacePath: vds.SecretIdToPath("batch-denied")}
+
+	batchRequest := newBatchRequest(model.ConflictPolicyFail, false, "batch-ok/id0", "batch-denied/id1", "batch-ok/id2")
+	batchRequest.Secrets[2].Type = "no-such-type"
+
+	resp, err := restrictedManager.CreateSecrets(context.GetTestRequestContext(), batchRequest)
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	assertBatchResults(t, resp, map[string]string{
+		"batch-ok/id0":     model.BatchActionCreate,
+		"batch-denied/id1": model.BatchActionFail,
+		"batch-ok/id2":     model.BatchActionFail,
+	})
+	if resp.Created != 1 || resp.Failed != 2 {
+		t.Fatalf("Unexpected batch response: %+v", resp)
+	}
+	if _, err := sm.GetSecret(context.GetTestRequestContext(), "batch-ok/id0"); err != nil {
+		t.Fatalf("Failed to get created secret: %v", err)
+	}
+}
+
+func TestCreateSecretsUnauthorizedExisting(t *testing.T) {
+	if _, err := createDataSecret("batch-hidden/id0", "hidden"); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "batch-hidden/id0")
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "batch-visible/id1")
+
+	restrictedManager := *sm
+	restrictedManager.authzManager = &denyNamespaceAuthzManager{namespacePath: vds.SecretIdToPath("batch-hidden")}
+
+	// an existing secret the caller can't access neither conflicts nor is reported as existing
+	batchRequest := newBatchRequest(model.ConflictPolicyFail, false, "batch-hidden/id0", "batch-visible/id1")
+
+	resp, err := restrictedManager.CreateSecrets(context.GetTestRequestContext(), batchRequest)
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	assertBatchResults(t, resp, map[string]string{
+		"batch-hidden/id0":  model.BatchActionFail,
+		"batch-visible/id1": model.BatchActionCreate,
+	})
+	if resp.Results[0].Error != util.ErrUnauthorized.Error() {
+		t.Fatalf("Unexpected error of a secret the caller can't access: %v", resp.Results[0].Error)
+	}
+}
+
+func TestAPICreateSecrets(t *testing.T) {
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "api-batch-id0")
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "api-batch-id1")
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(newBatchRequest("", false, "api-batch-id0", "api-batch-id1")); err != nil {
+		t.Fatalf("failed to marshal batch request: %v", err)
+	}
+	resp, err := http.Post(fmt.Sprintf("%v/secret-batches", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var batchResponse model.SecretBatchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&batchResponse); err != nil {
+		t.Fatalf("Failed to parse batch response: %v", err)
+	}
+	assertBatchResults(t, &batchResponse, map[string]string{"api-batch-id0": model.BatchActionCreate, "api-batch-id1": model.BatchActionCreate})
+
+	// duplicate ids are rejected
+	body = new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(newBatchRequest("", false, "api-batch-id2", "api-batch-id2")); err != nil {
+		t.Fatalf("failed to marshal batch request: %v", err)
+	}
+	resp2, err := http.Post(fmt.Sprintf("%v/secret-batches", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to create secrets: %v", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp2.Status)
+	}
+}
+
+func newBatchRequest(conflictPolicy string, dryRun bool, ids ...string) *model.SecretBatchRequest {
+	batchRequest := &model.SecretBatchRequest{ConflictPolicy: conflictPolicy, DryRun: dryRun}
+	for _, id := range ids {
+		batchRequest.Secrets = append(batchRequest.Secrets, model.SecretEntry{
+			Id:             id,
+			Type:           DataSecretTypeName,
+			SecretData:     []byte("imported-" + id),
+			Owner:          "user0",
+			ExpirationTime: time.Now().Add(time.Hour),
+		})
+	}
+
+	return batchRequest
+}
+
+func assertBatchResults(t *testing.T, resp *model.SecretBatchResponse, actions map[string]string) {
+	if len(resp.Results) != len(actions) {
+		t.Fatalf("Unexpected number of batch results: %+v", resp.Results)
+	}
+	for _, result := range resp.Results {
+		if actions[result.Id] != result.Action {
+			t.Fatalf("Unexpected outcome of secret %v: %v (%v)", result.Id, result.Action, result.Error)
+		}
+	}
+}
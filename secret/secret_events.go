@@ -0,0 +1,132 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Period for which secret events are kept, unless configured otherwise.
+const DefaultSecretEventRetention = 7 * 24 * time.Hour
+
+// recordSecretEvent records a change made to a secret by the server itself, so that
+// consumers of the secret can find out that they should reload it. The event is audited
+// as well.
+func (secretManager *SecretManager) recordSecretEvent(eventType string, secretId string, version int, msg string) error {
+	now := time.Now()
+
+	// event ids are ordered by the time of the events
+	eventEntry := &model.SecretEventEntry{
+		Id:       fmt.Sprintf("%019d-%v", now.UnixNano(), util.NewUUID()),
+		Type:     eventType,
+		SecretId: secretId,
+		Version:  version,
+		Time:     now,
+		Message:  msg,
+	}
+
+	secretManager.auditManager.Log(msg)
+
+	dataStoreEntry, err := vds.SecretEventEntryToDataStoreEntry(eventEntry)
+	if err != nil {
+		return err
+	}
+
+	return secretManager.dataStore.CreateEntry(dataStoreEntry)
+}
+
+// ListSecretEvents lists the events which occurred after the given time to secrets
+// the caller can read, ordered from the oldest to the most recent one.
+func (secretManager *SecretManager) ListSecretEvents(ctx gocontext.Context, since time.Time) ([]*model.SecretEventEntry, error) {
+	eventEntries := make([]*model.SecretEventEntry, 0)
+
+	// authorization is checked once per namespace
+	allowedNamespaces := make(map[string]bool)
+
+	err := secretManager.walkSecretEventEntries(func(eventEntry *model.SecretEventEntry) error {
+		if !eventEntry.Time.After(since) {
+			return nil
+		}
+
+		secretNamespace := path.Dir(vds.SecretIdToPath(eventEntry.SecretId))
+		allowed, ok := allowedNamespaces[secretNamespace]
+		if !ok {
+			allowed = secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, secretNamespace) == nil
+			allowedNamespaces[secretNamespace] = allowed
+		}
+		if !allowed {
+			return nil
+		}
+
+		eventEntries = append(eventEntries, eventEntry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(eventEntriesById(eventEntries))
+
+	return eventEntries, nil
+}
+
+// pruneSecretEvents deletes the events that occurred before the retention period,
+// counting back from time now.
+func (secretManager *SecretManager) pruneSecretEvents(now time.Time) {
+	pruneTime := now.Add(-secretManager.eventRetention)
+
+	err := secretManager.walkSecretEventEntries(func(eventEntry *model.SecretEventEntry) error {
+		if !eventEntry.Time.Before(pruneTime) {
+			return nil
+		}
+
+		if err := secretManager.dataStore.DeleteEntry(vds.SecretEventIdToPath(eventEntry.Id)); err != nil {
+			log.Printf("failed to delete secret event %v: %v\n", eventEntry.Id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to look for secret events to prune: %v\n", err)
+	}
+}
+
+func (secretManager *SecretManager) walkSecretEventEntries(walkFn func(*model.SecretEventEntry) error) error {
+	childEntries, err := secretManager.dataStore.SearchChildEntries(vds.SecretEventsRootPath)
+	if err != nil {
+		return err
+	}
+
+	for _, childEntry := range childEntries {
+		if !vds.IsSecretEventEntry(childEntry) {
+			continue
+		}
+
+		eventEntry, err := vds.DataStoreEntryToSecretEventEntry(childEntry)
+		if err != nil {
+			return err
+		}
+
+		if err := walkFn(eventEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type eventEntriesById []*model.SecretEventEntry
+
+func (s eventEntriesById) Len() int           { return len(s) }
+func (s eventEntriesById) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s eventEntriesById) Less(i, j int) bool { return s[i].Id < s[j].Id }
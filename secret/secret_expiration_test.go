@@ -0,0 +1,236 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestGetExpiredSecret(t *testing.T) {
+	id, err := createExpiringDataSecret("expiring-id0", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if _, err := sm.GetSecret(context.GetTestRequestContext(), id); err != util.ErrNotFound {
+		t.Fatalf("Expected not found error when getting an expired secret, got: %v", err)
+	}
+
+	// nor can it be updated
+	se := &model.SecretEntry{
+		Id:             id,
+		SecretData:     []byte("renewed"),
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), se); err != util.ErrNotFound {
+		t.Fatalf("Expected not found error when updating an expired secret, got: %v", err)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestClearSecretExpiration(t *testing.T) {
+	id, err := createExpiringDataSecret("expiring-id6", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if err := updateDataSecret(id, "secret-v2"); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret %v: %v", id, err)
+	}
+	if !se.ExpirationTime.IsZero() {
+		t.Fatalf("Expiration time of secret %v wasn't cleared: %v", id, se.ExpirationTime)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestRollbackExpiredSecret(t *testing.T) {
+	id, err := createExpiringDataSecret("expiring-id7", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	expirationTime := time.Now().Add(2 * time.Hour).Round(time.Second)
+	se := &model.SecretEntry{
+		Id:             id,
+		SecretData:     []byte("secret-v2"),
+		ExpirationTime: expirationTime,
+	}
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), se); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+
+	// the expiration time isn't rolled back
+	if _, err := sm.RollbackSecret(context.GetTestRequestContext(), id, model.FirstSecretVersion); err != nil {
+		t.Fatalf("Failed to roll back secret %v: %v", id, err)
+	}
+	rolledBackSE, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret %v: %v", id, err)
+	}
+	if !rolledBackSE.ExpirationTime.Equal(expirationTime) {
+		t.Fatalf("Unexpected expiration time of rolled back secret %v: %v", id, rolledBackSE.ExpirationTime)
+	}
+
+	// nor can an expired secret be brought back to life by rolling it back
+	se.SecretData = []byte("secret-v4")
+	se.ExpirationTime = time.Now().Add(-time.Minute)
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), se); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+	if _, err := sm.RollbackSecret(context.GetTestRequestContext(), id, model.FirstSecretVersion+1); err != util.ErrNotFound {
+		t.Fatalf("Expected not found error when rolling back an expired secret, got: %v", err)
+	}
+}
+
+func TestReapExpiredSecrets(t *testing.T) {
+	// the test configuration has a grace period of an hour
+	reapedId, err := createDataSecret("expiring-id1", "secret-v1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	se := &model.SecretEntry{
+		Id:             reapedId,
+		SecretData:     []byte("secret-v2"),
+		ExpirationTime: time.Now().Add(-2 * time.Hour),
+	}
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), se); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+
+	graceId, err := createExpiringDataSecret("expiring-id2", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if reaped := sm.reapExpiredSecrets(time.Now()); reaped < 1 {
+		t.Fatalf("Expected at least 1 secret to be reaped, got %v", reaped)
+	}
+
+	if _, err := sm.dataStore.ReadEntry(vds.SecretIdToPath(reapedId)); err != util.ErrNotFound {
+		t.Fatalf("Expected expired secret %v to be reaped, got: %v", reapedId, err)
+	}
+	if _, err := sm.dataStore.ReadEntry(vds.SecretVersionPath(reapedId, 1)); err != util.ErrNotFound {
+		t.Fatalf("Expected prior version of expired secret %v to be reaped, got: %v", reapedId, err)
+	}
+
+	if _, err := sm.dataStore.ReadEntry(vds.SecretIdToPath(graceId)); err != nil {
+		t.Fatalf("Secret %v was reaped during its grace period: %v", graceId, err)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), graceId); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", graceId, err)
+	}
+}
+
+func TestListExpiringSecrets(t *testing.T) {
+	soonId, err := createExpiringDataSecret("expiring-id3", time.Now().Add(10*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	laterId, err := createExpiringDataSecret("expiring-id4", time.Now().Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	expirationEntries, err := sm.ListExpiringSecrets(context.GetTestRequestContext(), 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to list expiring secrets: %v", err)
+	}
+
+	if !expirationEntriesContain(expirationEntries, soonId) {
+		t.Fatalf("Secret %v is missing from expiring secrets", soonId)
+	}
+	if expirationEntriesContain(expirationEntries, laterId) {
+		t.Fatalf("Secret %v is unexpectedly listed as expiring", laterId)
+	}
+
+	for _, id := range []string{soonId, laterId} {
+		if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+			t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+		}
+	}
+}
+
+func TestAPIListExpiringSecrets(t *testing.T) {
+	id, err := createExpiringDataSecret("expiring-id5", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%v/expiring-secrets?within=2h", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to list expiring secrets: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var expirationEntries []*model.SecretExpirationEntry
+	if err = json.NewDecoder(resp.Body).Decode(&expirationEntries); err != nil {
+		t.Fatalf("Failed to parse list expiring secrets response: %v", err)
+	}
+
+	if !expirationEntriesContain(expirationEntries, id) {
+		t.Fatalf("Secret %v is missing from expiring secrets", id)
+	}
+
+	resp2, err := http.Get(fmt.Sprintf("%v/expiring-secrets?within=soon", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to list expiring secrets: %v", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp2.Status)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func createExpiringDataSecret(id string, expirationTime time.Time) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           DataSecretTypeName,
+		SecretData:     []byte("expiring"),
+		Owner:          "user0",
+		ExpirationTime: expirationTime,
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
+
+func expirationEntriesContain(expirationEntries []*model.SecretExpirationEntry, id string) bool {
+	for _, expirationEntry := range expirationEntries {
+		if expirationEntry.Id == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestListSecrets(t *testing.T) {
+	namespaces := []string{"list-ns", "list-ns/sub", "list-ns/private"}
+	ids := []string{"list-ns/id0", "list-ns/id1", "list-ns/sub/id2", "list-ns/private/id3"}
+	setupListSecrets(t, namespaces, ids)
+	defer cleanupListSecrets(t, namespaces, ids)
+
+	// expired secrets are not listed
+	expiredId, err := createExpiringDataSecret("list-ns/expired-id", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(vds.SecretIdToPath(expiredId))
+
+	listEntries, err := sm.ListSecrets(context.GetTestRequestContext(), "list-ns", false)
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	if !listEntriesEqual(listEntries, []string{"list-ns/id0", "list-ns/id1"}) {
+		t.Fatalf("Unexpected non-recursive secret listing: %v", listEntries)
+	}
+
+	listEntries, err = sm.ListSecrets(context.GetTestRequestContext(), "/list-ns/", true)
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	if !listEntriesEqual(listEntries, []string{"list-ns/id0", "list-ns/id1", "list-ns/private/id3", "list-ns/sub/id2"}) {
+		t.Fatalf("Unexpected recursive secret listing: %v", listEntries)
+	}
+
+	// secrets in namespaces the caller is not allowed to read are filtered out
+	restrictedManager := *sm
+	restrictedManager.authzManager = &denyNamespaceAuthzManager{namespacePath: vds.SecretIdToPath("list-ns/private")}
+
+	listEntries, err = restrictedManager.ListSecrets(context.GetTestRequestContext(), "list-ns", true)
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	if !listEntriesEqual(listEntries, []string{"list-ns/id0", "list-ns/id1", "list-ns/sub/id2"}) {
+		t.Fatalf("Unexpected secret listing with restricted access: %v", listEntries)
+	}
+
+	if _, err := sm.ListSecrets(context.GetTestRequestContext(), "no-such-ns", true); err != util.ErrNotFound {
+		t.Fatalf("Expected not found error when listing a non-existent namespace, got: %v", err)
+	}
+}
+
+func TestAPIListSecrets(t *testing.T) {
+	namespaces := []string{"api-list-ns", "api-list-ns/sub"}
+	ids := []string{"api-list-ns/id0", "api-list-ns/sub/id1"}
+	setupListSecrets(t, namespaces, ids)
+	defer cleanupListSecrets(t, namespaces, ids)
+
+	resp, err := http.Get(fmt.Sprintf("%v/secrets?prefix=api-list-ns&recursive=true", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var listEntries []*model.SecretListEntry
+	if err = json.NewDecoder(resp.Body).Decode(&listEntries); err != nil {
+		t.Fatalf("Failed to parse list secrets response: %v", err)
+	}
+
+	if !listEntriesEqual(listEntries, ids) {
+		t.Fatalf("Unexpected secret listing: %v", listEntries)
+	}
+	if listEntries[0].Type != DataSecretTypeName || listEntries[0].Owner != "user0" {
+		t.Fatalf("Unexpected secret list entry: %v", listEntries[0])
+	}
+}
+
+func setupListSecrets(t *testing.T, namespaces, ids []string) {
+	for _, namespace := range namespaces {
+		dataStoreEntry, err := vds.NamespaceEntryToDataStoreEntry(&model.NamespaceEntry{Path: vds.SecretIdToPath(namespace)})
+		if err != nil {
+			t.Fatalf("Failed to convert namespace %v: %v", namespace, err)
+		}
+		if err := sm.dataStore.CreateEntry(dataStoreEntry); err != nil {
+			t.Fatalf("Failed to create namespace %v: %v", namespace, err)
+		}
+	}
+
+	for _, id := range ids {
+		if _, err := createDataSecret(id, "listed"); err != nil {
+			t.Fatalf("Failed to create secret %v: %v", id, err)
+		}
+	}
+}
+
+func cleanupListSecrets(t *testing.T, namespaces, ids []string) {
+	for _, id := range ids {
+		if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+			t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+		}
+	}
+
+	for i := len(namespaces) - 1; i >= 0; i-- {
+		if err := sm.dataStore.DeleteEntry(vds.SecretIdToPath(namespaces[i])); err != nil {
+			t.Fatalf("Failed to delete namespace %v: %v", namespaces[i], err)
+		}
+	}
+}
+
+func listEntriesEqual(listEntries []*model.SecretListEntry, ids []string) bool {
+	if len(listEntries) != len(ids) {
+		return false
+	}
+
+	for i, listEntry := range listEntries {
+		if listEntry.Id != ids[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// denyNamespaceAuthzManager denies all operations on a single namespace.
+type denyNamespaceAuthzManager struct {
+	namespacePath string
+}
+
+func (d *denyNamespaceAuthzManager) Allowed(ctx gocontext.Context, op model.Operation, namespacePath string) error {
+	if namespacePath == d.namespacePath {
+		return util.ErrUnauthorized
+	}
+
+	return nil
+}
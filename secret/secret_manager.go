@@ -4,17 +4,46 @@ package secret
 
 import (
 	gocontext "context"
+	"fmt"
+	"log"
 	"path"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/vmware/virtual-security-module/audit"
+	"github.com/vmware/virtual-security-module/config"
 	"github.com/vmware/virtual-security-module/context"
 	"github.com/vmware/virtual-security-module/model"
 	"github.com/vmware/virtual-security-module/util"
 	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
 )
 
+// Number of prior versions kept for each secret, unless configured otherwise.
+const DefaultMaxSecretVersions = 10
+
 type SecretManager struct {
-	dataStore    vds.DataStoreAdapter
-	authzManager context.AuthorizationManager
+	dataStore             vds.DataStoreAdapter
+	keyStore              *vks.VirtualKeyStore
+	authzManager          context.AuthorizationManager
+	auditManager          audit.AuditManager
+	maxVersions           int
+	expirationGracePeriod time.Duration
+	reaperStop            chan struct{}
+	reaperDone            chan struct{}
+	cfg                   *config.Config
+	crlInterval           time.Duration
+	crl                   *crlCache
+	crlUpdaterStop        chan struct{}
+	crlUpdaterDone        chan struct{}
+	eventRetention        time.Duration
+	renewerStop           chan struct{}
+	renewerDone           chan struct{}
+	// Identifies this server among the ones sharing the data store, e.g. as a lock holder
+	nodeId      string
+	rotatorStop chan struct{}
+	rotatorDone chan struct{}
 }
 
 func New() *SecretManager {
@@ -27,16 +56,77 @@ func (secretManager *SecretManager) Type() string {
 
 func (secretManager *SecretManager) Init(moduleInitContext *context.ModuleInitContext) error {
 	secretManager.dataStore = moduleInitContext.DataStore
+	secretManager.keyStore = moduleInitContext.VirtualKeyStore
 	secretManager.authzManager = moduleInitContext.AuthzManager
+	secretManager.auditManager = audit.NewLogAuditManager()
+	secretManager.cfg = moduleInitContext.Config
+	secretManager.crl = &crlCache{crlDERs: make(map[string][]byte)}
+	secretManager.nodeId = util.NewUUID()
+
+	secretsConfig := moduleInitContext.Config.SecretsConfig
+
+	secretManager.maxVersions = DefaultMaxSecretVersions
+	if secretsConfig.MaxVersions > 0 {
+		secretManager.maxVersions = secretsConfig.MaxVersions
+	}
+
+	if secretsConfig.ExpirationGracePeriod < 0 || secretsConfig.ReaperInterval < 0 {
+		return util.ErrBadConfig
+	}
+	secretManager.expirationGracePeriod = secretsConfig.ExpirationGracePeriod
+
+	reaperInterval := DefaultReaperInterval
+	if secretsConfig.ReaperInterval > 0 {
+		reaperInterval = secretsConfig.ReaperInterval
+	}
+
+	if secretsConfig.EventRetention < 0 || secretsConfig.RotationInterval < 0 {
+		return util.ErrBadConfig
+	}
+
+	secretManager.eventRetention = DefaultSecretEventRetention
+	if secretsConfig.EventRetention > 0 {
+		secretManager.eventRetention = secretsConfig.EventRetention
+	}
+
+	rotationInterval := DefaultRotationInterval
+	if secretsConfig.RotationInterval > 0 {
+		rotationInterval = secretsConfig.RotationInterval
+	}
+
+	pkiConfig := moduleInitContext.Config.PKIConfig
+	if pkiConfig.CRLInterval < 0 || pkiConfig.RenewalInterval < 0 {
+		return util.ErrBadConfig
+	}
+
+	secretManager.crlInterval = DefaultCRLInterval
+	if pkiConfig.CRLInterval > 0 {
+		secretManager.crlInterval = pkiConfig.CRLInterval
+	}
+
+	renewalInterval := DefaultRenewalInterval
+	if pkiConfig.RenewalInterval > 0 {
+		renewalInterval = pkiConfig.RenewalInterval
+	}
 
 	if err := SecretTypeRegistrar.InitSecretTypes(moduleInitContext); err != nil {
 		return err
 	}
 
+	secretManager.startReaper(reaperInterval)
+	secretManager.startCRLUpdater(secretManager.crlInterval)
+	secretManager.startRenewer(renewalInterval)
+	secretManager.startRotator(rotationInterval)
+
 	return nil
 }
 
 func (secretManager *SecretManager) Close() error {
+	secretManager.stopReaper()
+	secretManager.stopCRLUpdater()
+	secretManager.stopRenewer()
+	secretManager.stopRotator()
+
 	return nil
 }
 
@@ -47,12 +137,37 @@ func (secretManager *SecretManager) CreateSecret(ctx gocontext.Context, secretEn
 		return "", err
 	}
 
+	if err := SecretTypeRegistrar.Validate(secretEntry); err != nil {
+		return "", err
+	}
+
 	secretType, err := SecretTypeRegistrar.Get(secretEntry.Type)
 	if err != nil {
 		return "", util.ErrInputValidation
 	}
 
-	return secretType.CreateSecret(ctx, secretEntry)
+	se := model.NewSecretEntry(secretEntry)
+	se.Version = model.FirstSecretVersion
+
+	if se.RotationPolicy != nil && model.RotationPolicyEmpty(se.RotationPolicy) {
+		se.RotationPolicy = nil
+	}
+	if err := validateSecretRotationPolicy(secretType, se.RotationPolicy); err != nil {
+		return "", err
+	}
+
+	if err := model.ValidateTags(se.Tags); err != nil {
+		return "", err
+	}
+
+	id, err := secretType.CreateSecret(ctx, se)
+	if err != nil {
+		return "", err
+	}
+
+	secretManager.scheduleRotation(se.Id, se.RotationPolicy, nil, time.Now())
+
+	return id, nil
 }
 
 func (secretManager *SecretManager) GetSecret(ctx gocontext.Context, secretId string) (*model.SecretEntry, error) {
@@ -62,11 +177,40 @@ func (secretManager *SecretManager) GetSecret(ctx gocontext.Context, secretId st
 		return nil, err
 	}
 
-	secretEntry, err := secretManager.getSecretEntry(secretPath)
+	secretEntry, err := secretManager.getUnexpiredSecretEntry(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(secretEntry.Type)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return secretType.GetSecret(ctx, secretEntry)
+}
+
+// GetSecretVersion retrieves the given version of a secret, which is either
+// the current version or one of the prior versions kept.
+func (secretManager *SecretManager) GetSecretVersion(ctx gocontext.Context, secretId string, version int) (*model.SecretEntry, error) {
+	secretPath := vds.SecretIdToPath(secretId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, path.Dir(secretPath)); err != nil {
+		return nil, err
+	}
+
+	secretEntry, err := secretManager.getUnexpiredSecretEntry(secretPath)
 	if err != nil {
 		return nil, err
 	}
 
+	if secretEntry.Version != version {
+		secretEntry, err = secretManager.getSecretVersionEntry(secretId, version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	secretType, err := SecretTypeRegistrar.Get(secretEntry.Type)
 	if err != nil {
 		return nil, util.ErrInternal
@@ -75,6 +219,434 @@ func (secretManager *SecretManager) GetSecret(ctx gocontext.Context, secretId st
 	return secretType.GetSecret(ctx, secretEntry)
 }
 
+// UpdateSecret replaces a secret with a new version. The replaced version is
+// kept (encrypted under its own key) and can later be read or rolled back to.
+// The new version's expiration time replaces the current one, so an update
+// without an expiration time clears it. Expired secrets can't be updated.
+func (secretManager *SecretManager) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	secretPath := vds.SecretIdToPath(secretEntry.Id)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpUpdate}, path.Dir(secretPath)); err != nil {
+		return "", err
+	}
+
+	currentEntry, err := secretManager.getUnexpiredSecretEntry(secretPath)
+	if err != nil {
+		return "", err
+	}
+
+	se := model.NewSecretEntry(secretEntry)
+	if se.Type == "" {
+		se.Type = currentEntry.Type
+	}
+	if se.Type != currentEntry.Type {
+		return "", util.ErrInputValidation
+	}
+	if se.Owner == "" {
+		se.Owner = currentEntry.Owner
+	}
+	if se.MetaData == "" {
+		se.MetaData = currentEntry.MetaData
+	}
+	// the rotation policy is kept unless replaced; an empty policy removes it
+	if se.RotationPolicy == nil {
+		se.RotationPolicy = currentEntry.RotationPolicy
+	} else if model.RotationPolicyEmpty(se.RotationPolicy) {
+		se.RotationPolicy = nil
+	}
+	// so are the tags; empty tags remove them
+	if se.Tags == nil {
+		se.Tags = currentEntry.Tags
+	} else if len(se.Tags) == 0 {
+		se.Tags = nil
+	}
+	se.Version = currentEntry.Version + 1
+
+	secretType, err := SecretTypeRegistrar.Get(se.Type)
+	if err != nil {
+		return "", util.ErrInternal
+	}
+
+	if err := validateSecretRotationPolicy(secretType, se.RotationPolicy); err != nil {
+		return "", err
+	}
+
+	if err := model.ValidateTags(se.Tags); err != nil {
+		return "", err
+	}
+
+	if err := SecretTypeRegistrar.Validate(se); err != nil {
+		return "", err
+	}
+
+	if err := secretManager.archiveSecretVersion(currentEntry); err != nil {
+		return "", err
+	}
+
+	id, err := secretType.UpdateSecret(ctx, se)
+	if err != nil {
+		secretManager.unarchiveSecretVersion(currentEntry)
+		return "", err
+	}
+
+	secretManager.pruneSecretVersions(currentEntry)
+	secretManager.scheduleRotation(se.Id, se.RotationPolicy, currentEntry.RotationPolicy, time.Now())
+
+	return id, nil
+}
+
+// RotateSecret replaces a secret whose data is generated by the system (e.g. a
+// generated password or a private key) with a newly generated version, using the
+// secret's current metadata. Only secrets whose type is a RotatableSecretType can
+// be rotated.
+func (secretManager *SecretManager) RotateSecret(ctx gocontext.Context, secretId string) (string, error) {
+	se, err := secretManager.rotateSecret(ctx, secretId)
+	if err != nil {
+		return "", err
+	}
+
+	secretManager.recordOnDemandRotation(se, time.Now())
+
+	return se.Id, nil
+}
+
+// rotateSecret rotates a secret, returning its new version (without its data).
+func (secretManager *SecretManager) rotateSecret(ctx gocontext.Context, secretId string) (*model.SecretEntry, error) {
+	secretPath := vds.SecretIdToPath(secretId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpUpdate}, path.Dir(secretPath)); err != nil {
+		return nil, err
+	}
+
+	currentEntry, err := secretManager.getUnexpiredSecretEntry(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(currentEntry.Type)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	rotatableType, ok := secretType.(RotatableSecretType)
+	if !ok {
+		return nil, util.ErrInputValidation
+	}
+
+	se := model.NewSecretEntry(currentEntry)
+	se.SecretData = nil
+	se.Version = currentEntry.Version + 1
+
+	if err := secretManager.archiveSecretVersion(currentEntry); err != nil {
+		return nil, err
+	}
+
+	if _, err := rotatableType.RotateSecret(ctx, se); err != nil {
+		secretManager.unarchiveSecretVersion(currentEntry)
+		return nil, err
+	}
+
+	secretManager.pruneSecretVersions(currentEntry)
+
+	return se, nil
+}
+
+// SignSSHPublicKey signs a SSH public key using a SSH certificate authority secret,
+// returning a certificate which complies with the CA's policy. Signing requires
+// create permission on the CA's namespace.
+func (secretManager *SecretManager) SignSSHPublicKey(ctx gocontext.Context, signRequest *model.SSHSignRequest) (*model.SSHSignResponse, error) {
+	caPath := vds.SecretIdToPath(signRequest.CaId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpCreate}, path.Dir(caPath)); err != nil {
+		return nil, err
+	}
+
+	caEntry, err := secretManager.getUnexpiredSecretEntry(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if caEntry.Type != SSHCertificateAuthoritySecretTypeName {
+		return nil, util.ErrInputValidation
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(caEntry.Type)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	signResponse, err := secretType.(*SSHCertificateAuthoritySecretType).SignPublicKey(caEntry, signRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	secretManager.auditManager.Log(fmt.Sprintf("SSH CA %v signed certificate %v for principals %v, valid before %v",
+		caEntry.Id, signResponse.Serial, signRequest.Principals, signResponse.ValidBefore))
+
+	return signResponse, nil
+}
+
+// ListSecretVersions lists the current version of a secret, as well as the
+// prior versions kept, ordered from the oldest to the current one.
+func (secretManager *SecretManager) ListSecretVersions(ctx gocontext.Context, secretId string) ([]*model.SecretVersionEntry, error) {
+	secretPath := vds.SecretIdToPath(secretId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, path.Dir(secretPath)); err != nil {
+		return nil, err
+	}
+
+	currentEntry, err := secretManager.getUnexpiredSecretEntry(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	priorEntries, err := secretManager.getSecretVersionEntries(secretId)
+	if err != nil {
+		return nil, err
+	}
+
+	versionEntries := make([]*model.SecretVersionEntry, 0, len(priorEntries)+1)
+	for _, priorEntry := range priorEntries {
+		versionEntries = append(versionEntries, secretEntryToVersionEntry(priorEntry, false))
+	}
+	versionEntries = append(versionEntries, secretEntryToVersionEntry(currentEntry, true))
+
+	return versionEntries, nil
+}
+
+// Secret types which can't be rolled back: their prior versions are revoked outside of
+// the server (e.g. access keys deleted by a cloud provider) when the secrets are rotated.
+var unrollbackableSecretTypes = map[string]bool{
+	CloudAccessKeySecretTypeName: true,
+}
+
+// RollbackSecret makes the given prior version of a secret its current
+// version. Rolling back creates a new version, so it can be undone.
+func (secretManager *SecretManager) RollbackSecret(ctx gocontext.Context, secretId string, version int) (string, error) {
+	secretPath := vds.SecretIdToPath(secretId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpUpdate}, path.Dir(secretPath)); err != nil {
+		return "", err
+	}
+
+	currentEntry, err := secretManager.getUnexpiredSecretEntry(secretPath)
+	if err != nil {
+		return "", err
+	}
+
+	if unrollbackableSecretTypes[currentEntry.Type] {
+		return "", util.ErrInputValidation
+	}
+
+	if currentEntry.Version == version {
+		return secretId, nil
+	}
+
+	priorEntry, err := secretManager.getSecretVersionEntry(secretId, version)
+	if err != nil {
+		return "", err
+	}
+
+	// the prior version's data is re-used as-is; its key is copied under the
+	// new version's alias
+	key, err := secretManager.keyStore.Read(vds.SecretKeyAlias(secretId, priorEntry.Version))
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	// neither the expiration time, the rotation policy nor the tags are rolled back
+	se := model.NewSecretEntry(priorEntry)
+	se.Version = currentEntry.Version + 1
+	se.ExpirationTime = currentEntry.ExpirationTime
+	se.RotationPolicy = currentEntry.RotationPolicy
+	se.Tags = currentEntry.Tags
+
+	dataStoreEntry, err := vds.SecretEntryToDataStoreEntry(se)
+	if err != nil {
+		return "", err
+	}
+
+	if err := secretManager.archiveSecretVersion(currentEntry); err != nil {
+		return "", err
+	}
+
+	keyAlias := vds.SecretKeyAlias(secretId, se.Version)
+	if err := secretManager.keyStore.Create(keyAlias, key); err != nil {
+		secretManager.unarchiveSecretVersion(currentEntry)
+		return "", err
+	}
+
+	if err := secretManager.dataStore.UpdateEntry(dataStoreEntry); err != nil {
+		secretManager.keyStore.Delete(keyAlias)
+		secretManager.unarchiveSecretVersion(currentEntry)
+		return "", err
+	}
+
+	secretManager.pruneSecretVersions(currentEntry)
+
+	return secretId, nil
+}
+
+// ListSecrets lists the secrets the caller can read in the given namespace, whose
+// path is relative to the secrets' root namespace. If recursive is set, secrets in
+// sub-namespaces are listed as well. Expired secrets are not listed, and neither
+// is the secrets' data. Secrets are ordered by their id.
+func (secretManager *SecretManager) ListSecrets(ctx gocontext.Context, namespacePrefix string, recursive bool) ([]*model.SecretListEntry, error) {
+	namespacePath, err := secretManager.getSecretsNamespacePath(namespacePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	listEntries := make([]*model.SecretListEntry, 0)
+
+	// authorization is checked once per namespace
+	allowedNamespaces := make(map[string]bool)
+
+	err = secretManager.walkSecretEntries(namespacePath, recursive, func(secretEntry *model.SecretEntry) error {
+		if model.SecretExpired(secretEntry, now) {
+			return nil
+		}
+
+		secretNamespace := path.Dir(vds.SecretIdToPath(secretEntry.Id))
+		allowed, ok := allowedNamespaces[secretNamespace]
+		if !ok {
+			allowed = secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, secretNamespace) == nil
+			allowedNamespaces[secretNamespace] = allowed
+		}
+		if !allowed {
+			return nil
+		}
+
+		listEntries = append(listEntries, secretEntryToListEntry(secretEntry))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(listEntriesById(listEntries))
+
+	return listEntries, nil
+}
+
+// SearchSecrets lists the secrets the caller can read in the given namespace and its
+// sub-namespaces, whose tags match a tag selector (see model.ParseTagSelector). Secrets
+// inherit the tags of the namespaces they are in, unless they override them, and are
+// listed with the tags they inherit. Expired secrets are not listed, and neither is the
+// secrets' data. Secrets are ordered by their id.
+func (secretManager *SecretManager) SearchSecrets(ctx gocontext.Context, namespacePrefix string, selector string) ([]*model.SecretListEntry, error) {
+	requirements, err := model.ParseTagSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	namespacePath, err := secretManager.getSecretsNamespacePath(namespacePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	listEntries := make([]*model.SecretListEntry, 0)
+
+	// authorization is checked, and tags are looked up, once per namespace
+	allowedNamespaces := make(map[string]bool)
+	namespaceTags := make(map[string]map[string]string)
+
+	err = secretManager.walkSecretEntries(namespacePath, true, func(secretEntry *model.SecretEntry) error {
+		if model.SecretExpired(secretEntry, now) {
+			return nil
+		}
+
+		secretNamespace := path.Dir(vds.SecretIdToPath(secretEntry.Id))
+		allowed, ok := allowedNamespaces[secretNamespace]
+		if !ok {
+			allowed = secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, secretNamespace) == nil
+			allowedNamespaces[secretNamespace] = allowed
+		}
+		if !allowed {
+			return nil
+		}
+
+		tags := mergeTags(secretManager.getNamespaceTags(secretNamespace, namespaceTags), secretEntry.Tags)
+		if !model.TagsMatch(requirements, tags) {
+			return nil
+		}
+
+		listEntry := secretEntryToListEntry(secretEntry)
+		listEntry.Tags = tags
+		listEntries = append(listEntries, listEntry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(listEntriesById(listEntries))
+
+	return listEntries, nil
+}
+
+// ListSecretTypes lists the supported secret types, along with their descriptions and
+// the schemas of their metadata.
+func (secretManager *SecretManager) ListSecretTypes(ctx gocontext.Context) []*model.SecretTypeEntry {
+	typeEntries := make([]*model.SecretTypeEntry, 0)
+	for _, typeName := range SecretTypeRegistrar.TypeNames() {
+		typeEntry, err := SecretTypeRegistrar.Describe(typeName)
+		if err != nil {
+			continue
+		}
+		typeEntries = append(typeEntries, typeEntry)
+	}
+
+	return typeEntries
+}
+
+// ListExpiringSecrets lists the secrets the caller can read that expire within
+// the given duration from now, including the ones that have already expired but
+// were not yet deleted. Secrets are ordered by their expiration time.
+func (secretManager *SecretManager) ListExpiringSecrets(ctx gocontext.Context, within time.Duration) ([]*model.SecretExpirationEntry, error) {
+	if within < 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	now := time.Now()
+	expirationEntries := make([]*model.SecretExpirationEntry, 0)
+
+	err := secretManager.walkSecretEntries(vds.SecretsRootPath, true, func(secretEntry *model.SecretEntry) error {
+		if !model.SecretExpired(secretEntry, now.Add(within)) {
+			return nil
+		}
+
+		secretPath := vds.SecretIdToPath(secretEntry.Id)
+		if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, path.Dir(secretPath)); err != nil {
+			return nil
+		}
+
+		expirationEntries = append(expirationEntries, &model.SecretExpirationEntry{
+			Id:             secretEntry.Id,
+			Type:           secretEntry.Type,
+			Owner:          secretEntry.Owner,
+			ExpirationTime: secretEntry.ExpirationTime,
+			Expired:        model.SecretExpired(secretEntry, now),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(expirationEntriesByTime(expirationEntries))
+
+	return expirationEntries, nil
+}
+
 func (secretManager *SecretManager) DeleteSecret(ctx gocontext.Context, secretId string) error {
 	secretPath := vds.SecretIdToPath(secretId)
 
@@ -92,7 +664,59 @@ func (secretManager *SecretManager) DeleteSecret(ctx gocontext.Context, secretId
 		return util.ErrInternal
 	}
 
-	return secretType.DeleteSecret(ctx, secretEntry)
+	if err := secretType.DeleteSecret(ctx, secretEntry); err != nil {
+		return err
+	}
+
+	secretManager.unscheduleRotation(secretId)
+
+	return secretManager.deleteSecretVersions(secretId, 0)
+}
+
+// getSecretsNamespacePath returns the path of a namespace whose path is relative to the
+// secrets' root namespace.
+func (secretManager *SecretManager) getSecretsNamespacePath(namespacePrefix string) (string, error) {
+	prefix := strings.Trim(namespacePrefix, "/")
+	if prefix == "" {
+		return vds.SecretsRootPath, nil
+	}
+
+	namespacePath := vds.SecretIdToPath(prefix)
+
+	dataStoreEntry, err := secretManager.dataStore.ReadEntry(namespacePath)
+	if err != nil {
+		return "", err
+	}
+	if !vds.IsNamespaceEntry(dataStoreEntry) {
+		return "", util.ErrInputValidation
+	}
+
+	return namespacePath, nil
+}
+
+// getNamespaceTags returns the tags of a namespace, including the ones it inherits from
+// its parent namespaces. Tags are cached, by namespace path, in cache.
+func (secretManager *SecretManager) getNamespaceTags(namespacePath string, cache map[string]map[string]string) map[string]string {
+	if tags, ok := cache[namespacePath]; ok {
+		return tags
+	}
+
+	var parentTags map[string]string
+	if namespacePath != "/" {
+		parentTags = secretManager.getNamespaceTags(path.Dir(namespacePath), cache)
+	}
+
+	var ownTags map[string]string
+	if dataStoreEntry, err := secretManager.dataStore.ReadEntry(namespacePath); err == nil {
+		if namespaceEntry, err := vds.DataStoreEntryToNamespaceEntry(dataStoreEntry); err == nil {
+			ownTags = namespaceEntry.Tags
+		}
+	}
+
+	tags := mergeTags(parentTags, ownTags)
+	cache[namespacePath] = tags
+
+	return tags
 }
 
 func (secretManager *SecretManager) getSecretEntry(secretPath string) (*model.SecretEntry, error) {
@@ -108,3 +732,160 @@ func (secretManager *SecretManager) getSecretEntry(secretPath string) (*model.Se
 
 	return secretEntry, nil
 }
+
+// getUnexpiredSecretEntry is like getSecretEntry, but treats expired secrets as not found.
+func (secretManager *SecretManager) getUnexpiredSecretEntry(secretPath string) (*model.SecretEntry, error) {
+	secretEntry, err := secretManager.getSecretEntry(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if model.SecretExpired(secretEntry, time.Now()) {
+		return nil, util.ErrNotFound
+	}
+
+	return secretEntry, nil
+}
+
+func (secretManager *SecretManager) getSecretVersionEntry(secretId string, version int) (*model.SecretEntry, error) {
+	dataStoreEntry, err := secretManager.dataStore.ReadEntry(vds.SecretVersionPath(secretId, version))
+	if err != nil {
+		return nil, err
+	}
+
+	return vds.DataStoreVersionEntryToSecretEntry(dataStoreEntry)
+}
+
+// getSecretVersionEntries returns the prior versions of a secret, ordered from the oldest.
+func (secretManager *SecretManager) getSecretVersionEntries(secretId string) ([]*model.SecretEntry, error) {
+	dataStoreEntries, err := secretManager.dataStore.SearchChildEntries(vds.SecretVersionsPath(secretId))
+	if err != nil {
+		return nil, err
+	}
+
+	secretEntries := make([]*model.SecretEntry, 0, len(dataStoreEntries))
+	for _, dataStoreEntry := range dataStoreEntries {
+		secretEntry, err := vds.DataStoreVersionEntryToSecretEntry(dataStoreEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		secretEntries = append(secretEntries, secretEntry)
+	}
+
+	sort.Sort(secretEntriesByVersion(secretEntries))
+
+	return secretEntries, nil
+}
+
+// archiveSecretVersion keeps a copy of the given (encrypted) secret entry as a prior version.
+// The version's key is left in place - it is aliased by the version.
+func (secretManager *SecretManager) archiveSecretVersion(secretEntry *model.SecretEntry) error {
+	dataStoreEntry, err := vds.SecretEntryToDataStoreVersionEntry(secretEntry)
+	if err != nil {
+		return err
+	}
+
+	return secretManager.dataStore.CreateEntry(dataStoreEntry)
+}
+
+func (secretManager *SecretManager) unarchiveSecretVersion(secretEntry *model.SecretEntry) {
+	if err := secretManager.dataStore.DeleteEntry(vds.SecretVersionPath(secretEntry.Id, secretEntry.Version)); err != nil {
+		log.Printf("failed to remove version %v of secret %v: %v\n", secretEntry.Version, secretEntry.Id, err)
+	}
+}
+
+// Secret types whose prior versions aren't pruned: data encrypted (or MACed) using a key
+// refers to the key version, which must remain available for as long as the data is kept.
+var unprunedSecretTypes = map[string]bool{
+	SymmetricKeySecretTypeName: true,
+	HMACKeySecretTypeName:      true,
+}
+
+// pruneSecretVersions deletes the oldest prior versions of a secret, keeping at most maxVersions.
+func (secretManager *SecretManager) pruneSecretVersions(secretEntry *model.SecretEntry) {
+	if unprunedSecretTypes[secretEntry.Type] {
+		return
+	}
+
+	if err := secretManager.deleteSecretVersions(secretEntry.Id, secretManager.maxVersions); err != nil {
+		log.Printf("failed to prune versions of secret %v: %v\n", secretEntry.Id, err)
+	}
+}
+
+// deleteSecretVersions deletes the oldest prior versions of a secret (and their keys),
+// keeping the newest versionsToKeep ones.
+func (secretManager *SecretManager) deleteSecretVersions(secretId string, versionsToKeep int) error {
+	secretEntries, err := secretManager.getSecretVersionEntries(secretId)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(secretEntries)-versionsToKeep; i++ {
+		secretEntry := secretEntries[i]
+
+		if err := secretManager.dataStore.DeleteEntry(vds.SecretVersionPath(secretId, secretEntry.Version)); err != nil {
+			return err
+		}
+
+		if err := secretManager.keyStore.Delete(vds.SecretKeyAlias(secretId, secretEntry.Version)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func secretEntryToListEntry(secretEntry *model.SecretEntry) *model.SecretListEntry {
+	return &model.SecretListEntry{
+		Id:             secretEntry.Id,
+		Type:           secretEntry.Type,
+		Owner:          secretEntry.Owner,
+		ExpirationTime: secretEntry.ExpirationTime,
+		Tags:           secretEntry.Tags,
+	}
+}
+
+// mergeTags returns the union of two sets of tags; tags in overrides take precedence.
+func mergeTags(tags map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(tags)+len(overrides))
+	for key, value := range tags {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+func secretEntryToVersionEntry(secretEntry *model.SecretEntry, current bool) *model.SecretVersionEntry {
+	return &model.SecretVersionEntry{
+		Id:             secretEntry.Id,
+		Version:        secretEntry.Version,
+		Type:           secretEntry.Type,
+		Owner:          secretEntry.Owner,
+		ExpirationTime: secretEntry.ExpirationTime,
+		Current:        current,
+	}
+}
+
+type secretEntriesByVersion []*model.SecretEntry
+
+func (s secretEntriesByVersion) Len() int           { return len(s) }
+func (s secretEntriesByVersion) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s secretEntriesByVersion) Less(i, j int) bool { return s[i].Version < s[j].Version }
+
+type expirationEntriesByTime []*model.SecretExpirationEntry
+
+func (s expirationEntriesByTime) Len() int      { return len(s) }
+func (s expirationEntriesByTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s expirationEntriesByTime) Less(i, j int) bool {
+	return s[i].ExpirationTime.Before(s[j].ExpirationTime)
+}
+
+type listEntriesById []*model.SecretListEntry
+
+func (s listEntriesById) Len() int           { return len(s) }
+func (s listEntriesById) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s listEntriesById) Less(i, j int) bool { return s[i].Id < s[j].Id }
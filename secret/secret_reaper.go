@@ -0,0 +1,155 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Interval at which expired secrets are reaped, unless configured otherwise.
+const DefaultReaperInterval = time.Minute
+
+// startReaper starts a goroutine that periodically deletes expired secrets and wrapped
+// values, revokes credentials whose lease expired, and deletes secret events past their
+// retention period.
+func (secretManager *SecretManager) startReaper(interval time.Duration) {
+	secretManager.reaperStop = make(chan struct{})
+	secretManager.reaperDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now()
+				secretManager.revokeExpiredLeases(now)
+				secretManager.reapExpiredSecrets(now)
+				secretManager.pruneSecretEvents(now)
+				secretManager.pruneWrappedEntries(now)
+			case <-stop:
+				return
+			}
+		}
+	}(secretManager.reaperStop, secretManager.reaperDone)
+}
+
+func (secretManager *SecretManager) stopReaper() {
+	if secretManager.reaperStop == nil {
+		return
+	}
+
+	close(secretManager.reaperStop)
+	<-secretManager.reaperDone
+
+	secretManager.reaperStop = nil
+	secretManager.reaperDone = nil
+}
+
+// reapExpiredSecrets deletes the secrets (including their prior versions and keys)
+// whose expiration time, extended by the grace period, has passed at time now.
+// It returns the number of secrets deleted.
+func (secretManager *SecretManager) reapExpiredSecrets(now time.Time) int {
+	reapTime := now.Add(-secretManager.expirationGracePeriod)
+
+	expiredEntries := make([]*model.SecretEntry, 0)
+	err := secretManager.walkSecretEntries(vds.SecretsRootPath, true, func(secretEntry *model.SecretEntry) error {
+		if model.SecretExpired(secretEntry, reapTime) {
+			expiredEntries = append(expiredEntries, secretEntry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to look for expired secrets: %v\n", err)
+	}
+
+	reaped := 0
+	for _, secretEntry := range expiredEntries {
+		if err := secretManager.reapSecret(secretEntry.Id, reapTime); err != nil {
+			log.Printf("failed to reap expired secret %v: %v\n", secretEntry.Id, err)
+			continue
+		}
+
+		reaped++
+	}
+
+	return reaped
+}
+
+func (secretManager *SecretManager) reapSecret(secretId string, reapTime time.Time) error {
+	// re-read the secret, as it might have been renewed since it was found to be expired
+	secretEntry, err := secretManager.getSecretEntry(vds.SecretIdToPath(secretId))
+	if err != nil {
+		return err
+	}
+
+	if !model.SecretExpired(secretEntry, reapTime) {
+		return nil
+	}
+
+	secretType, err := SecretTypeRegistrar.Get(secretEntry.Type)
+	if err != nil {
+		return util.ErrInternal
+	}
+
+	if err := secretType.DeleteSecret(context.GetSystemRequestContext(), secretEntry); err != nil {
+		return err
+	}
+
+	secretManager.unscheduleRotation(secretId)
+
+	if err := secretManager.deleteSecretVersions(secretId, 0); err != nil {
+		return err
+	}
+
+	secretManager.auditManager.Log(fmt.Sprintf("deleted secret %v, which expired at %v", secretId, secretEntry.ExpirationTime))
+
+	return nil
+}
+
+// walkSecretEntries calls walkFn for each secret under the given namespace and,
+// if recursive is set, its sub-namespaces.
+func (secretManager *SecretManager) walkSecretEntries(namespacePath string, recursive bool, walkFn func(*model.SecretEntry) error) error {
+	childEntries, err := secretManager.dataStore.SearchChildEntries(namespacePath)
+	if err != nil {
+		return err
+	}
+
+	for _, childEntry := range childEntries {
+		if vds.IsNamespaceEntry(childEntry) {
+			if !recursive {
+				continue
+			}
+			if err := secretManager.walkSecretEntries(childEntry.Id, recursive, walkFn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !vds.IsSecretEntry(childEntry) {
+			continue
+		}
+
+		secretEntry, err := vds.DataStoreEntryToSecretEntry(childEntry)
+		if err != nil {
+			return err
+		}
+
+		if err := walkFn(secretEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,354 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+	"log"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Interval at which secrets due for rotation are looked for, unless configured otherwise.
+const DefaultRotationInterval = time.Minute
+
+// Number of recent rotation attempts kept in the history of each secret.
+const MaxRotationHistory = 10
+
+const (
+	// Delay before the first retry of a failed scheduled rotation; it doubles with
+	// each failed attempt, up to rotationRetryMaxDelay, and is jittered by up to 20%
+	rotationRetryBaseDelay = time.Minute
+	rotationRetryMaxDelay  = time.Hour
+	rotationRetryJitter    = 0.2
+	// A server rotating a secret holds its lock for at most this long, so that the
+	// rotation is retried by another server if it dies in the middle
+	rotationLockTTL = 10 * time.Minute
+)
+
+// startRotator starts a goroutine that periodically rotates the secrets whose rotation
+// policy calls for it.
+func (secretManager *SecretManager) startRotator(interval time.Duration) {
+	secretManager.rotatorStop = make(chan struct{})
+	secretManager.rotatorDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				secretManager.rotateDueSecrets(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}(secretManager.rotatorStop, secretManager.rotatorDone)
+}
+
+func (secretManager *SecretManager) stopRotator() {
+	if secretManager.rotatorStop == nil {
+		return
+	}
+
+	close(secretManager.rotatorStop)
+	<-secretManager.rotatorDone
+
+	secretManager.rotatorStop = nil
+	secretManager.rotatorDone = nil
+}
+
+// GetRotation retrieves the rotation schedule and history of a secret which has a
+// rotation policy.
+func (secretManager *SecretManager) GetRotation(ctx gocontext.Context, secretId string) (*model.RotationEntry, error) {
+	secretPath := vds.SecretIdToPath(secretId)
+
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpRead}, path.Dir(secretPath)); err != nil {
+		return nil, err
+	}
+
+	if _, err := secretManager.getUnexpiredSecretEntry(secretPath); err != nil {
+		return nil, err
+	}
+
+	return secretManager.getRotationEntry(secretId)
+}
+
+// rotateDueSecrets rotates the secrets whose next rotation time has passed at time now.
+// Each secret is rotated under a lock shared by the servers using the data store, so that
+// it is rotated by one of them only. It returns the number of secrets rotated.
+func (secretManager *SecretManager) rotateDueSecrets(now time.Time) int {
+	dataStoreEntries, err := secretManager.dataStore.SearchChildEntries(vds.RotationsRootPath)
+	if err != nil {
+		log.Printf("failed to look for secrets to rotate: %v\n", err)
+		return 0
+	}
+
+	rotated := 0
+	for _, dataStoreEntry := range dataStoreEntries {
+		if !vds.IsRotationEntry(dataStoreEntry) {
+			continue
+		}
+
+		rotationEntry, err := vds.DataStoreEntryToRotationEntry(dataStoreEntry)
+		if err != nil {
+			log.Printf("failed to read rotation entry %v: %v\n", dataStoreEntry.Id, err)
+			continue
+		}
+
+		if !rotationDue(rotationEntry, now) {
+			continue
+		}
+
+		ok, err := secretManager.rotateDueSecret(rotationEntry.SecretId, now)
+		if err != nil {
+			log.Printf("failed to rotate secret %v: %v\n", rotationEntry.SecretId, err)
+			continue
+		}
+
+		if ok {
+			rotated++
+		}
+	}
+
+	return rotated
+}
+
+// rotateDueSecret rotates a secret if it is still due for rotation at time now, and
+// schedules its next rotation (or a retry, if the rotation failed). It returns whether
+// the secret was rotated.
+func (secretManager *SecretManager) rotateDueSecret(secretId string, now time.Time) (bool, error) {
+	lockName := vds.SecretIdToRotationPath(secretId)
+	locked, err := vds.TryLock(secretManager.dataStore, lockName, secretManager.nodeId, rotationLockTTL)
+	if err != nil || !locked {
+		// another server is rotating the secret
+		return false, err
+	}
+	defer func() {
+		if err := vds.Unlock(secretManager.dataStore, lockName, secretManager.nodeId); err != nil {
+			log.Printf("failed to release rotation lock of secret %v: %v\n", secretId, err)
+		}
+	}()
+
+	// re-read the rotation entry, as the secret might have been rotated (by another
+	// server) since it was found to be due
+	rotationEntry, err := secretManager.getRotationEntry(secretId)
+	if err != nil {
+		return false, err
+	}
+	if !rotationDue(rotationEntry, now) {
+		return false, nil
+	}
+
+	secretEntry, err := secretManager.getSecretEntry(vds.SecretIdToPath(secretId))
+	if err == util.ErrNotFound || (err == nil && secretEntry.RotationPolicy == nil) {
+		secretManager.unscheduleRotation(secretId)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// expired secrets are left to the reaper
+	if model.SecretExpired(secretEntry, now) {
+		return false, nil
+	}
+
+	record := model.RotationRecord{Time: now, Scheduled: true}
+
+	se, err := secretManager.rotateSecret(context.GetSystemRequestContext(), secretId)
+	if err != nil {
+		rotationEntry.FailedAttempts++
+		rotationEntry.NextRotationTime = now.Add(rotationRetryDelay(rotationEntry.FailedAttempts))
+		record.Error = err.Error()
+		appendRotationRecord(rotationEntry, record)
+
+		msg := fmt.Sprintf("failed to rotate secret %v (attempt %v, retrying at %v): %v",
+			secretId, rotationEntry.FailedAttempts, rotationEntry.NextRotationTime, err)
+		if err := secretManager.recordSecretEvent(model.SecretEventRotationFailed, secretId, secretEntry.Version, msg); err != nil {
+			log.Printf("failed to record rotation failure of secret %v: %v\n", secretId, err)
+		}
+
+		if err := secretManager.putRotationEntry(rotationEntry, true); err != nil {
+			log.Printf("failed to schedule rotation retry of secret %v: %v\n", secretId, err)
+		}
+
+		return false, err
+	}
+
+	rotationEntry.LastRotationTime = now
+	rotationEntry.FailedAttempts = 0
+	rotationEntry.NextRotationTime = nextRotationTime(se.RotationPolicy, now)
+	record.Version = se.Version
+	appendRotationRecord(rotationEntry, record)
+
+	// the secret is already rotated; if its next rotation can't be scheduled, it is
+	// rotated again on the next run
+	if err := secretManager.putRotationEntry(rotationEntry, true); err != nil {
+		log.Printf("failed to schedule next rotation of secret %v: %v\n", secretId, err)
+	}
+
+	msg := fmt.Sprintf("rotated secret %v (version %v), next rotation at %v", secretId, se.Version, rotationEntry.NextRotationTime)
+	if err := secretManager.recordSecretEvent(model.SecretEventRotated, secretId, se.Version, msg); err != nil {
+		log.Printf("failed to record rotation of secret %v: %v\n", secretId, err)
+	}
+
+	secretManager.auditManager.Log(msg)
+
+	return true, nil
+}
+
+// recordOnDemandRotation adds a rotation to the history of a secret which has a rotation
+// policy; a max age policy's next rotation is postponed accordingly.
+func (secretManager *SecretManager) recordOnDemandRotation(se *model.SecretEntry, now time.Time) {
+	if se.RotationPolicy == nil {
+		return
+	}
+
+	rotationEntry, err := secretManager.getRotationEntry(se.Id)
+	if err != nil {
+		log.Printf("failed to record rotation of secret %v: %v\n", se.Id, err)
+		return
+	}
+
+	rotationEntry.LastRotationTime = now
+	if se.RotationPolicy.MaxAge != "" {
+		rotationEntry.FailedAttempts = 0
+		rotationEntry.NextRotationTime = nextRotationTime(se.RotationPolicy, now)
+	}
+	appendRotationRecord(rotationEntry, model.RotationRecord{Time: now, Version: se.Version})
+
+	if err := secretManager.putRotationEntry(rotationEntry, true); err != nil {
+		log.Printf("failed to record rotation of secret %v: %v\n", se.Id, err)
+	}
+}
+
+// scheduleRotation creates, updates or deletes the rotation entry of a secret whose
+// rotation policy was set to policy, replacing previousPolicy. The next rotation is
+// rescheduled if the policy changed, or if it is a max age policy (as the secret was
+// just replaced).
+func (secretManager *SecretManager) scheduleRotation(secretId string, policy *model.RotationPolicy, previousPolicy *model.RotationPolicy, now time.Time) {
+	if policy == nil {
+		if previousPolicy != nil {
+			secretManager.unscheduleRotation(secretId)
+		}
+		return
+	}
+
+	rotationEntry, err := secretManager.getRotationEntry(secretId)
+	exists := err == nil
+	if err == util.ErrNotFound {
+		rotationEntry = &model.RotationEntry{SecretId: secretId}
+	} else if err != nil {
+		log.Printf("failed to schedule rotation of secret %v: %v\n", secretId, err)
+		return
+	}
+
+	if !exists || previousPolicy == nil || *policy != *previousPolicy || policy.MaxAge != "" {
+		rotationEntry.NextRotationTime = nextRotationTime(policy, now)
+		rotationEntry.FailedAttempts = 0
+	}
+
+	if err := secretManager.putRotationEntry(rotationEntry, exists); err != nil {
+		log.Printf("failed to schedule rotation of secret %v: %v\n", secretId, err)
+	}
+}
+
+func (secretManager *SecretManager) unscheduleRotation(secretId string) {
+	err := secretManager.dataStore.DeleteEntry(vds.SecretIdToRotationPath(secretId))
+	if err != nil && err != util.ErrNotFound {
+		log.Printf("failed to delete rotation entry of secret %v: %v\n", secretId, err)
+	}
+}
+
+func (secretManager *SecretManager) getRotationEntry(secretId string) (*model.RotationEntry, error) {
+	dataStoreEntry, err := secretManager.dataStore.ReadEntry(vds.SecretIdToRotationPath(secretId))
+	if err != nil {
+		return nil, err
+	}
+
+	return vds.DataStoreEntryToRotationEntry(dataStoreEntry)
+}
+
+func (secretManager *SecretManager) putRotationEntry(rotationEntry *model.RotationEntry, exists bool) error {
+	dataStoreEntry, err := vds.RotationEntryToDataStoreEntry(rotationEntry)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return secretManager.dataStore.UpdateEntry(dataStoreEntry)
+	}
+
+	return secretManager.dataStore.CreateEntry(dataStoreEntry)
+}
+
+// validateSecretRotationPolicy checks that a secret of the given type can have the given
+// rotation policy, if any.
+func validateSecretRotationPolicy(secretType SecretType, policy *model.RotationPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if _, ok := secretType.(RotatableSecretType); !ok {
+		return util.ErrInputValidation
+	}
+
+	return model.ValidateRotationPolicy(policy)
+}
+
+// nextRotationTime returns the time of the next rotation of a secret rotated at time now,
+// or the zero time if the policy never calls for one. The policy is assumed to be valid.
+func nextRotationTime(policy *model.RotationPolicy, now time.Time) time.Time {
+	switch {
+	case policy.Interval != "":
+		interval, _ := time.ParseDuration(policy.Interval)
+		return now.Add(interval)
+	case policy.MaxAge != "":
+		maxAge, _ := time.ParseDuration(policy.MaxAge)
+		return now.Add(maxAge)
+	case policy.Cron != "":
+		if schedule, err := util.ParseCronSchedule(policy.Cron); err == nil {
+			return schedule.Next(now)
+		}
+	}
+
+	return time.Time{}
+}
+
+func rotationDue(rotationEntry *model.RotationEntry, now time.Time) bool {
+	return !rotationEntry.NextRotationTime.IsZero() && !now.Before(rotationEntry.NextRotationTime)
+}
+
+// rotationRetryDelay returns the delay before retrying a scheduled rotation which failed
+// the given number of times in a row. Delays are jittered, so that the retries of secrets
+// which failed together (e.g. because of an outage) are spread out.
+func rotationRetryDelay(failedAttempts int) time.Duration {
+	delay := rotationRetryMaxDelay
+	if failedAttempts < 8 {
+		delay = rotationRetryBaseDelay << uint(failedAttempts-1)
+		if delay > rotationRetryMaxDelay {
+			delay = rotationRetryMaxDelay
+		}
+	}
+
+	jitter := (rand.Float64()*2 - 1) * rotationRetryJitter
+	return delay + time.Duration(float64(delay)*jitter)
+}
+
+func appendRotationRecord(rotationEntry *model.RotationEntry, record model.RotationRecord) {
+	rotationEntry.History = append(rotationEntry.History, record)
+	if len(rotationEntry.History) > MaxRotationHistory {
+		rotationEntry.History = rotationEntry.History[len(rotationEntry.History)-MaxRotationHistory:]
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestScheduledRotation(t *testing.T) {
+	id, err := createRotatedPassword("rotation-id0", &model.RotationPolicy{Interval: "1h"})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	rotationEntry, err := sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	if next := rotationEntry.NextRotationTime; next.Before(time.Now().Add(59*time.Minute)) || next.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("Unexpected next rotation time: %v", next)
+	}
+
+	// not due for rotation yet
+	if rotated, err := sm.rotateDueSecret(id, time.Now()); err != nil || rotated {
+		t.Fatalf("Secret rotated before its rotation interval: %v", err)
+	}
+
+	start := time.Now()
+	rotationTime := time.Now().Add(time.Hour)
+	if rotated, err := sm.rotateDueSecret(id, rotationTime); err != nil || !rotated {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+
+	// a secret is rotated once per due time
+	if rotated, err := sm.rotateDueSecret(id, rotationTime); err != nil || rotated {
+		t.Fatalf("Secret rotated twice: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+	if se.Version != model.FirstSecretVersion+1 || se.RotationPolicy == nil || se.RotationPolicy.Interval != "1h" {
+		t.Fatalf("Unexpected secret after rotation: version %v, policy %v", se.Version, se.RotationPolicy)
+	}
+
+	rotationEntry, err = sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	if !rotationEntry.NextRotationTime.Equal(rotationTime.Add(time.Hour)) || !rotationEntry.LastRotationTime.Equal(rotationTime) {
+		t.Fatalf("Unexpected rotation times: %v", rotationEntry)
+	}
+	if len(rotationEntry.History) != 1 || !rotationEntry.History[0].Scheduled || rotationEntry.History[0].Version != se.Version {
+		t.Fatalf("Unexpected rotation history: %v", rotationEntry.History)
+	}
+
+	if !secretEventRecorded(t, start, model.SecretEventRotated, id) {
+		t.Fatalf("Rotation event not recorded for secret %v", id)
+	}
+}
+
+func TestScheduledRotationLocked(t *testing.T) {
+	id, err := createRotatedPassword("rotation-id1", &model.RotationPolicy{Cron: "0 3 * * *"})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// another server is rotating the secret
+	lockName := vds.SecretIdToRotationPath(id)
+	if locked, err := vds.TryLock(sm.dataStore, lockName, "another-node", time.Minute); err != nil || !locked {
+		t.Fatalf("Failed to lock secret %v: %v", id, err)
+	}
+
+	rotationTime := time.Now().Add(24 * time.Hour)
+	if rotated, err := sm.rotateDueSecret(id, rotationTime); err != nil || rotated {
+		t.Fatalf("Secret locked by another server rotated: %v", err)
+	}
+
+	if err := vds.Unlock(sm.dataStore, lockName, "another-node"); err != nil {
+		t.Fatalf("Failed to unlock secret %v: %v", id, err)
+	}
+
+	if rotated, err := sm.rotateDueSecret(id, rotationTime); err != nil || !rotated {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+
+	rotationEntry, err := sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	if next := rotationEntry.NextRotationTime; next.Hour() != 3 || next.Minute() != 0 || !next.After(rotationTime) || next.After(rotationTime.Add(24*time.Hour)) {
+		t.Fatalf("Unexpected next rotation time: %v", next)
+	}
+}
+
+func TestScheduledRotationRetry(t *testing.T) {
+	mock := newMockIAMServer()
+	defer mock.Close()
+
+	id, err := createCloudAccessKey(mock, "rotation-id2", "app3", &model.RotationPolicy{Interval: "720h"})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// the user has as many access keys as allowed, so no new one can be issued
+	mock.lock.Lock()
+	mock.accessKeys["app3"]["AKIAMOCKEXTRA"] = "extra"
+	mock.lock.Unlock()
+
+	start := time.Now()
+	rotationTime := time.Now().Add(720 * time.Hour)
+	if rotated, err := sm.rotateDueSecret(id, rotationTime); err == nil || rotated {
+		t.Fatalf("Rotation should have failed")
+	}
+
+	rotationEntry, err := sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	retryDelay := rotationEntry.NextRotationTime.Sub(rotationTime)
+	if rotationEntry.FailedAttempts != 1 || retryDelay < 48*time.Second || retryDelay > 72*time.Second {
+		t.Fatalf("Unexpected rotation retry: %v attempts, retrying after %v", rotationEntry.FailedAttempts, retryDelay)
+	}
+	if len(rotationEntry.History) != 1 || rotationEntry.History[0].Error == "" {
+		t.Fatalf("Unexpected rotation history: %v", rotationEntry.History)
+	}
+
+	if !secretEventRecorded(t, start, model.SecretEventRotationFailed, id) {
+		t.Fatalf("Rotation failure event not recorded for secret %v", id)
+	}
+
+	// not retried before the retry delay elapses
+	if rotated, err := sm.rotateDueSecret(id, rotationTime); err != nil || rotated {
+		t.Fatalf("Rotation retried too early: %v", err)
+	}
+
+	mock.lock.Lock()
+	delete(mock.accessKeys["app3"], "AKIAMOCKEXTRA")
+	mock.lock.Unlock()
+
+	if rotated, err := sm.rotateDueSecret(id, rotationEntry.NextRotationTime); err != nil || !rotated {
+		t.Fatalf("Failed to retry rotation: %v", err)
+	}
+
+	rotationEntry, err = sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	if rotationEntry.FailedAttempts != 0 || len(rotationEntry.History) != 2 || rotationEntry.History[1].Error != "" {
+		t.Fatalf("Unexpected rotation after retry: %v", rotationEntry)
+	}
+}
+
+func TestRotationPolicyUpdate(t *testing.T) {
+	id, err := createRotatedPassword("rotation-id3", &model.RotationPolicy{MaxAge: "2h"})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	rotationEntry, err := sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	scheduledTime := rotationEntry.NextRotationTime
+
+	// rotating a secret on demand postpones its max age rotation
+	time.Sleep(10 * time.Millisecond)
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to rotate secret %v: %v", id, err)
+	}
+
+	rotationEntry, err = sm.GetRotation(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+	if !rotationEntry.NextRotationTime.After(scheduledTime) || len(rotationEntry.History) != 1 || rotationEntry.History[0].Scheduled {
+		t.Fatalf("Unexpected rotation after rotating on demand: %v", rotationEntry)
+	}
+
+	// the policy is kept by updates which don't replace it
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), &model.SecretEntry{Id: id}); err != nil {
+		t.Fatalf("Failed to update secret %v: %v", id, err)
+	}
+	if _, err := sm.GetRotation(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to get rotation of secret %v: %v", id, err)
+	}
+
+	// an empty policy removes the policy
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), &model.SecretEntry{Id: id, RotationPolicy: &model.RotationPolicy{}}); err != nil {
+		t.Fatalf("Failed to update secret %v: %v", id, err)
+	}
+	if _, err := sm.GetRotation(context.GetTestRequestContext(), id); err != util.ErrNotFound {
+		t.Fatalf("Rotation of secret %v should have been removed: %v", id, err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+	if se.RotationPolicy != nil {
+		t.Fatalf("Unexpected rotation policy: %v", se.RotationPolicy)
+	}
+}
+
+func TestRotationPolicyInvalid(t *testing.T) {
+	invalidPolicies := []*model.RotationPolicy{
+		{Interval: "1h", MaxAge: "2h"},
+		{Interval: "monthly"},
+		{MaxAge: "-1h"},
+		{Cron: "0 3 * *"},
+		{Cron: "0 0 31 2 *"},
+	}
+
+	for _, policy := range invalidPolicies {
+		if _, err := createRotatedPassword("rotation-id4", policy); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when creating a secret with rotation policy %v: %v", policy, err)
+		}
+	}
+
+	// data secrets can't be rotated
+	se := &model.SecretEntry{
+		Id:             "rotation-id5",
+		Type:           DataSecretTypeName,
+		MetaData:       "{}",
+		SecretData:     []byte("data"),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+		RotationPolicy: &model.RotationPolicy{Interval: "1h"},
+	}
+	if _, err := sm.CreateSecret(context.GetTestRequestContext(), se); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when creating a data secret with a rotation policy: %v", err)
+	}
+}
+
+func TestRotationRetryDelay(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{1: time.Minute, 3: 4 * time.Minute, 7: time.Hour, 100: time.Hour} {
+		for i := 0; i < 10; i++ {
+			delay := rotationRetryDelay(attempts)
+			if delay < expected*8/10 || delay > expected*12/10 {
+				t.Fatalf("Unexpected retry delay after %v attempts: %v", attempts, delay)
+			}
+		}
+	}
+}
+
+func createRotatedPassword(id string, rotationPolicy *model.RotationPolicy) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           GeneratedPasswordSecretTypeName,
+		MetaData:       "{\"length\": 16}",
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(48 * time.Hour),
+		RotationPolicy: rotationPolicy,
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
+
+func secretEventRecorded(t *testing.T, since time.Time, eventType string, secretId string) bool {
+	eventEntries, err := sm.ListSecretEvents(context.GetTestRequestContext(), since)
+	if err != nil {
+		t.Fatalf("Failed to list secret events: %v", err)
+	}
+
+	for _, eventEntry := range eventEntries {
+		if eventEntry.Type == eventType && eventEntry.SecretId == secretId {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,207 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestSearchSecrets(t *testing.T) {
+	namespaceTags := map[string]map[string]string{
+		"search-ns":         nil,
+		"search-ns/team-a":  {"team": "a"},
+		"search-ns/private": {"team": "b"},
+	}
+	secretTags := map[string]map[string]string{
+		"search-ns/id0":         {"env": "prod", "app": "web"},
+		"search-ns/id1":         {"env": "staging", "app": "web"},
+		"search-ns/team-a/id2":  {"env": "prod"},
+		"search-ns/team-a/id3":  {"env": "dev", "team": "c"},
+		"search-ns/private/id4": {"env": "prod"},
+	}
+	setupSearchSecrets(t, []string{"search-ns", "search-ns/team-a", "search-ns/private"}, namespaceTags, secretTags)
+	defer cleanupSearchSecrets(t, []string{"search-ns", "search-ns/team-a", "search-ns/private"}, secretTags)
+
+	tests := []struct {
+		selector string
+		ids      []string
+	}{
+		{"env=prod", []string{"search-ns/id0", "search-ns/private/id4", "search-ns/team-a/id2"}},
+		{"env=prod,app=web", []string{"search-ns/id0"}},
+		{"env!=prod", []string{"search-ns/id1", "search-ns/team-a/id3"}},
+		{"app!=web", []string{"search-ns/private/id4", "search-ns/team-a/id2", "search-ns/team-a/id3"}},
+		{"env in (staging, dev)", []string{"search-ns/id1", "search-ns/team-a/id3"}},
+		// secrets inherit the tags of their namespaces, unless they override them
+		{"team=a", []string{"search-ns/team-a/id2"}},
+		{"team in (b,c),env=prod", []string{"search-ns/private/id4"}},
+		{"owner=nobody", []string{}},
+	}
+
+	for _, test := range tests {
+		listEntries, err := sm.SearchSecrets(context.GetTestRequestContext(), "search-ns", test.selector)
+		if err != nil {
+			t.Fatalf("Failed to search secrets using %q: %v", test.selector, err)
+		}
+		if !listEntriesEqual(listEntries, test.ids) {
+			t.Fatalf("Unexpected secrets found using %q: %v", test.selector, listEntries)
+		}
+	}
+
+	// secrets are listed with the tags they inherit
+	listEntries, err := sm.SearchSecrets(context.GetTestRequestContext(), "search-ns", "env=dev")
+	if err != nil {
+		t.Fatalf("Failed to search secrets: %v", err)
+	}
+	if len(listEntries) != 1 || !reflect.DeepEqual(listEntries[0].Tags, map[string]string{"env": "dev", "team": "c"}) {
+		t.Fatalf("Unexpected secrets found using %q: %v", "env=dev", listEntries)
+	}
+	if listEntries, err = sm.SearchSecrets(context.GetTestRequestContext(), "search-ns", "team=a"); err != nil {
+		t.Fatalf("Failed to search secrets: %v", err)
+	}
+	if len(listEntries) != 1 || !reflect.DeepEqual(listEntries[0].Tags, map[string]string{"env": "prod", "team": "a"}) {
+		t.Fatalf("Unexpected secrets found using %q: %v", "team=a", listEntries)
+	}
+
+	// secrets in namespaces the caller is not allowed to read are filtered out
+	restrictedManager := *sm
+	restrictedManager.authzManager = &denyNamespaceAuthzManager{namespacePath: vds.SecretIdToPath("search-ns/private")}
+
+	listEntries, err = restrictedManager.SearchSecrets(context.GetTestRequestContext(), "search-ns", "env=prod")
+	if err != nil {
+		t.Fatalf("Failed to search secrets: %v", err)
+	}
+	if !listEntriesEqual(listEntries, []string{"search-ns/id0", "search-ns/team-a/id2"}) {
+		t.Fatalf("Unexpected secrets found with restricted access: %v", listEntries)
+	}
+
+	for _, selector := range []string{"", "env", "env=prod,", "env==prod", "env in prod", "bad key=x", "env=a b"} {
+		if _, err := sm.SearchSecrets(context.GetTestRequestContext(), "search-ns", selector); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when searching secrets using %q: %v", selector, err)
+		}
+	}
+}
+
+func TestSecretTagsUpdate(t *testing.T) {
+	id, err := createTaggedDataSecret("tags-id0", map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// tags are kept by updates which don't replace them, and aren't rolled back
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), &model.SecretEntry{Id: id, SecretData: []byte("v2")}); err != nil {
+		t.Fatalf("Failed to update secret %v: %v", id, err)
+	}
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), &model.SecretEntry{Id: id, SecretData: []byte("v3"), Tags: map[string]string{"env": "dev"}}); err != nil {
+		t.Fatalf("Failed to update secret %v: %v", id, err)
+	}
+	if _, err := sm.RollbackSecret(context.GetTestRequestContext(), id, model.FirstSecretVersion); err != nil {
+		t.Fatalf("Failed to rollback secret %v: %v", id, err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+	if len(se.Tags) != 1 || se.Tags["env"] != "dev" {
+		t.Fatalf("Unexpected tags: %v", se.Tags)
+	}
+
+	// empty tags remove the tags
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), &model.SecretEntry{Id: id, SecretData: []byte("v5"), Tags: map[string]string{}}); err != nil {
+		t.Fatalf("Failed to update secret %v: %v", id, err)
+	}
+	if se, err = sm.GetSecret(context.GetTestRequestContext(), id); err != nil || len(se.Tags) != 0 {
+		t.Fatalf("Unexpected tags: %v %v", se, err)
+	}
+
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), &model.SecretEntry{Id: id, SecretData: []byte("v6"), Tags: map[string]string{"env,": "x"}}); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when updating a secret with invalid tags: %v", err)
+	}
+
+	if _, err := createTaggedDataSecret("tags-id1", map[string]string{"env": "a b"}); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when creating a secret with invalid tags: %v", err)
+	}
+}
+
+func TestAPISearchSecrets(t *testing.T) {
+	secretTags := map[string]map[string]string{
+		"api-search-ns/id0": {"env": "prod"},
+		"api-search-ns/id1": {"env": "dev"},
+	}
+	setupSearchSecrets(t, []string{"api-search-ns"}, nil, secretTags)
+	defer cleanupSearchSecrets(t, []string{"api-search-ns"}, secretTags)
+
+	query := url.Values{"prefix": {"api-search-ns"}, "selector": {"env in (prod,staging)"}}
+	resp, err := http.Get(fmt.Sprintf("%v/secret-search?%v", ts.URL, query.Encode()))
+	if err != nil {
+		t.Fatalf("Failed to search secrets: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var listEntries []*model.SecretListEntry
+	if err = json.NewDecoder(resp.Body).Decode(&listEntries); err != nil {
+		t.Fatalf("Failed to parse search secrets response: %v", err)
+	}
+
+	if !listEntriesEqual(listEntries, []string{"api-search-ns/id0"}) || listEntries[0].Tags["env"] != "prod" {
+		t.Fatalf("Unexpected secrets found: %v", listEntries)
+	}
+}
+
+func setupSearchSecrets(t *testing.T, namespaces []string, namespaceTags, secretTags map[string]map[string]string) {
+	for _, namespace := range namespaces {
+		namespaceEntry := &model.NamespaceEntry{Path: vds.SecretIdToPath(namespace), Tags: namespaceTags[namespace]}
+		dataStoreEntry, err := vds.NamespaceEntryToDataStoreEntry(namespaceEntry)
+		if err != nil {
+			t.Fatalf("Failed to convert namespace %v: %v", namespace, err)
+		}
+		if err := sm.dataStore.CreateEntry(dataStoreEntry); err != nil {
+			t.Fatalf("Failed to create namespace %v: %v", namespace, err)
+		}
+	}
+
+	for id, tags := range secretTags {
+		if _, err := createTaggedDataSecret(id, tags); err != nil {
+			t.Fatalf("Failed to create secret %v: %v", id, err)
+		}
+	}
+}
+
+func cleanupSearchSecrets(t *testing.T, namespaces []string, secretTags map[string]map[string]string) {
+	ids := make([]string, 0, len(secretTags))
+	for id := range secretTags {
+		ids = append(ids, id)
+	}
+
+	cleanupListSecrets(t, namespaces, ids)
+}
+
+func createTaggedDataSecret(id string, tags map[string]string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           DataSecretTypeName,
+		MetaData:       "{}",
+		SecretData:     []byte("tagged"),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+		Tags:           tags,
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
@@ -14,5 +14,32 @@ type SecretType interface {
 	Init(*context.ModuleInitContext) error
 	CreateSecret(gocontext.Context, *model.SecretEntry) (string, error)
 	GetSecret(gocontext.Context, *model.SecretEntry) (*model.SecretEntry, error)
+	UpdateSecret(gocontext.Context, *model.SecretEntry) (string, error)
 	DeleteSecret(gocontext.Context, *model.SecretEntry) error
 }
+
+// RotatableSecretType is implemented by secret types whose data is generated (or issued)
+// by the server, so that secrets of these types can be rotated, either on demand or
+// according to their rotation policy.
+type RotatableSecretType interface {
+	// RotateSecret replaces the data of a secret with newly generated data, using the
+	// secret's current metadata. The given entry is the secret's new version.
+	RotateSecret(gocontext.Context, *model.SecretEntry) (string, error)
+}
+
+// ValidatingSecretType is implemented by secret types which check secrets beyond the
+// schema of their metadata (e.g. their data) before they are created or updated.
+type ValidatingSecretType interface {
+	// Validate returns a *util.ValidationError detailing the invalid fields of a secret.
+	Validate(*model.SecretEntry) error
+}
+
+// DescribedSecretType is implemented by secret types which describe themselves to clients
+// discovering the supported secret types.
+type DescribedSecretType interface {
+	// Description returns a short description of the type's secrets.
+	Description() string
+	// SecretDataDescription describes the data clients provide when creating a secret of
+	// the type, or returns "" if the data is generated by the server.
+	SecretDataDescription() string
+}
@@ -3,7 +3,10 @@
 package secret
 
 import (
+	"sort"
+
 	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
 	"github.com/vmware/virtual-security-module/util"
 )
 
@@ -12,11 +15,14 @@ var SecretTypeRegistrar *secretTypeRegistrar = newRegistrar()
 
 type secretTypeRegistrar struct {
 	secretTypes map[string]SecretType
+	// Schemas of the metadata of secret types, by type name
+	schemas map[string]*model.Schema
 }
 
 func newRegistrar() *secretTypeRegistrar {
 	return &secretTypeRegistrar{
 		secretTypes: make(map[string]SecretType),
+		schemas:     make(map[string]*model.Schema),
 	}
 }
 
@@ -38,6 +44,7 @@ func (stRegistrar *secretTypeRegistrar) Unregister(typeName string) error {
 	}
 
 	delete(stRegistrar.secretTypes, typeName)
+	delete(stRegistrar.schemas, typeName)
 
 	return nil
 }
@@ -58,6 +65,79 @@ func (stRegistrar *secretTypeRegistrar) Get(typeName string) (SecretType, error)
 	return secretType, nil
 }
 
+// RegisterSchema registers the schema of the metadata of a registered secret type.
+func (stRegistrar *secretTypeRegistrar) RegisterSchema(typeName string, schema *model.Schema) error {
+	if !stRegistrar.Registered(typeName) {
+		return util.ErrNotFound
+	}
+
+	if _, ok := stRegistrar.schemas[typeName]; ok {
+		return util.ErrAlreadyExists
+	}
+
+	stRegistrar.schemas[typeName] = schema
+
+	return nil
+}
+
+// Schema returns the schema of the metadata of a secret type, or nil if it has none.
+func (stRegistrar *secretTypeRegistrar) Schema(typeName string) *model.Schema {
+	return stRegistrar.schemas[typeName]
+}
+
+// TypeNames returns the names of the registered secret types, in order.
+func (stRegistrar *secretTypeRegistrar) TypeNames() []string {
+	typeNames := make([]string, 0, len(stRegistrar.secretTypes))
+	for typeName := range stRegistrar.secretTypes {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+
+	return typeNames
+}
+
+// Describe describes a registered secret type to clients, by its description, if it's a
+// DescribedSecretType, and the schema of its metadata.
+func (stRegistrar *secretTypeRegistrar) Describe(typeName string) (*model.SecretTypeEntry, error) {
+	secretType, err := stRegistrar.Get(typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	typeEntry := &model.SecretTypeEntry{
+		Type:           typeName,
+		MetaDataSchema: stRegistrar.Schema(typeName),
+	}
+	if describedSecretType, ok := secretType.(DescribedSecretType); ok {
+		typeEntry.Description = describedSecretType.Description()
+		typeEntry.SecretDataDescription = describedSecretType.SecretDataDescription()
+	}
+
+	return typeEntry, nil
+}
+
+// Validate checks a secret's metadata against the schema of its type, if any, and then
+// lets the type validate the secret, if it's a ValidatingSecretType. Invalid secrets are
+// reported by a *util.ValidationError.
+func (stRegistrar *secretTypeRegistrar) Validate(secretEntry *model.SecretEntry) error {
+	secretType, err := stRegistrar.Get(secretEntry.Type)
+	if err != nil {
+		return util.NewValidationError([]util.FieldError{{Field: "type", Message: "is not a known secret type"}})
+	}
+
+	if schema := stRegistrar.Schema(secretEntry.Type); schema != nil {
+		if err := schema.ValidateJSON("metaData", secretEntry.MetaData); err != nil {
+			return err
+		}
+	}
+
+	if validatingSecretType, ok := secretType.(ValidatingSecretType); ok {
+		return validatingSecretType.Validate(secretEntry)
+	}
+
+	return nil
+}
+
 func (stRegistrar *secretTypeRegistrar) InitSecretTypes(moduleInitContext *context.ModuleInitContext) error {
 	for _, st := range stRegistrar.secretTypes {
 		if err := st.Init(moduleInitContext); err != nil {
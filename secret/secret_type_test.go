@@ -0,0 +1,172 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestSecretMetaDataValidation(t *testing.T) {
+	testCases := []struct {
+		se     *model.SecretEntry
+		fields []util.FieldError
+	}{
+		{
+			se: &model.SecretEntry{Id: "schema-id0", Type: RSAPrivateKeySecretTypeName, MetaData: "{\"keyLength\": \"2048\", \"curve\": \"P-256\"}"},
+			fields: []util.FieldError{
+				{Field: "metaData.curve", Message: "is not a known field"},
+				{Field: "metaData.keyLength", Message: "should be a number"},
+			},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id1", Type: RSAPrivateKeySecretTypeName, MetaData: "{\"keyLength\": 4096}"},
+			fields: []util.FieldError{{Field: "metaData.keyLength", Message: "should be at most 2048"}},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id2", Type: RSAPrivateKeySecretTypeName},
+			fields: []util.FieldError{{Field: "metaData.keyLength", Message: "is required"}},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id3", Type: RSAPrivateKeySecretTypeName, MetaData: "{keyLength"},
+			fields: []util.FieldError{{Field: "metaData", Message: "should be a JSON document"}},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id4", Type: "unknown-type"},
+			fields: []util.FieldError{{Field: "type", Message: "is not a known secret type"}},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id5", Type: DataSecretTypeName},
+			fields: []util.FieldError{{Field: "secretData", Message: "is required"}},
+		},
+		{
+			se: &model.SecretEntry{Id: "schema-id6", Type: X509CertificateSecretTypeName, MetaData: "{\"privateKeyId\": \"key-id\", \"rekey\": true}"},
+			fields: []util.FieldError{
+				{Field: "metaData.commonName", Message: "is required, unless metaData.csr is set"},
+				{Field: "metaData.organization", Message: "is required, unless metaData.csr is set"},
+				{Field: "metaData.rekey", Message: "requires metaData.autoRenew"},
+			},
+		},
+		{
+			se: &model.SecretEntry{Id: "schema-id7", Type: X509CertificateSecretTypeName, MetaData: "{\"autoRenew\": true, \"renewAfter\": 1, \"rekey\": true}"},
+			fields: []util.FieldError{
+				{Field: "metaData.privateKeyId", Message: "is required, unless metaData.csr is set"},
+				{Field: "metaData.renewAfter", Message: "should be at least 0 and less than 1"},
+				{Field: "metaData.rekey", Message: "requires metaData.privateKeyId"},
+			},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id8", Type: X509CertificateSecretTypeName, MetaData: "{\"privateKeyId\": \"key-id\", \"csr\": \"csr\"}"},
+			fields: []util.FieldError{{Field: "metaData.csr", Message: "can't be set along with metaData.privateKeyId"}},
+		},
+		{
+			se:     &model.SecretEntry{Id: "schema-id9", Type: X509CertificateAuthoritySecretTypeName, MetaData: "{\"commonName\": \"ca\"}"},
+			fields: []util.FieldError{{Field: "metaData.organization", Message: "is required"}},
+		},
+		{
+			se: &model.SecretEntry{Id: "schema-id10", Type: X509CertificateAuthoritySecretTypeName, MetaData: "{\"commonName\": \"ca\", \"organization\": \"org\", \"keyType\": \"ecdsa\", \"keyLength\": 2048}", SecretData: []byte("key")},
+			fields: []util.FieldError{
+				{Field: "secretData", Message: "is generated by the server"},
+				{Field: "metaData.keyLength", Message: "should be 256 or 384 for ecdsa keys"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := sm.CreateSecret(context.GetTestRequestContext(), testCase.se)
+		if !errors.Is(err, util.ErrInputValidation) {
+			t.Fatalf("Unexpected error creating secret %v: %v", testCase.se.Id, err)
+		}
+
+		validationErr, ok := err.(*util.ValidationError)
+		if !ok {
+			t.Fatalf("Error creating secret %v isn't a validation error: %v", testCase.se.Id, err)
+		}
+		if !reflect.DeepEqual(validationErr.Fields, testCase.fields) {
+			t.Fatalf("Unexpected invalid fields for secret %v: %v", testCase.se.Id, validationErr.Fields)
+		}
+	}
+}
+
+func TestAPICreateSecretValidationError(t *testing.T) {
+	se := &model.SecretEntry{
+		Id:       "api-schema-id0",
+		Type:     ECDSAPrivateKeySecretTypeName,
+		MetaData: "{\"curve\": \"P-521\"}",
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(se); err != nil {
+		t.Fatalf("failed to marshal se %v: %v", se, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/secrets", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp.Status)
+	}
+
+	var errorResponse util.ValidationErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("Failed to parse validation error response: %v", err)
+	}
+
+	if len(errorResponse.Fields) != 1 || errorResponse.Fields[0].Field != "metaData.curve" {
+		t.Fatalf("Unexpected invalid fields: %v", errorResponse.Fields)
+	}
+}
+
+func TestAPIListSecretTypes(t *testing.T) {
+	resp, err := http.Get(fmt.Sprintf("%v/secret-types", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to list secret types: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var typeEntries []*model.SecretTypeEntry
+	if err := json.NewDecoder(resp.Body).Decode(&typeEntries); err != nil {
+		t.Fatalf("Failed to parse secret types response: %v", err)
+	}
+
+	typeEntriesByName := make(map[string]*model.SecretTypeEntry)
+	for _, typeEntry := range typeEntries {
+		if typeEntry.Description == "" {
+			t.Fatalf("Secret type %v has no description", typeEntry.Type)
+		}
+		typeEntriesByName[typeEntry.Type] = typeEntry
+	}
+
+	if len(typeEntriesByName) != len(SecretTypeRegistrar.TypeNames()) {
+		t.Fatalf("Unexpected number of secret types: %v", len(typeEntriesByName))
+	}
+
+	rsaTypeEntry := typeEntriesByName[RSAPrivateKeySecretTypeName]
+	if rsaTypeEntry == nil || rsaTypeEntry.SecretDataDescription != "" {
+		t.Fatalf("Unexpected secret type %v: %v", RSAPrivateKeySecretTypeName, rsaTypeEntry)
+	}
+	schema := rsaTypeEntry.MetaDataSchema
+	if schema == nil || schema.Properties["keyLength"] == nil || !reflect.DeepEqual(schema.Required, []string{"keyLength"}) {
+		t.Fatalf("Unexpected metadata schema of secret type %v: %v", RSAPrivateKeySecretTypeName, schema)
+	}
+
+	dataTypeEntry := typeEntriesByName[DataSecretTypeName]
+	if dataTypeEntry == nil || dataTypeEntry.SecretDataDescription == "" {
+		t.Fatalf("Unexpected secret type %v: %v", DataSecretTypeName, dataTypeEntry)
+	}
+}
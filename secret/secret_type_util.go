@@ -0,0 +1,166 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+// createEncryptedSecret encrypts secretData using a newly generated key, creates
+// a data store entry for the secret and persists the key using the virtual key store.
+func createEncryptedSecret(dataStore vds.DataStoreAdapter, keyStore *vks.VirtualKeyStore, secretEntry *model.SecretEntry, secretData []byte) error {
+	dataStoreEntry, key, err := encryptSecret(secretEntry, secretData)
+	if err != nil {
+		return err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	if err := dataStore.CreateEntry(dataStoreEntry); err != nil {
+		return err
+	}
+
+	// persist key using virtual key store
+	if err := keyStore.Create(vds.SecretKeyAlias(secretEntry.Id, secretEntry.Version), key); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// updateEncryptedSecret replaces the data store entry of an existing secret with
+// secretData, encrypted using a newly generated key. The key of the new version
+// is persisted before the entry is replaced, so that readers never observe an
+// entry whose key is missing.
+func updateEncryptedSecret(dataStore vds.DataStoreAdapter, keyStore *vks.VirtualKeyStore, secretEntry *model.SecretEntry, secretData []byte) error {
+	dataStoreEntry, key, err := encryptSecret(secretEntry, secretData)
+	if err != nil {
+		return err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	keyAlias := vds.SecretKeyAlias(secretEntry.Id, secretEntry.Version)
+	if err := keyStore.Create(keyAlias, key); err != nil {
+		return err
+	}
+
+	if err := dataStore.UpdateEntry(dataStoreEntry); err != nil {
+		keyStore.Delete(keyAlias)
+		return err
+	}
+
+	return nil
+}
+
+// decryptSecret returns the decrypted data of the given (encrypted) secret entry.
+func decryptSecret(keyStore *vks.VirtualKeyStore, secretEntry *model.SecretEntry) ([]byte, error) {
+	// fetch encryption key
+	key, err := keyStore.Read(vds.SecretKeyAlias(secretEntry.Id, secretEntry.Version))
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	// decrypt secret data using key
+	secretData, err := crypt.Decrypt(secretEntry.SecretData, key)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return secretData, nil
+}
+
+// deleteEncryptedSecret deletes the data store entry of a secret and its key.
+func deleteEncryptedSecret(dataStore vds.DataStoreAdapter, keyStore *vks.VirtualKeyStore, secretEntry *model.SecretEntry) error {
+	if err := dataStore.DeleteEntry(vds.SecretIdToPath(secretEntry.Id)); err != nil {
+		return err
+	}
+
+	if err := keyStore.Delete(vds.SecretKeyAlias(secretEntry.Id, secretEntry.Version)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func encryptSecret(secretEntry *model.SecretEntry, secretData []byte) (*vds.DataStoreEntry, []byte, error) {
+	// generate encryption key for secret
+	key, err := crypt.GenerateKey()
+	if err != nil {
+		return nil, nil, util.ErrInternal
+	}
+
+	// encrypt secret data using key
+	encryptedSecretData, err := crypt.Encrypt(secretData, key)
+	if err != nil {
+		util.Memzero(key)
+		return nil, nil, util.ErrInternal
+	}
+
+	se := model.NewSecretEntry(secretEntry)
+	se.SecretData = encryptedSecretData
+
+	dataStoreEntry, err := vds.SecretEntryToDataStoreEntry(se)
+	if err != nil {
+		util.Memzero(key)
+		return nil, nil, err
+	}
+
+	return dataStoreEntry, key, nil
+}
+
+// encodePKCS8PrivateKey returns the PEM encoding of a private key in PKCS#8 form.
+func encodePKCS8PrivateKey(privKey interface{}) ([]byte, error) {
+	b, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	block := pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: b,
+	}
+
+	return pem.EncodeToMemory(&block), nil
+}
+
+// parsePrivateKeyPEM parses a PEM-encoded private key secret, either a PKCS#1 RSA
+// key or a PKCS#8 key of any supported algorithm.
+func parsePrivateKeyPEM(pkPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pkPEM)
+	if block == nil {
+		return nil, util.ErrInternal
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := privKey.(crypto.Signer)
+		if !ok {
+			return nil, util.ErrInputValidation
+		}
+
+		return signer, nil
+	default:
+		return nil, util.ErrInputValidation
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestAPIUpdateAndRollbackSecret(t *testing.T) {
+	id, err := createDataSecret("api-versioned-id0", "secret-v1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	// step 1: update the secret
+	se := &model.SecretEntry{
+		SecretData: []byte("secret-v2"),
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(se); err != nil {
+		t.Fatalf("failed to marshal se %v: %v", se, err)
+	}
+
+	secretUrl := fmt.Sprintf("%v/secrets/%v", ts.URL, id)
+	req, err := http.NewRequest("PUT", secretUrl, body)
+	if err != nil {
+		t.Fatalf("Failed to update secret with id %v: %v", id, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update secret with id %v: %v", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	// step 2: get the prior version
+	se2, err := apiGetSecret(fmt.Sprintf("%v?version=1", secretUrl))
+	if err != nil {
+		t.Fatalf("Failed to get version 1 of secret with id %v: %v", id, err)
+	}
+	if string(se2.SecretData) != "secret-v1" {
+		t.Fatalf("Unexpected data for version 1 of secret with id %v: %s", id, se2.SecretData)
+	}
+
+	// step 3: list versions
+	versionsUrl := fmt.Sprintf("%v/secret-versions/%v", ts.URL, id)
+	resp2, err := http.Get(versionsUrl)
+	if err != nil {
+		t.Fatalf("Failed to list versions of secret with id %v: %v", id, err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp2.Status)
+	}
+
+	var versionEntries []model.SecretVersionEntry
+	if err = json.NewDecoder(resp2.Body).Decode(&versionEntries); err != nil {
+		t.Fatalf("Failed to parse list versions response: %v", err)
+	}
+	if len(versionEntries) != 2 {
+		t.Fatalf("Expected 2 versions, got %v", len(versionEntries))
+	}
+
+	// step 4: rollback to the first version
+	body = new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(&model.RollbackRequest{Version: 1}); err != nil {
+		t.Fatalf("failed to marshal rollback request: %v", err)
+	}
+	resp3, err := http.Post(versionsUrl, "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to rollback secret with id %v: %v", id, err)
+	}
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp3.Status)
+	}
+
+	se3, err := apiGetSecret(secretUrl)
+	if err != nil {
+		t.Fatalf("Failed to get secret with id %v: %v", id, err)
+	}
+	if string(se3.SecretData) != "secret-v1" || se3.Version != 3 {
+		t.Fatalf("Unexpected secret after rollback: version %v, data %s", se3.Version, se3.SecretData)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func apiGetSecret(secretUrl string) (*model.SecretEntry, error) {
+	resp, err := http.Get(secretUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var se model.SecretEntry
+	if err = json.NewDecoder(resp.Body).Decode(&se); err != nil {
+		return nil, err
+	}
+
+	return &se, nil
+}
@@ -0,0 +1,171 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestUpdateAndGetSecretVersions(t *testing.T) {
+	id, err := createDataSecret("versioned-id1", "secret-v1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	for _, secretData := range []string{"secret-v2", "secret-v3"} {
+		if err := updateDataSecret(id, secretData); err != nil {
+			t.Fatalf("Failed to update secret: %v", err)
+		}
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+	if string(se.SecretData) != "secret-v3" || se.Version != 3 {
+		t.Fatalf("Unexpected current secret: version %v, data %s", se.Version, se.SecretData)
+	}
+
+	for version := 1; version <= 3; version++ {
+		se, err := sm.GetSecretVersion(context.GetTestRequestContext(), id, version)
+		if err != nil {
+			t.Fatalf("Failed to get version %v of secret %v: %v", version, id, err)
+		}
+
+		expectedData := fmt.Sprintf("secret-v%v", version)
+		if string(se.SecretData) != expectedData || se.Version != version {
+			t.Fatalf("Unexpected secret version: expected %v (%v), got %s (%v)", expectedData, version, se.SecretData, se.Version)
+		}
+	}
+
+	versionEntries, err := sm.ListSecretVersions(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to list versions of secret %v: %v", id, err)
+	}
+	if len(versionEntries) != 3 {
+		t.Fatalf("Expected 3 versions, got %v", len(versionEntries))
+	}
+	for i, versionEntry := range versionEntries {
+		if versionEntry.Version != i+1 || versionEntry.Current != (i == 2) {
+			t.Fatalf("Unexpected version entry at index %v: %v", i, versionEntry)
+		}
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+
+	if _, err := sm.GetSecretVersion(context.GetTestRequestContext(), id, 1); err == nil {
+		t.Fatalf("Succeeded to get a prior version of a deleted secret")
+	}
+}
+
+func TestRollbackSecret(t *testing.T) {
+	id, err := createDataSecret("versioned-id2", "secret-v1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if err := updateDataSecret(id, "secret-v2"); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+
+	if _, err := sm.RollbackSecret(context.GetTestRequestContext(), id, 1); err != nil {
+		t.Fatalf("Failed to rollback secret %v: %v", id, err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+	if string(se.SecretData) != "secret-v1" || se.Version != 3 {
+		t.Fatalf("Unexpected secret after rollback: version %v, data %s", se.Version, se.SecretData)
+	}
+
+	if _, err := sm.RollbackSecret(context.GetTestRequestContext(), id, 7); err == nil {
+		t.Fatalf("Succeeded to rollback secret to a non-existent version")
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestSecretVersionsArePruned(t *testing.T) {
+	id, err := createDataSecret("versioned-id3", "secret-v1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	for version := 2; version <= 6; version++ {
+		if err := updateDataSecret(id, fmt.Sprintf("secret-v%v", version)); err != nil {
+			t.Fatalf("Failed to update secret: %v", err)
+		}
+	}
+
+	versionEntries, err := sm.ListSecretVersions(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to list versions of secret %v: %v", id, err)
+	}
+
+	// the test configuration keeps 3 prior versions
+	if len(versionEntries) != 4 || versionEntries[0].Version != 3 {
+		t.Fatalf("Unexpected versions after pruning: %v", versionEntries)
+	}
+
+	if _, err := sm.GetSecretVersion(context.GetTestRequestContext(), id, 2); err == nil {
+		t.Fatalf("Succeeded to get a pruned version of secret %v", id)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestUpdateSecretWithDifferentType(t *testing.T) {
+	id, err := createDataSecret("versioned-id4", "secret-v1")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se := &model.SecretEntry{
+		Id:       id,
+		Type:     RSAPrivateKeySecretTypeName,
+		MetaData: "{\"keyLength\": 1024}",
+	}
+	if _, err := sm.UpdateSecret(context.GetTestRequestContext(), se); err == nil {
+		t.Fatalf("Succeeded to update secret %v with a different type", id)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func createDataSecret(id, secretData string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           DataSecretTypeName,
+		SecretData:     []byte(secretData),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
+
+func updateDataSecret(id, secretData string) error {
+	se := &model.SecretEntry{
+		Id:         id,
+		SecretData: []byte(secretData),
+	}
+
+	_, err := sm.UpdateSecret(context.GetTestRequestContext(), se)
+
+	return err
+}
@@ -0,0 +1,185 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// A stand-in database/sql driver which records the statements it executes, keyed by
+// data source name. Statements containing "fail" aren't recorded and fail.
+const testSQLDriverName = "vsm-test-sql"
+
+type testSQLDriver struct {
+	lock       sync.Mutex
+	statements map[string][]string
+}
+
+type testSQLConn struct {
+	driver *testSQLDriver
+	dsn    string
+}
+
+type testSQLStmt struct {
+	conn  *testSQLConn
+	query string
+}
+
+var testSQL = &testSQLDriver{statements: make(map[string][]string)}
+
+func init() {
+	sql.Register(testSQLDriverName, testSQL)
+}
+
+func (d *testSQLDriver) Open(dsn string) (driver.Conn, error) {
+	return &testSQLConn{driver: d, dsn: dsn}, nil
+}
+
+func (d *testSQLDriver) executed(dsn string) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([]string{}, d.statements[dsn]...)
+}
+
+func (c *testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &testSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *testSQLConn) Close() error { return nil }
+func (c *testSQLConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions not supported")
+}
+
+func (s *testSQLStmt) Close() error  { return nil }
+func (s *testSQLStmt) NumInput() int { return -1 }
+
+func (s *testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.Contains(s.query, "fail") {
+		return nil, fmt.Errorf("statement failed")
+	}
+
+	s.conn.driver.lock.Lock()
+	defer s.conn.driver.lock.Unlock()
+	s.conn.driver.statements[s.conn.dsn] = append(s.conn.driver.statements[s.conn.dsn], s.query)
+
+	return driver.RowsAffected(0), nil
+}
+
+func (s *testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("queries not supported")
+}
+
+func testSQLConfig(creationStatements ...string) json.RawMessage {
+	config, _ := json.Marshal(&SQLCredentialBackendConfig{
+		Driver:               testSQLDriverName,
+		CreationStatements:   creationStatements,
+		RevocationStatements: []string{"DROP USER {{.Name}}"},
+	})
+
+	return config
+}
+
+func TestSQLCredentialBackendCreateAndRevoke(t *testing.T) {
+	backend := NewSQLCredentialBackend()
+	dsn := "sql-backend-dsn0"
+	config := testSQLConfig("CREATE USER {{.Name}} PASSWORD '{{.Password}}' VALID UNTIL '{{.Expiration}}'")
+	expirationTime := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := backend.ValidateConfig(config, []byte(dsn)); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+
+	credential, revocationData, err := backend.CreateCredential(config, []byte(dsn), expirationTime)
+	if err != nil {
+		t.Fatalf("Failed to create credential: %v", err)
+	}
+
+	username := credential["username"]
+	if !strings.HasPrefix(username, sqlUsernamePrefix) || len(credential["password"]) != sqlPasswordLength {
+		t.Fatalf("Unexpected credential: %v", credential)
+	}
+
+	expected := fmt.Sprintf("CREATE USER %v PASSWORD '%v' VALID UNTIL '2017-06-01 12:00:00'", username, credential["password"])
+	if executed := testSQL.executed(dsn); len(executed) != 1 || executed[0] != expected {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+
+	if err := backend.RevokeCredential(config, []byte(dsn), revocationData); err != nil {
+		t.Fatalf("Failed to revoke credential: %v", err)
+	}
+
+	if executed := testSQL.executed(dsn); len(executed) != 2 || executed[1] != "DROP USER "+username {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+}
+
+func TestSQLCredentialBackendCreateFailure(t *testing.T) {
+	backend := NewSQLCredentialBackend()
+	dsn := "sql-backend-dsn1"
+	config := testSQLConfig("CREATE USER {{.Name}}", "fail")
+
+	if _, _, err := backend.CreateCredential(config, []byte(dsn), time.Now().Add(time.Hour)); err == nil {
+		t.Fatalf("Created a credential despite a failing statement")
+	}
+
+	// the partially created user is dropped
+	executed := testSQL.executed(dsn)
+	if len(executed) != 2 || !strings.HasPrefix(executed[1], "DROP USER ") {
+		t.Fatalf("Unexpected statements executed: %v", executed)
+	}
+}
+
+func TestSQLCredentialBackendValidateConfig(t *testing.T) {
+	backend := NewSQLCredentialBackend()
+
+	unknownDriver, _ := json.Marshal(&SQLCredentialBackendConfig{
+		Driver:               "no-such-driver",
+		CreationStatements:   []string{"CREATE USER {{.Name}}"},
+		RevocationStatements: []string{"DROP USER {{.Name}}"},
+	})
+	noRevocation, _ := json.Marshal(&SQLCredentialBackendConfig{
+		Driver:             testSQLDriverName,
+		CreationStatements: []string{"CREATE USER {{.Name}}"},
+	})
+
+	invalidConfigs := []json.RawMessage{
+		json.RawMessage(`not json`),
+		unknownDriver,
+		noRevocation,
+		testSQLConfig(),
+		testSQLConfig("CREATE USER {{.Name"),
+		testSQLConfig("CREATE USER {{.Username}}"),
+	}
+
+	for _, config := range invalidConfigs {
+		if err := backend.ValidateConfig(config, []byte("dsn")); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when validating config %v: %v", string(config), err)
+		}
+	}
+
+	if err := backend.ValidateConfig(testSQLConfig("CREATE USER {{.Name}}"), []byte{}); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when validating an empty data source name: %v", err)
+	}
+
+	// the supported drivers are linked into the server
+	for _, driver := range []string{"postgres", "mysql"} {
+		config, _ := json.Marshal(&SQLCredentialBackendConfig{
+			Driver:               driver,
+			CreationStatements:   []string{"CREATE USER {{.Name}}"},
+			RevocationStatements: []string{"DROP USER {{.Name}}"},
+		})
+		if err := backend.ValidateConfig(config, []byte("dsn")); err != nil {
+			t.Fatalf("Failed to validate config using driver %v: %v", driver, err)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+	"golang.org/x/crypto/ssh"
+)
+
+const SSHCertificateAuthoritySecretTypeName = "SSHCertificateAuthority"
+
+const (
+	SSHCertTypeUser = "user"
+	SSHCertTypeHost = "host"
+
+	// Validity of signed certificates, unless the CA's policy says otherwise.
+	DefaultSSHCertMaxTTL = 24 * time.Hour
+
+	// Tolerated clock skew between the server and the SSH servers validating certificates.
+	sshCertClockSkew = time.Minute
+)
+
+// Extensions granted to user certificates, when none are requested.
+var defaultSSHUserCertExtensions = []string{
+	"permit-X11-forwarding",
+	"permit-agent-forwarding",
+	"permit-port-forwarding",
+	"permit-pty",
+	"permit-user-rc",
+}
+
+func init() {
+	if err := SecretTypeRegistrar.Register(SSHCertificateAuthoritySecretTypeName, NewSSHCertificateAuthoritySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", SSHCertificateAuthoritySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(SSHCertificateAuthoritySecretTypeName, sshCAMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", SSHCertificateAuthoritySecretTypeName, err))
+	}
+}
+
+// A secret type whose data is a SSH certificate authority's private key, which
+// is used to sign users' (or hosts') public keys according to the CA's policy.
+// The private key never leaves the server: reading the secret returns the CA's
+// public key, in authorized_keys format (e.g. for sshd's TrustedUserCAKeys).
+type SSHCertificateAuthoritySecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+}
+
+// SSHCertificateAuthoritySecretMetaData holds the CA's key parameters and the
+// policy limiting the certificates it signs.
+type SSHCertificateAuthoritySecretMetaData struct {
+	// One of: ed25519 (default), ecdsa, rsa
+	KeyType   string `json:"keyType"`
+	KeyLength int    `json:"keyLength"`
+	// Set by the server
+	PublicKey string `json:"publicKey"`
+
+	// Principals that may be requested; "*" allows any principal
+	AllowedPrincipals []string `json:"allowedPrincipals"`
+	// Certificate types that may be requested: user (default), host
+	AllowedCertTypes []string `json:"allowedCertTypes"`
+	// Maximal certificate validity, e.g. 8h (default: 24h)
+	MaxTTL                 string   `json:"maxTTL"`
+	AllowedCriticalOptions []string `json:"allowedCriticalOptions"`
+	// Extensions that may be requested (default: the standard OpenSSH user extensions)
+	AllowedExtensions []string `json:"allowedExtensions"`
+}
+
+var sshCAMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"keyType":                {Type: model.SchemaTypeString, Description: "CA key type (default: ed25519)", Enum: []string{SSHKeyTypeEd25519, SSHKeyTypeECDSA, SSHKeyTypeRSA}},
+		"keyLength":              {Type: model.SchemaTypeInteger, Description: "CA key length in bits: 256 or 384 for ecdsa, 2048-4096 for rsa", Minimum: model.SchemaBound(0)},
+		"publicKey":              {Type: model.SchemaTypeString, Description: "CA public key, in authorized_keys format", ReadOnly: true},
+		"allowedPrincipals":      {Type: model.SchemaTypeArray, Description: "principals which may be signed, or * for any principal", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"allowedCertTypes":       {Type: model.SchemaTypeArray, Description: "certificate types which may be signed (default: user)", Items: &model.Schema{Type: model.SchemaTypeString, Enum: []string{SSHCertTypeUser, SSHCertTypeHost}}},
+		"maxTTL":                 {Type: model.SchemaTypeString, Description: "maximal certificate validity (default: 24h)", Format: model.SchemaFormatDuration},
+		"allowedCriticalOptions": {Type: model.SchemaTypeArray, Description: "critical options which may be requested", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"allowedExtensions":      {Type: model.SchemaTypeArray, Description: "extensions which may be requested (default: the standard user extensions)", Items: &model.Schema{Type: model.SchemaTypeString}},
+	},
+}
+
+func NewSSHCertificateAuthoritySecretType() *SSHCertificateAuthoritySecretType {
+	return &SSHCertificateAuthoritySecretType{}
+}
+
+func (sshCAST *SSHCertificateAuthoritySecretType) Type() string {
+	return SSHCertificateAuthoritySecretTypeName
+}
+
+func (sshCAST *SSHCertificateAuthoritySecretType) Description() string {
+	return "An SSH certificate authority, signing SSH public keys"
+}
+
+func (sshCAST *SSHCertificateAuthoritySecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (sshCAST *SSHCertificateAuthoritySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	sshCAST.dataStore = moduleInitContext.DataStore
+	sshCAST.keyStore = moduleInitContext.VirtualKeyStore
+
+	return nil
+}
+
+func (sshCAST *SSHCertificateAuthoritySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	se, pkPEM, err := generateSSHCertificateAuthoritySecretEntry(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := createEncryptedSecret(sshCAST.dataStore, sshCAST.keyStore, se, pkPEM); err != nil {
+		return "", err
+	}
+
+	return se.Id, nil
+}
+
+// GetSecret returns the CA's public key; the private key is not returned.
+func (sshCAST *SSHCertificateAuthoritySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	caMetaData, err := getSSHCertificateAuthorityMetaData(secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	secretEntry.SecretData = []byte(caMetaData.PublicKey)
+
+	return secretEntry, nil
+}
+
+// UpdateSecret generates a new CA key pair, replacing the current one. Certificates
+// signed by the previous key are no longer trusted once the CA's public key is replaced.
+func (sshCAST *SSHCertificateAuthoritySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	se, pkPEM, err := generateSSHCertificateAuthoritySecretEntry(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := updateEncryptedSecret(sshCAST.dataStore, sshCAST.keyStore, se, pkPEM); err != nil {
+		return "", err
+	}
+
+	return se.Id, nil
+}
+
+// RotateSecret generates a new CA key pair.
+func (sshCAST *SSHCertificateAuthoritySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return sshCAST.UpdateSecret(ctx, secretEntry)
+}
+
+func (sshCAST *SSHCertificateAuthoritySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(sshCAST.dataStore, sshCAST.keyStore, secretEntry)
+}
+
+// SignPublicKey signs the public key in signRequest using the CA secret in caEntry,
+// after validating the request against the CA's policy.
+func (sshCAST *SSHCertificateAuthoritySecretType) SignPublicKey(caEntry *model.SecretEntry, signRequest *model.SSHSignRequest) (*model.SSHSignResponse, error) {
+	caMetaData, err := getSSHCertificateAuthorityMetaData(caEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := sshCertificateFromRequest(caMetaData, signRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	pkPEM, err := decryptSecret(sshCAST.keyStore, caEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(pkPEM)
+
+	signer, err := ssh.ParsePrivateKey(pkPEM)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &model.SSHSignResponse{
+		Certificate: string(ssh.MarshalAuthorizedKey(cert)),
+		Serial:      cert.Serial,
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0),
+	}, nil
+}
+
+// sshCertificateFromRequest builds an (unsigned) certificate from signRequest, enforcing the CA's policy.
+func sshCertificateFromRequest(caMetaData *SSHCertificateAuthoritySecretMetaData, signRequest *model.SSHSignRequest) (*ssh.Certificate, error) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signRequest.PublicKey))
+	if err != nil {
+		return nil, util.ErrInputValidation
+	}
+
+	certType := signRequest.CertType
+	if certType == "" {
+		certType = SSHCertTypeUser
+	}
+	allowedCertTypes := caMetaData.AllowedCertTypes
+	if len(allowedCertTypes) == 0 {
+		allowedCertTypes = []string{SSHCertTypeUser}
+	}
+	if !stringsContain(allowedCertTypes, certType) {
+		return nil, util.ErrUnauthorized
+	}
+
+	var sshCertType uint32
+	switch certType {
+	case SSHCertTypeUser:
+		sshCertType = ssh.UserCert
+	case SSHCertTypeHost:
+		sshCertType = ssh.HostCert
+	default:
+		return nil, util.ErrInputValidation
+	}
+
+	for _, principal := range signRequest.Principals {
+		if !stringsContain(caMetaData.AllowedPrincipals, "*") && !stringsContain(caMetaData.AllowedPrincipals, principal) {
+			return nil, util.ErrUnauthorized
+		}
+	}
+
+	maxTTL := DefaultSSHCertMaxTTL
+	if caMetaData.MaxTTL != "" {
+		if maxTTL, err = time.ParseDuration(caMetaData.MaxTTL); err != nil {
+			return nil, util.ErrInternal
+		}
+	}
+	ttl := maxTTL
+	if signRequest.TTL != "" {
+		if ttl, err = time.ParseDuration(signRequest.TTL); err != nil || ttl <= 0 {
+			return nil, util.ErrInputValidation
+		}
+	}
+	if ttl > maxTTL {
+		return nil, util.ErrUnauthorized
+	}
+
+	for criticalOption := range signRequest.CriticalOptions {
+		if !stringsContain(caMetaData.AllowedCriticalOptions, criticalOption) {
+			return nil, util.ErrUnauthorized
+		}
+	}
+
+	allowedExtensions := caMetaData.AllowedExtensions
+	if len(allowedExtensions) == 0 {
+		allowedExtensions = defaultSSHUserCertExtensions
+	}
+	extensions := signRequest.Extensions
+	if len(extensions) == 0 && sshCertType == ssh.UserCert {
+		extensions = make(map[string]string)
+		for _, extension := range allowedExtensions {
+			extensions[extension] = ""
+		}
+	}
+	for extension := range extensions {
+		if !stringsContain(allowedExtensions, extension) {
+			return nil, util.ErrUnauthorized
+		}
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	keyId := signRequest.KeyId
+	if keyId == "" {
+		keyId = signRequest.Principals[0]
+	}
+
+	now := time.Now()
+
+	return &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          serial,
+		CertType:        sshCertType,
+		KeyId:           keyId,
+		ValidPrincipals: signRequest.Principals,
+		ValidAfter:      uint64(now.Add(-sshCertClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			CriticalOptions: signRequest.CriticalOptions,
+			Extensions:      extensions,
+		},
+	}, nil
+}
+
+func generateSSHCertificateAuthoritySecretEntry(secretEntry *model.SecretEntry) (*model.SecretEntry, []byte, error) {
+	var caMetaData SSHCertificateAuthoritySecretMetaData
+	if secretEntry.MetaData != "" {
+		if err := json.Unmarshal([]byte(secretEntry.MetaData), &caMetaData); err != nil {
+			return nil, nil, util.ErrInputValidation
+		}
+	}
+
+	// we expect the input to contain no data, as we're generating the data
+	// (the CA's private key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, nil, util.ErrInputValidation
+	}
+
+	if caMetaData.MaxTTL != "" {
+		if maxTTL, err := time.ParseDuration(caMetaData.MaxTTL); err != nil || maxTTL <= 0 {
+			return nil, nil, util.ErrInputValidation
+		}
+	}
+
+	privKey, err := generateSSHPrivateKey(caMetaData.KeyType, caMetaData.KeyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkPEM, publicKey, err := encodeSSHKeyPair(privKey, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caMetaData.PublicKey = publicKey
+	metaDataBytes, err := json.Marshal(caMetaData)
+	if err != nil {
+		return nil, nil, util.ErrInternal
+	}
+
+	se := model.NewSecretEntry(secretEntry)
+	se.MetaData = string(metaDataBytes)
+
+	return se, pkPEM, nil
+}
+
+func getSSHCertificateAuthorityMetaData(secretEntry *model.SecretEntry) (*SSHCertificateAuthoritySecretMetaData, error) {
+	var caMetaData SSHCertificateAuthoritySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &caMetaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &caMetaData, nil
+}
+
+func randomSerial() (uint64, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func stringsContain(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestAPISignSSHPublicKey(t *testing.T) {
+	id, err := createSSHCA("api-ssh-ca-id0", testSSHCAMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	userPubKey, err := generateTestSSHPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+
+	signRequest := &model.SSHSignRequest{
+		CaId:       id,
+		PublicKey:  userPubKey,
+		Principals: []string{"user1"},
+	}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(signRequest); err != nil {
+		t.Fatalf("failed to marshal sign request %v: %v", signRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/ssh/sign", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to sign public key: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var signResponse model.SSHSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signResponse); err != nil {
+		t.Fatalf("Failed to parse sign response: %v", err)
+	}
+
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signResponse.Certificate))
+	if err != nil {
+		t.Fatalf("Failed to parse returned certificate: %v", err)
+	}
+
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok || len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "user1" {
+		t.Fatalf("Unexpected returned certificate: %v", signResponse.Certificate)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"golang.org/x/crypto/ssh"
+)
+
+const testSSHCAMetaData = "{\"allowedPrincipals\": [\"user0\", \"user1\"], \"maxTTL\": \"8h\", \"allowedExtensions\": [\"permit-pty\"]}"
+
+func TestCreateAndGetSSHCertificateAuthoritySecret(t *testing.T) {
+	id, err := createSSHCA("ssh-ca-id0", testSSHCAMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	// only the CA's public key is returned
+	if _, _, _, _, err := ssh.ParseAuthorizedKey(se.SecretData); err != nil {
+		t.Fatalf("Failed to parse returned CA public key: %v", err)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestSignSSHPublicKey(t *testing.T) {
+	id, err := createSSHCA("ssh-ca-id1", testSSHCAMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	caPubKey, _, _, _, err := ssh.ParseAuthorizedKey(se.SecretData)
+	if err != nil {
+		t.Fatalf("Failed to parse returned CA public key: %v", err)
+	}
+
+	userPubKey, err := generateTestSSHPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+
+	signRequest := &model.SSHSignRequest{
+		CaId:       id,
+		PublicKey:  userPubKey,
+		Principals: []string{"user0"},
+		TTL:        "1h",
+	}
+	signResponse, err := sm.SignSSHPublicKey(context.GetTestRequestContext(), signRequest)
+	if err != nil {
+		t.Fatalf("Failed to sign public key: %v", err)
+	}
+
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signResponse.Certificate))
+	if err != nil {
+		t.Fatalf("Failed to parse returned certificate: %v", err)
+	}
+
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("Returned key is not a certificate")
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(caPubKey.Marshal())
+		},
+	}
+	if err := checker.CheckCert("user0", cert); err != nil {
+		t.Fatalf("Returned certificate is not valid for principal user0: %v", err)
+	}
+
+	if err := checker.CheckCert("user1", cert); err == nil {
+		t.Fatalf("Returned certificate is valid for a principal which wasn't requested")
+	}
+
+	if cert.CertType != ssh.UserCert || cert.Serial != signResponse.Serial {
+		t.Fatalf("Unexpected certificate type %v or serial %v", cert.CertType, cert.Serial)
+	}
+
+	if _, ok := cert.Permissions.Extensions["permit-pty"]; !ok || len(cert.Permissions.Extensions) != 1 {
+		t.Fatalf("Unexpected certificate extensions: %v", cert.Permissions.Extensions)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestSignSSHPublicKeyViolatingPolicy(t *testing.T) {
+	id, err := createSSHCA("ssh-ca-id2", testSSHCAMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	userPubKey, err := generateTestSSHPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+
+	for _, signRequest := range []*model.SSHSignRequest{
+		{CaId: id, PublicKey: userPubKey, Principals: []string{"root"}},
+		{CaId: id, PublicKey: userPubKey, Principals: []string{"user0"}, TTL: "9h"},
+		{CaId: id, PublicKey: userPubKey, Principals: []string{"user0"}, CertType: SSHCertTypeHost},
+		{CaId: id, PublicKey: userPubKey, Principals: []string{"user0"}, Extensions: map[string]string{"permit-port-forwarding": ""}},
+		{CaId: id, PublicKey: userPubKey, Principals: []string{"user0"}, CriticalOptions: map[string]string{"force-command": "/bin/true"}},
+		{CaId: id, PublicKey: "not a public key", Principals: []string{"user0"}},
+	} {
+		if _, err := sm.SignSSHPublicKey(context.GetTestRequestContext(), signRequest); err == nil {
+			t.Fatalf("Signed a public key although the request violates the CA's policy: %v", signRequest)
+		}
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func TestSignSSHPublicKeyWithNonCASecret(t *testing.T) {
+	id, err := createSSHKeyPair("ssh-ca-id3", "")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	userPubKey, err := generateTestSSHPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+
+	signRequest := &model.SSHSignRequest{
+		CaId:       id,
+		PublicKey:  userPubKey,
+		Principals: []string{"user0"},
+	}
+	if _, err := sm.SignSSHPublicKey(context.GetTestRequestContext(), signRequest); err == nil {
+		t.Fatalf("Signed a public key using a secret which isn't a SSH CA")
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func createSSHCA(id, metaData string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           SSHCertificateAuthoritySecretTypeName,
+		MetaData:       metaData,
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
+
+func generateTestSSHPublicKey() (string, error) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	sshPubKey, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return string(ssh.MarshalAuthorizedKey(sshPubKey)), nil
+}
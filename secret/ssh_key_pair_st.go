@@ -0,0 +1,236 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+	"golang.org/x/crypto/ssh"
+)
+
+const SSHKeyPairSecretTypeName = "SSHKeyPair"
+
+const (
+	SSHKeyTypeEd25519 = "ed25519"
+	SSHKeyTypeECDSA   = "ecdsa"
+	SSHKeyTypeRSA     = "rsa"
+
+	defaultSSHRSAKeyLength = 3072
+)
+
+func init() {
+	if err := SecretTypeRegistrar.Register(SSHKeyPairSecretTypeName, NewSSHKeyPairSecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", SSHKeyPairSecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(SSHKeyPairSecretTypeName, sshKeyPairMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", SSHKeyPairSecretTypeName, err))
+	}
+}
+
+// A secret type whose data is a generated SSH private key, in OpenSSH format.
+// The corresponding public key, in authorized_keys format, is kept in the
+// secret's meta-data, so it can be read without the private key.
+type SSHKeyPairSecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+}
+
+type SSHKeyPairSecretMetaData struct {
+	// One of: ed25519 (default), ecdsa, rsa
+	KeyType string `json:"keyType"`
+	// Key length in bits: 256 or 384 for ecdsa, 2048-4096 for rsa
+	KeyLength int    `json:"keyLength"`
+	Comment   string `json:"comment"`
+	// Set by the server
+	PublicKey string `json:"publicKey"`
+}
+
+var sshKeyPairMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"keyType":   {Type: model.SchemaTypeString, Description: "key type (default: ed25519)", Enum: []string{SSHKeyTypeEd25519, SSHKeyTypeECDSA, SSHKeyTypeRSA}},
+		"keyLength": {Type: model.SchemaTypeInteger, Description: "key length in bits: 256 or 384 for ecdsa, 2048-4096 for rsa", Minimum: model.SchemaBound(0)},
+		"comment":   {Type: model.SchemaTypeString, Description: "public key comment"},
+		"publicKey": {Type: model.SchemaTypeString, Description: "public key, in authorized_keys format", ReadOnly: true},
+	},
+}
+
+func NewSSHKeyPairSecretType() *SSHKeyPairSecretType {
+	return &SSHKeyPairSecretType{}
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) Type() string {
+	return SSHKeyPairSecretTypeName
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) Description() string {
+	return "An SSH key pair, generated by the server"
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	sshKeyPairST.dataStore = moduleInitContext.DataStore
+	sshKeyPairST.keyStore = moduleInitContext.VirtualKeyStore
+
+	return nil
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	se, pkPEM, err := generateSSHKeyPairSecretEntry(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := createEncryptedSecret(sshKeyPairST.dataStore, sshKeyPairST.keyStore, se, pkPEM); err != nil {
+		return "", err
+	}
+
+	return se.Id, nil
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	pkPEM, err := decryptSecret(sshKeyPairST.keyStore, secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// set decrypted data
+	secretEntry.SecretData = pkPEM
+
+	return secretEntry, nil
+}
+
+// UpdateSecret generates a new key pair, replacing the current one.
+func (sshKeyPairST *SSHKeyPairSecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	se, pkPEM, err := generateSSHKeyPairSecretEntry(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := updateEncryptedSecret(sshKeyPairST.dataStore, sshKeyPairST.keyStore, se, pkPEM); err != nil {
+		return "", err
+	}
+
+	return se.Id, nil
+}
+
+// RotateSecret generates a new key pair.
+func (sshKeyPairST *SSHKeyPairSecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return sshKeyPairST.UpdateSecret(ctx, secretEntry)
+}
+
+func (sshKeyPairST *SSHKeyPairSecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(sshKeyPairST.dataStore, sshKeyPairST.keyStore, secretEntry)
+}
+
+// generateSSHKeyPairSecretEntry generates a key pair, returning a copy of secretEntry
+// whose meta-data includes the public key, and the PEM-encoded private key.
+func generateSSHKeyPairSecretEntry(secretEntry *model.SecretEntry) (*model.SecretEntry, []byte, error) {
+	var sshKeyPairMetaData SSHKeyPairSecretMetaData
+	if secretEntry.MetaData != "" {
+		if err := json.Unmarshal([]byte(secretEntry.MetaData), &sshKeyPairMetaData); err != nil {
+			return nil, nil, util.ErrInputValidation
+		}
+	}
+
+	// we expect the input to contain no data, as we're generating the data
+	// (the private key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, nil, util.ErrInputValidation
+	}
+
+	privKey, err := generateSSHPrivateKey(sshKeyPairMetaData.KeyType, sshKeyPairMetaData.KeyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkPEM, publicKey, err := encodeSSHKeyPair(privKey, sshKeyPairMetaData.Comment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sshKeyPairMetaData.PublicKey = publicKey
+	metaDataBytes, err := json.Marshal(sshKeyPairMetaData)
+	if err != nil {
+		return nil, nil, util.ErrInternal
+	}
+
+	se := model.NewSecretEntry(secretEntry)
+	se.MetaData = string(metaDataBytes)
+
+	return se, pkPEM, nil
+}
+
+func generateSSHPrivateKey(keyType string, keyLength int) (crypto.Signer, error) {
+	switch keyType {
+	case "", SSHKeyTypeEd25519:
+		if keyLength != 0 {
+			return nil, util.ErrInputValidation
+		}
+
+		_, privKey, err := ed25519.GenerateKey(rand.Reader)
+		return privKey, err
+	case SSHKeyTypeECDSA:
+		var curve elliptic.Curve
+		switch keyLength {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			return nil, util.ErrInputValidation
+		}
+
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case SSHKeyTypeRSA:
+		if keyLength == 0 {
+			keyLength = defaultSSHRSAKeyLength
+		}
+		if keyLength < 2048 || keyLength > 4096 {
+			return nil, util.ErrInputValidation
+		}
+
+		return rsa.GenerateKey(rand.Reader, keyLength)
+	default:
+		return nil, util.ErrInputValidation
+	}
+}
+
+// encodeSSHKeyPair returns the PEM encoding of the private key in OpenSSH format, and
+// the public key in authorized_keys format.
+func encodeSSHKeyPair(privKey crypto.Signer, comment string) ([]byte, string, error) {
+	block, err := ssh.MarshalPrivateKey(privKey, comment)
+	if err != nil {
+		return nil, "", util.ErrInternal
+	}
+
+	sshPubKey, err := ssh.NewPublicKey(privKey.Public())
+	if err != nil {
+		return nil, "", util.ErrInternal
+	}
+
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPubKey)))
+	if comment != "" {
+		publicKey = publicKey + " " + comment
+	}
+
+	return pem.EncodeToMemory(block), publicKey, nil
+}
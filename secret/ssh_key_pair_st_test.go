@@ -0,0 +1,118 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCreateAndGetSSHKeyPairSecret(t *testing.T) {
+	for _, metaData := range []string{
+		"",
+		"{\"keyType\": \"ecdsa\", \"keyLength\": 384, \"comment\": \"user0@host0\"}",
+		"{\"keyType\": \"rsa\", \"keyLength\": 2048}",
+	} {
+		id, err := createSSHKeyPair("ssh-key-pair-id0", metaData)
+		if err != nil {
+			t.Fatalf("Failed to create secret with meta-data %v: %v", metaData, err)
+		}
+
+		se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+		if err != nil {
+			t.Fatalf("Failed to get secret for id %v: %v", id, err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(se.SecretData)
+		if err != nil {
+			t.Fatalf("Failed to parse returned private key: %v", err)
+		}
+
+		var sshKeyPairMetaData SSHKeyPairSecretMetaData
+		if err := json.Unmarshal([]byte(se.MetaData), &sshKeyPairMetaData); err != nil {
+			t.Fatalf("Failed to parse returned meta-data: %v", err)
+		}
+
+		pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(sshKeyPairMetaData.PublicKey))
+		if err != nil {
+			t.Fatalf("Failed to parse returned public key: %v", err)
+		}
+
+		if string(pubKey.Marshal()) != string(signer.PublicKey().Marshal()) {
+			t.Fatalf("Returned public key does not match the private key")
+		}
+
+		if comment != sshKeyPairMetaData.Comment {
+			t.Fatalf("Unexpected public key comment: %v", comment)
+		}
+
+		if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+			t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+		}
+	}
+}
+
+func TestCreateSSHKeyPairSecretWithBadMetaData(t *testing.T) {
+	for _, metaData := range []string{
+		"{\"keyType\": \"dsa\"}",
+		"{\"keyType\": \"ed25519\", \"keyLength\": 256}",
+		"{\"keyType\": \"ecdsa\", \"keyLength\": 521}",
+		"{\"keyType\": \"rsa\", \"keyLength\": 1024}",
+	} {
+		if _, err := createSSHKeyPair("ssh-key-pair-id1", metaData); err == nil {
+			t.Fatalf("Created SSH key pair with bad meta-data %v", metaData)
+		}
+	}
+}
+
+func TestRotateSSHKeyPairSecret(t *testing.T) {
+	id, err := createSSHKeyPair("ssh-key-pair-id2", "{\"comment\": \"user0@host0\"}")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to rotate secret for id %v: %v", id, err)
+	}
+
+	se2, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if string(se2.SecretData) == string(se.SecretData) || se2.MetaData == se.MetaData {
+		t.Fatalf("Rotated secret has the same key pair")
+	}
+
+	if !strings.Contains(se2.MetaData, "user0@host0") {
+		t.Fatalf("Rotated secret lost its comment: %v", se2.MetaData)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+		t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+	}
+}
+
+func createSSHKeyPair(id, metaData string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           SSHKeyPairSecretTypeName,
+		MetaData:       metaData,
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
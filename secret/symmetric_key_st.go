@@ -0,0 +1,125 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const SymmetricKeySecretTypeName = "SymmetricKey"
+
+func init() {
+	if err := SecretTypeRegistrar.Register(SymmetricKeySecretTypeName, NewSymmetricKeySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", SymmetricKeySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(SymmetricKeySecretTypeName, symmetricKeyMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", SymmetricKeySecretTypeName, err))
+	}
+}
+
+var symmetricKeyMetaDataSchema = &model.Schema{Type: model.SchemaTypeObject}
+
+// A secret type whose data is a generated 256-bit AES key, used for encrypting and
+// decrypting data on behalf of clients (see SecretManager.Encrypt). The key never
+// leaves the server: getting the secret returns its meta-data only. Updating (or
+// rotating) the secret generates a new key version, while prior versions are kept
+// for decrypting data encrypted using them.
+type SymmetricKeySecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+}
+
+func NewSymmetricKeySecretType() *SymmetricKeySecretType {
+	return &SymmetricKeySecretType{}
+}
+
+func (symKeyST *SymmetricKeySecretType) Type() string {
+	return SymmetricKeySecretTypeName
+}
+
+func (symKeyST *SymmetricKeySecretType) Description() string {
+	return "A symmetric key, generated by the server and used through the crypto API"
+}
+
+func (symKeyST *SymmetricKeySecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (symKeyST *SymmetricKeySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	symKeyST.dataStore = moduleInitContext.DataStore
+	symKeyST.keyStore = moduleInitContext.VirtualKeyStore
+
+	return nil
+}
+
+func (symKeyST *SymmetricKeySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	key, err := generateSymmetricKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	if err := createEncryptedSecret(symKeyST.dataStore, symKeyST.keyStore, secretEntry, key); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+func (symKeyST *SymmetricKeySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	// the key itself is never returned
+	secretEntry.SecretData = []byte{}
+
+	return secretEntry, nil
+}
+
+// UpdateSecret generates a new key, replacing the current one.
+func (symKeyST *SymmetricKeySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	key, err := generateSymmetricKey(secretEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	if err := updateEncryptedSecret(symKeyST.dataStore, symKeyST.keyStore, secretEntry, key); err != nil {
+		return "", err
+	}
+
+	return secretEntry.Id, nil
+}
+
+// RotateSecret generates a new key of the same length.
+func (symKeyST *SymmetricKeySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return symKeyST.UpdateSecret(ctx, secretEntry)
+}
+
+func (symKeyST *SymmetricKeySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(symKeyST.dataStore, symKeyST.keyStore, secretEntry)
+}
+
+func generateSymmetricKey(secretEntry *model.SecretEntry) ([]byte, error) {
+	// we expect the input to contain no data, as we're generating the data
+	// (the key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return nil, util.ErrInputValidation
+	}
+
+	key, err := crypt.GenerateKey()
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return key, nil
+}
@@ -0,0 +1,61 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func TestCreateAndGetSymmetricKeySecret(t *testing.T) {
+	id, err := createSymmetricKey("symmetric-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	if se.Type != SymmetricKeySecretTypeName {
+		t.Fatalf("Unexpected secret type: %v", se.Type)
+	}
+
+	// the key never leaves the server
+	if len(se.SecretData) != 0 {
+		t.Fatalf("Symmetric key secret data was returned")
+	}
+}
+
+func TestCreateSymmetricKeySecretWithData(t *testing.T) {
+	se := &model.SecretEntry{
+		Id:             "symmetric-key-id1",
+		Type:           SymmetricKeySecretTypeName,
+		SecretData:     []byte("my own key"),
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	if _, err := sm.CreateSecret(context.GetTestRequestContext(), se); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when creating a symmetric key with data: %v", err)
+	}
+}
+
+func createSymmetricKey(id string) (string, error) {
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           SymmetricKeySecretTypeName,
+		MetaData:       "",
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
@@ -0,0 +1,139 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// Ciphertexts are formatted as <prefix><key version>:<base64-encoded data>.
+const ciphertextPrefix = "vsm:v"
+
+var symmetricKeySecretTypes = map[string]bool{
+	SymmetricKeySecretTypeName: true,
+}
+
+// Encrypt encrypts data using the current version of a symmetric key secret, returning
+// a ciphertext that identifies the key version. Encrypting, like decrypting and rewrapping,
+// requires encrypt (i.e. use) permission on the key's namespace, rather than read permission.
+func (secretManager *SecretManager) Encrypt(ctx gocontext.Context, encryptRequest *model.EncryptRequest) (*model.EncryptResponse, error) {
+	keyEntry, err := secretManager.getKeyEntry(ctx, encryptRequest.KeyId, 0, model.OpEncrypt, symmetricKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return secretManager.encryptWithKey(keyEntry, encryptRequest.Plaintext, encryptRequest.Context)
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt, using the key version identified
+// by the ciphertext, provided that the key version is still kept.
+func (secretManager *SecretManager) Decrypt(ctx gocontext.Context, decryptRequest *model.DecryptRequest) (*model.DecryptResponse, error) {
+	plaintext, err := secretManager.decryptCiphertext(ctx, decryptRequest.KeyId, decryptRequest.Ciphertext, decryptRequest.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+// Rewrap re-encrypts a ciphertext using the current version of its key, so that data
+// encrypted using prior versions can be upgraded to it. The plaintext isn't revealed to
+// the caller.
+func (secretManager *SecretManager) Rewrap(ctx gocontext.Context, rewrapRequest *model.RewrapRequest) (*model.EncryptResponse, error) {
+	plaintext, err := secretManager.decryptCiphertext(ctx, rewrapRequest.KeyId, rewrapRequest.Ciphertext, rewrapRequest.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce data exposure due to memory compromize / leak
+	defer util.Memzero(plaintext)
+
+	keyEntry, err := secretManager.getKeyEntry(ctx, rewrapRequest.KeyId, 0, model.OpEncrypt, symmetricKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return secretManager.encryptWithKey(keyEntry, plaintext, rewrapRequest.Context)
+}
+
+func (secretManager *SecretManager) encryptWithKey(keyEntry *model.SecretEntry, plaintext []byte, context []byte) (*model.EncryptResponse, error) {
+	key, err := decryptSecret(secretManager.keyStore, keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	data, err := crypt.EncryptAuthenticated(plaintext, key, context)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &model.EncryptResponse{
+		KeyId:      keyEntry.Id,
+		KeyVersion: keyEntry.Version,
+		Ciphertext: fmt.Sprintf("%v%v:%v", ciphertextPrefix, keyEntry.Version, base64.StdEncoding.EncodeToString(data)),
+	}, nil
+}
+
+func (secretManager *SecretManager) decryptCiphertext(ctx gocontext.Context, keyId string, ciphertext string, context []byte) ([]byte, error) {
+	version, data, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	keyEntry, err := secretManager.getKeyEntry(ctx, keyId, version, model.OpEncrypt, symmetricKeySecretTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := decryptSecret(secretManager.keyStore, keyEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(key)
+
+	// the ciphertext or context were tampered with, or the ciphertext was produced by
+	// another key
+	plaintext, err := crypt.DecryptAuthenticated(data, key, context)
+	if err != nil {
+		return nil, util.ErrInputValidation
+	}
+
+	return plaintext, nil
+}
+
+// parseCiphertext returns the key version and the encrypted data of a ciphertext.
+func parseCiphertext(ciphertext string) (int, []byte, error) {
+	if !strings.HasPrefix(ciphertext, ciphertextPrefix) {
+		return 0, nil, util.ErrInputValidation
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(ciphertext, ciphertextPrefix), ":", 2)
+	if len(parts) != 2 {
+		return 0, nil, util.ErrInputValidation
+	}
+
+	version, err := strconv.Atoi(parts[0])
+	if err != nil || version < model.FirstSecretVersion {
+		return 0, nil, util.ErrInputValidation
+	}
+
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, nil, util.ErrInputValidation
+	}
+
+	return version, data, nil
+}
@@ -0,0 +1,155 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	keyId, err := createSymmetricKey("transit-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	plaintext := []byte("the quick brown fox jumps over the lazy dog")
+	encryptionContext := []byte("tenant0")
+
+	encryptResponse, err := sm.Encrypt(context.GetTestRequestContext(), &model.EncryptRequest{KeyId: keyId, Plaintext: plaintext, Context: encryptionContext})
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if encryptResponse.KeyVersion != model.FirstSecretVersion || !strings.HasPrefix(encryptResponse.Ciphertext, fmt.Sprintf("vsm:v%v:", model.FirstSecretVersion)) {
+		t.Fatalf("Unexpected encrypt response: %v", encryptResponse)
+	}
+
+	decryptResponse, err := sm.Decrypt(context.GetTestRequestContext(), &model.DecryptRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext, Context: encryptionContext})
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(decryptResponse.Plaintext, plaintext) {
+		t.Fatalf("Decrypted plaintext is different than the original one: %v", string(decryptResponse.Plaintext))
+	}
+
+	// the ciphertext is bound to its context
+	if _, err := sm.Decrypt(context.GetTestRequestContext(), &model.DecryptRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext, Context: []byte("tenant1")}); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when decrypting using another context: %v", err)
+	}
+}
+
+func TestDecryptAndRewrapWithRotatedKey(t *testing.T) {
+	keyId, err := createSymmetricKey("transit-key-id1")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	plaintext := []byte("rotated")
+	encryptResponse, err := sm.Encrypt(context.GetTestRequestContext(), &model.EncryptRequest{KeyId: keyId, Plaintext: plaintext})
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// the test configuration keeps 3 prior versions of secrets, but all versions of keys
+	rotations := 5
+	for i := 0; i < rotations; i++ {
+		if _, err := sm.RotateSecret(context.GetTestRequestContext(), keyId); err != nil {
+			t.Fatalf("Failed to rotate key: %v", err)
+		}
+	}
+
+	// ciphertexts of prior key versions remain readable
+	decryptResponse, err := sm.Decrypt(context.GetTestRequestContext(), &model.DecryptRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext})
+	if err != nil {
+		t.Fatalf("Failed to decrypt using the prior key version: %v", err)
+	}
+	if !bytes.Equal(decryptResponse.Plaintext, plaintext) {
+		t.Fatalf("Decrypted plaintext is different than the original one: %v", string(decryptResponse.Plaintext))
+	}
+
+	rewrapResponse, err := sm.Rewrap(context.GetTestRequestContext(), &model.RewrapRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext})
+	if err != nil {
+		t.Fatalf("Failed to rewrap: %v", err)
+	}
+	if rewrapResponse.KeyVersion != encryptResponse.KeyVersion+rotations {
+		t.Fatalf("Ciphertext was rewrapped using key version %v rather than the current one", rewrapResponse.KeyVersion)
+	}
+
+	decryptResponse, err = sm.Decrypt(context.GetTestRequestContext(), &model.DecryptRequest{KeyId: keyId, Ciphertext: rewrapResponse.Ciphertext})
+	if err != nil {
+		t.Fatalf("Failed to decrypt rewrapped ciphertext: %v", err)
+	}
+	if !bytes.Equal(decryptResponse.Plaintext, plaintext) {
+		t.Fatalf("Decrypted plaintext is different than the original one: %v", string(decryptResponse.Plaintext))
+	}
+}
+
+func TestDecryptBadRequest(t *testing.T) {
+	keyId, err := createSymmetricKey("transit-key-id2")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	dataId, err := createExpiringDataSecret("transit-data-id0", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), dataId)
+
+	encryptResponse, err := sm.Encrypt(context.GetTestRequestContext(), &model.EncryptRequest{KeyId: keyId, Plaintext: []byte("bad request")})
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	for _, decryptRequest := range []*model.DecryptRequest{
+		// malformed ciphertexts
+		&model.DecryptRequest{KeyId: keyId, Ciphertext: "bad request"},
+		&model.DecryptRequest{KeyId: keyId, Ciphertext: "vsm:vx:AAAA"},
+		&model.DecryptRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext[:len(encryptResponse.Ciphertext)-4]},
+		// only symmetric keys decrypt
+		&model.DecryptRequest{KeyId: dataId, Ciphertext: encryptResponse.Ciphertext},
+	} {
+		if _, err := sm.Decrypt(context.GetTestRequestContext(), decryptRequest); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when decrypting %v: %v", decryptRequest, err)
+		}
+	}
+}
+
+func TestEncryptRequiresEncryptPermission(t *testing.T) {
+	keyId, err := createSymmetricKey("transit-key-id3")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	encryptResponse, err := sm.Encrypt(context.GetTestRequestContext(), &model.EncryptRequest{KeyId: keyId, Plaintext: []byte("permission")})
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// the caller may sign with keys of the namespace, but not encrypt with them
+	restrictedManager := *sm
+	restrictedManager.authzManager = &denyOperationAuthzManager{label: model.OpEncrypt, namespacePath: path.Dir(vds.SecretIdToPath(keyId))}
+
+	if _, err := restrictedManager.Encrypt(context.GetTestRequestContext(), &model.EncryptRequest{KeyId: keyId, Plaintext: []byte("permission")}); err != util.ErrUnauthorized {
+		t.Fatalf("Unexpected error when encrypting without encrypt permission: %v", err)
+	}
+	if _, err := restrictedManager.Decrypt(context.GetTestRequestContext(), &model.DecryptRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext}); err != util.ErrUnauthorized {
+		t.Fatalf("Unexpected error when decrypting without encrypt permission: %v", err)
+	}
+	if _, err := restrictedManager.Rewrap(context.GetTestRequestContext(), &model.RewrapRequest{KeyId: keyId, Ciphertext: encryptResponse.Ciphertext}); err != util.ErrUnauthorized {
+		t.Fatalf("Unexpected error when rewrapping without encrypt permission: %v", err)
+	}
+}
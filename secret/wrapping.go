@@ -0,0 +1,233 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/crypt"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+const (
+	// Period within which a wrapped value must be unwrapped, unless requested otherwise.
+	DefaultWrapTTL = 15 * time.Minute
+	MaxWrapTTL     = 24 * time.Hour
+
+	// Length of the (AES-256) keys which wrapping tokens encode
+	wrappingTokenKeyLength = 32
+)
+
+// Wrap stores either the current data of a secret (which the caller must be allowed to
+// read) or a literal under the wrapped namespace, for a single retrieval within a short
+// TTL, and returns a token for retrieving it. Wrapping requires create permission on
+// the wrapped namespace.
+//
+// The value is encrypted using the token, which isn't kept by the server; the token
+// only identifies the wrapped entry through its hash.
+func (secretManager *SecretManager) Wrap(ctx gocontext.Context, wrapRequest *model.WrapRequest) (*model.WrapResponse, error) {
+	if err := secretManager.authzManager.Allowed(ctx, model.Operation{Label: model.OpCreate}, vds.WrappedRootPath); err != nil {
+		return nil, err
+	}
+
+	ttl := DefaultWrapTTL
+	if wrapRequest.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(wrapRequest.TTL); err != nil || ttl <= 0 || ttl > MaxWrapTTL {
+			return nil, util.ErrInputValidation
+		}
+	}
+
+	wrappedEntry := &model.WrappedEntry{
+		Creator: requestUsername(ctx),
+	}
+
+	data := wrapRequest.Data
+	if wrapRequest.SecretId != "" {
+		secretEntry, err := secretManager.GetSecret(ctx, wrapRequest.SecretId)
+		if err != nil {
+			return nil, err
+		}
+
+		// e.g. symmetric keys, which never leave the server
+		if len(secretEntry.SecretData) == 0 {
+			return nil, util.ErrInputValidation
+		}
+
+		data = secretEntry.SecretData
+		wrappedEntry.SecretId = secretEntry.Id
+		wrappedEntry.SecretVersion = secretEntry.Version
+	}
+
+	tokenKey, err := crypt.GenerateKey()
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(tokenKey)
+
+	wrappedEntry.Id = wrappedIdFromTokenKey(tokenKey)
+
+	// binding the encrypted value to the entry id prevents swapping values between entries
+	wrappedEntry.Data, err = crypt.EncryptAuthenticated(data, tokenKey, []byte(wrappedEntry.Id))
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	now := time.Now()
+	wrappedEntry.CreationTime = now
+	wrappedEntry.ExpirationTime = now.Add(ttl)
+
+	dataStoreEntry, err := vds.WrappedEntryToDataStoreEntry(wrappedEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := secretManager.dataStore.CreateEntry(dataStoreEntry); err != nil {
+		return nil, err
+	}
+
+	if wrappedEntry.SecretId != "" {
+		secretManager.auditManager.Log(fmt.Sprintf("%v wrapped secret %v (version %v) as %v, expiring at %v", wrappedEntry.Creator, wrappedEntry.SecretId, wrappedEntry.SecretVersion, wrappedEntry.Id, wrappedEntry.ExpirationTime))
+	} else {
+		secretManager.auditManager.Log(fmt.Sprintf("%v wrapped a %v-byte value as %v, expiring at %v", wrappedEntry.Creator, len(data), wrappedEntry.Id, wrappedEntry.ExpirationTime))
+	}
+
+	return &model.WrapResponse{
+		Token:          base64.RawURLEncoding.EncodeToString(tokenKey),
+		ExpirationTime: wrappedEntry.ExpirationTime,
+	}, nil
+}
+
+// Unwrap returns a wrapped value and drops it, so that each token is used once, even by
+// concurrent attempts on servers sharing the data store. The token itself authorizes
+// unwrapping, so the caller needn't be a VSM user. Further attempts to unwrap the token
+// fail with util.ErrAlreadyUsed and are audited, which lets the intended recipient find
+// out that the value was intercepted.
+func (secretManager *SecretManager) Unwrap(ctx gocontext.Context, unwrapRequest *model.UnwrapRequest) (*model.UnwrapResponse, error) {
+	tokenKey, err := base64.RawURLEncoding.DecodeString(unwrapRequest.Token)
+	if err != nil || len(tokenKey) != wrappingTokenKeyLength {
+		return nil, util.ErrInputValidation
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(tokenKey)
+
+	wrappedId := wrappedIdFromTokenKey(tokenKey)
+
+	dataStoreEntry, err := secretManager.dataStore.ReadEntry(vds.WrappedIdToPath(wrappedId))
+	if err != nil {
+		return nil, util.ErrNotFound
+	}
+
+	wrappedEntry, err := vds.DataStoreEntryToWrappedEntry(dataStoreEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	if wrappedEntry.Unwrapped {
+		secretManager.auditManager.Log(fmt.Sprintf("attempt to unwrap %v, wrapped by %v, which was already unwrapped at %v", wrappedId, wrappedEntry.Creator, wrappedEntry.UnwrapTime))
+		return nil, util.ErrAlreadyUsed
+	}
+
+	now := time.Now()
+	if !now.Before(wrappedEntry.ExpirationTime) {
+		return nil, util.ErrNotFound
+	}
+
+	data, err := crypt.DecryptAuthenticated(wrappedEntry.Data, tokenKey, []byte(wrappedId))
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	// the value is dropped, while the entry is kept in order to detect further attempts
+	wrappedEntry.Unwrapped = true
+	wrappedEntry.UnwrapTime = now
+	wrappedEntry.ExpirationTime = now.Add(secretManager.eventRetention)
+	wrappedEntry.Data = []byte{}
+
+	unwrappedDataStoreEntry, err := vds.WrappedEntryToDataStoreEntry(wrappedEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// the entry is only marked as unwrapped if it wasn't changed since it was read, so
+	// that of concurrent attempts (possibly on other servers) only one succeeds
+	err = secretManager.dataStore.CompareAndUpdateEntry(unwrappedDataStoreEntry, dataStoreEntry.MetaData)
+	if err == util.ErrNotFound {
+		util.Memzero(data)
+		secretManager.auditManager.Log(fmt.Sprintf("concurrent attempt to unwrap %v, wrapped by %v", wrappedId, wrappedEntry.Creator))
+		return nil, util.ErrAlreadyUsed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secretManager.auditManager.Log(fmt.Sprintf("unwrapped %v, wrapped by %v", wrappedId, wrappedEntry.Creator))
+
+	return &model.UnwrapResponse{
+		SecretId:      wrappedEntry.SecretId,
+		SecretVersion: wrappedEntry.SecretVersion,
+		Data:          data,
+	}, nil
+}
+
+// pruneWrappedEntries deletes the wrapped entries which expired at time now: values which
+// weren't unwrapped in time, and unwrapped entries past the event retention period.
+func (secretManager *SecretManager) pruneWrappedEntries(now time.Time) {
+	childEntries, err := secretManager.dataStore.SearchChildEntries(vds.WrappedRootPath)
+	if err != nil {
+		log.Printf("failed to look for wrapped entries to prune: %v\n", err)
+		return
+	}
+
+	for _, childEntry := range childEntries {
+		if !vds.IsWrappedEntry(childEntry) {
+			continue
+		}
+
+		wrappedEntry, err := vds.DataStoreEntryToWrappedEntry(childEntry)
+		if err != nil {
+			log.Printf("failed to parse wrapped entry %v: %v\n", childEntry.Id, err)
+			continue
+		}
+
+		if now.Before(wrappedEntry.ExpirationTime) {
+			continue
+		}
+
+		// the entry may have been unwrapped since it was read
+		if err := secretManager.dataStore.CompareAndDeleteEntry(childEntry.Id, childEntry.MetaData); err != nil {
+			if err != util.ErrNotFound {
+				log.Printf("failed to delete wrapped entry %v: %v\n", wrappedEntry.Id, err)
+			}
+			continue
+		}
+
+		if !wrappedEntry.Unwrapped {
+			secretManager.auditManager.Log(fmt.Sprintf("deleted %v, wrapped by %v, which expired at %v without being unwrapped", wrappedEntry.Id, wrappedEntry.Creator, wrappedEntry.ExpirationTime))
+		}
+	}
+}
+
+func wrappedIdFromTokenKey(tokenKey []byte) string {
+	hash := sha256.Sum256(tokenKey)
+
+	return hex.EncodeToString(hash[:])
+}
+
+func requestUsername(ctx gocontext.Context) string {
+	username, _ := ctx.Value(context.RequestContextKeyUsername).(string)
+
+	return username
+}
@@ -0,0 +1,103 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/naoina/denco"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+)
+
+func (secretManager *SecretManager) registerWrappingEndpoints(mux *denco.Mux) []denco.Handler {
+	// swagger:route POST /sys/wrapping/wrap wrapping Wrap
+	//
+	// Wraps a secret or a literal for a single retrieval using the returned token
+	//
+	//	Responses:
+	//		201: WrapResponse
+	wrap := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		wrapRequest, err := model.ExtractAndValidateWrapRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		wrapResponse, err := secretManager.Wrap(r.Context(), wrapRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, wrapResponse, http.StatusCreated); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	// swagger:route POST /sys/wrapping/unwrap wrapping Unwrap
+	//
+	// Unwraps a wrapped value using its token; further attempts fail with 410 Gone
+	//
+	//	Responses:
+	//		200: UnwrapResponse
+	unwrap := func(w http.ResponseWriter, r *http.Request, params denco.Params) {
+		unwrapRequest, err := model.ExtractAndValidateUnwrapRequest(r)
+		if err != nil {
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		unwrapResponse, err := secretManager.Unwrap(r.Context(), unwrapRequest)
+		if err != nil {
+			log.Printf("Error: %s\n", err.Error())
+			if e := util.WriteErrorResponse(w, err); e != nil {
+				log.Printf("failed to write error response: %v\n", e)
+			}
+			return
+		}
+
+		if e := util.WriteResponse(w, unwrapResponse, http.StatusOK); e != nil {
+			log.Printf("failed to write response: %v\n", e)
+		}
+	}
+
+	handlers := []denco.Handler{
+		mux.POST("/sys/wrapping/wrap", wrap),
+		mux.POST("/sys/wrapping/unwrap", unwrap),
+	}
+
+	return handlers
+}
+
+// swagger:parameters Wrap
+type WrapRequestParam struct {
+	// in:body
+	WrapRequest model.WrapRequest
+}
+
+// swagger:response WrapResponse
+type WrapResponse struct {
+	// in:body
+	WrapResponse model.WrapResponse
+}
+
+// swagger:parameters Unwrap
+type UnwrapRequestParam struct {
+	// in:body
+	UnwrapRequest model.UnwrapRequest
+}
+
+// swagger:response UnwrapResponse
+type UnwrapResponse struct {
+	// in:body
+	UnwrapResponse model.UnwrapResponse
+}
@@ -0,0 +1,73 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/model"
+)
+
+func TestAPIWrapAndUnwrap(t *testing.T) {
+	wrapRequest := &model.WrapRequest{Data: []byte("api wrap")}
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(wrapRequest); err != nil {
+		t.Fatalf("failed to marshal wrap request %v: %v", wrapRequest, err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%v/sys/wrapping/wrap", ts.URL), "application/json", body)
+	if err != nil {
+		t.Fatalf("Failed to wrap: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Response status is different than 201 StatusCreated: %v", resp.Status)
+	}
+
+	var wrapResponse model.WrapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&wrapResponse); err != nil {
+		t.Fatalf("Failed to parse wrap response: %v", err)
+	}
+
+	unwrap := func() *http.Response {
+		unwrapRequest := &model.UnwrapRequest{Token: wrapResponse.Token}
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(unwrapRequest); err != nil {
+			t.Fatalf("failed to marshal unwrap request %v: %v", unwrapRequest, err)
+		}
+
+		resp, err := http.Post(fmt.Sprintf("%v/sys/wrapping/unwrap", ts.URL), "application/json", body)
+		if err != nil {
+			t.Fatalf("Failed to unwrap: %v", err)
+		}
+
+		return resp
+	}
+
+	resp2 := unwrap()
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp2.Status)
+	}
+
+	var unwrapResponse model.UnwrapResponse
+	if err := json.NewDecoder(resp2.Body).Decode(&unwrapResponse); err != nil {
+		t.Fatalf("Failed to parse unwrap response: %v", err)
+	}
+	if string(unwrapResponse.Data) != "api wrap" {
+		t.Fatalf("Unexpected unwrapped data: %v", string(unwrapResponse.Data))
+	}
+
+	resp3 := unwrap()
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusGone {
+		t.Fatalf("Response status is different than 410 StatusGone: %v", resp3.Status)
+	}
+}
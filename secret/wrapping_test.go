@@ -0,0 +1,170 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestWrapAndUnwrap(t *testing.T) {
+	data := []byte("the database password is hunter2")
+
+	wrapResponse, err := sm.Wrap(context.GetTestRequestContext(), &model.WrapRequest{Data: data, TTL: "5m"})
+	if err != nil {
+		t.Fatalf("Failed to wrap: %v", err)
+	}
+	if wrapResponse.Token == "" || wrapResponse.ExpirationTime.After(time.Now().Add(5*time.Minute)) {
+		t.Fatalf("Unexpected wrap response: %v", wrapResponse)
+	}
+
+	unwrapResponse, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: wrapResponse.Token})
+	if err != nil {
+		t.Fatalf("Failed to unwrap: %v", err)
+	}
+	if !bytes.Equal(unwrapResponse.Data, data) || unwrapResponse.SecretId != "" {
+		t.Fatalf("Unexpected unwrap response: %v", unwrapResponse)
+	}
+
+	// tokens are single-use
+	if _, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: wrapResponse.Token}); err != util.ErrAlreadyUsed {
+		t.Fatalf("Unexpected error when unwrapping a second time: %v", err)
+	}
+}
+
+func TestConcurrentUnwrap(t *testing.T) {
+	wrapResponse, err := sm.Wrap(context.GetTestRequestContext(), &model.WrapRequest{Data: []byte("concurrent")})
+	if err != nil {
+		t.Fatalf("Failed to wrap: %v", err)
+	}
+
+	// both attempts read the wrapped entry before either marks it as unwrapped, as
+	// attempts on different servers may
+	dataStore := sm.dataStore
+	sm.dataStore = &concurrentReadsDS{DataStoreAdapter: dataStore, readers: 2, allRead: make(chan struct{})}
+	defer func() { sm.dataStore = dataStore }()
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: wrapResponse.Token})
+		}(i)
+	}
+	wg.Wait()
+
+	// only one of them succeeds
+	if !(errs[0] == nil && errs[1] == util.ErrAlreadyUsed) && !(errs[0] == util.ErrAlreadyUsed && errs[1] == nil) {
+		t.Fatalf("Unexpected errors when unwrapping concurrently: %v", errs)
+	}
+}
+
+// concurrentReadsDS lets the first readers of an entry all read it before any proceeds.
+type concurrentReadsDS struct {
+	vds.DataStoreAdapter
+	readers int
+	reads   int
+	mutex   sync.Mutex
+	allRead chan struct{}
+}
+
+func (ds *concurrentReadsDS) ReadEntry(entryId string) (*vds.DataStoreEntry, error) {
+	entry, err := ds.DataStoreAdapter.ReadEntry(entryId)
+
+	ds.mutex.Lock()
+	ds.reads++
+	if ds.reads == ds.readers {
+		close(ds.allRead)
+	}
+	ds.mutex.Unlock()
+
+	<-ds.allRead
+
+	return entry, err
+}
+
+func TestWrapSecret(t *testing.T) {
+	secretId, err := createDataSecret("wrap-data-id0", "wrapped secret data")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), secretId)
+
+	wrapResponse, err := sm.Wrap(context.GetTestRequestContext(), &model.WrapRequest{SecretId: secretId})
+	if err != nil {
+		t.Fatalf("Failed to wrap secret: %v", err)
+	}
+
+	unwrapResponse, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: wrapResponse.Token})
+	if err != nil {
+		t.Fatalf("Failed to unwrap: %v", err)
+	}
+	if string(unwrapResponse.Data) != "wrapped secret data" || unwrapResponse.SecretId != secretId || unwrapResponse.SecretVersion != model.FirstSecretVersion {
+		t.Fatalf("Unexpected unwrap response: %v", unwrapResponse)
+	}
+}
+
+func TestWrapBadRequest(t *testing.T) {
+	keyId, err := createSymmetricKey("wrap-symmetric-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create symmetric key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), keyId)
+
+	for _, wrapRequest := range []*model.WrapRequest{
+		&model.WrapRequest{Data: []byte("data"), TTL: "forever"},
+		&model.WrapRequest{Data: []byte("data"), TTL: "48h"},
+		// keys which never leave the server can't be wrapped
+		&model.WrapRequest{SecretId: keyId},
+	} {
+		if _, err := sm.Wrap(context.GetTestRequestContext(), wrapRequest); err != util.ErrInputValidation {
+			t.Fatalf("Unexpected error when wrapping %v: %v", wrapRequest, err)
+		}
+	}
+
+	if _, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: "not-a-token"}); err != util.ErrInputValidation {
+		t.Fatalf("Unexpected error when unwrapping a malformed token: %v", err)
+	}
+}
+
+func TestPruneWrappedEntries(t *testing.T) {
+	unwrappedResponse, err := sm.Wrap(context.GetTestRequestContext(), &model.WrapRequest{Data: []byte("unwrapped")})
+	if err != nil {
+		t.Fatalf("Failed to wrap: %v", err)
+	}
+	if _, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: unwrappedResponse.Token}); err != nil {
+		t.Fatalf("Failed to unwrap: %v", err)
+	}
+
+	expiringResponse, err := sm.Wrap(context.GetTestRequestContext(), &model.WrapRequest{Data: []byte("expiring"), TTL: "1m"})
+	if err != nil {
+		t.Fatalf("Failed to wrap: %v", err)
+	}
+
+	// values which weren't unwrapped in time are deleted
+	sm.pruneWrappedEntries(time.Now().Add(2 * time.Minute))
+
+	if _, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: expiringResponse.Token}); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when unwrapping an expired token: %v", err)
+	}
+
+	// unwrapped entries are kept for detecting further attempts, until the retention period passes
+	if _, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: unwrappedResponse.Token}); err != util.ErrAlreadyUsed {
+		t.Fatalf("Unexpected error when unwrapping a second time: %v", err)
+	}
+
+	sm.pruneWrappedEntries(time.Now().Add(sm.eventRetention + time.Minute))
+
+	if _, err := sm.Unwrap(context.GetTestRequestContext(), &model.UnwrapRequest{Token: unwrappedResponse.Token}); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when unwrapping a pruned token: %v", err)
+	}
+}
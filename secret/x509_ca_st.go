@@ -0,0 +1,491 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+	"github.com/vmware/virtual-security-module/vks"
+)
+
+const X509CertificateAuthoritySecretTypeName = "X509CertificateAuthority"
+
+const (
+	X509CAKeyTypeRSA   = "rsa"
+	X509CAKeyTypeECDSA = "ecdsa"
+
+	defaultX509CARSAKeyLength = 3072
+
+	// Validity of CA certificates, unless specified otherwise.
+	DefaultCACertTTL = 5 * 365 * 24 * time.Hour
+)
+
+func init() {
+	if err := SecretTypeRegistrar.Register(X509CertificateAuthoritySecretTypeName, NewX509CertificateAuthoritySecretType()); err != nil {
+		panic(fmt.Sprintf("Failed to register secret type %v: %v", X509CertificateAuthoritySecretTypeName, err))
+	}
+	if err := SecretTypeRegistrar.RegisterSchema(X509CertificateAuthoritySecretTypeName, x509CAMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", X509CertificateAuthoritySecretTypeName, err))
+	}
+}
+
+// A secret type whose data is the private key of an intermediate CA, which issues the
+// certificates of the namespace holding it and of its sub-namespaces (unless they hold
+// their own intermediate CA). A namespace holds at most one intermediate CA, whose
+// certificate is issued by the nearest CA up the namespace tree, or by the root CA.
+// The private key never leaves the server: reading the secret returns the CA's
+// certificate chain, up to (but excluding) the root CA's certificate.
+type X509CertificateAuthoritySecretType struct {
+	dataStore vds.DataStoreAdapter
+	keyStore  *vks.VirtualKeyStore
+	cfg       *config.Config
+}
+
+type X509CertificateAuthoritySecretMetaData struct {
+	CommonName         string `json:"commonName"`
+	Organization       string `json:"organization"`
+	OrganizationalUnit string `json:"organizationalUnit"`
+	Country            string `json:"country"`
+	Locality           string `json:"locality"`
+	// One of: rsa (default), ecdsa
+	KeyType   string `json:"keyType"`
+	KeyLength int    `json:"keyLength"`
+	// CA certificate validity, e.g. 17520h (default: 43800h, i.e. five years), capped by the issuer's
+	TTL string `json:"ttl"`
+	// Path length constraint (default: unlimited, or as allowed by the issuer)
+	MaxPathLen *int `json:"maxPathLen,omitempty"`
+
+	// Set by the server: the id of the issuing CA secret (empty for the root CA), and the
+	// PEM-encoded certificate chain, starting with the CA's own certificate
+	IssuerId         string `json:"issuerId"`
+	CertificateChain string `json:"certificateChain"`
+}
+
+var x509CAMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"commonName":         {Type: model.SchemaTypeString, Description: "CA subject common name"},
+		"organization":       {Type: model.SchemaTypeString, Description: "CA subject organization"},
+		"organizationalUnit": {Type: model.SchemaTypeString, Description: "CA subject organizational unit"},
+		"country":            {Type: model.SchemaTypeString, Description: "CA subject country"},
+		"locality":           {Type: model.SchemaTypeString, Description: "CA subject locality"},
+		"keyType":            {Type: model.SchemaTypeString, Description: "CA key type (default: rsa)", Enum: []string{X509CAKeyTypeRSA, X509CAKeyTypeECDSA}},
+		"keyLength":          {Type: model.SchemaTypeInteger, Description: "CA key length in bits: 2048-4096 for rsa, 256 or 384 for ecdsa", Minimum: model.SchemaBound(0)},
+		"ttl":                {Type: model.SchemaTypeString, Description: "CA certificate validity (default: 43800h)", Format: model.SchemaFormatDuration},
+		"maxPathLen":         {Type: model.SchemaTypeInteger, Description: "path length constraint (default: unlimited, or as allowed by the issuer)", Minimum: model.SchemaBound(0)},
+		"issuerId":           {Type: model.SchemaTypeString, Description: "id of the issuing CA secret", ReadOnly: true},
+		"certificateChain":   {Type: model.SchemaTypeString, Description: "PEM-encoded certificate chain", ReadOnly: true},
+	},
+	Required: []string{"commonName", "organization"},
+}
+
+// certIssuer is a CA which issues certificates: either the root CA or an intermediate CA secret.
+type certIssuer struct {
+	// empty for the root CA
+	id      string
+	cert    *x509.Certificate
+	privKey crypto.Signer
+	// PEM-encoded certificates of the issuer and its ancestors, excluding the root CA
+	chainPEM []byte
+}
+
+func NewX509CertificateAuthoritySecretType() *X509CertificateAuthoritySecretType {
+	return &X509CertificateAuthoritySecretType{}
+}
+
+func (caST *X509CertificateAuthoritySecretType) Type() string {
+	return X509CertificateAuthoritySecretTypeName
+}
+
+func (caST *X509CertificateAuthoritySecretType) Description() string {
+	return "An X.509 certificate authority, issuing certificates"
+}
+
+func (caST *X509CertificateAuthoritySecretType) SecretDataDescription() string {
+	return ""
+}
+
+func (caST *X509CertificateAuthoritySecretType) Init(moduleInitContext *context.ModuleInitContext) error {
+	caST.dataStore = moduleInitContext.DataStore
+	caST.keyStore = moduleInitContext.VirtualKeyStore
+	caST.cfg = moduleInitContext.Config
+
+	return nil
+}
+
+// Validate checks that a CA secret has no data, as its private key is generated by the
+// server, and that its key length suits its key type.
+func (caST *X509CertificateAuthoritySecretType) Validate(secretEntry *model.SecretEntry) error {
+	fieldErrors := make([]util.FieldError, 0)
+
+	if len(secretEntry.SecretData) > 0 {
+		fieldErrors = append(fieldErrors, util.FieldError{Field: "secretData", Message: "is generated by the server"})
+	}
+
+	// the metadata was checked against the type's schema
+	var caMetaData X509CertificateAuthoritySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &caMetaData); err == nil {
+		switch caMetaData.KeyType {
+		case "", X509CAKeyTypeRSA:
+			if caMetaData.KeyLength != 0 && (caMetaData.KeyLength < 2048 || caMetaData.KeyLength > 4096) {
+				fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.keyLength", Message: "should be between 2048 and 4096 for rsa keys"})
+			}
+		case X509CAKeyTypeECDSA:
+			if caMetaData.KeyLength != 0 && caMetaData.KeyLength != 256 && caMetaData.KeyLength != 384 {
+				fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.keyLength", Message: "should be 256 or 384 for ecdsa keys"})
+			}
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return util.NewValidationError(fieldErrors)
+	}
+
+	return nil
+}
+
+func (caST *X509CertificateAuthoritySecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	// we expect the input to contain no data, as we're generating the data
+	// (the CA's private key) in this case
+	if len(secretEntry.SecretData) > 0 {
+		return "", util.ErrInputValidation
+	}
+
+	caEntry, err := findCertificateAuthorityEntry(caST.dataStore, path.Dir(vds.SecretIdToPath(secretEntry.Id)), "")
+	if err != nil {
+		return "", err
+	}
+	if caEntry != nil {
+		return "", util.ErrAlreadyExists
+	}
+
+	var caMetaData X509CertificateAuthoritySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &caMetaData); err != nil {
+		return "", util.ErrInputValidation
+	}
+
+	privKey, err := generateCAPrivateKey(caMetaData.KeyType, caMetaData.KeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	pkPEM, err := encodePKCS8PrivateKey(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(pkPEM)
+
+	se, issuerId, err := caST.issueCACert(secretEntry, &caMetaData, privKey)
+	if err != nil {
+		return "", err
+	}
+
+	if err := createEncryptedSecret(caST.dataStore, caST.keyStore, se, pkPEM); err != nil {
+		return "", err
+	}
+
+	if err := recordIssuedCert(caST.dataStore, se.Id, issuerId, []byte(caMetaData.CertificateChain)); err != nil {
+		log.Printf("failed to register certificate of secret %v: %v\n", se.Id, err)
+	}
+
+	return se.Id, nil
+}
+
+// GetSecret returns the CA's certificate chain; the private key is not returned.
+func (caST *X509CertificateAuthoritySecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
+	caMetaData, err := getCertificateAuthorityMetaData(secretEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	secretEntry.SecretData = []byte(caMetaData.CertificateChain)
+
+	return secretEntry, nil
+}
+
+// UpdateSecret renews the CA's certificate, keeping its private key, so that the
+// certificates it issued remain valid.
+func (caST *X509CertificateAuthoritySecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if len(secretEntry.SecretData) > 0 {
+		return "", util.ErrInputValidation
+	}
+
+	// the current version of the secret is still in place
+	currentEntry, err := getCurrentSecretEntry(caST.dataStore, secretEntry.Id)
+	if err != nil {
+		return "", err
+	}
+
+	pkPEM, err := decryptSecret(caST.keyStore, currentEntry)
+	if err != nil {
+		return "", err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(pkPEM)
+
+	privKey, err := parsePrivateKeyPEM(pkPEM)
+	if err != nil {
+		return "", util.ErrInternal
+	}
+
+	var caMetaData X509CertificateAuthoritySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &caMetaData); err != nil {
+		return "", util.ErrInputValidation
+	}
+
+	se, issuerId, err := caST.issueCACert(secretEntry, &caMetaData, privKey)
+	if err != nil {
+		return "", err
+	}
+
+	if err := updateEncryptedSecret(caST.dataStore, caST.keyStore, se, pkPEM); err != nil {
+		return "", err
+	}
+
+	if err := recordIssuedCert(caST.dataStore, se.Id, issuerId, []byte(caMetaData.CertificateChain)); err != nil {
+		log.Printf("failed to register certificate of secret %v: %v\n", se.Id, err)
+	}
+
+	return se.Id, nil
+}
+
+// RotateSecret renews the CA's certificate.
+func (caST *X509CertificateAuthoritySecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return caST.UpdateSecret(ctx, secretEntry)
+}
+
+func (caST *X509CertificateAuthoritySecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	return deleteEncryptedSecret(caST.dataStore, caST.keyStore, secretEntry)
+}
+
+// issueCACert issues the CA's certificate using the nearest CA up the namespace tree,
+// and returns the secret entry whose meta-data holds the CA's certificate chain, along
+// with the issuer's id.
+func (caST *X509CertificateAuthoritySecretType) issueCACert(secretEntry *model.SecretEntry, caMetaData *X509CertificateAuthoritySecretMetaData, privKey crypto.Signer) (*model.SecretEntry, string, error) {
+	ttl := caMetaData.TTL
+	if ttl == "" {
+		ttl = DefaultCACertTTL.String()
+	}
+
+	certMetaData := &X509CertificateSecretMetaData{
+		CommonName:         caMetaData.CommonName,
+		Organization:       caMetaData.Organization,
+		OrganizationalUnit: caMetaData.OrganizationalUnit,
+		Country:            caMetaData.Country,
+		Locality:           caMetaData.Locality,
+		TTL:                ttl,
+		IsCA:               true,
+		MaxPathLen:         caMetaData.MaxPathLen,
+	}
+
+	subject, err := getCertSubject(certMetaData)
+	if err != nil {
+		return nil, "", err
+	}
+
+	issuer, err := findCertIssuer(caST.dataStore, caST.keyStore, caST.cfg, secretEntry.Id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	certPEM, err := issueCert(caST.dataStore, caST.cfg, issuer, secretEntry.Id, subject, certMetaData, privKey.Public())
+	if err != nil {
+		return nil, "", err
+	}
+
+	caMetaData.IssuerId = issuer.id
+	caMetaData.CertificateChain = string(certPEM)
+	metaDataBytes, err := json.Marshal(caMetaData)
+	if err != nil {
+		return nil, "", util.ErrInternal
+	}
+
+	se := model.NewSecretEntry(secretEntry)
+	se.MetaData = string(metaDataBytes)
+
+	return se, issuer.id, nil
+}
+
+// findCertIssuer returns the CA issuing the certificates of the given secret: the
+// intermediate CA of the nearest namespace up the tree which holds one (other than the
+// secret itself), or the root CA.
+func findCertIssuer(dataStore vds.DataStoreAdapter, keyStore *vks.VirtualKeyStore, cfg *config.Config, secretId string) (*certIssuer, error) {
+	for namespacePath := path.Dir(vds.SecretIdToPath(secretId)); ; namespacePath = path.Dir(namespacePath) {
+		caEntry, err := findCertificateAuthorityEntry(dataStore, namespacePath, secretId)
+		if err != nil {
+			return nil, err
+		}
+		if caEntry != nil {
+			return getCertificateAuthorityIssuer(keyStore, caEntry)
+		}
+
+		if namespacePath == vds.SecretsRootPath || namespacePath == "/" {
+			break
+		}
+	}
+
+	return getRootCertIssuer(cfg)
+}
+
+// getCertIssuer returns the CA with the given id: the root CA if the id is empty, or
+// the intermediate CA secret with the given id.
+func getCertIssuer(dataStore vds.DataStoreAdapter, keyStore *vks.VirtualKeyStore, cfg *config.Config, issuerId string) (*certIssuer, error) {
+	if issuerId == "" {
+		return getRootCertIssuer(cfg)
+	}
+
+	caEntry, err := getCurrentSecretEntry(dataStore, issuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if caEntry.Type != X509CertificateAuthoritySecretTypeName {
+		return nil, util.ErrNotFound
+	}
+
+	return getCertificateAuthorityIssuer(keyStore, caEntry)
+}
+
+func getRootCertIssuer(cfg *config.Config) (*certIssuer, error) {
+	caCert, caPrivKey, err := getCACertAndKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certIssuer{
+		cert:     caCert,
+		privKey:  caPrivKey,
+		chainPEM: []byte{},
+	}, nil
+}
+
+func getCertificateAuthorityIssuer(keyStore *vks.VirtualKeyStore, caEntry *model.SecretEntry) (*certIssuer, error) {
+	caMetaData, err := getCertificateAuthorityMetaData(caEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	caCert, err := parseCertificate([]byte(caMetaData.CertificateChain))
+	if err != nil {
+		return nil, err
+	}
+
+	pkPEM, err := decryptSecret(keyStore, caEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(pkPEM)
+
+	privKey, err := parsePrivateKeyPEM(pkPEM)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &certIssuer{
+		id:       caEntry.Id,
+		cert:     caCert,
+		privKey:  privKey,
+		chainPEM: []byte(caMetaData.CertificateChain),
+	}, nil
+}
+
+// findCertificateAuthorityEntry returns the intermediate CA secret held by the given
+// namespace, other than the secret with id excludedId, or nil if it holds none.
+func findCertificateAuthorityEntry(dataStore vds.DataStoreAdapter, namespacePath string, excludedId string) (*model.SecretEntry, error) {
+	childEntries, err := dataStore.SearchChildEntries(namespacePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, childEntry := range childEntries {
+		if !vds.IsSecretEntry(childEntry) {
+			continue
+		}
+
+		secretEntry, err := vds.DataStoreEntryToSecretEntry(childEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		if secretEntry.Type == X509CertificateAuthoritySecretTypeName && secretEntry.Id != excludedId {
+			return secretEntry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func getCurrentSecretEntry(dataStore vds.DataStoreAdapter, secretId string) (*model.SecretEntry, error) {
+	dataStoreEntry, err := dataStore.ReadEntry(vds.SecretIdToPath(secretId))
+	if err != nil {
+		return nil, err
+	}
+
+	return vds.DataStoreEntryToSecretEntry(dataStoreEntry)
+}
+
+func getCertificateAuthorityMetaData(secretEntry *model.SecretEntry) (*X509CertificateAuthoritySecretMetaData, error) {
+	var caMetaData X509CertificateAuthoritySecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &caMetaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	return &caMetaData, nil
+}
+
+func generateCAPrivateKey(keyType string, keyLength int) (crypto.Signer, error) {
+	switch keyType {
+	case "", X509CAKeyTypeRSA:
+		if keyLength == 0 {
+			keyLength = defaultX509CARSAKeyLength
+		}
+		if keyLength < 2048 || keyLength > 4096 {
+			return nil, util.ErrInputValidation
+		}
+
+		return rsa.GenerateKey(rand.Reader, keyLength)
+	case X509CAKeyTypeECDSA:
+		var curve elliptic.Curve
+		switch keyLength {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			return nil, util.ErrInputValidation
+		}
+
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	default:
+		return nil, util.ErrInputValidation
+	}
+}
+
+// appendCertChain returns the PEM encoding of the certificate, followed by the given chain.
+func appendCertChain(certDER []byte, chainPEM []byte) []byte {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	return append(certPEM, chainPEM...)
+}
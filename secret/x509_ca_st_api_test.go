@@ -0,0 +1,64 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/virtual-security-module/context"
+)
+
+func TestAPIGetCACRL(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("api-ca-ns0", testCAIssuancePolicy)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	caChain, err := createIntermediateCA("api-ca-ns0/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "api-ca-ns0 CA",
+		Organization: "Test Examples",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "api-ca-ns0/ca")
+
+	resp, err := http.Get(fmt.Sprintf("%v/pki/crl/api-ca-ns0/ca", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to get CRL: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/pkix-crl" {
+		t.Fatalf("Unexpected CRL response: %v %v", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	crlDER, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read CRL: %v", err)
+	}
+
+	crl, err := x509.ParseDERCRL(crlDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: %v", err)
+	}
+	if err := caChain[0].CheckCRLSignature(crl); err != nil {
+		t.Fatalf("CRL is not signed by the intermediate CA: %v", err)
+	}
+
+	// there's no CRL for secrets which aren't CAs
+	notFoundResp, err := http.Get(fmt.Sprintf("%v/pki/crl/api-ca-ns0/no-such-ca", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to get CRL: %v", err)
+	}
+	defer notFoundResp.Body.Close()
+
+	if notFoundResp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Response status is different than 404 StatusNotFound: %v", notFoundResp.Status)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Creating intermediate CAs takes an issuance policy allowing CA certificates.
+var testCAIssuancePolicy = &model.CertIssuancePolicy{
+	AllowedDomains:  []string{"example.com"},
+	AllowSubdomains: true,
+	AllowCA:         true,
+}
+
+func TestCreateIntermediateCA(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("ca-ns0", testCAIssuancePolicy)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	caChain, err := createIntermediateCA("ca-ns0/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns0 CA",
+		Organization: "Test Examples",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns0/ca")
+
+	rootCert, _, err := getCACertAndKey(config.GenerateTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+
+	if len(caChain) != 1 || !caChain[0].IsCA || caChain[0].Subject.CommonName != "ca-ns0 CA" {
+		t.Fatalf("Unexpected intermediate CA certificate chain: %v", caChain)
+	}
+	if err := caChain[0].CheckSignatureFrom(rootCert); err != nil {
+		t.Fatalf("Intermediate CA certificate is not signed by the root CA: %v", err)
+	}
+
+	// certificates of the namespace and its sub-namespaces are issued by the intermediate CA
+	for _, id := range []string{"ca-ns0/leaf0", "ca-ns0/sub/leaf1"} {
+		chain, err := signTestCSRChain(id, "leaf.example.com")
+		if err != nil {
+			t.Fatalf("Failed to issue certificate: %v", err)
+		}
+		defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+		if len(chain) != 2 || !chain[1].Equal(caChain[0]) {
+			t.Fatalf("Unexpected certificate chain of %v: %v", id, chain)
+		}
+		if err := chain[0].CheckSignatureFrom(caChain[0]); err != nil {
+			t.Fatalf("Certificate %v is not signed by the intermediate CA: %v", id, err)
+		}
+		if len(chain[0].CRLDistributionPoints) != 1 || chain[0].CRLDistributionPoints[0] != "https://localhost:8443/pki/crl/ca-ns0/ca" {
+			t.Fatalf("Unexpected CRL distribution points: %v", chain[0].CRLDistributionPoints)
+		}
+	}
+
+	// certificates of other namespaces are still issued by the root CA
+	cert, err := signTestCSR("ca-other-ns/leaf2", "leaf.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-other-ns/leaf2")
+
+	if err := cert.CheckSignatureFrom(rootCert); err != nil {
+		t.Fatalf("Certificate is not signed by the root CA: %v", err)
+	}
+
+	// a namespace holds at most one intermediate CA
+	_, err = createIntermediateCA("ca-ns0/ca2", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns0 CA 2",
+		Organization: "Test Examples",
+	})
+	if err != util.ErrAlreadyExists {
+		t.Fatalf("Unexpected error when creating a second intermediate CA: %v", err)
+	}
+}
+
+func TestCreateIntermediateCAHierarchy(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("ca-ns1", testCAIssuancePolicy)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	maxPathLen := 1
+	caChain, err := createIntermediateCA("ca-ns1/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns1 CA",
+		Organization: "Test Examples",
+		KeyType:      X509CAKeyTypeECDSA,
+		MaxPathLen:   &maxPathLen,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns1/ca")
+
+	subCAChain, err := createIntermediateCA("ca-ns1/sub/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns1/sub CA",
+		Organization: "Test Examples",
+		TTL:          "87600h",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sub-namespace intermediate CA: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns1/sub/ca")
+
+	// the sub-namespace's CA is issued by the namespace's CA, and constrained by it
+	if len(subCAChain) != 2 || !subCAChain[1].Equal(caChain[0]) {
+		t.Fatalf("Unexpected sub-namespace CA certificate chain: %v", subCAChain)
+	}
+	if err := subCAChain[0].CheckSignatureFrom(caChain[0]); err != nil {
+		t.Fatalf("Sub-namespace CA certificate is not signed by the namespace's CA: %v", err)
+	}
+	if !subCAChain[0].MaxPathLenZero || subCAChain[0].NotAfter.After(caChain[0].NotAfter) {
+		t.Fatalf("Sub-namespace CA certificate isn't constrained by its issuer")
+	}
+
+	chain, err := signTestCSRChain("ca-ns1/sub/leaf0", "leaf.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns1/sub/leaf0")
+
+	if len(chain) != 3 || chain[0].CheckSignatureFrom(subCAChain[0]) != nil {
+		t.Fatalf("Unexpected certificate chain: %v", chain)
+	}
+
+	// the path length constraint of the sub-namespace's CA rules out further CAs
+	_, err = createIntermediateCA("ca-ns1/sub/sub/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns1/sub/sub CA",
+		Organization: "Test Examples",
+	})
+	if err != util.ErrUnauthorized {
+		t.Fatalf("Unexpected error when creating a CA exceeding the path length constraint: %v", err)
+	}
+}
+
+func TestRenewIntermediateCA(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("ca-ns2", testCAIssuancePolicy)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	caChain, err := createIntermediateCA("ca-ns2/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns2 CA",
+		Organization: "Test Examples",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns2/ca")
+
+	if _, err := sm.RotateSecret(context.GetTestRequestContext(), "ca-ns2/ca"); err != nil {
+		t.Fatalf("Failed to renew intermediate CA: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), "ca-ns2/ca")
+	if err != nil {
+		t.Fatalf("Failed to get intermediate CA: %v", err)
+	}
+
+	renewedChain, err := parseCertificateChainPEM(se.SecretData)
+	if err != nil {
+		t.Fatalf("Failed to parse renewed CA certificate chain: %v", err)
+	}
+
+	// the key is kept, so that certificates issued by the CA remain valid
+	if renewedChain[0].SerialNumber.Cmp(caChain[0].SerialNumber) == 0 || !publicKeysEqual(renewedChain[0].PublicKey, caChain[0].PublicKey) {
+		t.Fatalf("Unexpected renewed CA certificate")
+	}
+}
+
+func TestRevokeIntermediateCAIssuedCertificate(t *testing.T) {
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("ca-ns3", testCAIssuancePolicy)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	caChain, err := createIntermediateCA("ca-ns3/ca", &X509CertificateAuthoritySecretMetaData{
+		CommonName:   "ca-ns3 CA",
+		Organization: "Test Examples",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns3/ca")
+
+	cert, err := signTestCSR("ca-ns3/leaf0", "leaf.example.com")
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), "ca-ns3/leaf0")
+
+	revocationRequest := &model.RevocationRequest{
+		SecretId: "ca-ns3/leaf0",
+		Reason:   "keyCompromise",
+	}
+	certEntry, err := sm.RevokeCertificate(context.GetTestRequestContext(), revocationRequest)
+	if err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+	if certEntry.IssuerId != "ca-ns3/ca" {
+		t.Fatalf("Unexpected certificate issuer: %v", certEntry.IssuerId)
+	}
+
+	// the certificate is listed in the intermediate CA's CRL, rather than in the root CA's
+	crlDER, err := sm.GetCRL("ca-ns3/ca")
+	if err != nil {
+		t.Fatalf("Failed to get CRL: %v", err)
+	}
+
+	crl, err := x509.ParseDERCRL(crlDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: %v", err)
+	}
+	if err := caChain[0].CheckCRLSignature(crl); err != nil {
+		t.Fatalf("CRL is not signed by the intermediate CA: %v", err)
+	}
+	if len(crl.TBSCertList.RevokedCertificates) != 1 || crl.TBSCertList.RevokedCertificates[0].SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Fatalf("Unexpected CRL entries: %v", crl.TBSCertList.RevokedCertificates)
+	}
+
+	if _, err := sm.GetCRL("ca-ns3/leaf0"); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when getting the CRL of a non-CA secret: %v", err)
+	}
+
+	// the OCSP response is signed by the intermediate CA
+	resp, err := respondTestOCSP(cert, caChain[0])
+	if err != nil {
+		t.Fatalf("Failed to get OCSP response: %v", err)
+	}
+	if resp.Status != ocsp.Revoked {
+		t.Fatalf("Unexpected status of revoked certificate: %v", resp.Status)
+	}
+}
+
+func createIntermediateCA(id string, caMetaData *X509CertificateAuthoritySecretMetaData) ([]*x509.Certificate, error) {
+	metaDataBytes, err := json.Marshal(caMetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           X509CertificateAuthoritySecretTypeName,
+		MetaData:       string(metaDataBytes),
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	if _, err := sm.CreateSecret(context.GetTestRequestContext(), se); err != nil {
+		return nil, err
+	}
+
+	se, err = sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCertificateChainPEM(se.SecretData)
+}
+
+func signTestCSRChain(id string, commonName string) ([]*x509.Certificate, error) {
+	_, csrPEM, err := generateTestCSR(commonName, []string{commonName})
+	if err != nil {
+		return nil, err
+	}
+
+	signRequest := &model.CSRSignRequest{
+		Id:  id,
+		CSR: csrPEM,
+	}
+	signResponse, err := sm.SignCSR(context.GetTestRequestContext(), signRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCertificateChainPEM([]byte(signResponse.Certificate))
+}
+
+func parseCertificateChainPEM(chainPEM []byte) ([]*x509.Certificate, error) {
+	chain := make([]*x509.Certificate, 0)
+	for block, rest := pem.Decode(chainPEM); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("failed to decode certificate chain")
+	}
+
+	return chain, nil
+}
@@ -4,20 +4,20 @@ package secret
 
 import (
 	gocontext "context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
+	"log"
 	"math/big"
 	"path"
 	"time"
 
 	"github.com/vmware/virtual-security-module/config"
 	"github.com/vmware/virtual-security-module/context"
-	"github.com/vmware/virtual-security-module/crypt"
 	"github.com/vmware/virtual-security-module/model"
 	"github.com/vmware/virtual-security-module/util"
 	"github.com/vmware/virtual-security-module/vds"
@@ -30,6 +30,9 @@ func init() {
 	if err := SecretTypeRegistrar.Register(X509CertificateSecretTypeName, NewX509CertificateSecretType()); err != nil {
 		panic(fmt.Sprintf("Failed to register secret type %v: %v", X509CertificateSecretTypeName, err))
 	}
+	if err := SecretTypeRegistrar.RegisterSchema(X509CertificateSecretTypeName, x509CertificateMetaDataSchema); err != nil {
+		panic(fmt.Sprintf("Failed to register the metadata schema of secret type %v: %v", X509CertificateSecretTypeName, err))
+	}
 }
 
 type X509CertificateSecretType struct {
@@ -45,7 +48,60 @@ type X509CertificateSecretMetaData struct {
 	OrganizationalUnit string `json:"organizationalUnit"`
 	Country            string `json:"country"`
 	Locality           string `json:"locality"`
-	PrivateKeyId       string `json:"privateKeyId"`
+	// The subject's public key is taken either from a private key secret or from a
+	// PEM-encoded PKCS#10 certificate signing request, whose subject and SANs are used
+	// unless specified otherwise
+	PrivateKeyId string `json:"privateKeyId"`
+	CSR          string `json:"csr"`
+
+	// Subject alternative names
+	DNSNames    []string `json:"dnsNames"`
+	IPAddresses []string `json:"ipAddresses"`
+	URIs        []string `json:"uris"`
+	// Certificate validity, e.g. 2160h (default: 8760h, i.e. a year)
+	TTL string `json:"ttl"`
+	// Key usages (e.g. digitalSignature, keyEncipherment) and extended key usages
+	// (e.g. serverAuth, clientAuth); suitable defaults are used if not specified
+	KeyUsages    []string `json:"keyUsages"`
+	ExtKeyUsages []string `json:"extKeyUsages"`
+	IsCA         bool     `json:"isCA"`
+	// Path length constraint of a CA certificate (default: unlimited)
+	MaxPathLen *int `json:"maxPathLen,omitempty"`
+
+	// Renewal policy: if AutoRenew is set, the certificate is renewed once the given
+	// fraction of its lifetime has passed (default: 2/3), keeping the previous certificate
+	// as a prior version. If Rekey is set, the private key secret is rotated as well,
+	// on behalf of RekeyGrantedBy: the user who set Rekey, which requires update
+	// permission on the private key's namespace.
+	AutoRenew      bool    `json:"autoRenew"`
+	RenewAfter     float64 `json:"renewAfter,omitempty"`
+	Rekey          bool    `json:"rekey"`
+	RekeyGrantedBy string  `json:"rekeyGrantedBy,omitempty"`
+}
+
+var x509CertificateMetaDataSchema = &model.Schema{
+	Type: model.SchemaTypeObject,
+	Properties: map[string]*model.Schema{
+		"commonName":         {Type: model.SchemaTypeString, Description: "subject common name"},
+		"organization":       {Type: model.SchemaTypeString, Description: "subject organization"},
+		"organizationalUnit": {Type: model.SchemaTypeString, Description: "subject organizational unit"},
+		"country":            {Type: model.SchemaTypeString, Description: "subject country"},
+		"locality":           {Type: model.SchemaTypeString, Description: "subject locality"},
+		"privateKeyId":       {Type: model.SchemaTypeString, Description: "id of the private key secret holding the subject's key"},
+		"csr":                {Type: model.SchemaTypeString, Description: "PEM-encoded certificate signing request, instead of a private key secret"},
+		"dnsNames":           {Type: model.SchemaTypeArray, Description: "DNS subject alternative names", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"ipAddresses":        {Type: model.SchemaTypeArray, Description: "IP address subject alternative names", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"uris":               {Type: model.SchemaTypeArray, Description: "URI subject alternative names", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"ttl":                {Type: model.SchemaTypeString, Description: "certificate validity (default: 8760h)", Format: model.SchemaFormatDuration},
+		"keyUsages":          {Type: model.SchemaTypeArray, Description: "key usages, e.g. digitalSignature", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"extKeyUsages":       {Type: model.SchemaTypeArray, Description: "extended key usages, e.g. serverAuth", Items: &model.Schema{Type: model.SchemaTypeString}},
+		"isCA":               {Type: model.SchemaTypeBoolean, Description: "issue a CA certificate"},
+		"maxPathLen":         {Type: model.SchemaTypeInteger, Description: "path length constraint of a CA certificate (default: unlimited)", Minimum: model.SchemaBound(0)},
+		"autoRenew":          {Type: model.SchemaTypeBoolean, Description: "renew the certificate automatically before it expires"},
+		"renewAfter":         {Type: model.SchemaTypeNumber, Description: "fraction of the certificate's lifetime after which it's renewed (default: 0.67)", Minimum: model.SchemaBound(0), Maximum: model.SchemaBound(1)},
+		"rekey":              {Type: model.SchemaTypeBoolean, Description: "rotate the private key when renewing the certificate"},
+		"rekeyGrantedBy":     {Type: model.SchemaTypeString, Description: "user on whose behalf the private key is rotated", ReadOnly: true},
+	},
 }
 
 func NewX509CertificateSecretType() *X509CertificateSecretType {
@@ -56,6 +112,14 @@ func (certST *X509CertificateSecretType) Type() string {
 	return X509CertificateSecretTypeName
 }
 
+func (certST *X509CertificateSecretType) Description() string {
+	return "An X.509 certificate, issued by the server"
+}
+
+func (certST *X509CertificateSecretType) SecretDataDescription() string {
+	return ""
+}
+
 func (certST *X509CertificateSecretType) Init(moduleInitContext *context.ModuleInitContext) error {
 	certST.dataStore = moduleInitContext.DataStore
 	certST.keyStore = moduleInitContext.VirtualKeyStore
@@ -65,129 +129,240 @@ func (certST *X509CertificateSecretType) Init(moduleInitContext *context.ModuleI
 	return nil
 }
 
-func (certST *X509CertificateSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
-	// get certificate meta-data
+// Validate checks that the subject's key of a certificate secret comes from either a
+// private key secret, in which case the subject is taken from the metadata, or a CSR,
+// and that its renewal policy is valid.
+func (certST *X509CertificateSecretType) Validate(secretEntry *model.SecretEntry) error {
+	fieldErrors := make([]util.FieldError, 0)
+
+	// the metadata was checked against the type's schema
 	var certMetaData X509CertificateSecretMetaData
-	if err := json.Unmarshal([]byte(secretEntry.MetaData), &certMetaData); err != nil {
-		return "", util.ErrInputValidation
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &certMetaData); err == nil {
+		switch {
+		case certMetaData.PrivateKeyId == "" && certMetaData.CSR == "":
+			fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.privateKeyId", Message: "is required, unless metaData.csr is set"})
+		case certMetaData.PrivateKeyId != "" && certMetaData.CSR != "":
+			fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.csr", Message: "can't be set along with metaData.privateKeyId"})
+		case certMetaData.PrivateKeyId != "":
+			if certMetaData.CommonName == "" {
+				fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.commonName", Message: "is required, unless metaData.csr is set"})
+			}
+			if certMetaData.Organization == "" {
+				fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.organization", Message: "is required, unless metaData.csr is set"})
+			}
+		}
+
+		fieldErrors = append(fieldErrors, validateRenewalPolicy(&certMetaData)...)
 	}
 
-	// generate encryption key for secret
-	key, err := crypt.GenerateKey()
-	if err != nil {
-		return "", util.ErrInternal
+	if len(fieldErrors) > 0 {
+		return util.NewValidationError(fieldErrors)
 	}
 
-	// reduce key exposure due to memory compromize / leak
-	defer util.Memzero(key)
+	return nil
+}
 
-	// generate secret data (certificate in this case) and encrypt it using key
-	certPEM, err := certST.generateCert(ctx, &certMetaData)
-	if err != nil {
+func (certST *X509CertificateSecretType) CreateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if err := certST.grantRekey(ctx, secretEntry); err != nil {
 		return "", err
 	}
 
-	encryptedSecretData, err := crypt.Encrypt(certPEM, key)
-	if err != nil {
-		return "", util.ErrInternal
-	}
-
-	se := model.NewSecretEntry(secretEntry)
-	se.SecretData = encryptedSecretData
-
-	// create a data store entry and save it
-	dataStoreEntry, err := vds.SecretEntryToDataStoreEntry(se)
+	// generate secret data (certificate in this case)
+	certPEM, issuerId, err := certST.generateCertFromMetaData(ctx, secretEntry)
 	if err != nil {
 		return "", err
 	}
-	if err := certST.dataStore.CreateEntry(dataStoreEntry); err != nil {
+
+	if err := createEncryptedSecret(certST.dataStore, certST.keyStore, secretEntry, certPEM); err != nil {
 		return "", err
 	}
 
-	// persist key using virtual key store
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-	if err := certST.keyStore.Create(secretPath, key); err != nil {
-		return "", err
+	// the certificate is already stored; if it can't be registered, it can still be
+	// revoked by secret id
+	if err := recordIssuedCert(certST.dataStore, secretEntry.Id, issuerId, certPEM); err != nil {
+		log.Printf("failed to register certificate of secret %v: %v\n", secretEntry.Id, err)
 	}
 
 	return secretEntry.Id, nil
 }
 
 func (certST *X509CertificateSecretType) GetSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (*model.SecretEntry, error) {
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
-
-	// fetch encryption key
-	key, err := certST.keyStore.Read(secretPath)
+	certPEM, err := decryptSecret(certST.keyStore, secretEntry)
 	if err != nil {
 		return nil, err
 	}
 
-	// reduce key exposure due to memory compromize / leak
-	defer util.Memzero(key)
+	// set decrypted data
+	secretEntry.SecretData = certPEM
 
-	// decrypt secret data using key
-	certPEM, err := crypt.Decrypt(secretEntry.SecretData, key)
+	return secretEntry, nil
+}
+
+// UpdateSecret issues a new certificate, replacing the current one.
+func (certST *X509CertificateSecretType) UpdateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	if err := certST.grantRekey(ctx, secretEntry); err != nil {
+		return "", err
+	}
+
+	return certST.reissueCert(ctx, secretEntry)
+}
+
+// RotateSecret issues a new certificate, per the secret's current metadata.
+func (certST *X509CertificateSecretType) RotateSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	return certST.reissueCert(ctx, secretEntry)
+}
+
+func (certST *X509CertificateSecretType) reissueCert(ctx gocontext.Context, secretEntry *model.SecretEntry) (string, error) {
+	certPEM, issuerId, err := certST.generateCertFromMetaData(ctx, secretEntry)
 	if err != nil {
-		return nil, util.ErrInternal
+		return "", err
 	}
 
-	// set decrypted data
-	secretEntry.SecretData = certPEM
+	if err := updateEncryptedSecret(certST.dataStore, certST.keyStore, secretEntry, certPEM); err != nil {
+		return "", err
+	}
 
-	return secretEntry, nil
+	// the certificate is already stored; if it can't be registered, it can still be
+	// revoked by secret id
+	if err := recordIssuedCert(certST.dataStore, secretEntry.Id, issuerId, certPEM); err != nil {
+		log.Printf("failed to register certificate of secret %v: %v\n", secretEntry.Id, err)
+	}
+
+	return secretEntry.Id, nil
 }
 
 func (certST *X509CertificateSecretType) DeleteSecret(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
-	secretPath := vds.SecretIdToPath(secretEntry.Id)
+	return deleteEncryptedSecret(certST.dataStore, certST.keyStore, secretEntry)
+}
 
-	if err := certST.dataStore.DeleteEntry(secretPath); err != nil {
-		return err
+// grantRekey records the caller as the user on whose behalf the subject's private key
+// secret is rotated when the certificate is renewed, provided that its renewal policy
+// calls for re-keying and that the caller may update the private key secret.
+func (certST *X509CertificateSecretType) grantRekey(ctx gocontext.Context, secretEntry *model.SecretEntry) error {
+	var certMetaData X509CertificateSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &certMetaData); err != nil {
+		return util.ErrInputValidation
 	}
 
-	if err := certST.keyStore.Delete(secretPath); err != nil {
-		return err
+	if !certMetaData.Rekey && certMetaData.RekeyGrantedBy == "" {
+		return nil
 	}
 
+	certMetaData.RekeyGrantedBy = ""
+	if certMetaData.Rekey {
+		privKeyPath := vds.SecretIdToPath(certMetaData.PrivateKeyId)
+		if err := certST.authzManager.Allowed(ctx, model.Operation{Label: model.OpUpdate}, path.Dir(privKeyPath)); err != nil {
+			return err
+		}
+
+		username, _ := ctx.Value(context.RequestContextKeyUsername).(string)
+		certMetaData.RekeyGrantedBy = username
+	}
+
+	metaDataBytes, err := json.Marshal(certMetaData)
+	if err != nil {
+		return util.ErrInternal
+	}
+	secretEntry.MetaData = string(metaDataBytes)
+
 	return nil
 }
 
-func (certST *X509CertificateSecretType) generateCert(ctx gocontext.Context, certMetaData *X509CertificateSecretMetaData) ([]byte, error) {
-	if certMetaData.PrivateKeyId == "" {
-		return []byte{}, util.ErrInputValidation
+func (certST *X509CertificateSecretType) generateCertFromMetaData(ctx gocontext.Context, secretEntry *model.SecretEntry) ([]byte, string, error) {
+	// get certificate meta-data
+	var certMetaData X509CertificateSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &certMetaData); err != nil {
+		return nil, "", util.ErrInputValidation
+	}
+
+	if fieldErrors := validateRenewalPolicy(&certMetaData); len(fieldErrors) > 0 {
+		return nil, "", util.NewValidationError(fieldErrors)
 	}
 
-	privKey, err := certST.getSubjectPrivKey(ctx, certMetaData.PrivateKeyId)
+	return certST.generateCert(ctx, secretEntry.Id, &certMetaData)
+}
+
+// generateCert issues the certificate described by certMetaData, using the nearest CA up
+// the namespace tree, provided that it complies with the issuance policies of the secret's
+// namespace and of all its ancestor namespaces. It returns the PEM-encoded certificate,
+// followed by the issuer's chain, along with the issuer's id.
+func (certST *X509CertificateSecretType) generateCert(ctx gocontext.Context, secretId string, certMetaData *X509CertificateSecretMetaData) ([]byte, string, error) {
+	subject, pubKey, err := certST.getCertSubjectAndPublicKey(ctx, secretId, certMetaData)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, "", err
 	}
 
-	serialNumber, err := getCertSerialNumber()
+	issuer, err := findCertIssuer(certST.dataStore, certST.keyStore, certST.cfg, secretId)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, "", err
 	}
 
-	subject, err := getCertSubject(certMetaData)
+	certPEM, err := issueCert(certST.dataStore, certST.cfg, issuer, secretId, subject, certMetaData, pubKey)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, "", err
+	}
+
+	return certPEM, issuer.id, nil
+}
+
+// getCertSubjectAndPublicKey returns the certificate's subject and public key, either from
+// the subject's private key secret or from the certificate signing request. In the latter
+// case, the names requested by the CSR must comply with the issuance policies of the
+// secret's namespace and of its ancestors, and the SANs in the request are copied to
+// certMetaData, unless it specifies SANs.
+func (certST *X509CertificateSecretType) getCertSubjectAndPublicKey(ctx gocontext.Context, secretId string, certMetaData *X509CertificateSecretMetaData) (*pkix.Name, crypto.PublicKey, error) {
+	if (certMetaData.PrivateKeyId == "") == (certMetaData.CSR == "") {
+		return nil, nil, util.ErrInputValidation
 	}
 
-	template := getCertTemplate(serialNumber, subject)
+	if certMetaData.CSR != "" {
+		csr, err := parseCSR(certMetaData.CSR)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// a CSR requesting names outside of the policies is refused, even if the issued
+		// certificate's names are overridden
+		for _, policy := range getCertIssuancePolicies(certST.dataStore, secretId) {
+			if err := checkCertNamesIssuancePolicy(policy, csr.Subject.CommonName, csr.DNSNames, csr.IPAddresses, csr.URIs); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if len(certMetaData.DNSNames) == 0 && len(certMetaData.IPAddresses) == 0 && len(certMetaData.URIs) == 0 {
+			certMetaData.DNSNames = csr.DNSNames
+			for _, ip := range csr.IPAddresses {
+				certMetaData.IPAddresses = append(certMetaData.IPAddresses, ip.String())
+			}
+			for _, uri := range csr.URIs {
+				certMetaData.URIs = append(certMetaData.URIs, uri.String())
+			}
+		}
+
+		subject := csr.Subject
+		if certMetaData.CommonName != "" {
+			subject.CommonName = certMetaData.CommonName
+		}
+
+		return &subject, csr.PublicKey, nil
+	}
 
-	caCert, caPrivKey, err := getCACertAndKey(certST.cfg)
+	privKey, err := certST.getSubjectPrivKey(ctx, certMetaData.PrivateKeyId)
 	if err != nil {
-		return []byte{}, err
+		return nil, nil, err
 	}
 
-	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, privKey.Public(), caPrivKey)
+	subject, err := getCertSubject(certMetaData)
 	if err != nil {
-		return []byte{}, err
+		return nil, nil, err
 	}
 
-	block := pem.Block{Type: "CERTIFICATE", Bytes: certDER}
-	return pem.EncodeToMemory(&block), nil
+	return subject, privKey.Public(), nil
 }
 
-func (certST *X509CertificateSecretType) getSubjectPrivKey(ctx gocontext.Context, privKeyId string) (*rsa.PrivateKey, error) {
+// getSubjectPrivKey returns the private key of the certificate's subject, which
+// can be a RSA, ECDSA or Ed25519 private key secret.
+func (certST *X509CertificateSecretType) getSubjectPrivKey(ctx gocontext.Context, privKeyId string) (crypto.Signer, error) {
 	privKeyPath := vds.SecretIdToPath(privKeyId)
 
 	// verify that the caller has access to the private key
@@ -205,24 +380,20 @@ func (certST *X509CertificateSecretType) getSubjectPrivKey(ctx gocontext.Context
 		return nil, err
 	}
 
-	key, err := certST.keyStore.Read(privKeyPath)
-	if err != nil {
-		return nil, err
+	// an expired private key can no longer be used
+	if model.SecretExpired(secretEntry, time.Now()) {
+		return nil, util.ErrNotFound
 	}
 
-	defer util.Memzero(key)
-
-	pkPEM, err := crypt.Decrypt(secretEntry.SecretData, key)
+	pkPEM, err := decryptSecret(certST.keyStore, secretEntry)
 	if err != nil {
-		return nil, util.ErrInternal
+		return nil, err
 	}
 
-	block, _ := pem.Decode(pkPEM)
-	if block == nil {
-		return nil, util.ErrInternal
-	}
+	// reduce key exposure due to memory compromize / leak
+	defer util.Memzero(pkPEM)
 
-	return x509.ParsePKCS1PrivateKey(block.Bytes)
+	return parsePrivateKeyPEM(pkPEM)
 }
 
 func getCertSubject(certMetaData *X509CertificateSecretMetaData) (*pkix.Name, error) {
@@ -244,18 +415,6 @@ func getCertSerialNumber() (*big.Int, error) {
 	return rand.Int(rand.Reader, serialNumberLimit)
 }
 
-func getCertTemplate(serialNumber *big.Int, subject *pkix.Name) *x509.Certificate {
-	return &x509.Certificate{
-		SerialNumber:       serialNumber,
-		Subject:            *subject,
-		SignatureAlgorithm: x509.SHA256WithRSA,
-		NotBefore:          time.Now(),
-		NotAfter:           time.Now().Add(365 * 24 * time.Hour),
-		KeyUsage:           x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
-	}
-}
-
 func getCACertAndKey(cfg *config.Config) (*x509.Certificate, *rsa.PrivateKey, error) {
 	caCertFile := cfg.HttpsConfig.CaCert
 	caKeyFile := cfg.HttpsConfig.CaKey
@@ -3,7 +3,9 @@
 package secret
 
 import (
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"testing"
 	"time"
@@ -63,6 +65,78 @@ func TestCreateAndGetX509CertificateSecret(t *testing.T) {
 	}
 }
 
+func TestCreateX509CertificateSecretWithNonRSAKeys(t *testing.T) {
+	ecdsaPrivKeyId, err := createECDSAPrivKey("ecdsa-priv-key-id1", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+
+	ed25519PrivKeyId, err := createEd25519PrivKey("ed25519-priv-key-id1")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+
+	expectedAlgorithms := map[string]x509.PublicKeyAlgorithm{
+		ecdsaPrivKeyId:   x509.ECDSA,
+		ed25519PrivKeyId: x509.Ed25519,
+	}
+
+	for privKeyId, expectedAlgorithm := range expectedAlgorithms {
+		secretMetaData := X509CertificateSecretMetaData{
+			CommonName:   "test.example.com",
+			Organization: "Test Examples",
+			Country:      "IL",
+			PrivateKeyId: privKeyId,
+		}
+
+		secretMetaDataBytes, err := json.Marshal(secretMetaData)
+		if err != nil {
+			t.Fatalf("Failed to json marshal secret meta-data: %v", err)
+		}
+
+		se := &model.SecretEntry{
+			Id:             "cert-" + privKeyId,
+			Type:           X509CertificateSecretTypeName,
+			MetaData:       string(secretMetaDataBytes),
+			SecretData:     []byte{},
+			Owner:          "user0",
+			ExpirationTime: time.Now().Add(time.Hour),
+		}
+
+		id, err := sm.CreateSecret(context.GetTestRequestContext(), se)
+		if err != nil {
+			t.Fatalf("Failed to create certificate for private key %v: %v", privKeyId, err)
+		}
+
+		se2, err := sm.GetSecret(context.GetTestRequestContext(), id)
+		if err != nil {
+			t.Fatalf("Failed to get secret for id %v: %v", id, err)
+		}
+
+		block, _ := pem.Decode(se2.SecretData)
+		if block == nil {
+			t.Fatalf("Failed to decode returned certificate")
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse returned certificate: %v", err)
+		}
+
+		if cert.PublicKeyAlgorithm != expectedAlgorithm {
+			t.Fatalf("Unexpected certificate public key algorithm: %v", cert.PublicKeyAlgorithm)
+		}
+
+		if err := sm.DeleteSecret(context.GetTestRequestContext(), id); err != nil {
+			t.Fatalf("Failed to delete secret for id %v: %v", id, err)
+		}
+
+		if err := deletePrivKey(privKeyId); err != nil {
+			t.Fatalf("Failed to private key with id %v: %v", privKeyId, err)
+		}
+	}
+}
+
 func createPrivKey() (string, error) {
 	secretMetaData := fmt.Sprintf("{\"keyLength\": %v}", 2048)
 
@@ -0,0 +1,387 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Validity of issued certificates, unless specified otherwise.
+const DefaultCertTTL = 365 * 24 * time.Hour
+
+// getCertTemplate returns the template of the certificate described by certMetaData,
+// for the given subject public key.
+func getCertTemplate(serialNumber *big.Int, subject *pkix.Name, certMetaData *X509CertificateSecretMetaData, pubKey crypto.PublicKey) (*x509.Certificate, error) {
+	ttl := DefaultCertTTL
+	if certMetaData.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(certMetaData.TTL); err != nil || ttl <= 0 {
+			return nil, util.ErrInputValidation
+		}
+	}
+
+	ipAddresses := make([]net.IP, 0, len(certMetaData.IPAddresses))
+	for _, ipStr := range certMetaData.IPAddresses {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return nil, util.ErrInputValidation
+		}
+		ipAddresses = append(ipAddresses, ip)
+	}
+
+	uris := make([]*url.URL, 0, len(certMetaData.URIs))
+	for _, uriStr := range certMetaData.URIs {
+		uri, err := url.Parse(uriStr)
+		if err != nil || uri.Scheme == "" {
+			return nil, util.ErrInputValidation
+		}
+		uris = append(uris, uri)
+	}
+
+	for _, dnsName := range certMetaData.DNSNames {
+		if dnsName == "" || strings.ContainsAny(dnsName, " /:") {
+			return nil, util.ErrInputValidation
+		}
+	}
+
+	keyUsage, err := getCertKeyUsage(certMetaData, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	extKeyUsage, err := getCertExtKeyUsage(certMetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	// a path length constraint only applies to CA certificates
+	maxPathLen := -1
+	if certMetaData.MaxPathLen != nil {
+		if !certMetaData.IsCA || *certMetaData.MaxPathLen < 0 {
+			return nil, util.ErrInputValidation
+		}
+		maxPathLen = *certMetaData.MaxPathLen
+	}
+
+	now := time.Now()
+
+	return &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               *subject,
+		NotBefore:             now,
+		NotAfter:              now.Add(ttl),
+		DNSNames:              certMetaData.DNSNames,
+		IPAddresses:           ipAddresses,
+		URIs:                  uris,
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  certMetaData.IsCA,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+	}, nil
+}
+
+// issueCert issues a certificate for the subject and public key using the issuer, provided
+// that it complies with the issuance policies of the secret's namespace and of all its
+// ancestor namespaces. The certificate's path length (and, for intermediate issuers, its
+// validity) is capped by the issuer's. It returns the PEM-encoded certificate, followed by
+// the issuer's chain.
+func issueCert(dataStore vds.DataStoreAdapter, cfg *config.Config, issuer *certIssuer, secretId string, subject *pkix.Name, certMetaData *X509CertificateSecretMetaData, pubKey crypto.PublicKey) ([]byte, error) {
+	serialNumber, err := getCertSerialNumber()
+	if err != nil {
+		return []byte{}, err
+	}
+
+	template, err := getCertTemplate(serialNumber, subject, certMetaData, pubKey)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	// intermediate CAs don't issue certificates outliving them; the root CA's certificate
+	// is managed outside of the server
+	if issuer.id != "" && template.NotAfter.After(issuer.cert.NotAfter) {
+		template.NotAfter = issuer.cert.NotAfter
+	}
+
+	// a CA issued by a CA with a path length constraint has a tighter constraint
+	if template.IsCA && (issuer.cert.MaxPathLen > 0 || issuer.cert.MaxPathLenZero) {
+		switch {
+		case issuer.cert.MaxPathLenZero:
+			return []byte{}, util.ErrUnauthorized
+		case template.MaxPathLen < 0:
+			template.MaxPathLen = issuer.cert.MaxPathLen - 1
+			template.MaxPathLenZero = template.MaxPathLen == 0
+		case template.MaxPathLen >= issuer.cert.MaxPathLen:
+			return []byte{}, util.ErrUnauthorized
+		}
+	}
+
+	// point relying parties at the issuer's CRL and at the OCSP responder
+	if baseUrl := cfg.PKIConfig.BaseUrl; baseUrl != "" {
+		baseUrl = strings.TrimSuffix(baseUrl, "/")
+		template.CRLDistributionPoints = []string{baseUrl + crlPath}
+		if issuer.id != "" {
+			template.CRLDistributionPoints = []string{baseUrl + path.Join(crlPath, issuer.id)}
+		}
+		template.OCSPServer = []string{baseUrl + ocspPath}
+	}
+
+	policies := getCertIssuancePolicies(dataStore, secretId)
+	if template.IsCA && !certIssuanceAllowsCA(policies) {
+		return []byte{}, util.ErrUnauthorized
+	}
+	for _, policy := range policies {
+		if err := checkCertIssuancePolicy(policy, template); err != nil {
+			return []byte{}, err
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, issuer.cert, pubKey, issuer.privKey)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return appendCertChain(certDER, issuer.chainPEM), nil
+}
+
+// getCertKeyUsage returns the requested key usages or, if none are requested, the key
+// usages suitable for the certificate: digital signature (and key encipherment for RSA
+// keys) for end-entity certificates, and certificate and CRL signing for CA certificates.
+func getCertKeyUsage(certMetaData *X509CertificateSecretMetaData, pubKey crypto.PublicKey) (x509.KeyUsage, error) {
+	if len(certMetaData.KeyUsages) == 0 {
+		keyUsage := x509.KeyUsageDigitalSignature
+		if _, ok := pubKey.(*rsa.PublicKey); ok && !certMetaData.IsCA {
+			keyUsage |= x509.KeyUsageKeyEncipherment
+		}
+		if certMetaData.IsCA {
+			keyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		}
+
+		return keyUsage, nil
+	}
+
+	var keyUsage x509.KeyUsage
+	for _, keyUsageName := range certMetaData.KeyUsages {
+		ku, ok := model.X509KeyUsages[keyUsageName]
+		if !ok {
+			return 0, util.ErrInputValidation
+		}
+		keyUsage |= ku
+	}
+
+	// only CA certificates may sign certificates
+	if (keyUsage&x509.KeyUsageCertSign != 0) != certMetaData.IsCA {
+		return 0, util.ErrInputValidation
+	}
+
+	return keyUsage, nil
+}
+
+// getCertExtKeyUsage returns the requested extended key usages or, if none are requested,
+// server and client authentication for end-entity certificates (and none for CA certificates).
+func getCertExtKeyUsage(certMetaData *X509CertificateSecretMetaData) ([]x509.ExtKeyUsage, error) {
+	if len(certMetaData.ExtKeyUsages) == 0 {
+		if certMetaData.IsCA {
+			return nil, nil
+		}
+
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, nil
+	}
+
+	extKeyUsage := make([]x509.ExtKeyUsage, 0, len(certMetaData.ExtKeyUsages))
+	for _, extKeyUsageName := range certMetaData.ExtKeyUsages {
+		eku, ok := model.X509ExtKeyUsages[extKeyUsageName]
+		if !ok {
+			return nil, util.ErrInputValidation
+		}
+		extKeyUsage = append(extKeyUsage, eku)
+	}
+
+	return extKeyUsage, nil
+}
+
+// getCertIssuancePolicies returns the issuance policies of the namespace of the
+// given certificate secret and of all its ancestor namespaces.
+func getCertIssuancePolicies(dataStore vds.DataStoreAdapter, secretId string) []*model.CertIssuancePolicy {
+	policies := []*model.CertIssuancePolicy{}
+
+	for namespacePath := path.Dir(vds.SecretIdToPath(secretId)); ; namespacePath = path.Dir(namespacePath) {
+		if dataStoreEntry, err := dataStore.ReadEntry(namespacePath); err == nil && vds.IsNamespaceEntry(dataStoreEntry) {
+			if namespaceEntry, err := vds.DataStoreEntryToNamespaceEntry(dataStoreEntry); err == nil && namespaceEntry.CertIssuancePolicy != nil {
+				policies = append(policies, namespaceEntry.CertIssuancePolicy)
+			}
+		}
+
+		if namespacePath == "/" {
+			break
+		}
+	}
+
+	return policies
+}
+
+// certIssuanceAllowsCA returns whether CA certificates may be issued under the given
+// policies. Issuing CA certificates must be explicitly allowed, so it takes at least one
+// policy, and all of them must allow it.
+func certIssuanceAllowsCA(policies []*model.CertIssuancePolicy) bool {
+	if len(policies) == 0 {
+		return false
+	}
+
+	for _, policy := range policies {
+		if !policy.AllowCA {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkCertIssuancePolicy verifies that the certificate template complies with the policy.
+func checkCertIssuancePolicy(policy *model.CertIssuancePolicy, template *x509.Certificate) error {
+	// the common name of end-entity certificates is a host name (or an IP address) as far
+	// as legacy clients are concerned, whereas that of CA certificates is a display name
+	commonName := template.Subject.CommonName
+	if template.IsCA {
+		commonName = ""
+	}
+
+	if err := checkCertNamesIssuancePolicy(policy, commonName, template.DNSNames, template.IPAddresses, template.URIs); err != nil {
+		return err
+	}
+
+	if policy.MaxTTL != "" {
+		maxTTL, err := time.ParseDuration(policy.MaxTTL)
+		if err != nil {
+			return util.ErrInternal
+		}
+		if template.NotAfter.Sub(template.NotBefore) > maxTTL {
+			return util.ErrUnauthorized
+		}
+	}
+
+	if len(policy.AllowedKeyUsages) > 0 {
+		for keyUsageName, keyUsage := range model.X509KeyUsages {
+			if template.KeyUsage&keyUsage != 0 && !stringsContain(policy.AllowedKeyUsages, keyUsageName) {
+				return util.ErrUnauthorized
+			}
+		}
+	}
+
+	if len(policy.AllowedExtKeyUsages) > 0 {
+		for extKeyUsageName, extKeyUsage := range model.X509ExtKeyUsages {
+			if certHasExtKeyUsage(template, extKeyUsage) && !stringsContain(policy.AllowedExtKeyUsages, extKeyUsageName) {
+				return util.ErrUnauthorized
+			}
+		}
+	}
+
+	if template.IsCA {
+		if !policy.AllowCA {
+			return util.ErrUnauthorized
+		}
+
+		// an unlimited path length exceeds any cap
+		if policy.MaxPathLen != nil && (template.MaxPathLen < 0 || template.MaxPathLen > *policy.MaxPathLen) {
+			return util.ErrUnauthorized
+		}
+	}
+
+	return nil
+}
+
+// checkCertNamesIssuancePolicy verifies that the policy allows the common name (unless
+// empty) and the SANs.
+func checkCertNamesIssuancePolicy(policy *model.CertIssuancePolicy, commonName string, dnsNames []string, ipAddresses []net.IP, uris []*url.URL) error {
+	if commonName != "" {
+		if ip := net.ParseIP(commonName); ip != nil {
+			if !certIPAllowed(policy, ip) {
+				return util.ErrUnauthorized
+			}
+		} else if !certDomainAllowed(policy, commonName) {
+			return util.ErrUnauthorized
+		}
+	}
+
+	for _, dnsName := range dnsNames {
+		if !certDomainAllowed(policy, dnsName) {
+			return util.ErrUnauthorized
+		}
+	}
+
+	for _, ip := range ipAddresses {
+		if !certIPAllowed(policy, ip) {
+			return util.ErrUnauthorized
+		}
+	}
+
+	for _, uri := range uris {
+		if !certURIAllowed(policy, uri.String()) {
+			return util.ErrUnauthorized
+		}
+	}
+
+	return nil
+}
+
+func certDomainAllowed(policy *model.CertIssuancePolicy, dnsName string) bool {
+	dnsName = strings.ToLower(dnsName)
+
+	for _, domain := range policy.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if domain == "*" || dnsName == domain {
+			return true
+		}
+		if policy.AllowSubdomains && strings.HasSuffix(dnsName, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func certIPAllowed(policy *model.CertIssuancePolicy, ip net.IP) bool {
+	for _, ipRange := range policy.AllowedIPRanges {
+		if _, ipNet, err := net.ParseCIDR(ipRange); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func certURIAllowed(policy *model.CertIssuancePolicy, uri string) bool {
+	for _, uriPrefix := range policy.AllowedURIPrefixes {
+		if strings.HasPrefix(uri, uriPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func certHasExtKeyUsage(cert *x509.Certificate, extKeyUsage x509.ExtKeyUsage) bool {
+	for _, eku := range cert.ExtKeyUsage {
+		if eku == extKeyUsage {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,283 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestCreateX509CertificateSecretWithSANs(t *testing.T) {
+	privKeyId, err := createPrivKey()
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer deletePrivKey(privKeyId)
+
+	certMetaData := &X509CertificateSecretMetaData{
+		CommonName:   "test.example.com",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		DNSNames:     []string{"test.example.com", "*.test.example.com"},
+		IPAddresses:  []string{"10.0.0.1", "::1"},
+		URIs:         []string{"spiffe://example.com/test"},
+		TTL:          "48h",
+		ExtKeyUsages: []string{"serverAuth"},
+	}
+
+	cert, err := createAndParseX509Certificate("san-cert-id0", certMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	if len(cert.DNSNames) != 2 || cert.DNSNames[1] != "*.test.example.com" {
+		t.Fatalf("Unexpected DNS SANs: %v", cert.DNSNames)
+	}
+
+	if len(cert.IPAddresses) != 2 || !cert.IPAddresses[0].Equal([]byte{10, 0, 0, 1}) {
+		t.Fatalf("Unexpected IP SANs: %v", cert.IPAddresses)
+	}
+
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != "spiffe://example.com/test" {
+		t.Fatalf("Unexpected URI SANs: %v", cert.URIs)
+	}
+
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity != 48*time.Hour {
+		t.Fatalf("Unexpected certificate validity: %v", validity)
+	}
+
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment {
+		t.Fatalf("Unexpected default key usage for a RSA key: %v", cert.KeyUsage)
+	}
+
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Fatalf("Unexpected extended key usage: %v", cert.ExtKeyUsage)
+	}
+
+	if cert.IsCA {
+		t.Fatalf("End-entity certificate is a CA certificate")
+	}
+
+	if err := cert.VerifyHostname("www.test.example.com"); err != nil {
+		t.Fatalf("Certificate is not valid for a host matching its SANs: %v", err)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), "san-cert-id0"); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+}
+
+func TestCreateCAX509CertificateSecret(t *testing.T) {
+	privKeyId, err := createEd25519PrivKey("ca-priv-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer deletePrivKey(privKeyId)
+
+	maxPathLen := 0
+	certMetaData := &X509CertificateSecretMetaData{
+		CommonName:   "Test Examples Issuing CA",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		IsCA:         true,
+		MaxPathLen:   &maxPathLen,
+	}
+
+	// issuing CA certificates must be explicitly allowed
+	if _, err := createAndParseX509Certificate("ca-cert-id0", certMetaData); err != util.ErrUnauthorized {
+		t.Fatalf("Unexpected error when creating a CA certificate without an issuance policy: %v", err)
+	}
+
+	namespaceEntryId, err := createCertIssuancePolicyNamespace("ca-cert-ns", &model.CertIssuancePolicy{AllowCA: true})
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer sm.dataStore.DeleteEntry(namespaceEntryId)
+
+	cert, err := createAndParseX509Certificate("ca-cert-ns/ca-cert-id0", certMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	if !cert.IsCA || cert.MaxPathLen != 0 || !cert.MaxPathLenZero {
+		t.Fatalf("Unexpected basic constraints: CA %v, path length %v", cert.IsCA, cert.MaxPathLen)
+	}
+
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign|x509.KeyUsageCRLSign {
+		t.Fatalf("Unexpected default key usage for a CA certificate: %v", cert.KeyUsage)
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), "ca-cert-ns/ca-cert-id0"); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+}
+
+func TestCreateX509CertificateSecretWithBadMetaData(t *testing.T) {
+	privKeyId, err := createEd25519PrivKey("bad-cert-priv-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer deletePrivKey(privKeyId)
+
+	maxPathLen := 1
+	for _, certMetaData := range []*X509CertificateSecretMetaData{
+		{IPAddresses: []string{"10.0.0.256"}},
+		{URIs: []string{"no-scheme"}},
+		{TTL: "-1h"},
+		{KeyUsages: []string{"digitalSignature", "certSign"}},
+		{KeyUsages: []string{"decipherOnly"}},
+		{ExtKeyUsages: []string{"anyPurpose"}},
+		{MaxPathLen: &maxPathLen},
+	} {
+		certMetaData.CommonName = "test.example.com"
+		certMetaData.Organization = "Test Examples"
+		certMetaData.PrivateKeyId = privKeyId
+
+		if _, err := createAndParseX509Certificate("bad-cert-id0", certMetaData); err == nil {
+			t.Fatalf("Created certificate with bad meta-data %v", certMetaData)
+		}
+	}
+}
+
+func TestX509CertificateIssuancePolicies(t *testing.T) {
+	privKeyId, err := createEd25519PrivKey("policy-priv-key-id0")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer deletePrivKey(privKeyId)
+
+	// a looser policy in a sub-namespace doesn't lift the caps of its parent
+	policies := map[string]*model.CertIssuancePolicy{
+		"issuance-ns": {
+			AllowedDomains:  []string{"example.com"},
+			AllowSubdomains: true,
+			AllowedIPRanges: []string{"10.0.0.0/8"},
+			MaxTTL:          "720h",
+		},
+		"issuance-ns/sub": {
+			AllowedDomains:     []string{"*"},
+			AllowedIPRanges:    []string{"0.0.0.0/0"},
+			AllowedURIPrefixes: []string{"spiffe://example.com/"},
+			AllowCA:            true,
+		},
+	}
+	for _, namespace := range []string{"issuance-ns", "issuance-ns/sub"} {
+		namespaceEntryId, err := createCertIssuancePolicyNamespace(namespace, policies[namespace])
+		if err != nil {
+			t.Fatalf("Failed to create namespace %v: %v", namespace, err)
+		}
+		defer sm.dataStore.DeleteEntry(namespaceEntryId)
+	}
+
+	allowed := &X509CertificateSecretMetaData{
+		DNSNames:    []string{"example.com", "api.example.com"},
+		IPAddresses: []string{"10.1.2.3"},
+		TTL:         "720h",
+	}
+
+	violating := []*X509CertificateSecretMetaData{
+		{DNSNames: []string{"example.org"}},
+		{DNSNames: []string{"notexample.com"}},
+		{IPAddresses: []string{"192.168.1.1"}},
+		{URIs: []string{"spiffe://example.org/test"}},
+		{TTL: "721h"},
+		{IsCA: true},
+		{CommonName: "test.example.org"},
+		{CommonName: "192.168.1.1"},
+	}
+
+	for _, certMetaData := range append(violating, allowed) {
+		if certMetaData.CommonName == "" {
+			certMetaData.CommonName = "test.example.com"
+		}
+		certMetaData.Organization = "Test Examples"
+		certMetaData.PrivateKeyId = privKeyId
+
+		_, err := createAndParseX509Certificate("issuance-ns/sub/cert-id0", certMetaData)
+		if certMetaData == allowed {
+			if err != nil {
+				t.Fatalf("Failed to create certificate complying with the issuance policies: %v", err)
+			}
+		} else if err == nil {
+			t.Fatalf("Created certificate violating the issuance policies: %v", certMetaData)
+		}
+	}
+
+	if err := sm.DeleteSecret(context.GetTestRequestContext(), "issuance-ns/sub/cert-id0"); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+}
+
+func TestValidateCertIssuancePolicy(t *testing.T) {
+	maxPathLen := 1
+	for _, policy := range []*model.CertIssuancePolicy{
+		{AllowedIPRanges: []string{"10.0.0.1"}},
+		{MaxTTL: "a year"},
+		{AllowedKeyUsages: []string{"signEverything"}},
+		{AllowedExtKeyUsages: []string{"anyPurpose"}},
+		{MaxPathLen: &maxPathLen},
+	} {
+		if err := model.ValidateCertIssuancePolicy(policy); err == nil {
+			t.Fatalf("Bad issuance policy %v passed validation", policy)
+		}
+	}
+}
+
+// createCertIssuancePolicyNamespace creates a namespace with the given certificate
+// issuance policy, returning the id of its data store entry.
+func createCertIssuancePolicyNamespace(namespace string, policy *model.CertIssuancePolicy) (string, error) {
+	namespaceEntry := &model.NamespaceEntry{
+		Path:               vds.SecretIdToPath(namespace),
+		CertIssuancePolicy: policy,
+	}
+	dataStoreEntry, err := vds.NamespaceEntryToDataStoreEntry(namespaceEntry)
+	if err != nil {
+		return "", err
+	}
+	if err := sm.dataStore.CreateEntry(dataStoreEntry); err != nil {
+		return "", err
+	}
+
+	return dataStoreEntry.Id, nil
+}
+
+func createAndParseX509Certificate(id string, certMetaData *X509CertificateSecretMetaData) (*x509.Certificate, error) {
+	secretMetaDataBytes, err := json.Marshal(certMetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	se := &model.SecretEntry{
+		Id:             id,
+		Type:           X509CertificateSecretTypeName,
+		MetaData:       string(secretMetaDataBytes),
+		SecretData:     []byte{},
+		Owner:          "user0",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+
+	if _, err := sm.CreateSecret(context.GetTestRequestContext(), se); err != nil {
+		return nil, err
+	}
+
+	se2, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(se2.SecretData)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode returned certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
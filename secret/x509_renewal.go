@@ -0,0 +1,205 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+// Interval at which certificates are checked for renewal, unless configured otherwise.
+const DefaultRenewalInterval = 10 * time.Minute
+
+// Fraction of a certificate's lifetime after which it's renewed, unless specified otherwise.
+const DefaultRenewAfter = 2.0 / 3
+
+// startRenewer starts a goroutine that periodically renews the certificates which are due
+// for renewal.
+func (secretManager *SecretManager) startRenewer(interval time.Duration) {
+	secretManager.renewerStop = make(chan struct{})
+	secretManager.renewerDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				secretManager.renewCertificates(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}(secretManager.renewerStop, secretManager.renewerDone)
+}
+
+func (secretManager *SecretManager) stopRenewer() {
+	if secretManager.renewerStop == nil {
+		return
+	}
+
+	close(secretManager.renewerStop)
+	<-secretManager.renewerDone
+
+	secretManager.renewerStop = nil
+	secretManager.renewerDone = nil
+}
+
+// renewCertificates renews the certificate secrets whose renewal policy calls for renewal
+// at time now. It returns the number of certificates renewed.
+func (secretManager *SecretManager) renewCertificates(now time.Time) int {
+	autoRenewEntries := make([]*model.SecretEntry, 0)
+	err := secretManager.walkSecretEntries(vds.SecretsRootPath, true, func(secretEntry *model.SecretEntry) error {
+		if secretEntry.Type != X509CertificateSecretTypeName || model.SecretExpired(secretEntry, now) {
+			return nil
+		}
+
+		var certMetaData X509CertificateSecretMetaData
+		if err := json.Unmarshal([]byte(secretEntry.MetaData), &certMetaData); err == nil && certMetaData.AutoRenew {
+			autoRenewEntries = append(autoRenewEntries, secretEntry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to look for certificates to renew: %v\n", err)
+	}
+
+	renewed := 0
+	for _, secretEntry := range autoRenewEntries {
+		ok, err := secretManager.renewCertificate(secretEntry.Id, now)
+		if err != nil {
+			log.Printf("failed to renew certificate %v: %v\n", secretEntry.Id, err)
+			continue
+		}
+
+		if ok {
+			renewed++
+		}
+	}
+
+	return renewed
+}
+
+// renewCertificate issues a new certificate for the given secret, with the same subject,
+// if its renewal time has passed at time now. The previous certificate is kept as a prior
+// version of the secret, so that it can be used until its consumers reload the secret.
+// If the renewal policy calls for re-keying, the subject's private key secret is rotated
+// first, on behalf of the user who allowed it, and rolled back if the certificate can't
+// be renewed. It returns whether the certificate was renewed.
+func (secretManager *SecretManager) renewCertificate(secretId string, now time.Time) (bool, error) {
+	// the lock is shared with scheduled rotations of the secret
+	lockName := vds.SecretIdToRotationPath(secretId)
+	locked, err := vds.TryLock(secretManager.dataStore, lockName, secretManager.nodeId, rotationLockTTL)
+	if err != nil || !locked {
+		// another server is renewing the certificate
+		return false, err
+	}
+	defer func() {
+		if err := vds.Unlock(secretManager.dataStore, lockName, secretManager.nodeId); err != nil {
+			log.Printf("failed to release renewal lock of certificate %v: %v\n", secretId, err)
+		}
+	}()
+
+	// re-read the secret, as it might have been renewed since it was found to be due
+	secretEntry, err := secretManager.getSecretEntry(vds.SecretIdToPath(secretId))
+	if err != nil {
+		return false, err
+	}
+
+	var certMetaData X509CertificateSecretMetaData
+	if err := json.Unmarshal([]byte(secretEntry.MetaData), &certMetaData); err != nil {
+		return false, util.ErrInternal
+	}
+
+	certPEM, err := decryptSecret(secretManager.keyStore, secretEntry)
+	if err != nil {
+		return false, err
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return false, err
+	}
+
+	renewAfter := certMetaData.RenewAfter
+	if renewAfter == 0 {
+		renewAfter = DefaultRenewAfter
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	renewTime := cert.NotBefore.Add(time.Duration(float64(lifetime) * renewAfter))
+	if now.Before(renewTime) {
+		return false, nil
+	}
+
+	ctx := context.GetSystemRequestContext()
+	var privKeyEntry *model.SecretEntry
+	if certMetaData.Rekey {
+		if certMetaData.RekeyGrantedBy == "" {
+			return false, util.ErrUnauthorized
+		}
+
+		grantorCtx := gocontext.WithValue(gocontext.Background(), context.RequestContextKeyUsername, certMetaData.RekeyGrantedBy)
+		privKeyEntry, err = secretManager.rotateSecret(grantorCtx, certMetaData.PrivateKeyId)
+		if err != nil {
+			return false, err
+		}
+		secretManager.recordOnDemandRotation(privKeyEntry, now)
+	}
+
+	if _, err := secretManager.RotateSecret(ctx, secretId); err != nil {
+		// the certificate keeps the previous key, which is thus restored
+		if privKeyEntry != nil {
+			if _, rollbackErr := secretManager.RollbackSecret(ctx, privKeyEntry.Id, privKeyEntry.Version-1); rollbackErr != nil {
+				log.Printf("failed to roll back private key %v of certificate %v: %v\n", privKeyEntry.Id, secretId, rollbackErr)
+			}
+		}
+
+		return false, err
+	}
+
+	msg := fmt.Sprintf("renewed certificate %v (version %v), replacing the certificate expiring at %v", secretId, secretEntry.Version+1, cert.NotAfter)
+	if certMetaData.Rekey {
+		msg = fmt.Sprintf("%v, re-keying %v", msg, certMetaData.PrivateKeyId)
+	}
+
+	// the certificate is already renewed; if the event can't be recorded, consumers still
+	// get the new certificate on their next read
+	if err := secretManager.recordSecretEvent(model.SecretEventCertificateRenewed, secretId, secretEntry.Version+1, msg); err != nil {
+		log.Printf("failed to record renewal of certificate %v: %v\n", secretId, err)
+	}
+
+	return true, nil
+}
+
+// validateRenewalPolicy validates the renewal policy of a certificate secret, returning
+// the invalid fields. Re-keying requires the subject's key to be a private key secret,
+// rather than a CSR.
+func validateRenewalPolicy(certMetaData *X509CertificateSecretMetaData) []util.FieldError {
+	fieldErrors := make([]util.FieldError, 0)
+
+	if certMetaData.RenewAfter < 0 || certMetaData.RenewAfter >= 1 {
+		fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.renewAfter", Message: "should be at least 0 and less than 1"})
+	}
+
+	if certMetaData.Rekey && !certMetaData.AutoRenew {
+		fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.rekey", Message: "requires metaData.autoRenew"})
+	}
+	if certMetaData.Rekey && certMetaData.PrivateKeyId == "" {
+		fieldErrors = append(fieldErrors, util.FieldError{Field: "metaData.rekey", Message: "requires metaData.privateKeyId"})
+	}
+
+	return fieldErrors
+}
@@ -0,0 +1,358 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package secret
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/vmware/virtual-security-module/context"
+	"github.com/vmware/virtual-security-module/model"
+	"github.com/vmware/virtual-security-module/util"
+	"github.com/vmware/virtual-security-module/vds"
+)
+
+func TestRenewCertificate(t *testing.T) {
+	privKeyId, err := createECDSAPrivKey("renewal-priv-key-id0", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), privKeyId)
+
+	start := time.Now()
+	id, err := createAutoRenewCert("renewal-cert-id0", &X509CertificateSecretMetaData{
+		CommonName:   "renewal0.example.com",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		TTL:          "300h",
+		AutoRenew:    true,
+		RenewAfter:   0.5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// the certificate isn't renewed before half of its lifetime has passed
+	if renewed := sm.renewCertificates(start.Add(100 * time.Hour)); renewed != 0 {
+		t.Fatalf("Unexpected number of renewed certificates: %v", renewed)
+	}
+
+	if renewed := sm.renewCertificates(start.Add(200 * time.Hour)); renewed != 1 {
+		t.Fatalf("Unexpected number of renewed certificates: %v", renewed)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+	if se.Version != model.FirstSecretVersion+1 {
+		t.Fatalf("Unexpected version of renewed certificate: %v", se.Version)
+	}
+
+	// the previous certificate is kept, and the renewed one has the same subject and key
+	priorSE, err := sm.GetSecretVersion(context.GetTestRequestContext(), id, model.FirstSecretVersion)
+	if err != nil {
+		t.Fatalf("Failed to get previous certificate: %v", err)
+	}
+
+	cert, err := parseCertificate(se.SecretData)
+	if err != nil {
+		t.Fatalf("Failed to parse renewed certificate: %v", err)
+	}
+	priorCert, err := parseCertificate(priorSE.SecretData)
+	if err != nil {
+		t.Fatalf("Failed to parse previous certificate: %v", err)
+	}
+
+	if cert.SerialNumber.Cmp(priorCert.SerialNumber) == 0 || cert.Subject.CommonName != priorCert.Subject.CommonName ||
+		!publicKeysEqual(cert.PublicKey, priorCert.PublicKey) {
+		t.Fatalf("Unexpected renewed certificate")
+	}
+
+	// the renewal is reported to the certificate's consumers
+	eventEntries, err := sm.ListSecretEvents(context.GetTestRequestContext(), start)
+	if err != nil {
+		t.Fatalf("Failed to list secret events: %v", err)
+	}
+
+	found := false
+	for _, eventEntry := range eventEntries {
+		if eventEntry.SecretId == id && eventEntry.Type == model.SecretEventCertificateRenewed && eventEntry.Version == se.Version {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Certificate renewal event not found in %v", eventEntries)
+	}
+
+	// events are pruned once their retention period has passed
+	sm.pruneSecretEvents(time.Now().Add(sm.eventRetention + time.Minute))
+
+	eventEntries, err = sm.ListSecretEvents(context.GetTestRequestContext(), start)
+	if err != nil {
+		t.Fatalf("Failed to list secret events: %v", err)
+	}
+	if len(eventEntries) != 0 {
+		t.Fatalf("Unexpected secret events after pruning: %v", eventEntries)
+	}
+}
+
+func TestRenewCertificateWithRekey(t *testing.T) {
+	privKeyId, err := createECDSAPrivKey("renewal-priv-key-id1", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), privKeyId)
+
+	id, err := createAutoRenewCert("renewal-cert-id1", &X509CertificateSecretMetaData{
+		CommonName:   "renewal1.example.com",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		AutoRenew:    true,
+		Rekey:        true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// by default, the certificate is renewed after two thirds of its lifetime
+	if renewed, err := sm.renewCertificate(id, time.Now().Add(200*24*time.Hour)); renewed || err != nil {
+		t.Fatalf("Certificate renewed before its renewal time: %v", err)
+	}
+
+	if renewed, err := sm.renewCertificate(id, time.Now().Add(250*24*time.Hour)); !renewed || err != nil {
+		t.Fatalf("Failed to renew certificate: %v", err)
+	}
+
+	privKeySE, err := sm.GetSecret(context.GetTestRequestContext(), privKeyId)
+	if err != nil {
+		t.Fatalf("Failed to get private key: %v", err)
+	}
+	if privKeySE.Version != model.FirstSecretVersion+1 {
+		t.Fatalf("Private key was not rotated")
+	}
+
+	privKey, err := parsePrivateKeyPEM(privKeySE.SecretData)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	se, err := sm.GetSecret(context.GetTestRequestContext(), id)
+	if err != nil {
+		t.Fatalf("Failed to get secret for id %v: %v", id, err)
+	}
+
+	cert, err := parseCertificate(se.SecretData)
+	if err != nil {
+		t.Fatalf("Failed to parse renewed certificate: %v", err)
+	}
+	if !publicKeysEqual(cert.PublicKey, privKey.Public()) {
+		t.Fatalf("Renewed certificate doesn't match the rotated private key")
+	}
+
+	var certMetaData X509CertificateSecretMetaData
+	if err := json.Unmarshal([]byte(se.MetaData), &certMetaData); err != nil {
+		t.Fatalf("Failed to parse certificate metadata: %v", err)
+	}
+	if certMetaData.RekeyGrantedBy != "root" {
+		t.Fatalf("Unexpected user allowing re-keying: %v", certMetaData.RekeyGrantedBy)
+	}
+}
+
+func TestCreateCertificateWithUnauthorizedRekey(t *testing.T) {
+	privKeyId, err := createECDSAPrivKey("renewal-priv-key-id4", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), privKeyId)
+
+	secretType, err := SecretTypeRegistrar.Get(X509CertificateSecretTypeName)
+	if err != nil {
+		t.Fatalf("Failed to get secret type: %v", err)
+	}
+	certST := secretType.(*X509CertificateSecretType)
+
+	// the caller may read the private key, but not update it
+	authzManager := certST.authzManager
+	defer func() { certST.authzManager = authzManager }()
+	certST.authzManager = &denyOperationAuthzManager{label: model.OpUpdate, namespacePath: path.Dir(vds.SecretIdToPath(privKeyId))}
+
+	certMetaData := &X509CertificateSecretMetaData{
+		CommonName:   "renewal4.example.com",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		AutoRenew:    true,
+		Rekey:        true,
+	}
+	if _, err := createAutoRenewCert("renewal-cert-id4", certMetaData); !errors.Is(err, util.ErrUnauthorized) {
+		t.Fatalf("Unexpected error when creating certificate re-keying a private key the caller can't update: %v", err)
+	}
+
+	certMetaData.Rekey = false
+	id, err := createAutoRenewCert("renewal-cert-id4", certMetaData)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	sm.DeleteSecret(context.GetTestRequestContext(), id)
+}
+
+func TestRenewCertificateWithRekeyRollback(t *testing.T) {
+	privKeyId, err := createECDSAPrivKey("renewal-priv-key-id5", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), privKeyId)
+
+	privKeySE, err := sm.GetSecret(context.GetTestRequestContext(), privKeyId)
+	if err != nil {
+		t.Fatalf("Failed to get private key: %v", err)
+	}
+
+	id, err := createAutoRenewCert("renewal-ns5/cert-id5", &X509CertificateSecretMetaData{
+		CommonName:   "renewal5.example.com",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		AutoRenew:    true,
+		Rekey:        true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	// the private key is rotated, but the certificate can't be renewed
+	restrictedManager := *sm
+	restrictedManager.authzManager = &denyNamespaceAuthzManager{namespacePath: path.Dir(vds.SecretIdToPath(id))}
+	if renewed, err := restrictedManager.renewCertificate(id, time.Now().Add(250*24*time.Hour)); renewed || !errors.Is(err, util.ErrUnauthorized) {
+		t.Fatalf("Unexpected result of renewing certificate: %v, %v", renewed, err)
+	}
+
+	rolledBackSE, err := sm.GetSecret(context.GetTestRequestContext(), privKeyId)
+	if err != nil {
+		t.Fatalf("Failed to get private key: %v", err)
+	}
+	if rolledBackSE.Version != privKeySE.Version+2 || !bytes.Equal(rolledBackSE.SecretData, privKeySE.SecretData) {
+		t.Fatalf("Private key was not rolled back after failing to renew the certificate")
+	}
+}
+
+func TestCreateCertificateWithBadRenewalPolicy(t *testing.T) {
+	_, csrPEM, err := generateTestCSR("renewal2.example.com", []string{"renewal2.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate CSR: %v", err)
+	}
+
+	for _, certMetaData := range []*X509CertificateSecretMetaData{
+		&X509CertificateSecretMetaData{CSR: csrPEM, AutoRenew: true, RenewAfter: 1.5},
+		&X509CertificateSecretMetaData{CSR: csrPEM, AutoRenew: true, RenewAfter: -0.5},
+		// the key of a CSR can't be rotated by the server
+		&X509CertificateSecretMetaData{CSR: csrPEM, AutoRenew: true, Rekey: true},
+	} {
+		if _, err := createAutoRenewCert("renewal-cert-id2", certMetaData); !errors.Is(err, util.ErrInputValidation) {
+			t.Fatalf("Unexpected error when creating certificate with renewal policy %v: %v", certMetaData, err)
+		}
+	}
+}
+
+func TestAPIListSecretEvents(t *testing.T) {
+	privKeyId, err := createECDSAPrivKey("renewal-priv-key-id3", "P-256")
+	if err != nil {
+		t.Fatalf("Failed to create private key: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), privKeyId)
+
+	start := time.Now()
+	id, err := createAutoRenewCert("renewal-cert-id3", &X509CertificateSecretMetaData{
+		CommonName:   "renewal3.example.com",
+		Organization: "Test Examples",
+		PrivateKeyId: privKeyId,
+		AutoRenew:    true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	defer sm.DeleteSecret(context.GetTestRequestContext(), id)
+
+	if renewed, err := sm.renewCertificate(id, time.Now().Add(300*24*time.Hour)); !renewed || err != nil {
+		t.Fatalf("Failed to renew certificate: %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("since", start.Add(-time.Second).Format(time.RFC3339))
+
+	resp, err := http.Get(fmt.Sprintf("%v/secret-events?%v", ts.URL, query.Encode()))
+	if err != nil {
+		t.Fatalf("Failed to list secret events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response status is different than 200 StatusOK: %v", resp.Status)
+	}
+
+	var eventEntries []*model.SecretEventEntry
+	if err = json.NewDecoder(resp.Body).Decode(&eventEntries); err != nil {
+		t.Fatalf("Failed to parse list secret events response: %v", err)
+	}
+
+	found := false
+	for _, eventEntry := range eventEntries {
+		if eventEntry.SecretId == id && eventEntry.Type == model.SecretEventCertificateRenewed {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Certificate renewal event not found in %v", eventEntries)
+	}
+
+	resp2, err := http.Get(fmt.Sprintf("%v/secret-events?since=yesterday", ts.URL))
+	if err != nil {
+		t.Fatalf("Failed to list secret events: %v", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Response status is different than 400 StatusBadRequest: %v", resp2.Status)
+	}
+}
+
+// denyOperationAuthzManager denies a single operation on a single namespace.
+type denyOperationAuthzManager struct {
+	label         string
+	namespacePath string
+}
+
+func (d *denyOperationAuthzManager) Allowed(ctx gocontext.Context, op model.Operation, namespacePath string) error {
+	if op.Label == d.label && namespacePath == d.namespacePath {
+		return util.ErrUnauthorized
+	}
+
+	return nil
+}
+
+func createAutoRenewCert(id string, certMetaData *X509CertificateSecretMetaData) (string, error) {
+	metaDataBytes, err := json.Marshal(certMetaData)
+	if err != nil {
+		return "", err
+	}
+
+	se := &model.SecretEntry{
+		Id:         id,
+		Type:       X509CertificateSecretTypeName,
+		MetaData:   string(metaDataBytes),
+		SecretData: []byte{},
+		Owner:      "user0",
+	}
+
+	return sm.CreateSecret(context.GetTestRequestContext(), se)
+}
@@ -190,9 +190,6 @@ func (server *Server) initHttps(configuration *config.Config) error {
 	if configuration.HttpsConfig.CaCert == "" {
 		return fmt.Errorf("%v cannot be empty", PropertyNameCaCert)
 	}
-	if configuration.HttpsConfig.CaKey == "" {
-		return fmt.Errorf("%v cannot be empty", PropertyNameCaKey)
-	}
 	if configuration.HttpsConfig.ServerCert == "" {
 		return fmt.Errorf("%v cannot be empty", PropertyNameServerCert)
 	}
@@ -0,0 +1,157 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package util
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression made of five fields: minute, hour, day of
+// month, month and day of week. Times are matched in UTC.
+type CronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// Whether the day of month and day of week fields start with *; when both are
+	// restricted, a day matches if either of them does
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 being Sunday
+}
+
+// Times further than this are not searched when looking for the next match of a schedule
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCronSchedule parses a cron expression whose fields are either *, a number, a
+// range (1-5), a step (*/15 or 1-30/2) or a comma separated list of these.
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, ErrInputValidation
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseCronField(field, cronFields[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sunday may be written either 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = (bits[4] | 1) &^ (1 << 7)
+	}
+
+	return &CronSchedule{
+		minutes:       bits[0],
+		hours:         bits[1],
+		daysOfMonth:   bits[2],
+		months:        bits[3],
+		daysOfWeek:    bits[4],
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, ErrInputValidation
+			}
+		}
+
+		var first, last int
+		switch {
+		case rangePart == "*":
+			first, last = bounds.min, bounds.max
+		case strings.Contains(rangePart, "-"):
+			ends := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			first, err1 = strconv.Atoi(ends[0])
+			last, err2 = strconv.Atoi(ends[1])
+			if err1 != nil || err2 != nil {
+				return 0, ErrInputValidation
+			}
+		default:
+			var err error
+			if first, err = strconv.Atoi(rangePart); err != nil {
+				return 0, ErrInputValidation
+			}
+			last = first
+			// A step from a single value runs to the end of the range
+			if step > 1 {
+				last = bounds.max
+			}
+		}
+
+		if first < bounds.min || last > bounds.max || first > last {
+			return 0, ErrInputValidation
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+// Next returns the first time matched by the schedule strictly after the given time,
+// or the zero time if there is none within the next few years.
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if schedule.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if schedule.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if schedule.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (schedule *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := schedule.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := schedule.daysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if !schedule.anyDayOfMonth && !schedule.anyDayOfWeek {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
@@ -0,0 +1,57 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	valid := []string{"* * * * *", "0 3 * * 0", "*/15 0-6 1,15 * 1-5", "30 2 * 1-12/3 7"}
+	for _, expr := range valid {
+		if _, err := ParseCronSchedule(expr); err != nil {
+			t.Fatalf("Failed to parse cron expression %q: %v", expr, err)
+		}
+	}
+
+	invalid := []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "1-x * * * *"}
+	for _, expr := range invalid {
+		if _, err := ParseCronSchedule(expr); err != ErrInputValidation {
+			t.Fatalf("Parsing invalid cron expression %q should have failed: %v", expr, err)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2017, time.March, 15, 10, 20, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2017, time.March, 15, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2017, time.March, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2017, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 0", time.Date(2017, time.March, 19, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2017, time.March, 19, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2017, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		// Either the day of month or the day of week matches
+		{"0 0 20 * 5", time.Date(2017, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseCronSchedule(test.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse cron expression %q: %v", test.expr, err)
+		}
+		if next := schedule.Next(now); !next.Equal(test.next) {
+			t.Fatalf("Unexpected next time for %q: %v, expected %v", test.expr, next, test.next)
+		}
+	}
+}
@@ -3,7 +3,9 @@
 package util
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"errors"
 )
@@ -15,9 +17,50 @@ var (
 	ErrUnauthorized    = errors.New("unauthorized error")
 	ErrInternal        = errors.New("internal error")
 	ErrBadConfig       = errors.New("bad configuration")
+	ErrAlreadyUsed     = errors.New("already used")
 )
 
+// FieldError describes why a field of a request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is an input validation error detailing the invalid fields of a
+// request. It is an ErrInputValidation, as far as errors.Is is concerned.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// ValidationErrorResponse is the body of the response to a request which fails
+// validation due to a ValidationError.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+func NewValidationError(fields []FieldError) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%v: %v", field.Field, field.Message))
+	}
+
+	return fmt.Sprintf("%v: %v", ErrInputValidation.Error(), strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInputValidation
+}
+
 func HttpStatus(err error) int {
+	if _, ok := err.(*ValidationError); ok {
+		return http.StatusBadRequest
+	}
+
 	switch err {
 	case ErrNotFound:
 		return http.StatusNotFound
@@ -27,6 +70,8 @@ func HttpStatus(err error) int {
 		return http.StatusBadRequest
 	case ErrUnauthorized:
 		return http.StatusForbidden
+	case ErrAlreadyUsed:
+		return http.StatusGone
 	default:
 		return http.StatusInternalServerError
 	}
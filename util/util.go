@@ -26,6 +26,14 @@ func NewUUID() string {
 
 func WriteErrorResponse(w http.ResponseWriter, e error) error {
 	w.WriteHeader(HttpStatus(e))
+
+	if validationErr, ok := e.(*ValidationError); ok {
+		return json.NewEncoder(w).Encode(&ValidationErrorResponse{
+			Error:  ErrInputValidation.Error(),
+			Fields: validationErr.Fields,
+		})
+	}
+
 	return json.NewEncoder(w).Encode(e.Error())
 }
 
@@ -153,6 +153,24 @@ func (ds *CassandraDS) ReadEntry(entryId string) (*DataStoreEntry, error) {
 	}, nil
 }
 
+func (ds *CassandraDS) UpdateEntry(entry *DataStoreEntry) error {
+	query := ds.buildUpdateStatement(entry)
+	defer query.Release()
+
+	// a conditional update only returns the [applied] column
+	query.SerialConsistency(gocql.LocalSerial)
+	applied, err := query.ScanCAS()
+	if err != nil {
+		return translateCassandraError(err)
+	}
+
+	if !applied {
+		return util.ErrNotFound
+	}
+
+	return nil
+}
+
 func (ds *CassandraDS) DeleteEntry(entryId string) error {
 	query := ds.buildDeleteEntryQuery(entryId)
 	defer query.Release()
@@ -165,6 +183,43 @@ func (ds *CassandraDS) DeleteEntry(entryId string) error {
 	return nil
 }
 
+func (ds *CassandraDS) CompareAndUpdateEntry(entry *DataStoreEntry, expectedMetaData string) error {
+	query := ds.buildCompareAndUpdateStatement(entry, expectedMetaData)
+	defer query.Release()
+
+	// unless applied, the current meta-data (if any) is returned along with [applied]
+	query.SerialConsistency(gocql.LocalSerial)
+	var metaData string
+	applied, err := query.ScanCAS(&metaData)
+	if err != nil {
+		return translateCassandraError(err)
+	}
+
+	if !applied {
+		return util.ErrNotFound
+	}
+
+	return nil
+}
+
+func (ds *CassandraDS) CompareAndDeleteEntry(entryId string, expectedMetaData string) error {
+	query := ds.buildCompareAndDeleteStatement(entryId, expectedMetaData)
+	defer query.Release()
+
+	query.SerialConsistency(gocql.LocalSerial)
+	var metaData string
+	applied, err := query.ScanCAS(&metaData)
+	if err != nil {
+		return translateCassandraError(err)
+	}
+
+	if !applied {
+		return util.ErrNotFound
+	}
+
+	return nil
+}
+
 func (ds *CassandraDS) SearchChildEntries(parentEntryId string) ([]*DataStoreEntry, error) {
 	query := ds.buildFindChildrenQuery(parentEntryId)
 	defer query.Release()
@@ -209,6 +264,16 @@ func (ds *CassandraDS) buildInsertStatement(entry *DataStoreEntry) *gocql.Query
 	return ds.dbSession.Query(queryStr, entry.Id, parentId, entry.Data, entry.MetaData)
 }
 
+func (ds *CassandraDS) buildUpdateStatement(entry *DataStoreEntry) *gocql.Query {
+	queryStr := fmt.Sprintf("UPDATE %s SET data = ?, meta_data = ? WHERE id = ? IF EXISTS", cassandraVSMTable)
+	return ds.dbSession.Query(queryStr, entry.Data, entry.MetaData, entry.Id)
+}
+
+func (ds *CassandraDS) buildCompareAndUpdateStatement(entry *DataStoreEntry, expectedMetaData string) *gocql.Query {
+	queryStr := fmt.Sprintf("UPDATE %s SET data = ?, meta_data = ? WHERE id = ? IF meta_data = ?", cassandraVSMTable)
+	return ds.dbSession.Query(queryStr, entry.Data, entry.MetaData, entry.Id, expectedMetaData)
+}
+
 func (ds *CassandraDS) buildFindEntryQuery(entryId string) *gocql.Query {
 	queryStr := fmt.Sprintf("SELECT data, meta_data FROM %s WHERE id = ?", cassandraVSMTable)
 	return ds.dbSession.Query(queryStr, entryId)
@@ -219,6 +284,11 @@ func (ds *CassandraDS) buildDeleteEntryQuery(entryId string) *gocql.Query {
 	return ds.dbSession.Query(queryStr, entryId)
 }
 
+func (ds *CassandraDS) buildCompareAndDeleteStatement(entryId string, expectedMetaData string) *gocql.Query {
+	queryStr := fmt.Sprintf("DELETE FROM %s WHERE id = ? IF meta_data = ?", cassandraVSMTable)
+	return ds.dbSession.Query(queryStr, entryId, expectedMetaData)
+}
+
 func (ds *CassandraDS) buildFindChildrenQuery(parentEntryId string) *gocql.Query {
 	queryStr := fmt.Sprintf("SELECT id, data, meta_data FROM %s WHERE parent_id = ? ALLOW FILTERING", cassandraVSMTable)
 	return ds.dbSession.Query(queryStr, parentEntryId)
@@ -24,9 +24,16 @@ type DataStoreAdapter interface {
 
 	CreateEntry(entry *DataStoreEntry) error
 	ReadEntry(entryId string) (*DataStoreEntry, error)
+	UpdateEntry(entry *DataStoreEntry) error
 	DeleteEntry(entryId string) error
 	SearchChildEntries(parentEntryId string) ([]*DataStoreEntry, error)
 
+	// Conditional counterparts of UpdateEntry and DeleteEntry, which only apply while the
+	// entry's meta-data is still expectedMetaData and fail with util.ErrNotFound otherwise.
+	// They let servers sharing a data store act on an entry they read without racing.
+	CompareAndUpdateEntry(entry *DataStoreEntry, expectedMetaData string) error
+	CompareAndDeleteEntry(entryId string, expectedMetaData string) error
+
 	Type() string
 	Location() string
 }
@@ -67,3 +67,48 @@ func IsAuthorizationPolicyEntry(dsEntry *DataStoreEntry) bool {
 
 	return metaData.EntryType == authorizationPolicyEntryType
 }
+
+func IsCertificateEntry(dsEntry *DataStoreEntry) bool {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dsEntry.MetaData), &metaData); err != nil {
+		return false
+	}
+
+	return metaData.EntryType == certificateEntryType
+}
+
+func IsSecretEventEntry(dsEntry *DataStoreEntry) bool {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dsEntry.MetaData), &metaData); err != nil {
+		return false
+	}
+
+	return metaData.EntryType == secretEventEntryType
+}
+
+func IsWrappedEntry(dsEntry *DataStoreEntry) bool {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dsEntry.MetaData), &metaData); err != nil {
+		return false
+	}
+
+	return metaData.EntryType == wrappedEntryType
+}
+
+func IsLeaseEntry(dsEntry *DataStoreEntry) bool {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dsEntry.MetaData), &metaData); err != nil {
+		return false
+	}
+
+	return metaData.EntryType == leaseEntryType
+}
+
+func IsRotationEntry(dsEntry *DataStoreEntry) bool {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dsEntry.MetaData), &metaData); err != nil {
+		return false
+	}
+
+	return metaData.EntryType == rotationEntryType
+}
@@ -89,6 +89,29 @@ func (ds *InMemoryDS) ReadEntry(entryId string) (*DataStoreEntry, error) {
 	return dsEntry, nil
 }
 
+func (ds *InMemoryDS) UpdateEntry(entry *DataStoreEntry) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	_, ok := ds.entryMap[entry.Id]
+	if !ok {
+		return util.ErrNotFound
+	}
+
+	buf := make([]byte, len(entry.Data))
+	copy(buf, entry.Data)
+
+	dsEntry := &DataStoreEntry{
+		Id:       entry.Id,
+		Data:     buf,
+		MetaData: entry.MetaData,
+	}
+
+	ds.entryMap[entry.Id] = dsEntry
+
+	return nil
+}
+
 func (ds *InMemoryDS) DeleteEntry(entryId string) error {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
@@ -103,6 +126,43 @@ func (ds *InMemoryDS) DeleteEntry(entryId string) error {
 	return nil
 }
 
+func (ds *InMemoryDS) CompareAndUpdateEntry(entry *DataStoreEntry, expectedMetaData string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	currentEntry, ok := ds.entryMap[entry.Id]
+	if !ok || currentEntry.MetaData != expectedMetaData {
+		return util.ErrNotFound
+	}
+
+	buf := make([]byte, len(entry.Data))
+	copy(buf, entry.Data)
+
+	dsEntry := &DataStoreEntry{
+		Id:       entry.Id,
+		Data:     buf,
+		MetaData: entry.MetaData,
+	}
+
+	ds.entryMap[entry.Id] = dsEntry
+
+	return nil
+}
+
+func (ds *InMemoryDS) CompareAndDeleteEntry(entryId string, expectedMetaData string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	currentEntry, ok := ds.entryMap[entryId]
+	if !ok || currentEntry.MetaData != expectedMetaData {
+		return util.ErrNotFound
+	}
+
+	delete(ds.entryMap, entryId)
+
+	return nil
+}
+
 func (ds *InMemoryDS) SearchChildEntries(parentEntryId string) ([]*DataStoreEntry, error) {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/vmware/virtual-security-module/config"
+	"github.com/vmware/virtual-security-module/util"
 	"reflect"
 )
 
@@ -119,3 +120,90 @@ func TestInMemoryDSRecreateAfterDelete(t *testing.T) {
 		t.Fatalf("Failed to delete entry: %v", err)
 	}
 }
+
+func TestInMemoryDSUpdate(t *testing.T) {
+	id := "id1"
+
+	dsEntry := &DataStoreEntry{
+		Id:       id,
+		Data:     []byte("data1"),
+		MetaData: "metadata1",
+	}
+
+	if err := inMemoryDS.CreateEntry(dsEntry); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+
+	dsEntry.Data = []byte("data2")
+	dsEntry.MetaData = "metadata2"
+	if err := inMemoryDS.UpdateEntry(dsEntry); err != nil {
+		t.Fatalf("Failed to update entry: %v", err)
+	}
+
+	dsEntry2, err := inMemoryDS.ReadEntry(id)
+	if err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+
+	if !reflect.DeepEqual(dsEntry, dsEntry2) {
+		t.Fatalf("Retreived value is different than expected")
+	}
+
+	if err := inMemoryDS.DeleteEntry(id); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+}
+
+func TestInMemoryUpdateNonExistent(t *testing.T) {
+	dsEntry := &DataStoreEntry{
+		Id:       "non-existent-id",
+		Data:     []byte("data1"),
+		MetaData: "metadata1",
+	}
+
+	if err := inMemoryDS.UpdateEntry(dsEntry); err == nil {
+		t.Fatalf("Succeeded to update entry with non-exietent id")
+	}
+}
+
+func TestInMemoryDSCompareAndUpdate(t *testing.T) {
+	id := "id1"
+
+	dsEntry := &DataStoreEntry{
+		Id:       id,
+		Data:     []byte("data1"),
+		MetaData: "metadata1",
+	}
+
+	if err := inMemoryDS.CreateEntry(dsEntry); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+
+	dsEntry2 := &DataStoreEntry{
+		Id:       id,
+		Data:     []byte("data2"),
+		MetaData: "metadata2",
+	}
+	if err := inMemoryDS.CompareAndUpdateEntry(dsEntry2, "metadata0"); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when updating entry with different meta-data: %v", err)
+	}
+	if err := inMemoryDS.CompareAndUpdateEntry(dsEntry2, "metadata1"); err != nil {
+		t.Fatalf("Failed to update entry: %v", err)
+	}
+
+	dsEntry3, err := inMemoryDS.ReadEntry(id)
+	if err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+
+	if !reflect.DeepEqual(dsEntry2, dsEntry3) {
+		t.Fatalf("Retreived value is different than expected")
+	}
+
+	if err := inMemoryDS.CompareAndDeleteEntry(id, "metadata1"); err != util.ErrNotFound {
+		t.Fatalf("Unexpected error when deleting entry with different meta-data: %v", err)
+	}
+	if err := inMemoryDS.CompareAndDeleteEntry(id, "metadata2"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+}
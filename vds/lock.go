@@ -0,0 +1,99 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package vds
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/vmware/virtual-security-module/util"
+)
+
+// LockMetaData describes a lock held by one of the servers sharing a data store.
+type LockMetaData struct {
+	Holder         string
+	ExpirationTime time.Time
+}
+
+// TryLock attempts to acquire the named lock on behalf of holder, without waiting.
+// Locks are held until released or until ttl elapses, after which another holder may
+// take them over; ttl should be longer than any work done while holding the lock.
+func TryLock(dataStore DataStoreAdapter, name string, holder string, ttl time.Duration) (bool, error) {
+	lockPath := LockNameToPath(name)
+
+	metaDataBytes, err := json.Marshal(&MetaData{
+		EntryType: lockEntryType,
+		Lock: &LockMetaData{
+			Holder:         holder,
+			ExpirationTime: time.Now().Add(ttl),
+		},
+	})
+	if err != nil {
+		return false, util.ErrInternal
+	}
+	lockEntry := &DataStoreEntry{
+		Id:       lockPath,
+		Data:     []byte{},
+		MetaData: string(metaDataBytes),
+	}
+
+	err = dataStore.CreateEntry(lockEntry)
+	if err == util.ErrAlreadyExists {
+		current, currentMetaData, readErr := readLock(dataStore, lockPath)
+		if readErr == util.ErrNotFound {
+			return false, nil
+		}
+		if readErr != nil {
+			return false, readErr
+		}
+		if time.Now().Before(current.ExpirationTime) {
+			return false, nil
+		}
+
+		// The lock has expired: take it over, unless another holder did so since it
+		// was read
+		err = dataStore.CompareAndUpdateEntry(lockEntry, currentMetaData)
+		if err == util.ErrNotFound {
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Unlock releases the named lock, provided it is still held by holder.
+func Unlock(dataStore DataStoreAdapter, name string, holder string) error {
+	lockPath := LockNameToPath(name)
+
+	current, currentMetaData, err := readLock(dataStore, lockPath)
+	if err != nil {
+		return err
+	}
+	if current.Holder != holder {
+		return util.ErrNotFound
+	}
+
+	// the lock may have expired and been taken over since it was read
+	return dataStore.CompareAndDeleteEntry(lockPath, currentMetaData)
+}
+
+// readLock returns the lock at lockPath, along with the meta-data of its data store entry.
+func readLock(dataStore DataStoreAdapter, lockPath string) (*LockMetaData, string, error) {
+	dataStoreEntry, err := dataStore.ReadEntry(lockPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
+		return nil, "", util.ErrInternal
+	}
+	if metaData.EntryType != lockEntryType || metaData.Lock == nil {
+		return nil, "", util.ErrInternal
+	}
+
+	return metaData.Lock, dataStoreEntry.MetaData, nil
+}
@@ -0,0 +1,141 @@
+// Copyright © 2017 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+package vds
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockExclusive(t *testing.T) {
+	locked, err := TryLock(inMemoryDS, "exclusive", "node-1", time.Minute)
+	if err != nil || !locked {
+		t.Fatalf("Failed to acquire lock: %v %v", locked, err)
+	}
+
+	if locked, err := TryLock(inMemoryDS, "exclusive", "node-2", time.Minute); err != nil || locked {
+		t.Fatalf("Lock held by another holder should not have been acquired: %v %v", locked, err)
+	}
+
+	if err := Unlock(inMemoryDS, "exclusive", "node-2"); err == nil {
+		t.Fatalf("Lock held by another holder should not have been released")
+	}
+
+	if err := Unlock(inMemoryDS, "exclusive", "node-1"); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+
+	if locked, err := TryLock(inMemoryDS, "exclusive", "node-2", time.Minute); err != nil || !locked {
+		t.Fatalf("Failed to acquire released lock: %v %v", locked, err)
+	}
+	Unlock(inMemoryDS, "exclusive", "node-2")
+}
+
+func TestLockExpired(t *testing.T) {
+	if locked, err := TryLock(inMemoryDS, "expired", "node-1", -time.Second); err != nil || !locked {
+		t.Fatalf("Failed to acquire lock: %v %v", locked, err)
+	}
+
+	if locked, err := TryLock(inMemoryDS, "expired", "node-2", time.Minute); err != nil || !locked {
+		t.Fatalf("Failed to take over expired lock: %v %v", locked, err)
+	}
+
+	if err := Unlock(inMemoryDS, "expired", "node-1"); err == nil {
+		t.Fatalf("Lock taken over by another holder should not have been released")
+	}
+	Unlock(inMemoryDS, "expired", "node-2")
+}
+
+// racingDS lets the first contenders reading an entry all read it before any proceeds,
+// so that they act on the same state.
+type racingDS struct {
+	*InMemoryDS
+	contenders int
+	reads      int
+	mutex      sync.Mutex
+	allRead    chan struct{}
+}
+
+func newRacingDS(contenders int) *racingDS {
+	return &racingDS{
+		InMemoryDS: NewInMemoryDS(),
+		contenders: contenders,
+		allRead:    make(chan struct{}),
+	}
+}
+
+func (ds *racingDS) ReadEntry(entryId string) (*DataStoreEntry, error) {
+	entry, err := ds.InMemoryDS.ReadEntry(entryId)
+
+	ds.mutex.Lock()
+	ds.reads++
+	if ds.reads == ds.contenders {
+		close(ds.allRead)
+	}
+	ds.mutex.Unlock()
+
+	<-ds.allRead
+
+	return entry, err
+}
+
+func TestLockExpiredRace(t *testing.T) {
+	holders := []string{"node-1", "node-2"}
+	ds := newRacingDS(len(holders))
+
+	if err := ds.CreateEntry(expiredLockEntry(t, "expired-race", "node-0")); err != nil {
+		t.Fatalf("Failed to create expired lock: %v", err)
+	}
+
+	// the contenders race to take over the expired lock; only one of them may win
+	results := make([]bool, len(holders))
+	var wg sync.WaitGroup
+	for i, holder := range holders {
+		wg.Add(1)
+		go func(i int, holder string) {
+			defer wg.Done()
+			locked, err := TryLock(ds, "expired-race", holder, time.Minute)
+			if err != nil {
+				t.Errorf("Failed to try to take over expired lock: %v", err)
+			}
+			results[i] = locked
+		}(i, holder)
+	}
+	wg.Wait()
+
+	if results[0] == results[1] {
+		t.Fatalf("Expected exactly one contender to take over the expired lock: %v", results)
+	}
+
+	winner, loser := holders[0], holders[1]
+	if results[1] {
+		winner, loser = loser, winner
+	}
+	if err := Unlock(ds, "expired-race", loser); err == nil {
+		t.Fatalf("Lock taken over by another holder should not have been released")
+	}
+	if err := Unlock(ds, "expired-race", winner); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+}
+
+func expiredLockEntry(t *testing.T, name string, holder string) *DataStoreEntry {
+	metaDataBytes, err := json.Marshal(&MetaData{
+		EntryType: lockEntryType,
+		Lock: &LockMetaData{
+			Holder:         holder,
+			ExpirationTime: time.Now().Add(-time.Second),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal lock: %v", err)
+	}
+
+	return &DataStoreEntry{
+		Id:       LockNameToPath(name),
+		Data:     []byte{},
+		MetaData: string(metaDataBytes),
+	}
+}
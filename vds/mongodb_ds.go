@@ -119,6 +119,19 @@ func (ds *MongoDBDS) ReadEntry(entryId string) (*DataStoreEntry, error) {
 	return dsEntry, nil
 }
 
+func (ds *MongoDBDS) UpdateEntry(entry *DataStoreEntry) error {
+	session, collection := ds.getSessionAndCollection()
+	defer session.Close()
+
+	doc := translateToMongoDocument(entry)
+	err := collection.Update(bson.M{"_id": entry.Id}, doc)
+	if err != nil {
+		return translateMongoError(err)
+	}
+
+	return nil
+}
+
 func (ds *MongoDBDS) DeleteEntry(entryId string) error {
 	session, collection := ds.getSessionAndCollection()
 	defer session.Close()
@@ -131,6 +144,31 @@ func (ds *MongoDBDS) DeleteEntry(entryId string) error {
 	return err
 }
 
+func (ds *MongoDBDS) CompareAndUpdateEntry(entry *DataStoreEntry, expectedMetaData string) error {
+	session, collection := ds.getSessionAndCollection()
+	defer session.Close()
+
+	doc := translateToMongoDocument(entry)
+	err := collection.Update(bson.M{"_id": entry.Id, "metaData": expectedMetaData}, doc)
+	if err != nil {
+		return translateMongoError(err)
+	}
+
+	return nil
+}
+
+func (ds *MongoDBDS) CompareAndDeleteEntry(entryId string, expectedMetaData string) error {
+	session, collection := ds.getSessionAndCollection()
+	defer session.Close()
+
+	err := collection.Remove(bson.M{"_id": entryId, "metaData": expectedMetaData})
+	if err != nil {
+		return translateMongoError(err)
+	}
+
+	return nil
+}
+
 func (ds *MongoDBDS) SearchChildEntries(parentEntryId string) ([]*DataStoreEntry, error) {
 	session, collection := ds.getSessionAndCollection()
 	defer session.Close()
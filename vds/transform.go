@@ -4,7 +4,9 @@ package vds
 
 import (
 	"encoding/json"
+	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,14 +16,40 @@ import (
 
 const (
 	PoliciesDirname = "policies"
-
-	secretsPathPrefix = "/secrets/"
-	usersPathPrefix   = "/users/"
+	SecretsRootPath = "/secrets"
+	// Certificates issued by the server are registered (by serial number) under this path
+	CertificatesRootPath = "/pki/certificates"
+	// Secret events are kept (by id) under this path
+	SecretEventsRootPath = "/secret-events"
+	// Wrapped values are kept (by the id of their wrapping token) under this namespace
+	WrappedRootPath = "/sys/wrapped"
+	// Leases of dynamic credentials are kept (by the last element of their id) under this path
+	LeasesRootPath = "/leases"
+	// Rotation schedules are kept (by escaped secret id) under this path
+	RotationsRootPath = "/rotations"
+	// Locks shared by the servers of a cluster are kept (by name) under this namespace
+	LocksRootPath = "/sys/locks"
+
+	secretsPathPrefix        = "/secrets/"
+	secretVersionsPathPrefix = "/secret-versions/"
+	usersPathPrefix          = "/users/"
+	certificatesPathPrefix   = "/pki/certificates/"
+	secretEventsPathPrefix   = "/secret-events/"
+	wrappedPathPrefix        = "/sys/wrapped/"
+	leasesPathPrefix         = "/leases/"
+	rotationsPathPrefix      = "/rotations/"
+	locksPathPrefix          = "/sys/locks/"
 
 	secretEntryType              = "secret"
 	userEntryType                = "user"
 	namespaceEntryType           = "namespace"
 	authorizationPolicyEntryType = "authzPolicy"
+	certificateEntryType         = "certificate"
+	secretEventEntryType         = "secretEvent"
+	wrappedEntryType             = "wrapped"
+	leaseEntryType               = "lease"
+	rotationEntryType            = "rotation"
+	lockEntryType                = "lock"
 )
 
 type RoleMetaData struct {
@@ -37,19 +65,59 @@ type MetaData struct {
 	EntryType         string
 	SecretType        string
 	SecretMetaData    string
+	SecretVersion     int
 	Owner             string
 	ExpirationTime    time.Time
 	Roles             []RoleMetaData
 	AllowedOperations []OperationMetaData
+	// only set for namespace entries
+	CertIssuancePolicy *model.CertIssuancePolicy `json:",omitempty"`
+	// only set for certificate entries
+	Certificate *model.CertificateEntry `json:",omitempty"`
+	// only set for secret event entries
+	SecretEvent *model.SecretEventEntry `json:",omitempty"`
+	// only set for wrapped entries
+	Wrapped *model.WrappedEntry `json:",omitempty"`
+	// only set for lease entries
+	Lease *model.LeaseEntry `json:",omitempty"`
+	// only set for secret entries whose secret has a rotation policy
+	RotationPolicy *model.RotationPolicy `json:",omitempty"`
+	// only set for rotation entries
+	Rotation *model.RotationEntry `json:",omitempty"`
+	// only set for lock entries
+	Lock *LockMetaData `json:",omitempty"`
+	// only set for secret and namespace entries
+	Tags map[string]string `json:",omitempty"`
 }
 
 func SecretEntryToDataStoreEntry(secretEntry *model.SecretEntry) (*DataStoreEntry, error) {
+	return secretEntryToDataStoreEntry(secretEntry, SecretIdToPath(secretEntry.Id))
+}
+
+func DataStoreEntryToSecretEntry(dataStoreEntry *DataStoreEntry) (*model.SecretEntry, error) {
+	return dataStoreEntryToSecretEntry(dataStoreEntry, SecretPathToId(dataStoreEntry.Id))
+}
+
+// SecretEntryToDataStoreVersionEntry is like SecretEntryToDataStoreEntry, but
+// the resulting entry is placed at the path of the secret's (prior) version.
+func SecretEntryToDataStoreVersionEntry(secretEntry *model.SecretEntry) (*DataStoreEntry, error) {
+	return secretEntryToDataStoreEntry(secretEntry, SecretVersionPath(secretEntry.Id, secretEntry.Version))
+}
+
+func DataStoreVersionEntryToSecretEntry(dataStoreEntry *DataStoreEntry) (*model.SecretEntry, error) {
+	return dataStoreEntryToSecretEntry(dataStoreEntry, SecretVersionPathToId(dataStoreEntry.Id))
+}
+
+func secretEntryToDataStoreEntry(secretEntry *model.SecretEntry, entryPath string) (*DataStoreEntry, error) {
 	metaData := &MetaData{
 		EntryType:      secretEntryType,
 		SecretType:     secretEntry.Type,
 		SecretMetaData: secretEntry.MetaData,
+		SecretVersion:  secretEntry.Version,
 		Owner:          secretEntry.Owner,
 		ExpirationTime: secretEntry.ExpirationTime,
+		RotationPolicy: secretEntry.RotationPolicy,
+		Tags:           secretEntry.Tags,
 	}
 
 	metaDataBytes, err := json.Marshal(metaData)
@@ -58,7 +126,7 @@ func SecretEntryToDataStoreEntry(secretEntry *model.SecretEntry) (*DataStoreEntr
 	}
 
 	dataStoreEntry := &DataStoreEntry{
-		Id:       SecretIdToPath(secretEntry.Id),
+		Id:       entryPath,
 		Data:     secretEntry.SecretData,
 		MetaData: string(metaDataBytes),
 	}
@@ -66,7 +134,7 @@ func SecretEntryToDataStoreEntry(secretEntry *model.SecretEntry) (*DataStoreEntr
 	return dataStoreEntry, nil
 }
 
-func DataStoreEntryToSecretEntry(dataStoreEntry *DataStoreEntry) (*model.SecretEntry, error) {
+func dataStoreEntryToSecretEntry(dataStoreEntry *DataStoreEntry, secretId string) (*model.SecretEntry, error) {
 	var metaData MetaData
 
 	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
@@ -77,13 +145,22 @@ func DataStoreEntryToSecretEntry(dataStoreEntry *DataStoreEntry) (*model.SecretE
 		return nil, util.ErrInternal
 	}
 
+	// secrets created before versioning was introduced are at their first version
+	version := metaData.SecretVersion
+	if version < model.FirstSecretVersion {
+		version = model.FirstSecretVersion
+	}
+
 	secretEntry := &model.SecretEntry{
-		Id:             SecretPathToId(dataStoreEntry.Id),
+		Id:             secretId,
 		Type:           metaData.SecretType,
 		MetaData:       metaData.SecretMetaData,
 		SecretData:     dataStoreEntry.Data,
 		Owner:          metaData.Owner,
 		ExpirationTime: metaData.ExpirationTime,
+		Version:        version,
+		RotationPolicy: metaData.RotationPolicy,
+		Tags:           metaData.Tags,
 	}
 
 	return secretEntry, nil
@@ -132,9 +209,11 @@ func DataStoreEntryToUserEntry(dataStoreEntry *DataStoreEntry) (*model.UserEntry
 
 func NamespaceEntryToDataStoreEntry(namespaceEntry *model.NamespaceEntry) (*DataStoreEntry, error) {
 	metaData := &MetaData{
-		EntryType: namespaceEntryType,
-		Owner:     namespaceEntry.Owner,
-		Roles:     roleLabelsToMetaData(namespaceEntry.RoleLabels),
+		EntryType:          namespaceEntryType,
+		Owner:              namespaceEntry.Owner,
+		Roles:              roleLabelsToMetaData(namespaceEntry.RoleLabels),
+		CertIssuancePolicy: namespaceEntry.CertIssuancePolicy,
+		Tags:               namespaceEntry.Tags,
 	}
 	metaDataBytes, err := json.Marshal(metaData)
 	if err != nil {
@@ -161,9 +240,11 @@ func DataStoreEntryToNamespaceEntry(dataStoreEntry *DataStoreEntry) (*model.Name
 	}
 
 	namespaceEntry := &model.NamespaceEntry{
-		Path:       dataStoreEntry.Id,
-		Owner:      metaData.Owner,
-		RoleLabels: roleLabelsFromMetaData(metaData.Roles),
+		Path:               dataStoreEntry.Id,
+		Owner:              metaData.Owner,
+		RoleLabels:         roleLabelsFromMetaData(metaData.Roles),
+		CertIssuancePolicy: metaData.CertIssuancePolicy,
+		Tags:               metaData.Tags,
 	}
 
 	return namespaceEntry, nil
@@ -210,6 +291,169 @@ func DataStoreEntryToAuthorizationPolicyEntry(dataStoreEntry *DataStoreEntry) (*
 	return policyEntry, nil
 }
 
+func CertificateEntryToDataStoreEntry(certificateEntry *model.CertificateEntry) (*DataStoreEntry, error) {
+	metaData := &MetaData{
+		EntryType:   certificateEntryType,
+		Certificate: certificateEntry,
+	}
+	metaDataBytes, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	dataStoreEntry := &DataStoreEntry{
+		Id:       CertificateSerialToPath(certificateEntry.SerialNumber),
+		Data:     []byte{},
+		MetaData: string(metaDataBytes),
+	}
+
+	return dataStoreEntry, nil
+}
+
+func DataStoreEntryToCertificateEntry(dataStoreEntry *DataStoreEntry) (*model.CertificateEntry, error) {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if metaData.EntryType != certificateEntryType || metaData.Certificate == nil {
+		return nil, util.ErrInternal
+	}
+
+	return metaData.Certificate, nil
+}
+
+func SecretEventEntryToDataStoreEntry(eventEntry *model.SecretEventEntry) (*DataStoreEntry, error) {
+	metaData := &MetaData{
+		EntryType:   secretEventEntryType,
+		SecretEvent: eventEntry,
+	}
+	metaDataBytes, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	dataStoreEntry := &DataStoreEntry{
+		Id:       SecretEventIdToPath(eventEntry.Id),
+		Data:     []byte{},
+		MetaData: string(metaDataBytes),
+	}
+
+	return dataStoreEntry, nil
+}
+
+func DataStoreEntryToSecretEventEntry(dataStoreEntry *DataStoreEntry) (*model.SecretEventEntry, error) {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if metaData.EntryType != secretEventEntryType || metaData.SecretEvent == nil {
+		return nil, util.ErrInternal
+	}
+
+	return metaData.SecretEvent, nil
+}
+
+func WrappedEntryToDataStoreEntry(wrappedEntry *model.WrappedEntry) (*DataStoreEntry, error) {
+	metaData := &MetaData{
+		EntryType: wrappedEntryType,
+		Wrapped:   wrappedEntry,
+	}
+	metaDataBytes, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	dataStoreEntry := &DataStoreEntry{
+		Id:       WrappedIdToPath(wrappedEntry.Id),
+		Data:     wrappedEntry.Data,
+		MetaData: string(metaDataBytes),
+	}
+
+	return dataStoreEntry, nil
+}
+
+func DataStoreEntryToWrappedEntry(dataStoreEntry *DataStoreEntry) (*model.WrappedEntry, error) {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if metaData.EntryType != wrappedEntryType || metaData.Wrapped == nil {
+		return nil, util.ErrInternal
+	}
+
+	wrappedEntry := metaData.Wrapped
+	wrappedEntry.Data = dataStoreEntry.Data
+
+	return wrappedEntry, nil
+}
+
+func LeaseEntryToDataStoreEntry(leaseEntry *model.LeaseEntry) (*DataStoreEntry, error) {
+	metaData := &MetaData{
+		EntryType: leaseEntryType,
+		Lease:     leaseEntry,
+	}
+	metaDataBytes, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	dataStoreEntry := &DataStoreEntry{
+		Id:       LeaseIdToPath(leaseEntry.Id),
+		Data:     []byte{},
+		MetaData: string(metaDataBytes),
+	}
+
+	return dataStoreEntry, nil
+}
+
+func DataStoreEntryToLeaseEntry(dataStoreEntry *DataStoreEntry) (*model.LeaseEntry, error) {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if metaData.EntryType != leaseEntryType || metaData.Lease == nil {
+		return nil, util.ErrInternal
+	}
+
+	return metaData.Lease, nil
+}
+
+func RotationEntryToDataStoreEntry(rotationEntry *model.RotationEntry) (*DataStoreEntry, error) {
+	metaData := &MetaData{
+		EntryType: rotationEntryType,
+		Rotation:  rotationEntry,
+	}
+	metaDataBytes, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, util.ErrInternal
+	}
+
+	dataStoreEntry := &DataStoreEntry{
+		Id:       SecretIdToRotationPath(rotationEntry.SecretId),
+		Data:     []byte{},
+		MetaData: string(metaDataBytes),
+	}
+
+	return dataStoreEntry, nil
+}
+
+func DataStoreEntryToRotationEntry(dataStoreEntry *DataStoreEntry) (*model.RotationEntry, error) {
+	var metaData MetaData
+	if err := json.Unmarshal([]byte(dataStoreEntry.MetaData), &metaData); err != nil {
+		return nil, util.ErrInternal
+	}
+
+	if metaData.EntryType != rotationEntryType || metaData.Rotation == nil {
+		return nil, util.ErrInternal
+	}
+
+	return metaData.Rotation, nil
+}
+
 func DataStoreEntriesToPaths(dataStoreEntries []*DataStoreEntry) []string {
 	paths := make([]string, 0, len(dataStoreEntries))
 
@@ -228,6 +472,34 @@ func SecretPathToId(secretPath string) string {
 	return strings.TrimPrefix(secretPath, secretsPathPrefix)
 }
 
+// SecretVersionsPath returns the path under which prior versions of a secret are kept.
+func SecretVersionsPath(secretId string) string {
+	return secretVersionsPathPrefix + secretId
+}
+
+func SecretVersionPath(secretId string, version int) string {
+	return path.Join(SecretVersionsPath(secretId), strconv.Itoa(version))
+}
+
+func SecretVersionPathToId(secretVersionPath string) string {
+	return strings.TrimPrefix(path.Dir(secretVersionPath), secretVersionsPathPrefix)
+}
+
+func SecretVersionPathToVersion(secretVersionPath string) (int, error) {
+	return strconv.Atoi(path.Base(secretVersionPath))
+}
+
+// SecretKeyAlias returns the alias, in the virtual key store, of the key that
+// encrypts the given version of a secret. Each version is encrypted using its
+// own key; the first version's key is aliased by the secret's path.
+func SecretKeyAlias(secretId string, version int) string {
+	if version <= model.FirstSecretVersion {
+		return SecretIdToPath(secretId)
+	}
+
+	return SecretVersionPath(secretId, version)
+}
+
 func UsernameToPath(username string) string {
 	return usersPathPrefix + username
 }
@@ -236,6 +508,37 @@ func UserpathToName(userpath string) string {
 	return strings.TrimPrefix(userpath, usersPathPrefix)
 }
 
+// CertificateSerialToPath returns the path under which the certificate with the
+// given (hex-encoded) serial number is registered.
+func CertificateSerialToPath(serialNumber string) string {
+	return certificatesPathPrefix + serialNumber
+}
+
+func SecretEventIdToPath(eventId string) string {
+	return secretEventsPathPrefix + eventId
+}
+
+func WrappedIdToPath(wrappedId string) string {
+	return wrappedPathPrefix + wrappedId
+}
+
+// LeaseIdToPath returns the path of a lease. Leases are kept directly under the leases
+// path, by the unique last element of their id, so the id of the lease entry at the
+// returned path should be compared to leaseId.
+func LeaseIdToPath(leaseId string) string {
+	return leasesPathPrefix + path.Base(leaseId)
+}
+
+// SecretIdToRotationPath returns the path of a secret's rotation schedule. Secret ids are
+// escaped so that all the schedules are kept directly under the rotations path.
+func SecretIdToRotationPath(secretId string) string {
+	return rotationsPathPrefix + url.PathEscape(secretId)
+}
+
+func LockNameToPath(name string) string {
+	return locksPathPrefix + url.PathEscape(name)
+}
+
 func AuthorizationPolicyIdToPath(policyId string) string {
 	dir, file := path.Split(policyId)
 	return path.Join("/", dir, PoliciesDirname, file)